@@ -0,0 +1,118 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Mirrors server.ErrCodeAccountNotFound; the CLI only talks to the daemon
+// over HTTP and does not import the server package.
+const apiCodeAccountNotFound = "account_not_found"
+
+// apiError is a non-2xx response from the daemon.
+type apiError struct {
+	Status  int
+	Code    string
+	Message string
+	// Hint is appended to the message, e.g. a suggested account id.
+	Hint string
+}
+
+func (e *apiError) Error() string {
+	msg := fmt.Sprintf("http %d: %s", e.Status, e.Message)
+	if e.Hint != "" {
+		msg += " — " + e.Hint
+	}
+	if e.Code != "" {
+		msg += " [" + e.Code + "]"
+	}
+	return msg
+}
+
+// parseAPIError decodes an error body. Bodies that are not the daemon's
+// JSON error shape are kept verbatim as the message.
+func parseAPIError(status int, raw []byte) *apiError {
+	body := strings.TrimSpace(string(raw))
+	var payload struct {
+		Error string `json:"error"`
+		Code  string `json:"code"`
+	}
+	if err := json.Unmarshal(raw, &payload); err != nil || payload.Error == "" {
+		return &apiError{Status: status, Message: body}
+	}
+	return &apiError{Status: status, Code: payload.Code, Message: payload.Error}
+}
+
+// suggestAccount fills in a "did you mean" hint for account_not_found
+// errors. It is best effort: any failure listing accounts leaves the error
+// unchanged.
+func (c *apiClient) suggestAccount(apiErr *apiError) {
+	if apiErr.Code != apiCodeAccountNotFound {
+		return
+	}
+	missing, ok := strings.CutPrefix(apiErr.Message, "account ")
+	if !ok {
+		return
+	}
+	missing, ok = strings.CutSuffix(missing, " not found")
+	if !ok || missing == "" {
+		return
+	}
+	// The lookup must not replace the request id of the failed request.
+	requestID := c.lastRequestID
+	defer func() { c.lastRequestID = requestID }()
+	var out struct {
+		Accounts []struct {
+			ID string `json:"id"`
+		} `json:"accounts"`
+	}
+	if err := c.get("/v1/accounts", &out); err != nil {
+		return
+	}
+	ids := make([]string, 0, len(out.Accounts))
+	for _, acct := range out.Accounts {
+		ids = append(ids, acct.ID)
+	}
+	if best := closestID(missing, ids); best != "" {
+		apiErr.Hint = fmt.Sprintf("did you mean '%s'?", best)
+	}
+}
+
+// closestID returns the id that contains target, or failing that the one
+// within a small edit distance of it.
+func closestID(target string, ids []string) string {
+	lower := strings.ToLower(target)
+	best, bestDist := "", 4
+	for _, id := range ids {
+		candidate := strings.ToLower(id)
+		if strings.Contains(candidate, lower) || strings.Contains(lower, candidate) {
+			return id
+		}
+		if d := levenshtein(lower, candidate); d < bestDist {
+			best, bestDist = id, d
+		}
+	}
+	return best
+}
+
+func levenshtein(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
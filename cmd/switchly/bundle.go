@@ -0,0 +1,109 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+const bundlePassphraseEnv = "SWITCHLY_BUNDLE_PASSPHRASE"
+
+// readPassphrase prompts without echo; tests replace it.
+var readPassphrase = func(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", fmt.Errorf("no passphrase given: pass --passphrase or set %s", bundlePassphraseEnv)
+	}
+	fmt.Fprint(os.Stderr, prompt)
+	raw, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", err
+	}
+	return string(raw), nil
+}
+
+// bundlePassphrase resolves the passphrase from the flag, then the
+// environment, then an interactive prompt. confirm asks for it twice.
+func bundlePassphrase(flagValue string, confirm bool) (string, error) {
+	if flagValue != "" {
+		return flagValue, nil
+	}
+	if v := os.Getenv(bundlePassphraseEnv); v != "" {
+		return v, nil
+	}
+	passphrase, err := readPassphrase("Bundle passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if confirm {
+		again, err := readPassphrase("Repeat passphrase: ")
+		if err != nil {
+			return "", err
+		}
+		if again != passphrase {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+func runAccountExport(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("account export", flag.ContinueOnError)
+	outPath := fs.String("out", "", "file to write the encrypted bundle to")
+	passphrase := fs.String("passphrase", "", "bundle passphrase (default: $"+bundlePassphraseEnv+" or prompt)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*outPath) == "" {
+		return fmt.Errorf("--out is required")
+	}
+	secret, err := bundlePassphrase(*passphrase, true)
+	if err != nil {
+		return err
+	}
+
+	var envelope json.RawMessage
+	if err := c.post("/v1/accounts/export", map[string]string{"passphrase": secret}, &envelope); err != nil {
+		return err
+	}
+	if err := os.WriteFile(*outPath, append(envelope, '\n'), 0o600); err != nil {
+		return fmt.Errorf("write bundle: %w", err)
+	}
+	return printResult(map[string]string{"status": "ok", "file": *outPath})
+}
+
+func runAccountImportBundle(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("account import-bundle", flag.ContinueOnError)
+	file := fs.String("file", "", "bundle written by account export")
+	passphrase := fs.String("passphrase", "", "bundle passphrase (default: $"+bundlePassphraseEnv+" or prompt)")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*file) == "" {
+		return fmt.Errorf("--file is required")
+	}
+	data, err := os.ReadFile(*file)
+	if err != nil {
+		return err
+	}
+	if !json.Valid(data) {
+		return fmt.Errorf("parse %s: not a JSON bundle", *file)
+	}
+	secret, err := bundlePassphrase(*passphrase, false)
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{"passphrase": secret, "bundle": json.RawMessage(data)}
+	var out map[string]interface{}
+	if err := c.post("/v1/accounts/import/bundle", payload, &out); err != nil {
+		return err
+	}
+	return printResult(out)
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunAccountExportAndImportBundle(t *testing.T) {
+	envelope := map[string]any{"format": "switchly-bundle", "version": 1, "ciphertext": "c2VhbGVk"}
+	var imported map[string]json.RawMessage
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				var body map[string]json.RawMessage
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				switch r.URL.Path {
+				case "/v1/accounts/export":
+					if string(body["passphrase"]) != `"from-env-secret"` {
+						t.Fatalf("unexpected export passphrase: %s", body["passphrase"])
+					}
+					return jsonResponse(http.StatusOK, envelope), nil
+				case "/v1/accounts/import/bundle":
+					imported = body
+					return jsonResponse(http.StatusOK, map[string]any{"total": 1, "succeeded": 1}), nil
+				default:
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+			}),
+		},
+	}
+	t.Setenv(bundlePassphraseEnv, "from-env-secret")
+	path := filepath.Join(t.TempDir(), "accounts.bundle")
+
+	captureStdout(t, func() {
+		if err := runAccount(client, []string{"export", "--out", path}); err != nil {
+			t.Fatalf("export: %v", err)
+		}
+	})
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat bundle: %v", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 && os.PathSeparator == '/' {
+		t.Fatalf("expected a private bundle file, got %v", info.Mode().Perm())
+	}
+
+	out := captureStdout(t, func() {
+		if err := runAccount(client, []string{"import-bundle", "--file", path, "--passphrase", "flag-secret"}); err != nil {
+			t.Fatalf("import-bundle: %v", err)
+		}
+	})
+	if string(imported["passphrase"]) != `"flag-secret"` {
+		t.Fatalf("expected the flag passphrase to win, got %s", imported["passphrase"])
+	}
+	if !strings.Contains(string(imported["bundle"]), `"switchly-bundle"`) {
+		t.Fatalf("expected the bundle file to be forwarded, got %s", imported["bundle"])
+	}
+	if !strings.Contains(out, `"succeeded": 1`) {
+		t.Fatalf("unexpected import output: %s", out)
+	}
+}
+
+func TestBundlePassphrasePromptMismatch(t *testing.T) {
+	t.Setenv(bundlePassphraseEnv, "")
+	answers := []string{"first-secret", "other-secret"}
+	orig := readPassphrase
+	readPassphrase = func(string) (string, error) {
+		next := answers[0]
+		answers = answers[1:]
+		return next, nil
+	}
+	t.Cleanup(func() { readPassphrase = orig })
+
+	if _, err := bundlePassphrase("", true); err == nil || !strings.Contains(err.Error(), "do not match") {
+		t.Fatalf("expected a mismatch error, got %v", err)
+	}
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+
+	"switchly/internal/platform"
+)
+
+const defaultTimeout = 15 * time.Second
+
+type cliConfig struct {
+	BaseURL       string `json:"base_url" toml:"base_url"`
+	Timeout       string `json:"timeout" toml:"timeout"`
+	Output        string `json:"output" toml:"output"`
+	SocketPath    string `json:"socket_path" toml:"socket_path"`
+	TLSCACert     string `json:"tls_ca_cert" toml:"tls_ca_cert"`
+	TLSClientCert string `json:"tls_client_cert" toml:"tls_client_cert"`
+	TLSClientKey  string `json:"tls_client_key" toml:"tls_client_key"`
+	APIToken      string `json:"api_token" toml:"api_token"`
+}
+
+type effectiveConfig struct {
+	BaseURL       string `json:"base_url"`
+	Timeout       string `json:"timeout"`
+	Output        string `json:"output"`
+	SocketPath    string `json:"socket_path,omitempty"`
+	TLSCACert     string `json:"tls_ca_cert,omitempty"`
+	TLSClientCert string `json:"tls_client_cert,omitempty"`
+	TLSClientKey  string `json:"tls_client_key,omitempty"`
+	Profile       string `json:"profile,omitempty"`
+	ConfigFile    string `json:"config_file,omitempty"`
+
+	timeout  time.Duration
+	apiToken string
+}
+
+func configFilePath() (string, error) {
+	if path := strings.TrimSpace(os.Getenv("SWITCHLY_CONFIG")); path != "" {
+		return path, nil
+	}
+	dir, err := platform.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.toml"), nil
+}
+
+func loadConfigFile(path string) (cliConfig, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cliConfig{}, false, nil
+		}
+		return cliConfig{}, false, err
+	}
+
+	var out cliConfig
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		err = json.Unmarshal(data, &out)
+	} else {
+		err = toml.Unmarshal(data, &out)
+	}
+	if err != nil {
+		return cliConfig{}, false, fmt.Errorf("decode config %s: %w", path, err)
+	}
+	return out, true, nil
+}
+
+// resolveConfig merges settings with precedence flag > env > config file > default.
+func resolveConfig(file cliConfig, getenv func(string) string, flags globalFlags) (effectiveConfig, error) {
+	pick := func(flagValue, envKey, fileValue, fallback string) string {
+		for _, v := range []string{flagValue, getenv(envKey), fileValue} {
+			if v = strings.TrimSpace(v); v != "" {
+				return v
+			}
+		}
+		return fallback
+	}
+
+	out := effectiveConfig{
+		BaseURL:       strings.TrimRight(pick(flags.baseURL, "SWITCHLY_BASE_URL", file.BaseURL, defaultBaseURL), "/"),
+		Timeout:       pick(flags.timeout, "SWITCHLY_TIMEOUT", file.Timeout, defaultTimeout.String()),
+		Output:        pick(flags.output, "SWITCHLY_OUTPUT", file.Output, outputJSON),
+		SocketPath:    pick(flags.socket, "SWITCHLY_SOCKET_PATH", file.SocketPath, ""),
+		TLSCACert:     pick(flags.tlsCACert, "SWITCHLY_TLS_CA_CERT", file.TLSCACert, ""),
+		TLSClientCert: pick(flags.tlsClientCert, "SWITCHLY_TLS_CLIENT_CERT", file.TLSClientCert, ""),
+		TLSClientKey:  pick(flags.tlsClientKey, "SWITCHLY_TLS_CLIENT_KEY", file.TLSClientKey, ""),
+		apiToken:      pick(flags.apiToken, "SWITCHLY_API_TOKEN", file.APIToken, ""),
+	}
+
+	timeout, err := time.ParseDuration(out.Timeout)
+	if err != nil || timeout <= 0 {
+		return effectiveConfig{}, fmt.Errorf("invalid timeout %q", out.Timeout)
+	}
+	out.timeout = timeout
+	if err := validateOutputFormat(out.Output); err != nil {
+		return effectiveConfig{}, err
+	}
+	if (out.TLSClientCert == "") != (out.TLSClientKey == "") {
+		return effectiveConfig{}, errors.New("tls client cert and key must be set together")
+	}
+	return out, nil
+}
+
+func loadEffectiveConfig(flags globalFlags) (effectiveConfig, error) {
+	path, err := configFilePath()
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+	file, found, err := loadConfigFile(path)
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+
+	dir, err := profilesDir()
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+	flags, file, profileName, err := applyProfile(dir, os.Getenv, flags, file)
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+
+	cfg, err := resolveConfig(file, os.Getenv, flags)
+	if err != nil {
+		return effectiveConfig{}, err
+	}
+	cfg.Profile = profileName
+	if found {
+		cfg.ConfigFile = path
+	}
+	return cfg, nil
+}
+
+// applyProfile layers the selected profile over the settings. A profile named
+// by --profile or SWITCHLY_PROFILE ranks with flags; the implicit "default"
+// profile only overrides the config file, and is skipped when it is missing.
+func applyProfile(dir string, getenv func(string) string, flags globalFlags, file cliConfig) (globalFlags, cliConfig, string, error) {
+	name, explicit := selectProfile(flags.profile, getenv)
+	if !explicit {
+		if _, err := os.Stat(filepath.Join(dir, name+".json")); err != nil {
+			return flags, file, "", nil
+		}
+	}
+	profile, err := loadProfile(dir, name)
+	if err != nil {
+		return flags, file, "", err
+	}
+
+	if explicit {
+		if flags.baseURL == "" {
+			flags.baseURL = profile.BaseURL
+		}
+		if flags.apiToken == "" {
+			flags.apiToken = profile.APIToken
+		}
+		if flags.socket == "" {
+			flags.socket = profile.SocketPath
+		}
+		return flags, file, name, nil
+	}
+	file.BaseURL = profile.BaseURL
+	if profile.APIToken != "" {
+		file.APIToken = profile.APIToken
+	}
+	if profile.SocketPath != "" {
+		file.SocketPath = profile.SocketPath
+	}
+	return flags, file, name, nil
+}
+
+func runConfig(cfg effectiveConfig, args []string) error {
+	if len(args) < 1 || args[0] != "show" {
+		return fmt.Errorf("usage: switchly config show")
+	}
+	return printJSON(cfg)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestResolveConfigPrecedence(t *testing.T) {
+	file := cliConfig{BaseURL: "http://file:1", Timeout: "20s", Output: "csv", SocketPath: "/tmp/file.sock", APIToken: "file-token"}
+	env := map[string]string{
+		"SWITCHLY_BASE_URL":    "http://env:2",
+		"SWITCHLY_TIMEOUT":     "30s",
+		"SWITCHLY_OUTPUT":      "table",
+		"SWITCHLY_SOCKET_PATH": "/tmp/env.sock",
+		"SWITCHLY_API_TOKEN":   "env-token",
+	}
+	getenv := func(key string) string { return env[key] }
+	noEnv := func(string) string { return "" }
+	flags := globalFlags{baseURL: "http://flag:3/", timeout: "40s", output: "json", socket: "/tmp/flag.sock", apiToken: "flag-token"}
+
+	tests := []struct {
+		name        string
+		file        cliConfig
+		getenv      func(string) string
+		flags       globalFlags
+		wantBaseURL string
+		wantTimeout time.Duration
+		wantOutput  string
+		wantSocket  string
+		wantToken   string
+	}{
+		{name: "defaults", getenv: noEnv, wantBaseURL: defaultBaseURL, wantTimeout: defaultTimeout, wantOutput: outputJSON},
+		{name: "config file", file: file, getenv: noEnv, wantBaseURL: "http://file:1", wantTimeout: 20 * time.Second, wantOutput: "csv", wantSocket: "/tmp/file.sock", wantToken: "file-token"},
+		{name: "env over file", file: file, getenv: getenv, wantBaseURL: "http://env:2", wantTimeout: 30 * time.Second, wantOutput: "table", wantSocket: "/tmp/env.sock", wantToken: "env-token"},
+		{name: "flag over env", file: file, getenv: getenv, flags: flags, wantBaseURL: "http://flag:3", wantTimeout: 40 * time.Second, wantOutput: "json", wantSocket: "/tmp/flag.sock", wantToken: "flag-token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolveConfig(tt.file, tt.getenv, tt.flags)
+			if err != nil {
+				t.Fatalf("resolve: %v", err)
+			}
+			if got.BaseURL != tt.wantBaseURL || got.timeout != tt.wantTimeout || got.Output != tt.wantOutput || got.SocketPath != tt.wantSocket || got.apiToken != tt.wantToken {
+				t.Fatalf("unexpected config: %#v timeout=%s", got, got.timeout)
+			}
+		})
+	}
+
+	if _, err := resolveConfig(cliConfig{Timeout: "soon"}, noEnv, globalFlags{}); err == nil {
+		t.Fatal("expected invalid timeout error")
+	}
+	if _, err := resolveConfig(cliConfig{Output: "yaml"}, noEnv, globalFlags{}); err == nil {
+		t.Fatal("expected invalid output error")
+	}
+	if _, err := resolveConfig(cliConfig{TLSClientCert: "client.pem"}, noEnv, globalFlags{}); err == nil {
+		t.Fatal("expected error for a client cert without a key")
+	}
+}
+
+func TestLoadConfigFile(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, found, err := loadConfigFile(filepath.Join(dir, "missing.toml")); err != nil || found {
+		t.Fatalf("expected missing config to be ignored, found=%v err=%v", found, err)
+	}
+
+	tomlPath := filepath.Join(dir, "config.toml")
+	if err := os.WriteFile(tomlPath, []byte("base_url = \"http://127.0.0.1:7778\"\ntimeout = \"5s\"\noutput = \"table\"\n"), 0o600); err != nil {
+		t.Fatalf("write toml: %v", err)
+	}
+	got, found, err := loadConfigFile(tomlPath)
+	if err != nil || !found {
+		t.Fatalf("load toml: found=%v err=%v", found, err)
+	}
+	if got.BaseURL != "http://127.0.0.1:7778" || got.Timeout != "5s" || got.Output != "table" {
+		t.Fatalf("unexpected toml config: %#v", got)
+	}
+
+	jsonPath := filepath.Join(dir, "config.json")
+	if err := os.WriteFile(jsonPath, []byte(`{"socket_path":"/run/switchly.sock"}`), 0o600); err != nil {
+		t.Fatalf("write json: %v", err)
+	}
+	got, _, err = loadConfigFile(jsonPath)
+	if err != nil || got.SocketPath != "/run/switchly.sock" {
+		t.Fatalf("unexpected json config: %#v err=%v", got, err)
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+type daemonRuntimeStats struct {
+	GoVersion      string    `json:"go_version"`
+	NumGoroutines  int       `json:"num_goroutines"`
+	HeapAllocBytes uint64    `json:"heap_alloc_bytes"`
+	HeapSysBytes   uint64    `json:"heap_sys_bytes"`
+	NumGC          uint32    `json:"num_gc"`
+	LastGCAt       time.Time `json:"last_gc_at"`
+}
+
+func runDaemonInfo(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("daemon info", flag.ContinueOnError)
+	runtimeOnly := fs.Bool("runtime", false, "show only the Go runtime stats of the daemon")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if !*runtimeOnly {
+		var out map[string]interface{}
+		if err := c.get("/v1/daemon/info", &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	}
+
+	var info struct {
+		Runtime *daemonRuntimeStats `json:"runtime"`
+	}
+	if err := c.get("/v1/daemon/info", &info); err != nil {
+		return err
+	}
+	if info.Runtime == nil {
+		return errors.New("daemon does not report runtime stats (started with --include-runtime-stats=false)")
+	}
+	return printRuntimeStats(*info.Runtime)
+}
+
+func printRuntimeStats(stats daemonRuntimeStats) error {
+	if outputFormat == outputJSON {
+		return printJSON(stats)
+	}
+	lastGC := "-"
+	if !stats.LastGCAt.IsZero() {
+		lastGC = fmt.Sprintf("%s (%s ago)", formatTime(stats.LastGCAt), time.Since(stats.LastGCAt).Round(time.Second))
+	}
+	tw := newTableWriter(os.Stdout, outputFormat)
+	rows := [][2]string{
+		{"go version", stats.GoVersion},
+		{"goroutines", strconv.Itoa(stats.NumGoroutines)},
+		{"heap alloc", formatBytes(stats.HeapAllocBytes)},
+		{"heap sys", formatBytes(stats.HeapSysBytes)},
+		{"gc cycles", strconv.FormatUint(uint64(stats.NumGC), 10)},
+		{"last gc", lastGC},
+	}
+	if err := tw.Row("KEY", "VALUE"); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if err := tw.Row(row[0], row[1]); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func daemonInfoClient(info map[string]any) *apiClient {
+	return &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.Method == http.MethodGet && r.URL.Path == "/v1/daemon/info" {
+					return jsonResponse(http.StatusOK, info), nil
+				}
+				return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+			}),
+		},
+	}
+}
+
+func TestRunDaemonInfoRuntime(t *testing.T) {
+	client := daemonInfoClient(map[string]any{
+		"pid": 42,
+		"runtime": map[string]any{
+			"go_version":       "go1.99",
+			"num_goroutines":   17,
+			"heap_alloc_bytes": 3 * 1024 * 1024,
+			"heap_sys_bytes":   8 * 1024 * 1024,
+			"num_gc":           5,
+		},
+	})
+	prev := outputFormat
+	outputFormat = outputTable
+	defer func() { outputFormat = prev }()
+
+	out := captureStdout(t, func() {
+		if err := runDaemon(client, []string{"info", "--runtime"}); err != nil {
+			t.Fatalf("daemon info --runtime: %v", err)
+		}
+	})
+	for _, want := range []string{"go1.99", "17", "3.0 MiB", "8.0 MiB"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected %q in output:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "pid") {
+		t.Fatalf("expected only the runtime section, got:\n%s", out)
+	}
+}
+
+func TestRunDaemonInfoRuntimeOmitted(t *testing.T) {
+	client := daemonInfoClient(map[string]any{"pid": 42})
+	err := runDaemon(client, []string{"info", "--runtime"})
+	if err == nil || !strings.Contains(err.Error(), "--include-runtime-stats=false") {
+		t.Fatalf("expected omitted runtime error, got %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	cases := map[uint64]string{512: "512 B", 2048: "2.0 KiB", 5 * 1024 * 1024 * 1024: "5.0 GiB"}
+	for n, want := range cases {
+		if got := formatBytes(n); got != want {
+			t.Fatalf("formatBytes(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
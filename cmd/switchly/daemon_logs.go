@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+)
+
+func runDaemonLogs(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("daemon logs", flag.ContinueOnError)
+	follow := fs.Bool("follow", false, "keep streaming new log lines")
+	fs.BoolVar(follow, "f", false, "shorthand for --follow")
+	lines := fs.Int("lines", 50, "number of recent lines to show")
+	fs.IntVar(lines, "n", 50, "shorthand for --lines")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *lines <= 0 {
+		return fmt.Errorf("--lines must be positive")
+	}
+	path := fmt.Sprintf("/v1/daemon/logs?lines=%d", *lines)
+
+	if !*follow {
+		var out struct {
+			Lines []string `json:"lines"`
+		}
+		if err := c.get(path, &out); err != nil {
+			return err
+		}
+		if outputFormat == outputJSON {
+			return printJSON(out)
+		}
+		for _, line := range out.Lines {
+			fmt.Println(line)
+		}
+		return nil
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	err := c.streamSSE(ctx, path+"&follow=true", func(evt sseEvent) error {
+		fmt.Println(evt.Data)
+		return nil
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+)
+
+type sseEvent struct {
+	Type string
+	Data string
+}
+
+func runEvents(client *apiClient, args []string) error {
+	fs := flag.NewFlagSet("events", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	err := client.streamEvents(ctx, func(evt sseEvent) error {
+		var payload interface{}
+		if err := json.Unmarshal([]byte(evt.Data), &payload); err != nil {
+			payload = evt.Data
+		}
+		return printResult(map[string]interface{}{"event": evt.Type, "data": payload})
+	})
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+	return err
+}
+
+func (c *apiClient) streamEvents(ctx context.Context, handle func(sseEvent) error) error {
+	return c.streamSSE(ctx, "/v1/events", handle)
+}
+
+func (c *apiClient) streamSSE(ctx context.Context, path string, handle func(sseEvent) error) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.baseURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if c.apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	}
+
+	// The stream is long-lived, so the client-wide timeout must not apply.
+	streamClient := *c.http
+	streamClient.Timeout = 0
+	resp, err := streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		raw, _ := io.ReadAll(resp.Body)
+		return parseAPIError(resp.StatusCode, raw)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	var current sseEvent
+	var data []string
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if current.Type == "" && len(data) == 0 {
+				continue
+			}
+			current.Data = strings.Join(data, "\n")
+			if err := handle(current); err != nil {
+				return err
+			}
+			current = sseEvent{}
+			data = nil
+		case strings.HasPrefix(line, ":"):
+			// Comment line, used by the daemon for keepalives.
+		case strings.HasPrefix(line, "event:"):
+			current.Type = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " "))
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	return nil
+}
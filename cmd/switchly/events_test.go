@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStreamEventsParsesServerSentEvents(t *testing.T) {
+	stream := ": keepalive\n\n" +
+		"event: account.switched\ndata: {\"type\":\"account.switched\",\"account_id\":\"acc-b\"}\n\n" +
+		"event: daemon.shutdown\ndata: {\"type\":\"daemon.shutdown\"}\n\n"
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.URL.Path != "/v1/events" {
+					t.Fatalf("unexpected path: %s", r.URL.Path)
+				}
+				return &http.Response{
+					StatusCode: http.StatusOK,
+					Header:     http.Header{"Content-Type": []string{"text/event-stream"}},
+					Body:       io.NopCloser(strings.NewReader(stream)),
+				}, nil
+			}),
+		},
+	}
+
+	var got []sseEvent
+	err := client.streamEvents(context.Background(), func(evt sseEvent) error {
+		got = append(got, evt)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("stream events: %v", err)
+	}
+	if len(got) != 2 {
+		t.Fatalf("expected 2 events, got %#v", got)
+	}
+	if got[0].Type != "account.switched" || !strings.Contains(got[0].Data, `"acc-b"`) {
+		t.Fatalf("unexpected first event: %#v", got[0])
+	}
+	if got[1].Type != "daemon.shutdown" {
+		t.Fatalf("unexpected second event: %#v", got[1])
+	}
+}
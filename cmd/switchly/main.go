@@ -1,64 +1,99 @@
 package main
 
 import (
+	"bufio"
 	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"os/exec"
+	"os/signal"
 	"runtime"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
 	"switchly/internal/codexauth"
+	"switchly/internal/model"
 )
 
 const defaultBaseURL = "http://127.0.0.1:7777"
 
 func main() {
-	if len(os.Args) < 2 {
+	globals, args, err := extractGlobalFlags(os.Args[1:])
+	must(err)
+	if len(args) < 1 {
 		printUsage()
 		os.Exit(1)
 	}
 
-	baseURL := os.Getenv("SWITCHLY_BASE_URL")
-	if baseURL == "" {
-		baseURL = defaultBaseURL
-	}
-	client := &apiClient{baseURL: baseURL, http: &http.Client{Timeout: 15 * time.Second}}
+	cfg, err := loadEffectiveConfig(globals)
+	must(err)
+	outputFormat = cfg.Output
+	tlsConfig, err := clientTLSConfig(cfg.TLSCACert, cfg.TLSClientCert, cfg.TLSClientKey)
+	must(err)
+	client := newAPIClient(cfg.BaseURL, cfg.apiToken, cfg.SocketPath, cfg.timeout, tlsConfig).WithVerbose(globals.verbose)
+	must(waitForDaemonIfRequested(client, globals.waitForDaemon, args))
 
-	switch os.Args[1] {
+	switch args[0] {
 	case "status":
-		must(runStatus(client))
+		must(runStatus(client, args[1:]))
+	case "events":
+		must(runEvents(client, args[1:]))
 	case "account":
-		must(runAccount(client, os.Args[2:]))
+		must(runAccount(client, args[1:]))
 	case "quota":
-		must(runQuota(client, os.Args[2:]))
+		must(runQuota(client, args[1:]))
 	case "switch":
-		must(runSwitch(client, os.Args[2:]))
+		must(runSwitch(client, args[1:]))
 	case "strategy":
-		must(runStrategy(client, os.Args[2:]))
+		must(runStrategy(client, args[1:]))
 	case "oauth":
-		must(runOAuth(client, os.Args[2:]))
+		must(runOAuth(client, args[1:]))
 	case "daemon":
-		must(runDaemon(client, os.Args[2:]))
+		must(runDaemon(client, args[1:]))
+	case "secrets":
+		must(runSecrets(client, args[1:]))
+	case "profile":
+		must(runProfile(args[1:]))
+	case "config":
+		must(runConfig(cfg, args[1:]))
 	default:
 		printUsage()
 		os.Exit(1)
 	}
 }
 
-func runStatus(c *apiClient) error {
-	var out map[string]interface{}
+func runStatus(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("status", flag.ContinueOnError)
+	health := fs.Bool("health", false, "run the daemon's detailed health checks; fails when degraded")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *health {
+		// A degraded daemon answers 503, which surfaces as an error carrying
+		// the failed checks.
+		var out map[string]interface{}
+		if err := c.get("/v1/health?detailed=true", &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	}
+
+	var out json.RawMessage
 	if err := c.get("/v1/status", &out); err != nil {
 		return err
 	}
-	return printJSON(out)
+	return printStatus(out)
 }
 
 func runAccount(c *apiClient, args []string) error {
@@ -79,10 +114,34 @@ func runAccount(c *apiClient, args []string) error {
 			accountID     = fs.String("account-id", "", "provider account id")
 			accessExpiry  = fs.String("access-expiry", "", "RFC3339")
 			refreshExpiry = fs.String("refresh-expiry", "", "RFC3339")
+			weight        = fs.Int("weight", 0, "routing weight for weighted-round-robin (default 1)")
+			fromLocalFile = fs.Bool("from-local-file", false, "fill unset fields from the codex auth.json ($CODEX_DIR, $CODEX_HOME or ~/.codex)")
 		)
 		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
+		if *fromLocalFile {
+			local, err := readCodexAuthFile()
+			if err != nil {
+				return err
+			}
+			// Flags given on the command line win over the file.
+			for _, f := range []struct {
+				dst *string
+				val string
+			}{
+				{id, local.ID},
+				{email, local.Email},
+				{accessToken, local.Secrets.AccessToken},
+				{refreshToken, local.Secrets.RefreshToken},
+				{idToken, local.Secrets.IDToken},
+				{accountID, local.Secrets.AccountID},
+			} {
+				if strings.TrimSpace(*f.dst) == "" {
+					*f.dst = f.val
+				}
+			}
+		}
 		if strings.TrimSpace(*id) == "" {
 			return fmt.Errorf("--id is required")
 		}
@@ -90,7 +149,7 @@ func runAccount(c *apiClient, args []string) error {
 			return fmt.Errorf("--access-token is required")
 		}
 
-		payload := map[string]string{
+		payload := map[string]interface{}{
 			"id":                 *id,
 			"provider":           *provider,
 			"email":              *email,
@@ -101,73 +160,439 @@ func runAccount(c *apiClient, args []string) error {
 			"access_expires_at":  *accessExpiry,
 			"refresh_expires_at": *refreshExpiry,
 		}
+		if *weight != 0 {
+			payload["weight"] = *weight
+		}
 		var out map[string]interface{}
 		if err := c.post("/v1/accounts", payload, &out); err != nil {
 			return err
 		}
-		return printJSON(out)
+		return printResult(out)
 	case "list":
+		fs := flag.NewFlagSet("account list", flag.ContinueOnError)
+		status := fs.String("status", "", "only accounts with this status (ready, warning, need_reauth, disabled)")
+		provider := fs.String("provider", "", "only accounts of this provider")
+		sessionGT := fs.Int("session-gt", -1, "only accounts whose session usage is above this percentage")
+		weeklyGT := fs.Int("weekly-gt", -1, "only accounts whose weekly usage is above this percentage")
+		limit := fs.Int("limit", 0, "maximum number of accounts to list (0 lists all)")
+		offset := fs.Int("offset", 0, "number of matching accounts to skip")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		query := url.Values{}
+		if *status != "" {
+			query.Set("status", *status)
+		}
+		if *provider != "" {
+			query.Set("provider", *provider)
+		}
+		if *sessionGT >= 0 {
+			query.Set("session_gt", strconv.Itoa(*sessionGT))
+		}
+		if *weeklyGT >= 0 {
+			query.Set("weekly_gt", strconv.Itoa(*weeklyGT))
+		}
+		if *limit > 0 {
+			query.Set("limit", strconv.Itoa(*limit))
+		}
+		if *offset > 0 {
+			query.Set("offset", strconv.Itoa(*offset))
+		}
+		path := "/v1/accounts"
+		if len(query) > 0 {
+			path += "?" + query.Encode()
+		}
+		var out json.RawMessage
+		if err := c.get(path, &out); err != nil {
+			return err
+		}
+		return printAccounts(out)
+	case "get":
+		fs := flag.NewFlagSet("account get", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		verbose := fs.Bool("verbose", false, "print the response request id to stderr")
+		fs.BoolVar(verbose, "v", false, "shorthand for --verbose")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
 		var out map[string]interface{}
-		if err := c.get("/v1/accounts", &out); err != nil {
+		err := c.get(fmt.Sprintf("/v1/accounts/%s", *id), &out)
+		if *verbose && c.lastRequestID != "" {
+			fmt.Fprintf(os.Stderr, "request_id: %s\n", c.lastRequestID)
+		}
+		if err != nil {
 			return err
 		}
-		return printJSON(out)
+		return printResult(out)
+	case "stats":
+		fs := flag.NewFlagSet("account stats", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var acct struct {
+			ID          string `json:"id"`
+			Status      string `json:"status"`
+			SwitchCount int    `json:"switch_count"`
+			ErrorCount  int    `json:"error_count"`
+			LastError   string `json:"last_error"`
+		}
+		if err := c.get(fmt.Sprintf("/v1/accounts/%s", *id), &acct); err != nil {
+			return err
+		}
+		return printResult(acct)
 	case "use":
 		fs := flag.NewFlagSet("account use", flag.ContinueOnError)
 		id := fs.String("id", "", "account id")
+		force := fs.Bool("force", false, "activate even if the account is not ready or applying it fails")
 		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
 		if *id == "" {
 			return fmt.Errorf("--id is required")
 		}
+		payload := map[string]interface{}{}
+		if *force {
+			payload["force"] = true
+		}
 		var out map[string]interface{}
-		if err := c.post(fmt.Sprintf("/v1/accounts/%s/activate", *id), map[string]string{}, &out); err != nil {
+		if err := c.post(fmt.Sprintf("/v1/accounts/%s/activate", *id), payload, &out); err != nil {
 			return err
 		}
-		return printJSON(out)
+		if msg, _ := out["applier_error"].(string); msg != "" {
+			fmt.Fprintf(os.Stderr, "warning: %s is active but was not applied: %s\n", *id, msg)
+		}
+		return printResult(out)
 	case "delete":
 		fs := flag.NewFlagSet("account delete", flag.ContinueOnError)
 		id := fs.String("id", "", "account id")
+		yes := fs.Bool("yes", false, "skip confirmation prompt")
 		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
 		if strings.TrimSpace(*id) == "" {
 			return fmt.Errorf("--id is required")
 		}
+		if !*yes {
+			ok, err := confirm(fmt.Sprintf("Delete account %s and its stored secrets?", *id))
+			if err != nil {
+				return err
+			}
+			if !ok {
+				return fmt.Errorf("account delete aborted")
+			}
+		}
 		var out map[string]interface{}
 		if err := c.delete(fmt.Sprintf("/v1/accounts/%s", *id), &out); err != nil {
 			return err
 		}
-		return printJSON(out)
+		return printResult(out)
+	case "enable", "disable":
+		fs := flag.NewFlagSet("account "+args[0], flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		status := "ready"
+		if args[0] == "disable" {
+			status = "disabled"
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/status", *id), map[string]string{"status": status}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "set-threshold":
+		fs := flag.NewFlagSet("account set-threshold", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		sessionWarn := fs.Int("session-warn", 0, "session used percentage that flags the account (0 disables)")
+		weeklyWarn := fs.Int("weekly-warn", 0, "weekly used percentage that flags the account (0 disables)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		payload := map[string]int{}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "session-warn":
+				payload["session_warn_at"] = *sessionWarn
+			case "weekly-warn":
+				payload["weekly_warn_at"] = *weeklyWarn
+			}
+		})
+		if len(payload) == 0 {
+			return fmt.Errorf("--session-warn or --weekly-warn is required")
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/thresholds", *id), payload, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "pin", "unpin":
+		fs := flag.NewFlagSet("account "+args[0], flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var out map[string]interface{}
+		if err := c.post(fmt.Sprintf("/v1/accounts/%s/%s", *id, args[0]), map[string]string{}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "alias", "rename":
+		fs := flag.NewFlagSet("account "+args[0], flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		name := fs.String("name", "", "display alias (empty clears it)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/alias", *id), map[string]string{"alias": *name}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "annotate":
+		fs := flag.NewFlagSet("account annotate", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		notes := fs.String("notes", "", "notes about the account, up to 500 characters (empty clears them)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/notes", *id), map[string]string{"notes": *notes}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "meta":
+		fs := flag.NewFlagSet("account meta", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		meta := paramFlags{}
+		fs.Var(meta, "set", "provider metadata key=value (repeatable)")
+		fs.Func("unset", "provider metadata key to remove (repeatable)", func(key string) error {
+			meta[strings.TrimSpace(key)] = ""
+			return nil
+		})
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		if len(meta) == 0 {
+			return fmt.Errorf("at least one --set or --unset is required")
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/meta", *id), map[string]string(meta), &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "update":
+		fs := flag.NewFlagSet("account update", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		email := fs.String("email", "", "new email")
+		alias := fs.String("alias", "", "new display alias (empty clears it)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		// Only send flags that were given so the rest of the account is kept.
+		body := map[string]string{}
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "email":
+				body["email"] = *email
+			case "alias":
+				body["alias"] = *alias
+			}
+		})
+		if len(body) == 0 {
+			return fmt.Errorf("nothing to update: pass --email or --alias")
+		}
+		var out map[string]interface{}
+		if err := c.patch("/v1/accounts/"+*id, body, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "set-proxy":
+		fs := flag.NewFlagSet("account set-proxy", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		proxy := fs.String("proxy", "", "proxy URL for this account (empty uses the default client)")
+		timeout := fs.Int("timeout", 0, "request timeout in seconds (0 keeps the default)")
+		skipVerify := fs.Bool("insecure-skip-verify", false, "skip TLS certificate verification")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		body := map[string]interface{}{
+			"proxy_url":       *proxy,
+			"timeout_seconds": *timeout,
+			"skip_tls_verify": *skipVerify,
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/http-config", *id), body, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "weight":
+		fs := flag.NewFlagSet("account weight", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		value := fs.Int("value", 1, "routing weight")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var out map[string]interface{}
+		if err := c.patch(fmt.Sprintf("/v1/accounts/%s/weight", *id), map[string]int{"weight": *value}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "refresh":
+		fs := flag.NewFlagSet("account refresh", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var out map[string]interface{}
+		if err := c.post(fmt.Sprintf("/v1/accounts/%s/refresh", *id), map[string]string{}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "test":
+		fs := flag.NewFlagSet("account test", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		var out struct {
+			AccountID  string `json:"account_id"`
+			Provider   string `json:"provider"`
+			Valid      bool   `json:"valid"`
+			HTTPStatus int    `json:"http_status,omitempty"`
+			LatencyMS  int64  `json:"latency_ms"`
+		}
+		if err := c.post(fmt.Sprintf("/v1/accounts/%s/test", *id), map[string]string{}, &out); err != nil {
+			return err
+		}
+		if err := printResult(out); err != nil {
+			return err
+		}
+		if !out.Valid {
+			return fmt.Errorf("provider rejected the access token for %s (http %d)", *id, out.HTTPStatus)
+		}
+		return nil
 	case "apply":
 		fs := flag.NewFlagSet("account apply", flag.ContinueOnError)
 		id := fs.String("id", "", "account id (default: current active account)")
 		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
-		targetID := strings.TrimSpace(*id)
-		if targetID == "" {
-			var status struct {
-				ActiveAccountID string `json:"active_account_id"`
-			}
-			if err := c.get("/v1/status", &status); err != nil {
-				return err
-			}
-			targetID = strings.TrimSpace(status.ActiveAccountID)
-			if targetID == "" {
-				return fmt.Errorf("no active account configured; pass --id explicitly")
-			}
+		targetID, err := accountIDOrActive(c, *id)
+		if err != nil {
+			return err
 		}
 		var out map[string]interface{}
 		if err := c.post(fmt.Sprintf("/v1/accounts/%s/activate", targetID), map[string]string{}, &out); err != nil {
 			return err
 		}
-		return printJSON(map[string]interface{}{
+		return printResult(map[string]interface{}{
 			"status":     "ok",
 			"account_id": targetID,
 			"action":     "applied",
 		})
+	case "rotate":
+		fs := flag.NewFlagSet("account rotate", flag.ContinueOnError)
+		id := fs.String("id", "", "account id (default: current active account)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		targetID, err := accountIDOrActive(c, *id)
+		if err != nil {
+			return err
+		}
+		var refreshed struct {
+			AccessExpiresAt time.Time `json:"access_expires_at"`
+		}
+		if err := c.post(fmt.Sprintf("/v1/accounts/%s/refresh", targetID), map[string]string{}, &refreshed); err != nil {
+			return err
+		}
+		var out map[string]interface{}
+		if err := c.post(fmt.Sprintf("/v1/accounts/%s/activate", targetID), map[string]string{}, &out); err != nil {
+			// The refresh already happened; say so rather than reporting a plain failure.
+			fmt.Fprintf(os.Stderr, "warning: token for %s was refreshed (expires %s) but applying it failed: %v\n", targetID, formatTime(refreshed.AccessExpiresAt), err)
+			return err
+		}
+		return printResult(map[string]interface{}{
+			"status":            "ok",
+			"account_id":        targetID,
+			"action":            "rotated",
+			"access_expires_at": refreshed.AccessExpiresAt,
+		})
+	case "import-batch":
+		fs := flag.NewFlagSet("account import-batch", flag.ContinueOnError)
+		file := fs.String("file", "", "JSON file with {\"accounts\": [...]} or a bare array of accounts")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*file) == "" {
+			return fmt.Errorf("--file is required")
+		}
+		payload, err := readBatchImportFile(*file)
+		if err != nil {
+			return err
+		}
+		var out map[string]interface{}
+		if err := c.post("/v1/accounts/import/batch", payload, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "import-env":
+		var out map[string]interface{}
+		if err := c.post("/v1/accounts/import/env", map[string]string{}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "import-copilot":
+		var out map[string]interface{}
+		if err := c.post("/v1/accounts/import/copilot", map[string]string{}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "export":
+		return runAccountExport(c, args[1:])
+	case "import-bundle":
+		return runAccountImportBundle(c, args[1:])
 	case "import-codex":
 		fs := flag.NewFlagSet("account import-codex", flag.ContinueOnError)
 		overwriteExisting := fs.Bool("overwrite-existing", true, "overwrite existing account tokens when account already exists")
@@ -180,6 +605,36 @@ func runAccount(c *apiClient, args []string) error {
 	}
 }
 
+func splitCSV(raw string) []string {
+	parts := strings.Split(raw, ",")
+	out := make([]string, 0, len(parts))
+	for _, part := range parts {
+		if v := strings.TrimSpace(part); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func readBatchImportFile(path string) (map[string]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		return map[string]json.RawMessage{"accounts": trimmed}, nil
+	}
+	var payload map[string]json.RawMessage
+	if err := json.Unmarshal(trimmed, &payload); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if _, ok := payload["accounts"]; !ok {
+		return nil, fmt.Errorf("parse %s: missing \"accounts\" array", path)
+	}
+	return payload, nil
+}
+
 type codexImportCandidate struct {
 	ID       string `json:"id"`
 	Provider string `json:"provider"`
@@ -192,6 +647,38 @@ type codexImportCandidateResponse struct {
 	AlreadyExists bool                  `json:"already_exists,omitempty"`
 }
 
+// readCodexAuthFile loads the account the codex CLI is logged in as, naming
+// the file actually read in errors.
+func readCodexAuthFile() (codexauth.LocalAccount, error) {
+	path, err := codexauth.DefaultAuthFilePath()
+	if err != nil {
+		return codexauth.LocalAccount{}, err
+	}
+	account, err := codexauth.LoadLocalAccount(path)
+	if err != nil {
+		return codexauth.LocalAccount{}, fmt.Errorf("read %s failed: %w", path, err)
+	}
+	return account, nil
+}
+
+// accountIDOrActive returns id, or the daemon's active account when id is empty.
+func accountIDOrActive(c *apiClient, id string) (string, error) {
+	if id = strings.TrimSpace(id); id != "" {
+		return id, nil
+	}
+	var status struct {
+		ActiveAccountID string `json:"active_account_id"`
+	}
+	if err := c.get("/v1/status", &status); err != nil {
+		return "", err
+	}
+	id = strings.TrimSpace(status.ActiveAccountID)
+	if id == "" {
+		return "", fmt.Errorf("no active account configured; pass --id explicitly")
+	}
+	return id, nil
+}
+
 func runAccountImportCodex(c *apiClient, overwriteExisting bool) error {
 	var candidate codexImportCandidateResponse
 	if err := c.get("/v1/accounts/import/codex/candidate", &candidate); err != nil {
@@ -208,26 +695,108 @@ func runAccountImportCodex(c *apiClient, overwriteExisting bool) error {
 	if err := c.post("/v1/accounts/import/codex", map[string]bool{"overwrite_existing": overwriteExisting}, &out); err != nil {
 		return err
 	}
-	return printJSON(out)
+	return printResult(out)
 }
 
 func runSwitch(c *apiClient, args []string) error {
+	if len(args) >= 1 && args[0] == "rules" {
+		return runSwitchRules(c, args[1:])
+	}
+	if len(args) >= 1 && args[0] == "history" {
+		fs := flag.NewFlagSet("switch history", flag.ContinueOnError)
+		limit := fs.Int("limit", 20, "maximum number of switch events to show")
+		cursor := fs.String("cursor", "", "next_cursor from a previous page")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if *limit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		path := fmt.Sprintf("/v1/switch/history?limit=%d", *limit)
+		if strings.TrimSpace(*cursor) != "" {
+			path += "&cursor=" + url.QueryEscape(strings.TrimSpace(*cursor))
+		}
+		var out map[string]interface{}
+		if err := c.get(path, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	}
+	if len(args) >= 1 && args[0] == "reset-cooldown" {
+		var out map[string]interface{}
+		if err := c.delete("/v1/switch/cooldown", &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	}
 	if len(args) < 1 || args[0] != "simulate-error" {
-		return fmt.Errorf("usage: switchly switch simulate-error --status 429 --message \"quota exceeded\"")
+		return fmt.Errorf("usage: switchly switch simulate-error --status 429 --message \"quota exceeded\" [--dry-run]")
 	}
 	fs := flag.NewFlagSet("switch simulate-error", flag.ContinueOnError)
 	status := fs.Int("status", 429, "upstream status code")
 	message := fs.String("message", "quota exceeded", "upstream error message")
+	dryRun := fs.Bool("dry-run", false, "report which account would be chosen without switching")
 	if err := fs.Parse(args[1:]); err != nil {
 		return err
 	}
 
 	payload := map[string]interface{}{"status_code": *status, "error_message": *message}
+	if *dryRun {
+		payload["dry_run"] = true
+	}
 	var out map[string]interface{}
 	if err := c.post("/v1/switch/on-error", payload, &out); err != nil {
 		return err
 	}
-	return printJSON(out)
+	return printResult(out)
+}
+
+func runSwitchRules(c *apiClient, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing switch rules command")
+	}
+
+	switch args[0] {
+	case "list":
+		var out map[string]interface{}
+		if err := c.get("/v1/switch/rules", &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "add":
+		fs := flag.NewFlagSet("switch rules add", flag.ContinueOnError)
+		pattern := fs.String("pattern", "", "error message substring that triggers a switch")
+		status := fs.Int("status", 0, "upstream status code that triggers a switch")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*pattern) == "" && *status == 0 {
+			return fmt.Errorf("--pattern or --status is required")
+		}
+		var current struct {
+			User struct {
+				StatusCodes     []int    `json:"status_codes"`
+				MessagePatterns []string `json:"message_patterns"`
+			} `json:"user"`
+		}
+		if err := c.get("/v1/switch/rules", &current); err != nil {
+			return err
+		}
+		rules := current.User
+		if strings.TrimSpace(*pattern) != "" {
+			rules.MessagePatterns = append(rules.MessagePatterns, strings.TrimSpace(*pattern))
+		}
+		if *status != 0 {
+			rules.StatusCodes = append(rules.StatusCodes, *status)
+		}
+		var out map[string]interface{}
+		if err := c.put("/v1/switch/rules", rules, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	default:
+		return fmt.Errorf("unknown switch rules command: %s", args[0])
+	}
 }
 
 func runQuota(c *apiClient, args []string) error {
@@ -250,32 +819,146 @@ func runQuota(c *apiClient, args []string) error {
 		if err := c.post("/v1/quota/sync", payload, &out); err != nil {
 			return err
 		}
-		return printJSON(out)
+		return printResult(out)
+	case "sync-local":
+		fs := flag.NewFlagSet("quota sync-local", flag.ContinueOnError)
+		accountID := fs.String("id", "", "account id (default: active account)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		payload := map[string]string{}
+		if strings.TrimSpace(*accountID) != "" {
+			payload["account_id"] = strings.TrimSpace(*accountID)
+		}
+		var out map[string]interface{}
+		if err := c.post("/v1/quota/sync-local", payload, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "sync-warmup":
+		fs := flag.NewFlagSet("quota sync-warmup", flag.ContinueOnError)
+		accountID := fs.String("id", "", "account id (default: active account)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		payload := map[string]string{}
+		if strings.TrimSpace(*accountID) != "" {
+			payload["account_id"] = strings.TrimSpace(*accountID)
+		}
+		var out map[string]interface{}
+		if err := c.post("/v1/quota/sync-with-warmup", payload, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "warmup":
+		var out map[string]interface{}
+		if err := c.post("/v1/quota/warmup", map[string]string{}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
 	case "sync-all":
+		fs := flag.NewFlagSet("quota sync-all", flag.ContinueOnError)
+		providers := fs.String("providers", "", "comma-separated provider filter (default: all providers)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		path := "/v1/quota/sync-all"
+		if v := strings.TrimSpace(*providers); v != "" {
+			path += "?providers=" + url.QueryEscape(v)
+		}
 		var out map[string]interface{}
-		if err := c.post("/v1/quota/sync-all", map[string]string{}, &out); err != nil {
+		if err := c.post(path, map[string]string{}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "watch":
+		return runQuotaWatch(c, args[1:])
+	case "wait":
+		return runQuotaWait(c, args[1:])
+	case "history":
+		fs := flag.NewFlagSet("quota history", flag.ContinueOnError)
+		id := fs.String("id", "", "account id")
+		limit := fs.Int("limit", 48, "number of most recent snapshots")
+		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
-		return printJSON(out)
+		if strings.TrimSpace(*id) == "" {
+			return fmt.Errorf("--id is required")
+		}
+		if *limit < 1 {
+			return fmt.Errorf("--limit must be at least 1")
+		}
+		var out struct {
+			AccountID string                `json:"account_id"`
+			History   []model.QuotaSnapshot `json:"history"`
+		}
+		if err := c.get(fmt.Sprintf("/v1/accounts/%s/quota/history?limit=%d", *id, *limit), &out); err != nil {
+			return err
+		}
+		return printQuotaHistory(out.AccountID, out.History)
 	default:
 		return fmt.Errorf("unknown quota command: %s", args[0])
 	}
 }
 
-func runStrategy(c *apiClient, args []string) error {
-	if len(args) < 1 || args[0] != "set" {
-		return fmt.Errorf("usage: switchly strategy set --value round-robin|fill-first")
+var routingStrategies = func() []string {
+	names := make([]string, 0, len(model.RoutingStrategies))
+	for _, s := range model.RoutingStrategies {
+		names = append(names, string(s))
 	}
-	fs := flag.NewFlagSet("strategy set", flag.ContinueOnError)
-	value := fs.String("value", "round-robin", "routing strategy")
-	if err := fs.Parse(args[1:]); err != nil {
-		return err
+	return names
+}()
+
+func runStrategy(c *apiClient, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: switchly strategy set --value %s", strings.Join(routingStrategies, "|"))
 	}
-	var out map[string]interface{}
-	if err := c.patch("/v1/strategy", map[string]string{"strategy": *value}, &out); err != nil {
-		return err
+	switch args[0] {
+	case "get":
+		var out map[string]interface{}
+		if err := c.get("/v1/strategy", &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "set":
+		fs := flag.NewFlagSet("strategy set", flag.ContinueOnError)
+		value := fs.String("value", "round-robin", "routing strategy")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if !slices.Contains(routingStrategies, *value) {
+			return fmt.Errorf("invalid strategy %q (want %s)", *value, strings.Join(routingStrategies, ", "))
+		}
+		var out map[string]interface{}
+		if err := c.patch("/v1/strategy", map[string]string{"strategy": *value}, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "priority":
+		fs := flag.NewFlagSet("strategy priority", flag.ContinueOnError)
+		accounts := fs.String("accounts", "", "comma-separated account ids, highest priority first")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*accounts) == "" {
+			var out map[string]interface{}
+			if err := c.get("/v1/priority", &out); err != nil {
+				return err
+			}
+			return printResult(out)
+		}
+		var out map[string]interface{}
+		if err := c.put("/v1/priority", map[string][]string{"priorities": splitCSV(*accounts)}, &out); err != nil {
+			return err
+		}
+		if err := c.patch("/v1/strategy", map[string]string{"strategy": "priority"}, nil); err != nil {
+			return err
+		}
+		out["strategy"] = "priority"
+		return printResult(out)
+	default:
+		return fmt.Errorf("unknown strategy command: %s", args[0])
 	}
-	return printJSON(out)
 }
 
 type oauthSession struct {
@@ -295,11 +978,14 @@ func runOAuth(c *apiClient, args []string) error {
 
 	switch args[0] {
 	case "providers":
+		if len(args) > 1 {
+			return runOAuthProviders(c, args[1:])
+		}
 		var out map[string]interface{}
 		if err := c.get("/v1/oauth/providers", &out); err != nil {
 			return err
 		}
-		return printJSON(out)
+		return printResult(out)
 	case "start":
 		fs := flag.NewFlagSet("oauth start", flag.ContinueOnError)
 		provider := fs.String("provider", "codex", "provider name")
@@ -315,7 +1001,7 @@ func runOAuth(c *apiClient, args []string) error {
 		if *openBrowserFlag {
 			_ = openBrowser(sess.AuthURL)
 		}
-		return printJSON(sess)
+		return printResult(sess)
 	case "status":
 		fs := flag.NewFlagSet("oauth status", flag.ContinueOnError)
 		state := fs.String("state", "", "oauth state")
@@ -329,7 +1015,36 @@ func runOAuth(c *apiClient, args []string) error {
 		if err := c.get("/v1/oauth/status?state="+*state, &sess); err != nil {
 			return err
 		}
-		return printJSON(sess)
+		return printResult(sess)
+	case "cancel":
+		fs := flag.NewFlagSet("oauth cancel", flag.ContinueOnError)
+		state := fs.String("state", "", "oauth state")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*state) == "" {
+			return fmt.Errorf("--state is required")
+		}
+		var out map[string]string
+		if err := c.delete("/v1/oauth/sessions/"+url.PathEscape(*state), &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "sessions":
+		fs := flag.NewFlagSet("oauth sessions", flag.ContinueOnError)
+		status := fs.String("status", "", "only list sessions with this status: pending|success|error|expired|cancelled")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		path := "/v1/oauth/sessions"
+		if s := strings.TrimSpace(*status); s != "" {
+			path += "?status=" + url.QueryEscape(s)
+		}
+		var raw json.RawMessage
+		if err := c.get(path, &raw); err != nil {
+			return err
+		}
+		return printOAuthSessions(raw)
 	case "login":
 		fs := flag.NewFlagSet("oauth login", flag.ContinueOnError)
 		provider := fs.String("provider", "codex", "provider name")
@@ -364,9 +1079,11 @@ func runOAuth(c *apiClient, args []string) error {
 			}
 			switch sess.Status {
 			case "success":
-				return printJSON(sess)
+				return printResult(sess)
 			case "error", "expired":
 				return fmt.Errorf("oauth %s: %s", sess.Status, sess.Error)
+			case "cancelled":
+				return fmt.Errorf("oauth login cancelled (state %s)", sess.State)
 			}
 		}
 		return fmt.Errorf("oauth login timeout after %s", timeout.String())
@@ -375,12 +1092,17 @@ func runOAuth(c *apiClient, args []string) error {
 	}
 }
 
+// codexDeviceLoginCommand runs the codex CLI device login; tests replace it.
+var codexDeviceLoginCommand = func() *exec.Cmd {
+	return exec.Command("codex", "login", "--device-auth")
+}
+
 func runOAuthLoginDevice(c *apiClient, provider string) error {
 	if !strings.EqualFold(provider, "codex") {
 		return fmt.Errorf("device method is currently supported only for provider=codex")
 	}
 
-	cmd := exec.Command("codex", "login", "--device-auth")
+	cmd := codexDeviceLoginCommand()
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
@@ -388,9 +1110,9 @@ func runOAuthLoginDevice(c *apiClient, provider string) error {
 		return fmt.Errorf("codex device auth failed: %w", err)
 	}
 
-	account, err := codexauth.LoadLocalAccountFromDefaultFile()
+	account, err := readCodexAuthFile()
 	if err != nil {
-		return fmt.Errorf("read ~/.codex/auth.json failed: %w", err)
+		return err
 	}
 	if err := account.Validate(); err != nil {
 		return err
@@ -410,7 +1132,7 @@ func runOAuthLoginDevice(c *apiClient, provider string) error {
 	if err := c.post("/v1/accounts", payload, &out); err != nil {
 		return err
 	}
-	return printJSON(map[string]interface{}{
+	return printResult(map[string]interface{}{
 		"status":  "ok",
 		"method":  "device",
 		"account": out,
@@ -427,11 +1149,11 @@ func runDaemon(c *apiClient, args []string) error {
 
 	switch args[0] {
 	case "info":
-		var out map[string]interface{}
-		if err := c.get("/v1/daemon/info", &out); err != nil {
-			return err
-		}
-		return printJSON(out)
+		return runDaemonInfo(c, args[1:])
+	case "logs":
+		return runDaemonLogs(c, args[1:])
+	case "systemd-unit":
+		return runDaemonSystemdUnit(c, args[1:])
 	case "stop":
 		fs := flag.NewFlagSet("daemon stop", flag.ContinueOnError)
 		addr := fs.String("addr", defaultAddr, "daemon address")
@@ -443,7 +1165,7 @@ func runDaemon(c *apiClient, args []string) error {
 			var out map[string]interface{}
 			if err := c.post("/v1/daemon/shutdown", map[string]string{}, &out); err == nil {
 				out["mode"] = "api"
-				return printJSON(out)
+				return printResult(out)
 			}
 		}
 		port, err := portFromAddr(*addr)
@@ -454,7 +1176,7 @@ func runDaemon(c *apiClient, args []string) error {
 		if err != nil {
 			return err
 		}
-		return printJSON(map[string]interface{}{
+		return printResult(map[string]interface{}{
 			"status":      "stopped",
 			"addr":        *addr,
 			"port":        port,
@@ -468,18 +1190,45 @@ func runDaemon(c *apiClient, args []string) error {
 		startCmd := fs.String("start-cmd", "", "custom start command; default uses go run ./cmd/switchlyd")
 		wait := fs.Duration("wait", 8*time.Second, "health-check timeout")
 		skipHealth := fs.Bool("skip-health-check", false, "skip /v1/health polling")
+		minInterval := fs.Duration("health-min-interval", healthPollMinInterval, "first pause between health checks; doubles after each failed check")
+		maxInterval := fs.Duration("health-max-interval", healthPollMaxInterval, "longest pause between health checks")
+		noGitignore := fs.Bool("no-gitignore", false, "do not create a .gitignore next to the applied codex auth file")
+		metricsAddr := fs.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (empty disables)")
+		socketPath := fs.String("socket-path", "", "also serve the API on this unix domain socket")
+		apiToken := fs.String("api-token", "", "require this bearer token on the daemon API")
+		notify := fs.Bool("notify", true, "show a desktop notification when the daemon switches accounts")
+		detach := fs.Bool("detach", true, "run daemon in background; use --detach=false to stay in foreground")
 		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
-		if err := startDaemonProcess(*startCmd, *addr, *publicBaseURL); err != nil {
+		var extraArgs []string
+		if strings.TrimSpace(*apiToken) != "" {
+			extraArgs = append(extraArgs, "--api-token", strings.TrimSpace(*apiToken))
+		}
+		if *noGitignore {
+			extraArgs = append(extraArgs, "--no-gitignore")
+		}
+		if !*notify {
+			extraArgs = append(extraArgs, "--notify=false")
+		}
+		if strings.TrimSpace(*metricsAddr) != "" {
+			extraArgs = append(extraArgs, "--metrics-addr", strings.TrimSpace(*metricsAddr))
+		}
+		if strings.TrimSpace(*socketPath) != "" {
+			extraArgs = append(extraArgs, "--socket-path", strings.TrimSpace(*socketPath))
+		}
+		if !*detach {
+			return runDaemonForeground(daemonCommand(*startCmd, *addr, *publicBaseURL, extraArgs...))
+		}
+		if err := startDaemonProcess(*startCmd, *addr, *publicBaseURL, extraArgs...); err != nil {
 			return err
 		}
 		if !*skipHealth {
-			if err := waitForHealth(*addr, *wait); err != nil {
+			if err := waitForHealth(*addr, *wait, *minInterval, *maxInterval, c.verbose); err != nil {
 				return err
 			}
 		}
-		return printJSON(map[string]interface{}{
+		return printResult(map[string]interface{}{
 			"status":          "started",
 			"addr":            *addr,
 			"public_base_url": *publicBaseURL,
@@ -492,20 +1241,23 @@ func runDaemon(c *apiClient, args []string) error {
 		wait := fs.Duration("wait", 10*time.Second, "health-check timeout")
 		viaAPI := fs.Bool("via-api", true, "use daemon API first, then fallback to local restart")
 		skipHealth := fs.Bool("skip-health-check", false, "skip /v1/health polling")
+		minInterval := fs.Duration("health-min-interval", healthPollMinInterval, "first pause between health checks; doubles after each failed check")
+		maxInterval := fs.Duration("health-max-interval", healthPollMaxInterval, "longest pause between health checks")
+		detach := fs.Bool("detach", true, "run daemon in background; use --detach=false to stay in foreground")
 		if err := fs.Parse(args[1:]); err != nil {
 			return err
 		}
-		if *viaAPI {
+		if *viaAPI && *detach {
 			payload := map[string]string{"start_cmd": strings.TrimSpace(*startCmd)}
 			var out map[string]interface{}
 			if err := c.post("/v1/daemon/restart", payload, &out); err == nil {
 				if !*skipHealth {
-					if err := waitForHealth(*addr, *wait); err != nil {
+					if err := waitForHealth(*addr, *wait, *minInterval, *maxInterval, c.verbose); err != nil {
 						return err
 					}
 				}
 				out["mode"] = "api"
-				return printJSON(out)
+				return printResult(out)
 			}
 		}
 		port, err := portFromAddr(*addr)
@@ -516,15 +1268,18 @@ func runDaemon(c *apiClient, args []string) error {
 		if err != nil {
 			return err
 		}
+		if !*detach {
+			return runDaemonForeground(daemonCommand(*startCmd, *addr, *publicBaseURL))
+		}
 		if err := startDaemonProcess(*startCmd, *addr, *publicBaseURL); err != nil {
 			return err
 		}
 		if !*skipHealth {
-			if err := waitForHealth(*addr, *wait); err != nil {
+			if err := waitForHealth(*addr, *wait, *minInterval, *maxInterval, c.verbose); err != nil {
 				return err
 			}
 		}
-		return printJSON(map[string]interface{}{
+		return printResult(map[string]interface{}{
 			"status":          "restarted",
 			"addr":            *addr,
 			"public_base_url": *publicBaseURL,
@@ -621,16 +1376,47 @@ func stopDaemonByPort(port int) ([]int, error) {
 	return pids, nil
 }
 
-func startDaemonProcess(startCmd, addr, publicBaseURL string) error {
+func startDaemonProcess(startCmd, addr, publicBaseURL string, extraArgs ...string) error {
+	return daemonCommand(startCmd, addr, publicBaseURL, extraArgs...).Start()
+}
+
+func daemonCommand(startCmd, addr, publicBaseURL string, extraArgs ...string) *exec.Cmd {
 	if strings.TrimSpace(startCmd) != "" {
-		cmd := exec.Command("cmd", "/C", startCmd)
-		return cmd.Start()
+		return shellCommand(startCmd)
 	}
-	cmd := exec.Command("go", "run", "./cmd/switchlyd", "--addr", addr, "--public-base-url", publicBaseURL)
-	return cmd.Start()
+	args := append([]string{"run", "./cmd/switchlyd", "--addr", addr, "--public-base-url", publicBaseURL}, extraArgs...)
+	return exec.Command("go", args...)
 }
 
-func waitForHealth(addr string, timeout time.Duration) error {
+func runDaemonForeground(cmd *exec.Cmd) error {
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+	for {
+		select {
+		case err := <-done:
+			return err
+		case sig := <-sigCh:
+			// Interrupt is not deliverable to child processes on Windows.
+			if err := cmd.Process.Signal(sig); err != nil {
+				_ = cmd.Process.Kill()
+			}
+		}
+	}
+}
+
+func waitForHealth(addr string, timeout, minInterval, maxInterval time.Duration, verbose bool) error {
 	if timeout <= 0 {
 		timeout = 5 * time.Second
 	}
@@ -639,11 +1425,35 @@ func waitForHealth(addr string, timeout time.Duration) error {
 		_, p, _ := strings.Cut(host, ":")
 		host = "127.0.0.1:" + p
 	}
-	healthURL := "http://" + host + "/v1/health"
 	client := &http.Client{Timeout: 1200 * time.Millisecond}
+	if verbose {
+		client.Transport = newLoggingTransport(nil)
+	}
+	return pollHealth(client, "http://"+host+"/v1/health", timeout, minInterval, maxInterval)
+}
+
+// healthPollMinInterval and healthPollMaxInterval bound the backoff between
+// health checks; tests shorten them.
+var (
+	healthPollMinInterval = 100 * time.Millisecond
+	healthPollMaxInterval = 2 * time.Second
+)
+
+// pollHealth checks healthURL until it answers 2xx or timeout passes. The
+// pause starts at minInterval and doubles up to maxInterval, so a daemon
+// that is up quickly is noticed quickly and a slow one isn't flooded. One
+// last check is made at the deadline.
+func pollHealth(client *http.Client, healthURL string, timeout, minInterval, maxInterval time.Duration) error {
+	if minInterval <= 0 {
+		minInterval = healthPollMinInterval
+	}
+	if maxInterval < minInterval {
+		maxInterval = minInterval
+	}
 	deadline := time.Now().Add(timeout)
+	interval := minInterval
 	var lastErr error
-	for time.Now().Before(deadline) {
+	for {
 		resp, err := client.Get(healthURL)
 		if err == nil {
 			_ = resp.Body.Close()
@@ -654,14 +1464,75 @@ func waitForHealth(addr string, timeout time.Duration) error {
 		} else {
 			lastErr = err
 		}
-		time.Sleep(400 * time.Millisecond)
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			break
+		}
+		time.Sleep(min(interval, remaining))
+		interval = min(interval*2, maxInterval)
 	}
 	return fmt.Errorf("daemon did not become healthy in %s: %v", timeout.String(), lastErr)
 }
 
+// waitForDaemonIfRequested handles the global --wait-for-daemon flag by
+// polling the daemon's health through the configured client (socket, TLS)
+// before the command runs. Commands that start, stop or don't need the
+// daemon are not delayed.
+func waitForDaemonIfRequested(c *apiClient, raw string, args []string) error {
+	if strings.TrimSpace(raw) == "" {
+		return nil
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(raw))
+	if err != nil {
+		return fmt.Errorf("invalid --wait-for-daemon %q: %w", raw, err)
+	}
+	if timeout <= 0 || !commandNeedsDaemon(args) {
+		return nil
+	}
+	return pollHealth(c.http, c.baseURL+"/v1/health", timeout, healthPollMinInterval, healthPollMaxInterval)
+}
+
+func commandNeedsDaemon(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	switch args[0] {
+	case "profile", "config":
+		return false
+	case "daemon":
+		return len(args) > 1 && (args[1] == "info" || args[1] == "logs")
+	}
+	return true
+}
+
 type apiClient struct {
-	baseURL string
-	http    *http.Client
+	baseURL  string
+	apiToken string
+	http     *http.Client
+	verbose  bool
+
+	// lastRequestID is the X-Request-Id of the most recent response.
+	lastRequestID string
+}
+
+func newAPIClient(baseURL, apiToken, socketPath string, timeout time.Duration, tlsConfig *tls.Config) *apiClient {
+	httpClient := &http.Client{Timeout: timeout}
+	if tlsConfig != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = tlsConfig
+		httpClient.Transport = transport
+	}
+	if socketPath != "" {
+		// The host part of the URL is ignored when dialing the socket.
+		baseURL = "http://switchly"
+		httpClient.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", socketPath)
+			},
+		}
+	}
+	return &apiClient{baseURL: baseURL, apiToken: apiToken, http: httpClient}
 }
 
 func (c *apiClient) get(path string, out interface{}) error {
@@ -676,6 +1547,10 @@ func (c *apiClient) patch(path string, payload interface{}, out interface{}) err
 	return c.do(http.MethodPatch, path, payload, out)
 }
 
+func (c *apiClient) put(path string, payload interface{}, out interface{}) error {
+	return c.do(http.MethodPut, path, payload, out)
+}
+
 func (c *apiClient) delete(path string, out interface{}) error {
 	return c.do(http.MethodDelete, path, nil, out)
 }
@@ -695,16 +1570,22 @@ func (c *apiClient) do(method, path string, payload interface{}, out interface{}
 		return err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if c.apiToken != "" {
+		req.Header.Set("Authorization", "Bearer "+c.apiToken)
+	}
 
 	resp, err := c.http.Do(req)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
+	c.lastRequestID = resp.Header.Get("X-Request-Id")
 
 	if resp.StatusCode < 200 || resp.StatusCode > 299 {
 		raw, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("http %d: %s", resp.StatusCode, strings.TrimSpace(string(raw)))
+		apiErr := parseAPIError(resp.StatusCode, raw)
+		c.suggestAccount(apiErr)
+		return apiErr
 	}
 	if out == nil {
 		return nil
@@ -713,26 +1594,89 @@ func (c *apiClient) do(method, path string, payload interface{}, out interface{}
 }
 
 func printUsage() {
-	fmt.Println("switchly commands:")
-	fmt.Println("  status")
-	fmt.Println("  account add --id <id> --provider codex --access-token <token> [--refresh-token <token>] [--email <email>]")
-	fmt.Println("  account list")
-	fmt.Println("  account use --id <id>")
-	fmt.Println("  account delete --id <id>")
+	fmt.Println("switchly [--profile <name>] [--base-url <url>] [--timeout 15s] [--output json|table|csv] [--socket <path>] [--tls-ca-cert <file>] [--tls-client-cert <file> --tls-client-key <file>] [--api-token <token>] [--wait-for-daemon <duration>] [--verbose|-v] commands:")
+	fmt.Println("  status [--health]")
+	fmt.Println("  events")
+	fmt.Println("  account add --id <id> --provider codex --access-token <token> [--refresh-token <token>] [--email <email>] [--weight <n>]")
+	fmt.Println("  account add --from-local-file [--id <id>] [--email <email>]")
+	fmt.Println("  account list [--status ready] [--provider codex] [--session-gt 50] [--weekly-gt 80] [--limit 10] [--offset 0]")
+	fmt.Println("  account get --id <id> [--verbose]")
+	fmt.Println("  account use --id <id> [--force]")
+	fmt.Println("  account delete --id <id> [--yes]")
+	fmt.Println("  account enable --id <id>")
+	fmt.Println("  account disable --id <id>")
+	fmt.Println("  account weight --id <id> --value <n>")
+	fmt.Println("  account alias --id <id> --name <alias>")
+	fmt.Println("  account stats --id <id>")
+	fmt.Println("  account update --id <id> [--email <email>] [--alias <alias>]")
+	fmt.Println("  account annotate --id <id> --notes <text>")
+	fmt.Println("  account meta --id <id> [--set key=value] [--unset key]")
+	fmt.Println("  account set-proxy --id <id> --proxy <url> [--timeout <seconds>] [--insecure-skip-verify]")
+	fmt.Println("  account set-threshold --id <id> [--session-warn 70] [--weekly-warn 90]")
+	fmt.Println("  account pin --id <id>")
+	fmt.Println("  account unpin --id <id>")
+	fmt.Println("  account refresh --id <id>")
+	fmt.Println("  account test --id <id>")
 	fmt.Println("  account apply [--id <id>]")
+	fmt.Println("  account rotate [--id <id>]")
 	fmt.Println("  account import-codex [--overwrite-existing=true]")
+	fmt.Println("  account import-batch --file accounts.json")
+	fmt.Println("  account import-env")
+	fmt.Println("  account import-copilot")
+	fmt.Println("  account export --out accounts.bundle [--passphrase <text>]")
+	fmt.Println("  account import-bundle --file accounts.bundle [--passphrase <text>]")
 	fmt.Println("  quota sync [--id <id>]")
-	fmt.Println("  quota sync-all")
-	fmt.Println("  strategy set --value round-robin|fill-first")
-	fmt.Println("  switch simulate-error --status 429 --message \"quota exceeded\"")
+	fmt.Println("  quota sync-all [--providers codex,google]")
+	fmt.Println("  quota sync-local [--id <id>]")
+	fmt.Println("  quota sync-warmup [--id <id>]")
+	fmt.Println("  quota warmup")
+	fmt.Println("  quota watch [--interval 30s] [--id <id>] [--count N] [--sync]")
+	fmt.Println("  quota history --id <id> [--limit 48]")
+	fmt.Println("  quota wait [--timeout 2h]")
+	fmt.Println("  strategy get")
+	fmt.Println("  strategy set --value round-robin|fill-first|least-quota|weighted-round-robin|priority")
+	fmt.Println("  strategy priority [--accounts a,b,c]")
+	fmt.Println("  switch simulate-error --status 429 --message \"quota exceeded\" [--dry-run]")
+	fmt.Println("  switch reset-cooldown")
+	fmt.Println("  switch history [--limit 20] [--cursor <next_cursor>]")
+	fmt.Println("  switch rules list")
+	fmt.Println("  switch rules add [--pattern <text>] [--status <code>]")
 	fmt.Println("  oauth providers")
+	fmt.Println("  oauth providers add --name <name> --client-id <id> --auth-url <url> --token-url <url> [--redirect-uri <url>] [--scopes a,b] [--param key=value]")
+	fmt.Println("  oauth providers remove --name <name>")
 	fmt.Println("  oauth start --provider codex [--open=true]")
 	fmt.Println("  oauth status --state <state>")
+	fmt.Println("  oauth cancel --state <state>")
+	fmt.Println("  oauth sessions [--status pending]")
 	fmt.Println("  oauth login --provider codex [--method browser|device] [--timeout=3m]")
-	fmt.Println("  daemon info")
+	fmt.Println("  secrets backend")
+	fmt.Println("  secrets migrate --to <store> [--from <store>]")
+	fmt.Println("  daemon info [--runtime]")
+	fmt.Println("  daemon logs [--follow] [--lines 50]")
 	fmt.Println("  daemon stop [--addr 127.0.0.1:7777] [--via-api=true]")
-	fmt.Println("  daemon start [--addr 127.0.0.1:7777] [--public-base-url http://localhost:7777]")
-	fmt.Println("  daemon restart [--addr 127.0.0.1:7777] [--public-base-url http://localhost:7777] [--via-api=true]")
+	fmt.Println("  daemon start [--addr 127.0.0.1:7777] [--public-base-url http://localhost:7777] [--no-gitignore] [--metrics-addr 127.0.0.1:9477] [--socket-path <path>] [--api-token <token>] [--notify=false] [--detach=true]")
+	fmt.Println("  daemon systemd-unit [--user=true] [--output <path>] [--install] [--addr 127.0.0.1:7777] [--start-cmd <cmd>]")
+	fmt.Println("  daemon restart [--addr 127.0.0.1:7777] [--public-base-url http://localhost:7777] [--via-api=true] [--detach=true]")
+	fmt.Println("  config show")
+	fmt.Println("  profile create --name <name> --base-url http://127.0.0.1:7778 [--api-token <token>] [--socket-path <path>]")
+	fmt.Println("  profile list")
+	fmt.Println("  profile delete --name <name>")
+}
+
+var confirmInput io.Reader = os.Stdin
+
+func confirm(prompt string) (bool, error) {
+	fmt.Fprintf(os.Stderr, "%s [y/N]: ", prompt)
+	line, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	switch strings.ToLower(strings.TrimSpace(line)) {
+	case "y", "yes":
+		return true, nil
+	default:
+		return false, nil
+	}
 }
 
 func printJSON(v interface{}) error {
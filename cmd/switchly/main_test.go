@@ -3,13 +3,22 @@ package main
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"switchly/internal/platform"
 )
 
 func TestRunAccountImportCodexNoCandidate(t *testing.T) {
@@ -168,7 +177,7 @@ func TestRunAccountDelete(t *testing.T) {
 	}
 
 	out := captureStdout(t, func() {
-		if err := runAccount(client, []string{"delete", "--id", "acc-9"}); err != nil {
+		if err := runAccount(client, []string{"delete", "--id", "acc-9", "--yes"}); err != nil {
 			t.Fatalf("runAccount delete: %v", err)
 		}
 	})
@@ -181,19 +190,188 @@ func TestRunAccountDelete(t *testing.T) {
 	}
 }
 
+func TestRunAccountUpdateSendsOnlyGivenFields(t *testing.T) {
+	var gotBody map[string]any
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.Method != http.MethodPatch || r.URL.Path != "/v1/accounts/acc-9" {
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+				if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				return jsonResponse(http.StatusOK, map[string]any{"id": "acc-9", "email": "new@example.com"}), nil
+			}),
+		},
+	}
+
+	captureStdout(t, func() {
+		if err := runAccount(client, []string{"update", "--id", "acc-9", "--email", "new@example.com"}); err != nil {
+			t.Fatalf("runAccount update: %v", err)
+		}
+	})
+	if len(gotBody) != 1 || gotBody["email"] != "new@example.com" {
+		t.Fatalf("expected only email in the patch body, got %v", gotBody)
+	}
+
+	if err := runAccount(client, []string{"update", "--id", "acc-9"}); err == nil {
+		t.Fatal("expected an error when no fields are given")
+	}
+}
+
+func TestRunAccountMetaSendsSetAndUnset(t *testing.T) {
+	var gotBody map[string]any
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.Method != http.MethodPatch || r.URL.Path != "/v1/accounts/acc-9/meta" {
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+				if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				return jsonResponse(http.StatusOK, map[string]any{"id": "acc-9"}), nil
+			}),
+		},
+	}
+
+	captureStdout(t, func() {
+		if err := runAccount(client, []string{"meta", "--id", "acc-9", "--set", "org=acme", "--set", "region=eu=west", "--unset", "legacy"}); err != nil {
+			t.Fatalf("runAccount meta: %v", err)
+		}
+	})
+	if len(gotBody) != 3 || gotBody["org"] != "acme" || gotBody["region"] != "eu=west" || gotBody["legacy"] != "" {
+		t.Fatalf("unexpected meta patch body: %v", gotBody)
+	}
+
+	if err := runAccount(client, []string{"meta", "--id", "acc-9"}); err == nil {
+		t.Fatal("expected an error when no keys are given")
+	}
+}
+
+func writeCodexAuthFile(t *testing.T, accessToken string) {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	dir := filepath.Join(home, ".codex")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	doc := map[string]any{"tokens": map[string]any{
+		"access_token":  accessToken,
+		"refresh_token": "file-refresh",
+		"account_id":    "acct-file",
+	}}
+	data, _ := json.Marshal(doc)
+	if err := os.WriteFile(filepath.Join(dir, "auth.json"), data, 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+}
+
+func TestRunAccountAddFromLocalFile(t *testing.T) {
+	var gotBody map[string]any
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/accounts" {
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+				if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				return jsonResponse(http.StatusOK, map[string]any{"id": gotBody["id"]}), nil
+			}),
+		},
+	}
+
+	writeCodexAuthFile(t, "file-access")
+	captureStdout(t, func() {
+		if err := runAccount(client, []string{"add", "--from-local-file", "--id", "work", "--email", "me@example.com"}); err != nil {
+			t.Fatalf("runAccount add: %v", err)
+		}
+	})
+	if gotBody["id"] != "work" || gotBody["email"] != "me@example.com" {
+		t.Fatalf("expected flags to override the file, got %v", gotBody)
+	}
+	if gotBody["access_token"] != "file-access" || gotBody["refresh_token"] != "file-refresh" || gotBody["account_id"] != "acct-file" {
+		t.Fatalf("expected tokens from the file, got %v", gotBody)
+	}
+
+	writeCodexAuthFile(t, "")
+	if err := runAccount(client, []string{"add", "--from-local-file", "--id", "work"}); err == nil || !strings.Contains(err.Error(), "access_token") {
+		t.Fatalf("expected an empty-token error, got %v", err)
+	}
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	if err := runAccount(client, []string{"add", "--from-local-file", "--id", "work"}); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected a missing-file error, got %v", err)
+	}
+
+	if err := runAccount(client, []string{"add", "--id", "work"}); err == nil || err.Error() != "--access-token is required" {
+		t.Fatalf("expected the access token error, got %v", err)
+	}
+}
+
+func TestRunAccountDeleteAbortsWithoutConfirmation(t *testing.T) {
+	deleteCalls := 0
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				deleteCalls++
+				return jsonResponse(http.StatusOK, map[string]any{}), nil
+			}),
+		},
+	}
+	origInput := confirmInput
+	confirmInput = strings.NewReader("n\n")
+	defer func() {
+		confirmInput = origInput
+	}()
+
+	err := runAccount(client, []string{"delete", "--id", "acc-9"})
+	if err == nil || !strings.Contains(err.Error(), "aborted") {
+		t.Fatalf("expected abort error, got %v", err)
+	}
+	if deleteCalls != 0 {
+		t.Fatalf("expected no delete calls, got %d", deleteCalls)
+	}
+}
+
 func captureStdout(t *testing.T, fn func()) string {
 	t.Helper()
+	return captureFile(t, &os.Stdout, fn)
+}
 
-	origStdout := os.Stdout
+func captureStderr(t *testing.T, fn func()) string {
+	t.Helper()
+	return captureFile(t, &os.Stderr, fn)
+}
+
+func captureFile(t *testing.T, target **os.File, fn func()) string {
+	t.Helper()
+
+	orig := *target
 	r, w, err := os.Pipe()
 	if err != nil {
 		t.Fatalf("create pipe: %v", err)
 	}
 	defer r.Close()
 
-	os.Stdout = w
+	*target = w
 	defer func() {
-		os.Stdout = origStdout
+		*target = orig
 	}()
 
 	done := make(chan string, 1)
@@ -222,3 +400,719 @@ func jsonResponse(status int, body map[string]any) *http.Response {
 		Body:       io.NopCloser(bytes.NewReader(raw)),
 	}
 }
+
+func newAccountListClient() *apiClient {
+	return &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				switch r.URL.Path {
+				case "/v1/accounts":
+					return jsonResponse(http.StatusOK, map[string]any{
+						"accounts": []map[string]any{{
+							"id":       "acc-1",
+							"alias":    "work",
+							"provider": "codex",
+							"status":   "ready",
+							"notes":    "production team account, shared by the whole group; do not delete",
+							"quota": map[string]any{
+								"session": map[string]any{"used_percent": 42},
+								"weekly":  map[string]any{"used_percent": 7},
+							},
+						}, {
+							"id":       "acc-2",
+							"provider": "codex",
+							"status":   "ready",
+							"quota": map[string]any{
+								"session":           map[string]any{"used_percent": 0},
+								"weekly":            map[string]any{"used_percent": 15},
+								"session_supported": false,
+							},
+						}},
+					}), nil
+				case "/v1/status":
+					return jsonResponse(http.StatusOK, map[string]any{
+						"active_account_id": "acc-1",
+						"strategy":          "round-robin",
+						"accounts":          []map[string]any{{"id": "acc-1", "alias": "work", "status": "ready"}},
+					}), nil
+				default:
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+			}),
+		},
+	}
+}
+
+func TestRunAccountListOutputFormats(t *testing.T) {
+	defer func() { outputFormat = outputJSON }()
+	client := newAccountListClient()
+
+	outputFormat = outputTable
+	out := captureStdout(t, func() {
+		if err := runAccount(client, []string{"list"}); err != nil {
+			t.Fatalf("account list: %v", err)
+		}
+	})
+	for _, header := range []string{"ID", "ALIAS", "PROVIDER", "STATUS", "SESSION%", "WEEKLY%", "ACCESS EXPIRY", "LAST APPLIED", "NOTES"} {
+		if !strings.Contains(out, header) {
+			t.Fatalf("expected table header %q, got:\n%s", header, out)
+		}
+	}
+	if !strings.Contains(out, "acc-1") || !strings.Contains(out, "work") || !strings.Contains(out, "42") {
+		t.Fatalf("expected account row, got:\n%s", out)
+	}
+	if !strings.Contains(out, "production team account, shared by th...") || strings.Contains(out, "do not delete") {
+		t.Fatalf("expected notes cut to 40 characters, got:\n%s", out)
+	}
+
+	outputFormat = outputCSV
+	out = captureStdout(t, func() {
+		if err := runAccount(client, []string{"list"}); err != nil {
+			t.Fatalf("account list: %v", err)
+		}
+	})
+	if !strings.HasPrefix(out, "ID,ALIAS,PROVIDER,STATUS,SESSION%,WEEKLY%,ACCESS EXPIRY,LAST APPLIED,NOTES\n") {
+		t.Fatalf("unexpected csv header, got:\n%s", out)
+	}
+	if !strings.Contains(out, "acc-1,work,codex,ready,42,7,-,-,\"production team account, shared by the whole group; do not delete\"") || !strings.Contains(out, "acc-2,,codex,ready,N/A,15,-,-,\n") {
+		t.Fatalf("unexpected csv row, got:\n%s", out)
+	}
+}
+
+func TestRunStatusTableSummary(t *testing.T) {
+	defer func() { outputFormat = outputJSON }()
+	outputFormat = outputTable
+
+	out := captureStdout(t, func() {
+		if err := runStatus(newAccountListClient(), nil); err != nil {
+			t.Fatalf("status: %v", err)
+		}
+	})
+	if strings.TrimSpace(out) != "active=acc-1 alias=work strategy=round-robin accounts=1 ready=1" {
+		t.Fatalf("unexpected status summary: %q", out)
+	}
+}
+
+func TestExtractGlobalFlags(t *testing.T) {
+	flags, args, err := extractGlobalFlags([]string{"-o", "table", "--profile=dev", "account", "list"})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if flags.output != outputTable || flags.profile != "dev" || strings.Join(args, " ") != "account list" {
+		t.Fatalf("unexpected result: %#v %v", flags, args)
+	}
+
+	flags, args, err = extractGlobalFlags([]string{"--timeout=3s", "--base-url", "http://x", "status", "--output", "csv"})
+	if err != nil {
+		t.Fatalf("extract: %v", err)
+	}
+	if flags.timeout != "3s" || flags.baseURL != "http://x" || flags.output != "" || strings.Join(args, " ") != "status --output csv" {
+		t.Fatalf("unexpected result: %#v %v", flags, args)
+	}
+}
+
+func TestReadCodexAuthFileUsesCodexDir(t *testing.T) {
+	codexDir := t.TempDir()
+	t.Setenv(platform.CodexDirEnv, codexDir)
+
+	_, err := readCodexAuthFile()
+	if err == nil || !strings.Contains(err.Error(), filepath.Join(codexDir, "auth.json")) {
+		t.Fatalf("expected the error to name the CODEX_DIR auth file, got %v", err)
+	}
+
+	auth := `{"tokens":{"access_token":"token-a","account_id":"acct-1"}}`
+	if err := os.WriteFile(filepath.Join(codexDir, "auth.json"), []byte(auth), 0o600); err != nil {
+		t.Fatalf("write auth file: %v", err)
+	}
+	account, err := readCodexAuthFile()
+	if err != nil {
+		t.Fatalf("read auth file: %v", err)
+	}
+	if account.Secrets.AccessToken != "token-a" || account.Secrets.AccountID != "acct-1" {
+		t.Fatalf("unexpected account: %+v", account)
+	}
+}
+
+func TestWaitForDaemonRetriesUntilHealthy(t *testing.T) {
+	flags, args, err := extractGlobalFlags([]string{"--wait-for-daemon", "5s", "account", "list"})
+	if err != nil || flags.waitForDaemon != "5s" || strings.Join(args, " ") != "account list" {
+		t.Fatalf("unexpected result: %#v %v (%v)", flags, args, err)
+	}
+
+	prevMin, prevMax := healthPollMinInterval, healthPollMaxInterval
+	healthPollMinInterval, healthPollMaxInterval = time.Millisecond, time.Millisecond
+	defer func() { healthPollMinInterval, healthPollMaxInterval = prevMin, prevMax }()
+
+	healthCalls := 0
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			switch r.URL.Path {
+			case "/v1/health":
+				healthCalls++
+				if healthCalls <= 2 {
+					return nil, errors.New("connect: connection refused")
+				}
+				return jsonResponse(http.StatusOK, map[string]any{"status": "ok"}), nil
+			case "/v1/status":
+				if healthCalls < 3 {
+					t.Fatal("status requested before the daemon was healthy")
+				}
+				return jsonResponse(http.StatusOK, map[string]any{"active_account_id": "acc-1"}), nil
+			}
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+			return nil, nil
+		})},
+	}
+
+	if err := waitForDaemonIfRequested(client, flags.waitForDaemon, []string{"status"}); err != nil {
+		t.Fatalf("wait for daemon: %v", err)
+	}
+	if healthCalls != 3 {
+		t.Fatalf("expected 3 health checks, got %d", healthCalls)
+	}
+	captureStdout(t, func() {
+		err = runStatus(client, nil)
+	})
+	if err != nil {
+		t.Fatalf("status after wait: %v", err)
+	}
+
+	// Commands that don't talk to a running daemon are not delayed.
+	if err := waitForDaemonIfRequested(client, "5s", []string{"daemon", "start"}); err != nil || healthCalls != 3 {
+		t.Fatalf("expected daemon start to skip the wait, got %v after %d checks", err, healthCalls)
+	}
+	if err := waitForDaemonIfRequested(client, "soon", []string{"status"}); err == nil {
+		t.Fatal("expected an invalid duration to fail")
+	}
+}
+
+func TestVerboseLogsHTTPToStderr(t *testing.T) {
+	flags, args, err := extractGlobalFlags([]string{"-v", "--base-url", "http://x", "status"})
+	if err != nil || !flags.verbose || strings.Join(args, " ") != "status" {
+		t.Fatalf("unexpected result: %#v %v (%v)", flags, args, err)
+	}
+
+	client := (&apiClient{
+		baseURL:  "http://switchly.local",
+		apiToken: "secret-token",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, map[string]any{"status": "ok", "padding": strings.Repeat("x", 5000)}), nil
+		})},
+	}).WithVerbose(true)
+
+	var out map[string]any
+	stderr := captureStderr(t, func() {
+		if err := client.post("/v1/accounts", map[string]string{"id": "acc-1"}, &out); err != nil {
+			t.Fatalf("post: %v", err)
+		}
+	})
+	for _, want := range []string{"> POST http://switchly.local/v1/accounts", "> Authorization: [REDACTED]", `> {"id":"acc-1"}`, "< 200", `< {"padding":"xxx`, "bytes truncated"} {
+		if !strings.Contains(stderr, want) {
+			t.Fatalf("expected %q in verbose output:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, "secret-token") {
+		t.Fatalf("verbose output leaked the api token:\n%s", stderr)
+	}
+	if out["status"] != "ok" {
+		t.Fatalf("response body was not passed through: %#v", out)
+	}
+}
+
+func TestVerboseRedactsSecretFields(t *testing.T) {
+	client := (&apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return jsonResponse(http.StatusOK, map[string]any{
+				"accounts": []map[string]any{{"account": map[string]string{"id": "acc-1"}, "secrets": map[string]string{"access_token": "resp-access", "refresh_token": "resp-refresh"}}},
+			}), nil
+		})},
+	}).WithVerbose(true)
+
+	stderr := captureStderr(t, func() {
+		var out map[string]any
+		body := map[string]any{"id": "acc-1", "access_token": "req-access", "refresh_token": "req-refresh", "id_token": "req-id", "passphrase": "hunter22"}
+		if err := client.post("/v1/accounts", body, &out); err != nil {
+			t.Fatalf("post: %v", err)
+		}
+	})
+	for _, secret := range []string{"req-access", "req-refresh", "req-id", "hunter22", "resp-access", "resp-refresh"} {
+		if strings.Contains(stderr, secret) {
+			t.Fatalf("verbose output leaked %q:\n%s", secret, stderr)
+		}
+	}
+	for _, want := range []string{`"access_token":"[REDACTED]"`, `"passphrase":"[REDACTED]"`, `"id":"acc-1"`} {
+		if !strings.Contains(stderr, want) {
+			t.Fatalf("expected %s in verbose output:\n%s", want, stderr)
+		}
+	}
+}
+
+func TestWaitForHealthBacksOff(t *testing.T) {
+	var calls atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	start := time.Now()
+	if err := waitForHealth(addr, 5*time.Second, 10*time.Millisecond, 25*time.Millisecond, false); err != nil {
+		t.Fatalf("wait for health: %v", err)
+	}
+	// Three failed checks are followed by pauses of 10ms, 20ms and 25ms
+	// (capped), so the fourth check succeeds with no extra requests.
+	if got := calls.Load(); got != 4 {
+		t.Fatalf("expected 4 health checks, got %d", got)
+	}
+	if elapsed := time.Since(start); elapsed < 55*time.Millisecond {
+		t.Fatalf("expected the pauses to grow, finished in %s", elapsed)
+	}
+
+	var downCalls atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downCalls.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	err := waitForHealth(strings.TrimPrefix(down.URL, "http://"), 100*time.Millisecond, 10*time.Millisecond, 40*time.Millisecond, false)
+	if err == nil || !strings.Contains(err.Error(), "status 503") {
+		t.Fatalf("expected a timeout naming the last status, got %v", err)
+	}
+	// Checks at 0, 10, 30, 70 and the deadline at 100ms; a fixed 10ms poll
+	// would have made about ten.
+	if got := downCalls.Load(); got > 6 {
+		t.Fatalf("expected backoff to limit checks before the timeout, got %d", got)
+	}
+}
+
+func TestWaitForHealthVerbose(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+	addr := strings.TrimPrefix(srv.URL, "http://")
+
+	stderr := captureStderr(t, func() {
+		if err := waitForHealth(addr, time.Second, time.Millisecond, time.Millisecond, true); err != nil {
+			t.Fatalf("wait for health: %v", err)
+		}
+	})
+	if !strings.Contains(stderr, "> GET http://"+addr+"/v1/health") || !strings.Contains(stderr, `< {"status":"ok"}`) {
+		t.Fatalf("expected the health check in verbose output:\n%s", stderr)
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got := sparkline([]int{0, 14, 50, 100, 150, -5}); got != "▁▁▄██▁" {
+		t.Fatalf("unexpected sparkline: %q", got)
+	}
+}
+
+func TestReadBatchImportFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+		return path
+	}
+
+	for _, path := range []string{
+		write("wrapped.json", `{"accounts":[{"id":"acc-1"}]}`),
+		write("bare.json", ` [{"id":"acc-1"}]`),
+	} {
+		payload, err := readBatchImportFile(path)
+		if err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		if got := string(payload["accounts"]); got != `[{"id":"acc-1"}]` {
+			t.Fatalf("%s: unexpected accounts payload %s", path, got)
+		}
+	}
+
+	if _, err := readBatchImportFile(write("missing.json", `{"items":[]}`)); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Fatalf("expected missing accounts error, got %v", err)
+	}
+}
+
+func TestAccountGetVerbosePrintsRequestID(t *testing.T) {
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				resp := jsonResponse(http.StatusOK, map[string]any{"id": "acc-1"})
+				resp.Header.Set("X-Request-Id", "req-42")
+				return resp, nil
+			}),
+		},
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = w
+	captureStdout(t, func() {
+		if err := runAccount(client, []string{"get", "--id", "acc-1", "-v"}); err != nil {
+			t.Errorf("account get: %v", err)
+		}
+	})
+	os.Stderr = stderr
+	_ = w.Close()
+	got, _ := io.ReadAll(r)
+	if strings.TrimSpace(string(got)) != "request_id: req-42" {
+		t.Fatalf("unexpected stderr: %q", got)
+	}
+}
+
+func TestRunOAuthLoginDeviceImportsLocalAuthFile(t *testing.T) {
+	origCommand := codexDeviceLoginCommand
+	defer func() { codexDeviceLoginCommand = origCommand }()
+
+	var gotBody map[string]any
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.Method != http.MethodPost || r.URL.Path != "/v1/accounts" {
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+				if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+					t.Fatalf("decode body: %v", err)
+				}
+				return jsonResponse(http.StatusOK, map[string]any{"id": gotBody["id"]}), nil
+			}),
+		},
+	}
+
+	writeCodexAuthFile(t, "device-access")
+	// Re-running the test binary with no tests selected stands in for a
+	// successful `codex login --device-auth`.
+	codexDeviceLoginCommand = func() *exec.Cmd {
+		return exec.Command(os.Args[0], "-test.run=^$")
+	}
+	captureStdout(t, func() {
+		if err := runOAuthLoginDevice(client, "codex"); err != nil {
+			t.Fatalf("device login: %v", err)
+		}
+	})
+	if gotBody["id"] != "codex:acct-file" || gotBody["access_token"] != "device-access" {
+		t.Fatalf("expected the account from the auth file, got %v", gotBody)
+	}
+
+	gotBody = nil
+	codexDeviceLoginCommand = func() *exec.Cmd {
+		return exec.Command(filepath.Join(t.TempDir(), "missing-codex"))
+	}
+	if err := runOAuthLoginDevice(client, "codex"); err == nil || !strings.Contains(err.Error(), "codex device auth failed") {
+		t.Fatalf("expected the login failure to be reported, got %v", err)
+	}
+	if gotBody != nil {
+		t.Fatalf("expected no account to be added after a failed login, got %v", gotBody)
+	}
+}
+
+func TestAPIErrorSuggestsClosestAccount(t *testing.T) {
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path == "/v1/accounts" {
+				return jsonResponse(http.StatusOK, map[string]any{"accounts": []map[string]any{
+					{"id": "codex:alice@example.com"},
+					{"id": "codex:bob@example.com"},
+				}}), nil
+			}
+			resp := jsonResponse(http.StatusNotFound, map[string]any{"error": "account alice not found", "code": "account_not_found"})
+			resp.Header.Set("X-Request-Id", "req-7")
+			return resp, nil
+		})},
+	}
+
+	err := client.get("/v1/accounts/alice", nil)
+	var apiErr *apiError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an apiError, got %v", err)
+	}
+	if apiErr.Status != http.StatusNotFound || apiErr.Code != "account_not_found" {
+		t.Fatalf("unexpected error fields: %#v", apiErr)
+	}
+	if want := "http 404: account alice not found — did you mean 'codex:alice@example.com'?"; !strings.Contains(err.Error(), want) {
+		t.Fatalf("expected %q in %q", want, err.Error())
+	}
+	if client.lastRequestID != "req-7" {
+		t.Fatalf("expected the failed request's id to be kept, got %q", client.lastRequestID)
+	}
+}
+
+func TestParseAPIErrorKeepsNonJSONBody(t *testing.T) {
+	err := parseAPIError(http.StatusBadGateway, []byte("upstream down\n"))
+	if err.Error() != "http 502: upstream down" {
+		t.Fatalf("unexpected error: %q", err.Error())
+	}
+}
+
+func TestAccountTestReportsRejectedToken(t *testing.T) {
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.Method != http.MethodPost || r.URL.Path != "/v1/accounts/acc-1/test" {
+				t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			return jsonResponse(http.StatusOK, map[string]any{"account_id": "acc-1", "provider": "codex", "valid": false, "http_status": 401}), nil
+		})},
+	}
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runAccount(client, []string{"test", "--id", "acc-1"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Fatalf("expected a rejected-token error, got %v", err)
+	}
+	if !strings.Contains(out, "http_status") {
+		t.Fatalf("expected the result to be printed, got %q", out)
+	}
+}
+
+func TestOAuthLoginStopsWhenCancelled(t *testing.T) {
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			switch r.URL.Path {
+			case "/v1/oauth/start":
+				return jsonResponse(http.StatusCreated, map[string]any{"state": "st-1", "status": "pending", "auth_url": "https://auth.example.test"}), nil
+			case "/v1/oauth/status":
+				return jsonResponse(http.StatusOK, map[string]any{"state": "st-1", "status": "cancelled", "error": "oauth session cancelled"}), nil
+			}
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+			return nil, nil
+		})},
+	}
+
+	var err error
+	captureStdout(t, func() {
+		err = runOAuth(client, []string{"login", "--open=false", "--poll-interval", "1ms", "--timeout", "1s"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "cancelled") {
+		t.Fatalf("expected a cancelled login error, got %v", err)
+	}
+}
+
+func TestAPIClientDeleteAndPut(t *testing.T) {
+	type request struct {
+		method, path string
+		body         []byte
+	}
+	var got []request
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			var body []byte
+			if r.Body != nil {
+				body, _ = io.ReadAll(r.Body)
+			}
+			got = append(got, request{r.Method, r.URL.Path, body})
+			return jsonResponse(http.StatusOK, map[string]any{"status": "ok", "method": r.Method}), nil
+		})},
+	}
+
+	var out map[string]any
+	if err := client.delete("/v1/accounts/acc-1", &out); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if out["method"] != http.MethodDelete || out["status"] != "ok" {
+		t.Fatalf("expected the delete response to be decoded, got %v", out)
+	}
+	out = nil
+	if err := client.put("/v1/priority", map[string][]string{"priorities": {"acc-1"}}, &out); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if out["method"] != http.MethodPut {
+		t.Fatalf("expected the put response to be decoded, got %v", out)
+	}
+
+	if len(got) != 2 {
+		t.Fatalf("expected two requests, got %#v", got)
+	}
+	if got[0].method != http.MethodDelete || got[0].path != "/v1/accounts/acc-1" || len(got[0].body) != 0 {
+		t.Fatalf("unexpected delete request %#v", got[0])
+	}
+	if got[1].method != http.MethodPut || got[1].path != "/v1/priority" || string(got[1].body) != `{"priorities":["acc-1"]}` {
+		t.Fatalf("unexpected put request %#v", got[1])
+	}
+}
+
+func TestOAuthCancelDeletesSession(t *testing.T) {
+	var gotMethod, gotPath string
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			gotMethod, gotPath = r.Method, r.URL.Path
+			return jsonResponse(http.StatusOK, map[string]any{"status": "cancelled"}), nil
+		})},
+	}
+
+	captureStdout(t, func() {
+		if err := runOAuth(client, []string{"cancel", "--state", "st-1"}); err != nil {
+			t.Fatalf("cancel: %v", err)
+		}
+	})
+	if gotMethod != http.MethodDelete || gotPath != "/v1/oauth/sessions/st-1" {
+		t.Fatalf("unexpected request %s %s", gotMethod, gotPath)
+	}
+}
+
+func TestAccountRotateWarnsWhenApplyFails(t *testing.T) {
+	var paths []string
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			paths = append(paths, r.Method+" "+r.URL.Path)
+			switch r.URL.Path {
+			case "/v1/status":
+				return jsonResponse(http.StatusOK, map[string]any{"active_account_id": "acc-1"}), nil
+			case "/v1/accounts/acc-1/refresh":
+				return jsonResponse(http.StatusOK, map[string]any{"account_id": "acc-1", "access_expires_at": "2026-10-15T12:00:00Z"}), nil
+			case "/v1/accounts/acc-1/activate":
+				return jsonResponse(http.StatusBadRequest, map[string]any{"error": "auth file is read-only"}), nil
+			}
+			t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+			return nil, nil
+		})},
+	}
+
+	var err error
+	stderr := captureStderr(t, func() {
+		err = runAccount(client, []string{"rotate"})
+	})
+	if err == nil {
+		t.Fatal("expected the apply failure to be returned")
+	}
+	want := []string{"GET /v1/status", "POST /v1/accounts/acc-1/refresh", "POST /v1/accounts/acc-1/activate"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected requests %v", paths)
+	}
+	if !strings.Contains(stderr, "was refreshed") || !strings.Contains(stderr, "2026-10-15") {
+		t.Fatalf("expected a warning with the new expiry, got %q", stderr)
+	}
+}
+
+func TestAccountUseForce(t *testing.T) {
+	var gotBody map[string]any
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			if r.URL.Path != "/v1/accounts/acc-1/activate" {
+				t.Fatalf("unexpected request %s %s", r.Method, r.URL.Path)
+			}
+			if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+				t.Fatalf("decode body: %v", err)
+			}
+			return jsonResponse(http.StatusOK, map[string]any{"status": "ok", "account_id": "acc-1", "applier_error": "auth file is read-only"}), nil
+		})},
+	}
+
+	var stderr string
+	captureStdout(t, func() {
+		stderr = captureStderr(t, func() {
+			if err := runAccount(client, []string{"use", "--id", "acc-1", "--force"}); err != nil {
+				t.Fatalf("account use --force: %v", err)
+			}
+		})
+	})
+	if gotBody["force"] != true {
+		t.Fatalf("expected force in the request body, got %v", gotBody)
+	}
+	if !strings.Contains(stderr, "auth file is read-only") {
+		t.Fatalf("expected the applier error on stderr, got %q", stderr)
+	}
+}
+
+const daemonHelperEnv = "SWITCHLY_TEST_DAEMON_HELPER"
+
+// TestDaemonForegroundHelper is the child process the runDaemonForeground
+// tests start; it does nothing unless daemonHelperEnv is set.
+func TestDaemonForegroundHelper(t *testing.T) {
+	mode, ready, ok := strings.Cut(os.Getenv(daemonHelperEnv), ":")
+	if !ok {
+		return
+	}
+	switch mode {
+	case "exit":
+		os.Exit(7)
+	case "interrupt":
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt)
+		if err := os.WriteFile(ready, nil, 0o600); err != nil {
+			os.Exit(2)
+		}
+		select {
+		case <-sigCh:
+			os.Exit(3)
+		case <-time.After(10 * time.Second):
+			os.Exit(4)
+		}
+	}
+	os.Exit(2)
+}
+
+func daemonHelperCommand(mode, ready string) *exec.Cmd {
+	cmd := exec.Command(os.Args[0], "-test.run=^TestDaemonForegroundHelper$")
+	cmd.Env = append(os.Environ(), daemonHelperEnv+"="+mode+":"+ready)
+	return cmd
+}
+
+func TestRunDaemonForegroundPropagatesExitCode(t *testing.T) {
+	cmd := daemonHelperCommand("exit", "")
+	err := runDaemonForeground(cmd)
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 7 {
+		t.Fatalf("expected the child's exit code 7, got %v", err)
+	}
+	if cmd.ProcessState == nil || !cmd.ProcessState.Exited() {
+		t.Fatal("runDaemonForeground returned before the child exited")
+	}
+}
+
+func TestRunDaemonForegroundForwardsInterrupt(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("interrupt is not deliverable to child processes on Windows")
+	}
+	// Keep the interrupt sent below from killing the test binary.
+	guard := make(chan os.Signal, 1)
+	signal.Notify(guard, os.Interrupt)
+	defer signal.Stop(guard)
+
+	ready := filepath.Join(t.TempDir(), "ready")
+	go func() {
+		deadline := time.Now().Add(10 * time.Second)
+		for time.Now().Before(deadline) {
+			if _, err := os.Stat(ready); err == nil {
+				// Only this process is signalled; the child sees the
+				// interrupt only if runDaemonForeground forwards it.
+				self, _ := os.FindProcess(os.Getpid())
+				_ = self.Signal(os.Interrupt)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+
+	err := runDaemonForeground(daemonHelperCommand("interrupt", ready))
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("expected the child to exit 3 after the forwarded interrupt, got %v", err)
+	}
+}
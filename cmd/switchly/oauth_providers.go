@@ -0,0 +1,77 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// paramFlags collects repeated --param key=value flags.
+type paramFlags map[string]string
+
+func (p paramFlags) String() string { return "" }
+
+func (p paramFlags) Set(v string) error {
+	key, value, ok := strings.Cut(v, "=")
+	if !ok || strings.TrimSpace(key) == "" {
+		return fmt.Errorf("expected key=value, got %q", v)
+	}
+	p[strings.TrimSpace(key)] = value
+	return nil
+}
+
+func runOAuthProviders(c *apiClient, args []string) error {
+	switch args[0] {
+	case "add":
+		fs := flag.NewFlagSet("oauth providers add", flag.ContinueOnError)
+		name := fs.String("name", "", "provider name (lower-case letters and digits)")
+		clientID := fs.String("client-id", "", "oauth client id")
+		authURL := fs.String("auth-url", "", "https authorization endpoint")
+		tokenURL := fs.String("token-url", "", "https token endpoint")
+		redirectURI := fs.String("redirect-uri", "", "callback URL (default: the daemon's /auth/callback)")
+		scopes := fs.String("scopes", "", "comma-separated scopes")
+		params := paramFlags{}
+		fs.Var(params, "param", "extra authorization parameter key=value (repeatable)")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*name) == "" {
+			return fmt.Errorf("--name is required")
+		}
+		payload := map[string]interface{}{
+			"provider":     *name,
+			"client_id":    *clientID,
+			"auth_url":     *authURL,
+			"token_url":    *tokenURL,
+			"redirect_uri": *redirectURI,
+		}
+		if s := splitCSV(*scopes); len(s) > 0 {
+			payload["scopes"] = s
+		}
+		if len(params) > 0 {
+			payload["additional_params"] = map[string]string(params)
+		}
+		var out map[string]interface{}
+		if err := c.post("/v1/oauth/providers", payload, &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	case "remove":
+		fs := flag.NewFlagSet("oauth providers remove", flag.ContinueOnError)
+		name := fs.String("name", "", "provider name")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		if strings.TrimSpace(*name) == "" {
+			return fmt.Errorf("--name is required")
+		}
+		var out map[string]interface{}
+		if err := c.delete("/v1/oauth/providers/"+url.PathEscape(*name), &out); err != nil {
+			return err
+		}
+		return printResult(out)
+	default:
+		return fmt.Errorf("unknown oauth providers command: %s", args[0])
+	}
+}
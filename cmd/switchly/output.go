@@ -0,0 +1,428 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"switchly/internal/model"
+)
+
+const (
+	outputJSON  = "json"
+	outputTable = "table"
+	outputCSV   = "csv"
+)
+
+var outputFormat = outputJSON
+
+func validateOutputFormat(format string) error {
+	switch format {
+	case outputJSON, outputTable, outputCSV:
+		return nil
+	default:
+		return fmt.Errorf("invalid output format %q (want json, table, or csv)", format)
+	}
+}
+
+func extractOutputFlag(args []string) (string, []string, error) {
+	return extractLeadingFlag(args, "--output", "-output", "-o")
+}
+
+func extractSocketFlag(args []string) (string, []string, error) {
+	return extractLeadingFlag(args, "--socket", "-socket")
+}
+
+func extractLeadingFlag(args []string, names ...string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", args, nil
+	}
+	first := args[0]
+	for _, name := range names {
+		if first == name {
+			if len(args) < 2 {
+				return "", nil, fmt.Errorf("%s requires a value", name)
+			}
+			return args[1], args[2:], nil
+		}
+		if strings.HasPrefix(first, name+"=") {
+			return strings.TrimPrefix(first, name+"="), args[1:], nil
+		}
+	}
+	return "", args, nil
+}
+
+type globalFlags struct {
+	profile       string
+	output        string
+	socket        string
+	baseURL       string
+	timeout       string
+	tlsCACert     string
+	tlsClientCert string
+	tlsClientKey  string
+	apiToken      string
+	waitForDaemon string
+	verbose       bool
+}
+
+func extractGlobalFlags(args []string) (globalFlags, []string, error) {
+	var flags globalFlags
+	extractors := []struct {
+		extract func([]string) (string, []string, error)
+		target  *string
+	}{
+		{extract: extractProfileFlag, target: &flags.profile},
+		{extract: extractOutputFlag, target: &flags.output},
+		{extract: extractSocketFlag, target: &flags.socket},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--base-url", "-base-url")
+		}, target: &flags.baseURL},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--timeout", "-timeout")
+		}, target: &flags.timeout},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--tls-ca-cert", "-tls-ca-cert")
+		}, target: &flags.tlsCACert},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--tls-client-cert", "-tls-client-cert")
+		}, target: &flags.tlsClientCert},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--tls-client-key", "-tls-client-key")
+		}, target: &flags.tlsClientKey},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--api-token", "-api-token")
+		}, target: &flags.apiToken},
+		{extract: func(args []string) (string, []string, error) {
+			return extractLeadingFlag(args, "--wait-for-daemon", "-wait-for-daemon")
+		}, target: &flags.waitForDaemon},
+	}
+
+	for {
+		consumed := false
+		if len(args) > 0 && (args[0] == "--verbose" || args[0] == "-verbose" || args[0] == "-v") {
+			flags.verbose = true
+			args = args[1:]
+			consumed = true
+		}
+		for _, ex := range extractors {
+			value, rest, err := ex.extract(args)
+			if err != nil {
+				return globalFlags{}, nil, err
+			}
+			if len(rest) != len(args) {
+				*ex.target = value
+				args = rest
+				consumed = true
+			}
+		}
+		if !consumed {
+			return flags, args, nil
+		}
+	}
+}
+
+type tableWriter struct {
+	tw  *tabwriter.Writer
+	csv *csv.Writer
+}
+
+func newTableWriter(w io.Writer, format string) *tableWriter {
+	if format == outputCSV {
+		return &tableWriter{csv: csv.NewWriter(w)}
+	}
+	return &tableWriter{tw: tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)}
+}
+
+func (t *tableWriter) Row(cols ...string) error {
+	if t.csv != nil {
+		return t.csv.Write(cols)
+	}
+	_, err := fmt.Fprintln(t.tw, strings.Join(cols, "\t"))
+	return err
+}
+
+func (t *tableWriter) Flush() error {
+	if t.csv != nil {
+		t.csv.Flush()
+		return t.csv.Error()
+	}
+	return t.tw.Flush()
+}
+
+func printResult(v interface{}) error {
+	if outputFormat == outputJSON {
+		return printJSON(v)
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		// Not an object; there is no sensible tabular form.
+		return printJSON(v)
+	}
+
+	keys := make([]string, 0, len(fields))
+	for k := range fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tw := newTableWriter(os.Stdout, outputFormat)
+	if err := tw.Row("KEY", "VALUE"); err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := tw.Row(k, formatValue(fields[k])); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func formatValue(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case float64:
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, err := json.Marshal(val)
+		if err != nil {
+			return fmt.Sprint(val)
+		}
+		return string(data)
+	}
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatSessionPercent prints N/A for plans without a session window, where
+// a 0 would wrongly suggest unused quota.
+func formatSessionPercent(q model.QuotaSnapshot) string {
+	if q.SessionSupported != nil && !*q.SessionSupported {
+		return "N/A"
+	}
+	return strconv.Itoa(q.Session.UsedPercent)
+}
+
+func printAccounts(raw json.RawMessage) error {
+	if outputFormat == outputJSON {
+		var out map[string]interface{}
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return err
+		}
+		return printJSON(out)
+	}
+
+	var out struct {
+		Accounts []model.Account `json:"accounts"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return err
+	}
+	tw := newTableWriter(os.Stdout, outputFormat)
+	if err := tw.Row("ID", "ALIAS", "PROVIDER", "STATUS", "SESSION%", "WEEKLY%", "ACCESS EXPIRY", "LAST APPLIED", "NOTES"); err != nil {
+		return err
+	}
+	for _, acct := range out.Accounts {
+		notes := acct.Notes
+		if outputFormat == outputTable {
+			notes = truncateNotes(notes)
+		}
+		if err := tw.Row(
+			acct.ID,
+			acct.Alias,
+			acct.Provider,
+			string(acct.Status),
+			formatSessionPercent(acct.Quota),
+			strconv.Itoa(acct.Quota.Weekly.UsedPercent),
+			formatTime(acct.AccessExpiresAt),
+			formatTime(acct.LastAppliedAt),
+			notes,
+		); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+// notesColumnWidth caps notes in the account table; CSV keeps them whole.
+const notesColumnWidth = 40
+
+// truncateNotes fits notes on one table line.
+func truncateNotes(notes string) string {
+	notes = strings.Join(strings.Fields(notes), " ")
+	runes := []rune(notes)
+	if len(runes) <= notesColumnWidth {
+		return notes
+	}
+	return string(runes[:notesColumnWidth-3]) + "..."
+}
+
+func printOAuthSessions(raw json.RawMessage) error {
+	if outputFormat == outputJSON {
+		var out map[string]interface{}
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return err
+		}
+		return printJSON(out)
+	}
+
+	var out struct {
+		Sessions []oauthSession `json:"sessions"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return err
+	}
+	tw := newTableWriter(os.Stdout, outputFormat)
+	if err := tw.Row("STATE", "PROVIDER", "STATUS", "ACCOUNT", "EXPIRES", "ERROR"); err != nil {
+		return err
+	}
+	for _, sess := range out.Sessions {
+		if err := tw.Row(sess.State, sess.Provider, sess.Status, sess.AccountID, sess.ExpiresAt, sess.Error); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
+
+func printStatus(raw json.RawMessage) error {
+	if outputFormat == outputJSON {
+		var out map[string]interface{}
+		if err := json.Unmarshal(raw, &out); err != nil {
+			return err
+		}
+		return printJSON(out)
+	}
+
+	var out struct {
+		ActiveAccountID string          `json:"active_account_id"`
+		Strategy        string          `json:"strategy"`
+		Accounts        []model.Account `json:"accounts"`
+		LastError       string          `json:"last_error"`
+		Warnings        []struct {
+			AccountID string `json:"account_id"`
+			Type      string `json:"type"`
+			Value     int    `json:"value"`
+		} `json:"warnings"`
+	}
+	if err := json.Unmarshal(raw, &out); err != nil {
+		return err
+	}
+	ready := 0
+	for _, acct := range out.Accounts {
+		// Accounts with a quota warning are still routed to.
+		if acct.Status == model.AccountReady || acct.Status == model.AccountWarning {
+			ready++
+		}
+	}
+	active := out.ActiveAccountID
+	if active == "" {
+		active = "-"
+	}
+	var alias string
+	for _, acct := range out.Accounts {
+		if acct.ID == out.ActiveAccountID {
+			alias = acct.Alias
+		}
+	}
+
+	if outputFormat == outputCSV {
+		tw := newTableWriter(os.Stdout, outputCSV)
+		if err := tw.Row("active", "alias", "strategy", "accounts", "ready", "last_error"); err != nil {
+			return err
+		}
+		if err := tw.Row(active, alias, out.Strategy, strconv.Itoa(len(out.Accounts)), strconv.Itoa(ready), out.LastError); err != nil {
+			return err
+		}
+		return tw.Flush()
+	}
+
+	line := fmt.Sprintf("active=%s", active)
+	if alias != "" {
+		line += fmt.Sprintf(" alias=%s", alias)
+	}
+	line += fmt.Sprintf(" strategy=%s accounts=%d ready=%d", out.Strategy, len(out.Accounts), ready)
+	if out.LastError != "" {
+		line += fmt.Sprintf(" last_error=%q", out.LastError)
+	}
+	if _, err := fmt.Fprintln(os.Stdout, line); err != nil {
+		return err
+	}
+	for _, w := range out.Warnings {
+		if _, err := fmt.Fprintf(os.Stdout, "warning: %s %s at %d%%\n", w.AccountID, w.Type, w.Value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+var sparkTicks = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline maps percentages (0-100) onto block characters.
+func sparkline(values []int) string {
+	var b strings.Builder
+	for _, v := range values {
+		if v < 0 {
+			v = 0
+		}
+		if v > 100 {
+			v = 100
+		}
+		b.WriteRune(sparkTicks[v*(len(sparkTicks)-1)/100])
+	}
+	return b.String()
+}
+
+func printQuotaHistory(accountID string, history []model.QuotaSnapshot) error {
+	if outputFormat == outputCSV {
+		tw := newTableWriter(os.Stdout, outputCSV)
+		if err := tw.Row("last_updated", "session_percent", "weekly_percent", "limit_reached"); err != nil {
+			return err
+		}
+		for _, snap := range history {
+			if err := tw.Row(
+				formatTime(snap.LastUpdated),
+				strconv.Itoa(snap.Session.UsedPercent),
+				strconv.Itoa(snap.Weekly.UsedPercent),
+				strconv.FormatBool(snap.LimitReached),
+			); err != nil {
+				return err
+			}
+		}
+		return tw.Flush()
+	}
+
+	if len(history) == 0 {
+		fmt.Printf("%s: no quota history\n", accountID)
+		return nil
+	}
+	session := make([]int, 0, len(history))
+	for _, snap := range history {
+		session = append(session, snap.Session.UsedPercent)
+	}
+	first, last := history[0], history[len(history)-1]
+	fmt.Printf("%s session %s %d%%\n", accountID, sparkline(session), last.Session.UsedPercent)
+	fmt.Printf("%d snapshots from %s to %s\n", len(history), formatTime(first.LastUpdated), formatTime(last.LastUpdated))
+	return nil
+}
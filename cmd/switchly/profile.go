@@ -0,0 +1,211 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"switchly/internal/platform"
+)
+
+const defaultProfileName = "default"
+
+var profileNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+type cliProfile struct {
+	BaseURL    string `json:"base_url"`
+	APIToken   string `json:"api_token,omitempty"`
+	SocketPath string `json:"socket_path,omitempty"`
+}
+
+func profilesDir() (string, error) {
+	dir, err := platform.ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "profiles"), nil
+}
+
+func validateProfileName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("profile name is required")
+	}
+	if !profileNamePattern.MatchString(name) || name == "." || name == ".." {
+		return fmt.Errorf("invalid profile name: %q", name)
+	}
+	return nil
+}
+
+func loadProfile(dir, name string) (cliProfile, error) {
+	if err := validateProfileName(name); err != nil {
+		return cliProfile{}, err
+	}
+	data, err := os.ReadFile(filepath.Join(dir, name+".json"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cliProfile{}, fmt.Errorf("profile %q not found", name)
+		}
+		return cliProfile{}, err
+	}
+	var out cliProfile
+	if err := json.Unmarshal(data, &out); err != nil {
+		return cliProfile{}, fmt.Errorf("decode profile %q: %w", name, err)
+	}
+	if strings.TrimSpace(out.BaseURL) == "" {
+		return cliProfile{}, fmt.Errorf("profile %q is missing base_url", name)
+	}
+	return out, nil
+}
+
+// selectProfile picks the profile named by --profile, then SWITCHLY_PROFILE.
+// Without either, the "default" profile is used if it exists; explicit
+// reports whether the name was asked for, in which case it must exist.
+func selectProfile(flagValue string, getenv func(string) string) (name string, explicit bool) {
+	if v := strings.TrimSpace(flagValue); v != "" {
+		return v, true
+	}
+	if v := strings.TrimSpace(getenv("SWITCHLY_PROFILE")); v != "" {
+		return v, true
+	}
+	return defaultProfileName, false
+}
+
+func listProfiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || validateProfileName(name) != nil {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+func deleteProfile(dir, name string) error {
+	if err := validateProfileName(name); err != nil {
+		return err
+	}
+	if err := os.Remove(filepath.Join(dir, name+".json")); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("profile %q not found", name)
+		}
+		return err
+	}
+	return nil
+}
+
+func saveProfile(dir, name string, profile cliProfile) (string, error) {
+	if err := validateProfileName(name); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(profile, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, name+".json")
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+func extractProfileFlag(args []string) (string, []string, error) {
+	if len(args) == 0 {
+		return "", args, nil
+	}
+	first := args[0]
+	switch {
+	case first == "--profile" || first == "-profile":
+		if len(args) < 2 {
+			return "", nil, fmt.Errorf("--profile requires a value")
+		}
+		return args[1], args[2:], nil
+	case strings.HasPrefix(first, "--profile="):
+		return strings.TrimPrefix(first, "--profile="), args[1:], nil
+	case strings.HasPrefix(first, "-profile="):
+		return strings.TrimPrefix(first, "-profile="), args[1:], nil
+	}
+	return "", args, nil
+}
+
+func runProfile(args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing profile command")
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("profile create", flag.ContinueOnError)
+		name := fs.String("name", "", "profile name")
+		baseURL := fs.String("base-url", defaultBaseURL, "daemon base url")
+		apiToken := fs.String("api-token", "", "daemon api token")
+		socketPath := fs.String("socket-path", "", "daemon unix socket path")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		dir, err := profilesDir()
+		if err != nil {
+			return err
+		}
+		path, err := saveProfile(dir, strings.TrimSpace(*name), cliProfile{
+			BaseURL:    strings.TrimRight(strings.TrimSpace(*baseURL), "/"),
+			APIToken:   strings.TrimSpace(*apiToken),
+			SocketPath: strings.TrimSpace(*socketPath),
+		})
+		if err != nil {
+			return err
+		}
+		return printResult(map[string]interface{}{
+			"status": "created",
+			"name":   strings.TrimSpace(*name),
+			"path":   path,
+		})
+	case "list":
+		dir, err := profilesDir()
+		if err != nil {
+			return err
+		}
+		names, err := listProfiles(dir)
+		if err != nil {
+			return err
+		}
+		return printResult(map[string]interface{}{"profiles": names})
+	case "delete":
+		fs := flag.NewFlagSet("profile delete", flag.ContinueOnError)
+		name := fs.String("name", "", "profile name")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		dir, err := profilesDir()
+		if err != nil {
+			return err
+		}
+		if err := deleteProfile(dir, strings.TrimSpace(*name)); err != nil {
+			return err
+		}
+		return printResult(map[string]interface{}{
+			"status": "deleted",
+			"name":   strings.TrimSpace(*name),
+		})
+	default:
+		return fmt.Errorf("unknown profile command: %s", args[0])
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractProfileFlag(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		wantProfile string
+		wantArgs    []string
+		wantErr     bool
+	}{
+		{name: "no profile", args: []string{"status"}, wantArgs: []string{"status"}},
+		{name: "separate value", args: []string{"--profile", "dev", "status"}, wantProfile: "dev", wantArgs: []string{"status"}},
+		{name: "inline value", args: []string{"--profile=prod", "account", "list"}, wantProfile: "prod", wantArgs: []string{"account", "list"}},
+		{name: "missing value", args: []string{"--profile"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			profile, args, err := extractProfileFlag(tt.args)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if profile != tt.wantProfile || strings.Join(args, " ") != strings.Join(tt.wantArgs, " ") {
+				t.Fatalf("unexpected result: profile=%q args=%v", profile, args)
+			}
+		})
+	}
+}
+
+func TestSaveAndLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := saveProfile(dir, "dev", cliProfile{BaseURL: "http://127.0.0.1:7778", APIToken: "secret"}); err != nil {
+		t.Fatalf("save profile: %v", err)
+	}
+
+	got, err := loadProfile(dir, "dev")
+	if err != nil {
+		t.Fatalf("load profile: %v", err)
+	}
+	if got.BaseURL != "http://127.0.0.1:7778" || got.APIToken != "secret" {
+		t.Fatalf("unexpected profile: %#v", got)
+	}
+
+	if _, err := loadProfile(dir, "missing"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if _, err := saveProfile(dir, "../escape", cliProfile{BaseURL: "http://x"}); err == nil {
+		t.Fatal("expected invalid profile name error")
+	}
+}
+
+func TestAPIClientSendsProfileToken(t *testing.T) {
+	var gotAuth string
+	client := &apiClient{
+		baseURL:  "http://switchly.local",
+		apiToken: "secret",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				gotAuth = r.Header.Get("Authorization")
+				return jsonResponse(http.StatusOK, map[string]any{"status": "ok"}), nil
+			}),
+		},
+	}
+
+	var out map[string]any
+	if err := client.get("/v1/health", &out); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if gotAuth != "Bearer secret" {
+		t.Fatalf("unexpected authorization header: %q", gotAuth)
+	}
+}
+
+func TestApplyProfile(t *testing.T) {
+	dir := t.TempDir()
+	if _, err := saveProfile(dir, "prod", cliProfile{BaseURL: "http://prod:7777", APIToken: "prod-token", SocketPath: "/run/prod.sock"}); err != nil {
+		t.Fatalf("save prod: %v", err)
+	}
+	noEnv := func(string) string { return "" }
+	file := cliConfig{BaseURL: "http://file:7777"}
+
+	flags, gotFile, name, err := applyProfile(dir, noEnv, globalFlags{profile: "prod", apiToken: "flag-token"}, file)
+	if err != nil {
+		t.Fatalf("apply prod: %v", err)
+	}
+	if name != "prod" || flags.baseURL != "http://prod:7777" || flags.socket != "/run/prod.sock" || flags.apiToken != "flag-token" {
+		t.Fatalf("unexpected flags from explicit profile: %+v name=%q", flags, name)
+	}
+	if gotFile != file {
+		t.Fatalf("explicit profile should not touch file settings: %+v", gotFile)
+	}
+
+	env := func(key string) string {
+		if key == "SWITCHLY_PROFILE" {
+			return "prod"
+		}
+		return ""
+	}
+	if flags, _, name, err = applyProfile(dir, env, globalFlags{}, file); err != nil || name != "prod" || flags.baseURL != "http://prod:7777" {
+		t.Fatalf("expected SWITCHLY_PROFILE to select prod, got %+v name=%q err=%v", flags, name, err)
+	}
+
+	if _, _, name, err = applyProfile(dir, noEnv, globalFlags{}, file); err != nil || name != "" {
+		t.Fatalf("expected no profile without a default, got name=%q err=%v", name, err)
+	}
+	if _, err := saveProfile(dir, defaultProfileName, cliProfile{BaseURL: "http://default:7777"}); err != nil {
+		t.Fatalf("save default: %v", err)
+	}
+	flags, gotFile, name, err = applyProfile(dir, noEnv, globalFlags{}, file)
+	if err != nil || name != defaultProfileName {
+		t.Fatalf("expected default profile, got name=%q err=%v", name, err)
+	}
+	if flags.baseURL != "" || gotFile.BaseURL != "http://default:7777" {
+		t.Fatalf("default profile should override the file only: flags=%+v file=%+v", flags, gotFile)
+	}
+
+	if _, _, _, err := applyProfile(dir, noEnv, globalFlags{profile: "staging"}, file); err == nil || !strings.Contains(err.Error(), `profile "staging" not found`) {
+		t.Fatalf("expected a clear missing profile error, got %v", err)
+	}
+}
+
+func TestListAndDeleteProfiles(t *testing.T) {
+	dir := t.TempDir()
+	if names, err := listProfiles(dir + "/missing"); err != nil || len(names) != 0 {
+		t.Fatalf("expected no profiles for a missing dir, got %v err=%v", names, err)
+	}
+	for _, name := range []string{"prod", "dev"} {
+		if _, err := saveProfile(dir, name, cliProfile{BaseURL: "http://" + name}); err != nil {
+			t.Fatalf("save %s: %v", name, err)
+		}
+	}
+	names, err := listProfiles(dir)
+	if err != nil || strings.Join(names, ",") != "dev,prod" {
+		t.Fatalf("unexpected profiles: %v err=%v", names, err)
+	}
+	if err := deleteProfile(dir, "prod"); err != nil {
+		t.Fatalf("delete prod: %v", err)
+	}
+	if err := deleteProfile(dir, "prod"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Fatalf("expected not found error, got %v", err)
+	}
+	if names, _ := listProfiles(dir); strings.Join(names, ",") != "dev" {
+		t.Fatalf("unexpected profiles after delete: %v", names)
+	}
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"time"
+
+	"golang.org/x/term"
+)
+
+type quotaWaitOptions struct {
+	timeout time.Duration
+	tick    time.Duration
+	tty     bool
+}
+
+type quotaNextReset struct {
+	EarliestResetAt time.Time `json:"earliest_reset_at"`
+	AccountID       string    `json:"account_id"`
+	Window          string    `json:"window"`
+}
+
+func runQuotaWait(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("quota wait", flag.ContinueOnError)
+	timeout := fs.Duration("timeout", 2*time.Hour, "give up if no quota resets within this long")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *timeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return waitForQuotaReset(ctx, c, os.Stdout, quotaWaitOptions{
+		timeout: *timeout,
+		tick:    time.Second,
+		tty:     term.IsTerminal(int(os.Stdout.Fd())),
+	})
+}
+
+func waitForQuotaReset(ctx context.Context, c *apiClient, out io.Writer, opts quotaWaitOptions) error {
+	var next quotaNextReset
+	if err := c.get("/v1/quota/next-reset", &next); err != nil {
+		return err
+	}
+	// The reset time is fixed, so a reset beyond the timeout can fail now
+	// instead of after waiting the whole timeout out.
+	remaining := time.Until(next.EarliestResetAt)
+	if remaining > opts.timeout {
+		return fmt.Errorf("next quota reset (%s, account %s) is after the %s timeout", formatTime(next.EarliestResetAt), next.AccountID, opts.timeout)
+	}
+
+	fmt.Fprintf(out, "waiting for %s quota of %s to reset at %s\n", next.Window, next.AccountID, formatTime(next.EarliestResetAt))
+	reset := time.After(remaining)
+	ticker := time.NewTicker(opts.tick)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			if opts.tty {
+				fmt.Fprintln(out)
+			}
+			return errors.New("quota wait interrupted")
+		case <-reset:
+			if opts.tty {
+				fmt.Fprint(out, "\r\033[K")
+			}
+			fmt.Fprintf(out, "quota reset reached for %s\n", next.AccountID)
+			return nil
+		case <-ticker.C:
+			if opts.tty {
+				left := time.Until(next.EarliestResetAt).Round(time.Second)
+				fmt.Fprintf(out, "\r\033[Kresets in %s", left)
+			}
+		}
+	}
+}
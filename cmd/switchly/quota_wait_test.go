@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newQuotaNextResetClient(resetAt time.Time) *apiClient {
+	return &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				if r.URL.Path != "/v1/quota/next-reset" {
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+				return jsonResponse(http.StatusOK, map[string]any{
+					"earliest_reset_at": resetAt.Format(time.RFC3339Nano),
+					"account_id":        "B",
+					"window":            "session",
+				}), nil
+			}),
+		},
+	}
+}
+
+func TestWaitForQuotaResetReturnsWhenResetReached(t *testing.T) {
+	client := newQuotaNextResetClient(time.Now().Add(50 * time.Millisecond))
+	var out bytes.Buffer
+	err := waitForQuotaReset(context.Background(), client, &out, quotaWaitOptions{timeout: time.Second, tick: 10 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("waitForQuotaReset: %v", err)
+	}
+	if !strings.Contains(out.String(), "quota reset reached for B") {
+		t.Fatalf("unexpected output: %q", out.String())
+	}
+}
+
+func TestWaitForQuotaResetTimesOut(t *testing.T) {
+	client := newQuotaNextResetClient(time.Now().Add(time.Hour))
+	var out bytes.Buffer
+	err := waitForQuotaReset(context.Background(), client, &out, quotaWaitOptions{timeout: time.Minute, tick: time.Second})
+	if err == nil || !strings.Contains(err.Error(), "after the 1m0s timeout") {
+		t.Fatalf("expected a timeout error, got %v", err)
+	}
+}
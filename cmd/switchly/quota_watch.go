@@ -0,0 +1,175 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"golang.org/x/term"
+
+	"switchly/internal/model"
+)
+
+const (
+	ansiClearScreen = "\033[H\033[2J"
+	ansiHideCursor  = "\033[?25l"
+	ansiShowCursor  = "\033[?25h"
+	ansiRed         = "\033[31m"
+	ansiReset       = "\033[0m"
+
+	quotaWatchHighlightPercent = 80
+)
+
+type quotaWatchOptions struct {
+	interval  time.Duration
+	accountID string
+	count     int
+	sync      bool
+	tty       bool
+}
+
+func runQuotaWatch(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("quota watch", flag.ContinueOnError)
+	interval := fs.Duration("interval", 30*time.Second, "poll interval")
+	accountID := fs.String("id", "", "only show this account")
+	count := fs.Int("count", 0, "exit after N refreshes (0 = until interrupted)")
+	sync := fs.Bool("sync", false, "sync quota from the provider before each refresh")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *interval <= 0 {
+		return fmt.Errorf("--interval must be positive")
+	}
+	if *count < 0 {
+		return fmt.Errorf("--count must not be negative")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	return watchQuota(ctx, c, os.Stdout, quotaWatchOptions{
+		interval:  *interval,
+		accountID: strings.TrimSpace(*accountID),
+		count:     *count,
+		sync:      *sync,
+		tty:       term.IsTerminal(int(os.Stdout.Fd())),
+	})
+}
+
+func watchQuota(ctx context.Context, c *apiClient, out io.Writer, opts quotaWatchOptions) error {
+	if opts.tty {
+		fmt.Fprint(out, ansiHideCursor)
+		defer fmt.Fprint(out, ansiShowCursor)
+	}
+
+	ticker := time.NewTicker(opts.interval)
+	defer ticker.Stop()
+
+	for iteration := 1; ; iteration++ {
+		var frame bytes.Buffer
+		if err := fetchQuotaFrame(c, &frame, opts); err != nil {
+			return err
+		}
+		if opts.tty {
+			fmt.Fprint(out, ansiClearScreen)
+		} else if iteration > 1 {
+			fmt.Fprintln(out)
+		}
+		if _, err := out.Write(frame.Bytes()); err != nil {
+			return err
+		}
+
+		if opts.count > 0 && iteration >= opts.count {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func fetchQuotaFrame(c *apiClient, out io.Writer, opts quotaWatchOptions) error {
+	if opts.sync {
+		var ignored map[string]interface{}
+		var err error
+		if opts.accountID != "" {
+			err = c.post("/v1/quota/sync", map[string]string{"account_id": opts.accountID}, &ignored)
+		} else {
+			err = c.post("/v1/quota/sync-all", map[string]string{}, &ignored)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var status struct {
+		ActiveAccountID string          `json:"active_account_id"`
+		Accounts        []model.Account `json:"accounts"`
+	}
+	if err := c.get("/v1/status", &status); err != nil {
+		return err
+	}
+
+	accounts := status.Accounts
+	if opts.accountID != "" {
+		accounts = nil
+		for _, acct := range status.Accounts {
+			if acct.ID == opts.accountID {
+				accounts = append(accounts, acct)
+			}
+		}
+		if len(accounts) == 0 {
+			return fmt.Errorf("account %s not found", opts.accountID)
+		}
+	}
+	fmt.Fprintf(out, "quota at %s (every %s)\n\n", time.Now().Format(time.TimeOnly), opts.interval)
+	return renderQuotaTable(out, status.ActiveAccountID, accounts, opts.tty)
+}
+
+// renderQuotaTable aligns the table first and colours whole lines afterwards,
+// since escape sequences inside cells would skew tabwriter's column widths.
+func renderQuotaTable(out io.Writer, activeID string, accounts []model.Account, color bool) error {
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSESSION%\tWEEKLY%\tLIMIT REACHED\tACCESS EXPIRY")
+	hot := make([]bool, 0, len(accounts))
+	for _, acct := range accounts {
+		id := acct.ID
+		if acct.ID == activeID {
+			id += " *"
+		}
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%s\n",
+			id,
+			acct.Quota.Session.UsedPercent,
+			acct.Quota.Weekly.UsedPercent,
+			strconv.FormatBool(acct.Quota.LimitReached),
+			formatTime(acct.AccessExpiresAt),
+		)
+		hot = append(hot, acct.Quota.Session.UsedPercent > quotaWatchHighlightPercent ||
+			acct.Quota.Weekly.UsedPercent > quotaWatchHighlightPercent)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	lines := strings.SplitAfter(buf.String(), "\n")
+	for i, line := range lines {
+		if color && i > 0 && i <= len(hot) && hot[i-1] {
+			line = ansiRed + strings.TrimSuffix(line, "\n") + ansiReset + "\n"
+		}
+		if _, err := io.WriteString(out, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
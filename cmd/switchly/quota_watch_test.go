@@ -0,0 +1,92 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newQuotaWatchClient(statusCalls, syncCalls *int) *apiClient {
+	return &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v1/status":
+					*statusCalls++
+					return jsonResponse(http.StatusOK, map[string]any{
+						"active_account_id": "acc-1",
+						"accounts": []map[string]any{
+							{"id": "acc-1", "quota": map[string]any{"session": map[string]any{"used_percent": 91}}},
+							{"id": "acc-2", "quota": map[string]any{"weekly": map[string]any{"used_percent": 12}}},
+						},
+					}), nil
+				case r.Method == http.MethodPost && r.URL.Path == "/v1/quota/sync-all":
+					*syncCalls++
+					return jsonResponse(http.StatusOK, map[string]any{"total": 2}), nil
+				default:
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+			}),
+		},
+	}
+}
+
+func TestWatchQuotaStopsAfterCount(t *testing.T) {
+	statusCalls, syncCalls := 0, 0
+	client := newQuotaWatchClient(&statusCalls, &syncCalls)
+
+	var out bytes.Buffer
+	err := watchQuota(context.Background(), client, &out, quotaWatchOptions{
+		interval: time.Millisecond,
+		count:    3,
+		sync:     true,
+	})
+	if err != nil {
+		t.Fatalf("watchQuota error: %v", err)
+	}
+	if statusCalls != 3 || syncCalls != 3 {
+		t.Fatalf("expected 3 status and sync calls, got %d and %d", statusCalls, syncCalls)
+	}
+	if strings.Contains(out.String(), "\033[") {
+		t.Fatalf("expected plain output without a tty, got %q", out.String())
+	}
+	if got := strings.Count(out.String(), "ID  "); got != 3 {
+		t.Fatalf("expected 3 tables, got %d:\n%s", got, out.String())
+	}
+}
+
+func TestWatchQuotaFiltersAccountAndHighlightsOnTTY(t *testing.T) {
+	statusCalls, syncCalls := 0, 0
+	client := newQuotaWatchClient(&statusCalls, &syncCalls)
+
+	var out bytes.Buffer
+	err := watchQuota(context.Background(), client, &out, quotaWatchOptions{
+		interval:  time.Millisecond,
+		accountID: "acc-1",
+		count:     1,
+		tty:       true,
+	})
+	if err != nil {
+		t.Fatalf("watchQuota error: %v", err)
+	}
+	text := out.String()
+	if !strings.HasPrefix(text, ansiHideCursor+ansiClearScreen) || !strings.HasSuffix(text, ansiShowCursor) {
+		t.Fatalf("expected screen clear and cursor restore, got %q", text)
+	}
+	if !strings.Contains(text, ansiRed+"acc-1 *") {
+		t.Fatalf("expected hot active row in red, got %q", text)
+	}
+	if strings.Contains(text, "acc-2") || syncCalls != 0 {
+		t.Fatalf("unexpected output or sync calls (%d): %q", syncCalls, text)
+	}
+
+	err = watchQuota(context.Background(), client, &out, quotaWatchOptions{interval: time.Millisecond, accountID: "missing", count: 1})
+	if err == nil || !strings.Contains(err.Error(), "missing not found") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
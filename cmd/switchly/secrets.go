@@ -0,0 +1,108 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+)
+
+type secretsBackendInfo struct {
+	Backend   string   `json:"backend"`
+	Available []string `json:"available"`
+}
+
+type secretsMigrationResult struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Migrated []string          `json:"migrated"`
+	Failed   map[string]string `json:"failed,omitempty"`
+}
+
+func runSecrets(c *apiClient, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("missing secrets command")
+	}
+	switch args[0] {
+	case "backend":
+		var info secretsBackendInfo
+		if err := c.get("/v1/secrets/backend", &info); err != nil {
+			return err
+		}
+		return printResult(map[string]string{"backend": info.Backend, "available": strings.Join(info.Available, ",")})
+	case "migrate":
+		return runSecretsMigrate(c, args[1:])
+	default:
+		return fmt.Errorf("unknown secrets command: %s", args[0])
+	}
+}
+
+func runSecretsMigrate(c *apiClient, args []string) error {
+	fs := flag.NewFlagSet("secrets migrate", flag.ContinueOnError)
+	from := fs.String("from", "", "store to copy secrets from (default: the daemon's current store)")
+	to := fs.String("to", "", "store to copy secrets to")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if strings.TrimSpace(*to) == "" {
+		return fmt.Errorf("--to is required")
+	}
+
+	var info secretsBackendInfo
+	if err := c.get("/v1/secrets/backend", &info); err != nil {
+		return err
+	}
+	if *from == "" {
+		*from = info.Backend
+	}
+	for _, name := range []string{*from, *to} {
+		if !slices.Contains(info.Available, name) {
+			return fmt.Errorf("secret store %q is not available on the daemon's platform (available: %s)", name, strings.Join(info.Available, ", "))
+		}
+	}
+
+	var result secretsMigrationResult
+	if err := c.post("/v1/secrets/migrate", map[string]string{"from": *from, "to": *to}, &result); err != nil {
+		return err
+	}
+	if err := printMigrationResult(result); err != nil {
+		return err
+	}
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%d account(s) could not be migrated", len(result.Failed))
+	}
+	return nil
+}
+
+func printMigrationResult(result secretsMigrationResult) error {
+	if outputFormat == outputJSON {
+		return printJSON(result)
+	}
+	tw := newTableWriter(os.Stdout, outputFormat)
+	if err := tw.Row("ACCOUNT", "RESULT"); err != nil {
+		return err
+	}
+	for _, id := range result.Migrated {
+		if err := tw.Row(id, "migrated"); err != nil {
+			return err
+		}
+	}
+	failed := make([]string, 0, len(result.Failed))
+	for id := range result.Failed {
+		failed = append(failed, id)
+	}
+	slices.Sort(failed)
+	for _, id := range failed {
+		if err := tw.Row(id, "failed: "+result.Failed[id]); err != nil {
+			return err
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if outputFormat == outputTable {
+		fmt.Printf("migrated %d of %d account(s) from %s to %s\n", len(result.Migrated), len(result.Migrated)+len(result.Failed), result.From, result.To)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunSecretsMigrateDefaultsToCurrentStore(t *testing.T) {
+	var gotBody map[string]string
+	client := &apiClient{
+		baseURL: "http://switchly.local",
+		http: &http.Client{
+			Timeout: time.Second,
+			Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/v1/secrets/backend":
+					return jsonResponse(http.StatusOK, map[string]any{"backend": "file", "available": []string{"file", "secret-service"}}), nil
+				case r.Method == http.MethodPost && r.URL.Path == "/v1/secrets/migrate":
+					if err := json.NewDecoder(r.Body).Decode(&gotBody); err != nil {
+						t.Fatalf("decode body: %v", err)
+					}
+					return jsonResponse(http.StatusOK, map[string]any{
+						"from":     "file",
+						"to":       "secret-service",
+						"migrated": []string{"acc-1"},
+						"failed":   map[string]string{"acc-2": "read: not found"},
+					}), nil
+				default:
+					return jsonResponse(http.StatusNotFound, map[string]any{"error": "not found"}), nil
+				}
+			}),
+		},
+	}
+
+	prev := outputFormat
+	outputFormat = outputTable
+	defer func() { outputFormat = prev }()
+
+	var err error
+	out := captureStdout(t, func() {
+		err = runSecrets(client, []string{"migrate", "--to", "secret-service"})
+	})
+	if err == nil || !strings.Contains(err.Error(), "1 account(s) could not be migrated") {
+		t.Fatalf("expected the failed account to be reported, got %v", err)
+	}
+	if gotBody["from"] != "file" || gotBody["to"] != "secret-service" {
+		t.Fatalf("unexpected migrate request: %v", gotBody)
+	}
+	if !strings.Contains(out, "acc-1") || !strings.Contains(out, "failed: read: not found") || !strings.Contains(out, "migrated 1 of 2 account(s) from file to secret-service") {
+		t.Fatalf("expected a per-account summary, got: %s", out)
+	}
+
+	if err := runSecrets(client, []string{"migrate", "--to", "dpapi"}); err == nil || !strings.Contains(err.Error(), "not available") {
+		t.Fatalf("expected an unavailable store to be rejected before migrating, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package main
+
+import "os/exec"
+
+func shellCommand(cmd string) *exec.Cmd {
+	return exec.Command("sh", "-c", cmd)
+}
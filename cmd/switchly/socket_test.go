@@ -0,0 +1,150 @@
+//go:build !windows
+
+package main
+
+import (
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+	"switchly/internal/server"
+)
+
+type memoryStateStore struct {
+	mu    sync.Mutex
+	state model.AppState
+}
+
+func (s *memoryStateStore) Load() (model.AppState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := s.state
+	out.Accounts = make(map[string]model.Account, len(s.state.Accounts))
+	for id, acct := range s.state.Accounts {
+		out.Accounts[id] = acct
+	}
+	return out, nil
+}
+
+func (s *memoryStateStore) Save(state model.AppState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.state = state
+	return nil
+}
+
+type memorySecretStore struct {
+	mu      sync.Mutex
+	entries map[string]model.AuthSecrets
+}
+
+func (s *memorySecretStore) Put(id string, sec model.AuthSecrets) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = sec
+	return nil
+}
+
+func (s *memorySecretStore) Get(id string) (model.AuthSecrets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sec, ok := s.entries[id]
+	if !ok {
+		return model.AuthSecrets{}, os.ErrNotExist
+	}
+	return sec, nil
+}
+
+func (s *memorySecretStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *memorySecretStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func TestAPIClientOverUnixSocket(t *testing.T) {
+	// Keep the path short; unix socket paths are limited to ~104 bytes on macOS.
+	dir, err := os.MkdirTemp("", "swly")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	socketPath := filepath.Join(dir, "d.sock")
+
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	manager := core.NewManager(
+		&memoryStateStore{state: model.DefaultState()},
+		&memorySecretStore{entries: map[string]model.AuthSecrets{}},
+	)
+	srv := &http.Server{Handler: server.New(manager, nil, nil).Handler()}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	client := newAPIClient("http://ignored.invalid", "", socketPath, 5*time.Second, nil)
+
+	steps := []struct {
+		name string
+		run  func() error
+		want string
+	}{
+		{name: "health", run: func() error {
+			var out map[string]string
+			if err := client.get("/v1/health", &out); err != nil {
+				return err
+			}
+			return printResult(out)
+		}, want: `"status": "ok"`},
+		{name: "add a", run: func() error {
+			return runAccount(client, []string{"add", "--id", "acc-a", "--access-token", "token-a"})
+		}, want: `"id": "acc-a"`},
+		{name: "add b", run: func() error {
+			return runAccount(client, []string{"add", "--id", "acc-b", "--access-token", "token-b", "--weight", "2"})
+		}, want: `"weight": 2`},
+		{name: "list", run: func() error { return runAccount(client, []string{"list"}) }, want: `"acc-b"`},
+		{name: "get", run: func() error { return runAccount(client, []string{"get", "--id", "acc-b"}) }, want: `"id": "acc-b"`},
+		{name: "use", run: func() error { return runAccount(client, []string{"use", "--id", "acc-b"}) }, want: `"status": "ok"`},
+		{name: "disable", run: func() error { return runAccount(client, []string{"disable", "--id", "acc-a"}) }, want: `"status": "disabled"`},
+		{name: "strategy", run: func() error { return runStrategy(client, []string{"set", "--value", "fill-first"}) }, want: `"status": "ok"`},
+		{name: "status", run: func() error { return runStatus(client, nil) }, want: `"active_account_id": "acc-b"`},
+		{name: "history", run: func() error { return runSwitch(client, []string{"history"}) }, want: `"to_account_id": "acc-b"`},
+		{name: "rules", run: func() error { return runSwitchRules(client, []string{"add", "--pattern", "context_length_exceeded"}) }, want: "context_length_exceeded"},
+		{name: "delete", run: func() error { return runAccount(client, []string{"delete", "--id", "acc-a", "--yes"}) }, want: `"deleted_account_id": "acc-a"`},
+	}
+
+	for _, step := range steps {
+		var runErr error
+		out := captureStdout(t, func() { runErr = step.run() })
+		if runErr != nil {
+			t.Fatalf("%s: %v", step.name, runErr)
+		}
+		if !strings.Contains(out, step.want) {
+			t.Fatalf("%s: expected %q in output, got:\n%s", step.name, step.want, out)
+		}
+	}
+
+	var apiErr error
+	captureStdout(t, func() { apiErr = runAccount(client, []string{"get", "--id", "acc-a"}) })
+	if apiErr == nil || !strings.Contains(apiErr.Error(), "http 404") {
+		t.Fatalf("expected 404 for deleted account, got %v", apiErr)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+)
+
+const systemdUnitName = "switchlyd.service"
+
+type systemdUnitOptions struct {
+	binary        string
+	workDir       string
+	baseURL       string
+	addr          string
+	publicBaseURL string
+	startCmd      string
+	// runAs is set for system units so the daemon uses that user's config
+	// and secrets instead of root's.
+	runAs string
+	user  bool
+}
+
+func runDaemonSystemdUnit(c *apiClient, args []string) error {
+	defaultAddr := hostPortFromBaseURL(c.baseURL)
+	fs := flag.NewFlagSet("daemon systemd-unit", flag.ContinueOnError)
+	userUnit := fs.Bool("user", true, "generate a user unit; --user=false generates a system unit")
+	output := fs.String("output", "", "write the unit to this file instead of stdout")
+	install := fs.Bool("install", false, "install the unit and run systemctl daemon-reload")
+	addr := fs.String("addr", defaultAddr, "daemon address")
+	publicBaseURL := fs.String("public-base-url", publicBaseURLForAddr(defaultAddr), "oauth public base url")
+	startCmd := fs.String("start-cmd", "", "custom start command; default runs the switchlyd binary next to switchly")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	binary, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate switchly binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	start := strings.TrimSpace(*startCmd)
+	if start == "" {
+		if start, err = defaultSystemdStartCmd(binary, *addr, *publicBaseURL); err != nil {
+			return err
+		}
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return err
+	}
+	opts := systemdUnitOptions{
+		binary:        binary,
+		workDir:       workDir,
+		baseURL:       c.baseURL,
+		addr:          *addr,
+		publicBaseURL: *publicBaseURL,
+		startCmd:      start,
+		user:          *userUnit,
+	}
+	if !*userUnit {
+		current, err := user.Current()
+		if err != nil {
+			return fmt.Errorf("look up current user: %w", err)
+		}
+		opts.runAs = current.Username
+	}
+	unit := renderSystemdUnit(opts)
+
+	if *install {
+		path, err := installSystemdUnit(unit, *userUnit)
+		if err != nil {
+			return err
+		}
+		enable := "systemctl enable --now " + systemdUnitName
+		if *userUnit {
+			enable = "systemctl --user enable --now " + systemdUnitName
+		}
+		return printResult(map[string]string{"status": "installed", "path": path, "next": enable})
+	}
+	if strings.TrimSpace(*output) != "" {
+		if err := os.WriteFile(*output, []byte(unit), 0o644); err != nil {
+			return fmt.Errorf("write unit: %w", err)
+		}
+		return printResult(map[string]string{"status": "ok", "file": *output})
+	}
+	fmt.Print(unit)
+	return nil
+}
+
+// defaultSystemdStartCmd runs the switchlyd installed next to switchly. A
+// unit must not fall back to `go run`, which needs a source checkout and a
+// Go toolchain every time systemd starts it.
+func defaultSystemdStartCmd(binary, addr, publicBaseURL string) (string, error) {
+	daemon := filepath.Join(filepath.Dir(binary), "switchlyd")
+	if info, err := os.Stat(daemon); err != nil || info.IsDir() {
+		return "", fmt.Errorf("no switchlyd binary next to %s; pass --start-cmd with the command that runs the daemon", binary)
+	}
+	return strings.Join([]string{posixQuote(daemon), "--addr", posixQuote(addr), "--public-base-url", posixQuote(publicBaseURL)}, " "), nil
+}
+
+// posixQuote quotes s for the sh -c that runs --start-cmd.
+func posixQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// renderSystemdUnit runs `switchly daemon start` in the foreground so
+// systemd, not the CLI, supervises the daemon process.
+func renderSystemdUnit(opts systemdUnitOptions) string {
+	execArgs := []string{
+		opts.binary, "daemon", "start",
+		"--detach=false", "--skip-health-check",
+		"--addr", opts.addr,
+		"--public-base-url", opts.publicBaseURL,
+	}
+	if opts.startCmd != "" {
+		execArgs = append(execArgs, "--start-cmd", opts.startCmd)
+	}
+	quoted := make([]string, len(execArgs))
+	for i, arg := range execArgs {
+		quoted[i] = systemdQuote(arg)
+	}
+	wantedBy := "multi-user.target"
+	if opts.user {
+		wantedBy = "default.target"
+	}
+
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	b.WriteString("Description=Switchly account switching daemon\n")
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	if opts.runAs != "" {
+		fmt.Fprintf(&b, "User=%s\n", opts.runAs)
+	}
+	fmt.Fprintf(&b, "WorkingDirectory=%s\n", systemdQuote(opts.workDir))
+	fmt.Fprintf(&b, "Environment=%s\n", systemdQuote("SWITCHLY_BASE_URL="+opts.baseURL))
+	fmt.Fprintf(&b, "ExecStart=%s\n", strings.Join(quoted, " "))
+	b.WriteString("Restart=always\n")
+	b.WriteString("RestartSec=3\n\n")
+	b.WriteString("[Install]\n")
+	fmt.Fprintf(&b, "WantedBy=%s\n", wantedBy)
+	return b.String()
+}
+
+// systemdQuote quotes a value for a unit file, escaping specifiers.
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;") {
+		return s
+	}
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
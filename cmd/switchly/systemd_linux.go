@@ -0,0 +1,55 @@
+//go:build linux
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// systemctlDaemonReload is replaced in tests.
+var systemctlDaemonReload = func(user bool) error {
+	args := []string{"daemon-reload"}
+	if user {
+		args = []string{"--user", "daemon-reload"}
+	}
+	out, err := exec.Command("systemctl", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("systemctl daemon-reload: %v: %s", err, out)
+	}
+	return nil
+}
+
+func systemdUnitDir(user bool) (string, error) {
+	if !user {
+		return "/etc/systemd/system", nil
+	}
+	if dir := os.Getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "systemd", "user"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "systemd", "user"), nil
+}
+
+func installSystemdUnit(unit string, user bool) (string, error) {
+	dir, err := systemdUnitDir(user)
+	if err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(dir, systemdUnitName)
+	if err := os.WriteFile(path, []byte(unit), 0o644); err != nil {
+		return "", fmt.Errorf("write unit: %w", err)
+	}
+	if err := systemctlDaemonReload(user); err != nil {
+		return path, err
+	}
+	return path, nil
+}
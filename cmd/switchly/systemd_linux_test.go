@@ -0,0 +1,39 @@
+//go:build linux
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestInstallSystemdUserUnit(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	var reloadedUser *bool
+	orig := systemctlDaemonReload
+	systemctlDaemonReload = func(user bool) error {
+		reloadedUser = &user
+		return nil
+	}
+	t.Cleanup(func() { systemctlDaemonReload = orig })
+
+	client := &apiClient{baseURL: "http://127.0.0.1:7777"}
+	captureStdout(t, func() {
+		if err := runDaemon(client, []string{"systemd-unit", "--install", "--start-cmd", "/usr/local/bin/switchlyd"}); err != nil {
+			t.Fatalf("install: %v", err)
+		}
+	})
+	path := filepath.Join(configHome, "systemd", "user", systemdUnitName)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("expected unit at %s: %v", path, err)
+	}
+	if parseUnitFile(t, string(data))["Service"]["Restart"] != "always" {
+		t.Fatalf("unexpected unit:\n%s", data)
+	}
+	if reloadedUser == nil || !*reloadedUser {
+		t.Fatal("expected systemctl --user daemon-reload")
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package main
+
+import "errors"
+
+func installSystemdUnit(unit string, user bool) (string, error) {
+	return "", errors.New("systemd units can only be installed on Linux; use --output to write the file")
+}
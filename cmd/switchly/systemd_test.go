@@ -0,0 +1,141 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// parseUnitFile parses the INI subset systemd uses and fails on any line
+// that is not a section header, a comment or a key=value pair.
+func parseUnitFile(t *testing.T, unit string) map[string]map[string]string {
+	t.Helper()
+	sections := map[string]map[string]string{}
+	current := ""
+	for i, line := range strings.Split(unit, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case line == "" || strings.HasPrefix(line, "#"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			current = line[1 : len(line)-1]
+			sections[current] = map[string]string{}
+		default:
+			key, value, ok := strings.Cut(line, "=")
+			if !ok || current == "" || strings.TrimSpace(key) == "" {
+				t.Fatalf("line %d is not valid unit syntax: %q", i+1, line)
+			}
+			sections[current][key] = value
+		}
+	}
+	return sections
+}
+
+func TestRenderSystemdUnit(t *testing.T) {
+	unit := renderSystemdUnit(systemdUnitOptions{
+		binary:        "/usr/local/bin/switchly",
+		workDir:       "/home/me/src/switchly",
+		baseURL:       "http://127.0.0.1:7777",
+		addr:          "127.0.0.1:7777",
+		publicBaseURL: "http://localhost:7777",
+		startCmd:      "/usr/local/bin/switchlyd --addr 127.0.0.1:7777",
+		user:          true,
+	})
+	sections := parseUnitFile(t, unit)
+
+	service := sections["Service"]
+	wantExec := `/usr/local/bin/switchly daemon start --detach=false --skip-health-check --addr 127.0.0.1:7777 --public-base-url http://localhost:7777 --start-cmd "/usr/local/bin/switchlyd --addr 127.0.0.1:7777"`
+	if service["ExecStart"] != wantExec {
+		t.Fatalf("unexpected ExecStart:\n got %s\nwant %s", service["ExecStart"], wantExec)
+	}
+	if service["Restart"] != "always" || service["RestartSec"] != "3" {
+		t.Fatalf("unexpected restart policy: %#v", service)
+	}
+	if service["Environment"] != "SWITCHLY_BASE_URL=http://127.0.0.1:7777" {
+		t.Fatalf("unexpected Environment: %q", service["Environment"])
+	}
+	if _, ok := service["User"]; ok {
+		t.Fatal("user units must not set User=")
+	}
+	if sections["Unit"]["Description"] == "" {
+		t.Fatal("expected a Description")
+	}
+	if sections["Install"]["WantedBy"] != "default.target" {
+		t.Fatalf("unexpected WantedBy for a user unit: %q", sections["Install"]["WantedBy"])
+	}
+
+	system := parseUnitFile(t, renderSystemdUnit(systemdUnitOptions{binary: "/opt/switchly 1/switchly", workDir: "/srv", runAs: "me"}))
+	if system["Service"]["User"] != "me" || system["Install"]["WantedBy"] != "multi-user.target" {
+		t.Fatalf("unexpected system unit: %#v", system)
+	}
+	if !strings.HasPrefix(system["Service"]["ExecStart"], `"/opt/switchly 1/switchly" daemon start`) {
+		t.Fatalf("expected a quoted binary path, got %s", system["Service"]["ExecStart"])
+	}
+}
+
+func TestRunDaemonSystemdUnitWritesOutput(t *testing.T) {
+	client := &apiClient{baseURL: "http://127.0.0.1:7788"}
+	path := filepath.Join(t.TempDir(), systemdUnitName)
+	captureStdout(t, func() {
+		if err := runDaemon(client, []string{"systemd-unit", "--output", path, "--start-cmd", "/usr/local/bin/switchlyd"}); err != nil {
+			t.Fatalf("systemd-unit: %v", err)
+		}
+	})
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read unit: %v", err)
+	}
+	service := parseUnitFile(t, string(data))["Service"]
+	if !strings.Contains(service["ExecStart"], "--addr 127.0.0.1:7788") {
+		t.Fatalf("expected the client address in ExecStart, got %s", service["ExecStart"])
+	}
+	binary, _ := os.Executable()
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	if !strings.HasPrefix(service["ExecStart"], systemdQuote(binary)+" ") {
+		t.Fatalf("expected ExecStart to use the running binary %s, got %s", binary, service["ExecStart"])
+	}
+}
+
+func TestDefaultSystemdStartCmd(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "my bin")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	binary := filepath.Join(dir, "switchly")
+	if _, err := defaultSystemdStartCmd(binary, "127.0.0.1:7777", "http://localhost:7777"); err == nil || !strings.Contains(err.Error(), "--start-cmd") {
+		t.Fatalf("expected an error asking for --start-cmd without switchlyd, got %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "switchlyd"), []byte("#!/bin/sh\n"), 0o755); err != nil {
+		t.Fatalf("write switchlyd: %v", err)
+	}
+	got, err := defaultSystemdStartCmd(binary, "127.0.0.1:7777", "http://localhost:7777")
+	if err != nil {
+		t.Fatalf("default start cmd: %v", err)
+	}
+	want := "'" + filepath.Join(dir, "switchlyd") + "' --addr '127.0.0.1:7777' --public-base-url 'http://localhost:7777'"
+	if got != want {
+		t.Fatalf("unexpected start cmd:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestRunDaemonSystemdUnitRequiresStartCmdWithoutSwitchlyd(t *testing.T) {
+	binary, _ := os.Executable()
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+	if _, err := os.Stat(filepath.Join(filepath.Dir(binary), "switchlyd")); err == nil {
+		t.Skip("a switchlyd binary sits next to the test binary")
+	}
+	client := &apiClient{baseURL: "http://127.0.0.1:7788"}
+	path := filepath.Join(t.TempDir(), systemdUnitName)
+	err := runDaemon(client, []string{"systemd-unit", "--output", path})
+	if err == nil || !strings.Contains(err.Error(), "--start-cmd") {
+		t.Fatalf("expected an error asking for --start-cmd, got %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("no unit should be written without a start command")
+	}
+}
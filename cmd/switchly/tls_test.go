@@ -0,0 +1,144 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func healthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	})
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) string {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write %s: %v", path, err)
+	}
+	return path
+}
+
+func TestAPIClientTLSWithCustomCA(t *testing.T) {
+	srv := httptest.NewTLSServer(healthHandler())
+	defer srv.Close()
+
+	caFile := writePEM(t, filepath.Join(t.TempDir(), "ca.pem"), "CERTIFICATE", srv.Certificate().Raw)
+	tlsConfig, err := clientTLSConfig(caFile, "", "")
+	if err != nil {
+		t.Fatalf("clientTLSConfig: %v", err)
+	}
+
+	var out map[string]string
+	client := newAPIClient(srv.URL, "", "", 5*time.Second, tlsConfig)
+	if err := client.get("/v1/health", &out); err != nil {
+		t.Fatalf("get over tls: %v", err)
+	}
+	if out["status"] != "ok" {
+		t.Fatalf("unexpected response: %v", out)
+	}
+
+	untrusted := newAPIClient(srv.URL, "", "", 5*time.Second, nil)
+	if err := untrusted.get("/v1/health", &out); err == nil {
+		t.Fatal("expected certificate verification to fail without the CA")
+	}
+}
+
+func TestAPIClientMutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate ca key: %v", err)
+	}
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "switchly test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create ca cert: %v", err)
+	}
+	caCert, _ := x509.ParseCertificate(caDER)
+
+	clientKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("generate client key: %v", err)
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "switchly cli"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}, caCert, &clientKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("create client cert: %v", err)
+	}
+	clientKeyDER, err := x509.MarshalECPrivateKey(clientKey)
+	if err != nil {
+		t.Fatalf("marshal client key: %v", err)
+	}
+	certFile := writePEM(t, filepath.Join(dir, "client.pem"), "CERTIFICATE", clientDER)
+	keyFile := writePEM(t, filepath.Join(dir, "client-key.pem"), "EC PRIVATE KEY", clientKeyDER)
+
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(caCert)
+	srv := httptest.NewUnstartedServer(healthHandler())
+	srv.TLS = &tls.Config{ClientCAs: clientCAs, ClientAuth: tls.RequireAndVerifyClientCert}
+	srv.StartTLS()
+	defer srv.Close()
+	serverCAFile := writePEM(t, filepath.Join(dir, "server-ca.pem"), "CERTIFICATE", srv.Certificate().Raw)
+
+	tlsConfig, err := clientTLSConfig(serverCAFile, certFile, keyFile)
+	if err != nil {
+		t.Fatalf("clientTLSConfig: %v", err)
+	}
+	var out map[string]string
+	if err := newAPIClient(srv.URL, "", "", 5*time.Second, tlsConfig).get("/v1/health", &out); err != nil {
+		t.Fatalf("get with client cert: %v", err)
+	}
+
+	noClientCert, err := clientTLSConfig(serverCAFile, "", "")
+	if err != nil {
+		t.Fatalf("clientTLSConfig: %v", err)
+	}
+	if err := newAPIClient(srv.URL, "", "", 5*time.Second, noClientCert).get("/v1/health", &out); err == nil {
+		t.Fatal("expected the server to reject a client without a certificate")
+	}
+}
+
+func TestClientTLSConfigErrors(t *testing.T) {
+	if cfg, err := clientTLSConfig("", "", ""); cfg != nil || err != nil {
+		t.Fatalf("expected nil config without settings, got %v %v", cfg, err)
+	}
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(empty, []byte("not a cert"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := clientTLSConfig(empty, "", ""); err == nil {
+		t.Fatal("expected error for a CA file without certificates")
+	}
+	if _, err := clientTLSConfig("", empty, empty); err == nil {
+		t.Fatal("expected error for an invalid client key pair")
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const verboseBodyLimit = 4 << 10
+
+// verboseSecretFields are JSON fields whose values --verbose never prints,
+// at any depth of a request or response body.
+var verboseSecretFields = map[string]bool{
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"oauth_token":   true,
+	"api_token":     true,
+	"passphrase":    true,
+}
+
+// loggingTransport prints each request and response to stderr for
+// --verbose.
+type loggingTransport struct {
+	base http.RoundTripper
+}
+
+// WithVerbose makes c print its HTTP traffic to stderr.
+func (c *apiClient) WithVerbose(verbose bool) *apiClient {
+	c.verbose = verbose
+	if verbose {
+		c.http.Transport = newLoggingTransport(c.http.Transport)
+	}
+	return c
+}
+
+func newLoggingTransport(base http.RoundTripper) http.RoundTripper {
+	if _, ok := base.(*loggingTransport); ok {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &loggingTransport{base: base}
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	out := os.Stderr
+	fmt.Fprintf(out, "> %s %s\n", req.Method, req.URL)
+	names := make([]string, 0, len(req.Header))
+	for name := range req.Header {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.Join(req.Header.Values(name), ", ")
+		if strings.EqualFold(name, "Authorization") {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(out, "> %s: %s\n", name, value)
+	}
+	if req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			data, _ := io.ReadAll(body)
+			body.Close()
+			writeVerboseBody(out, ">", data)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(out, "< error after %s: %v\n", time.Since(start).Round(time.Millisecond), err)
+		return nil, err
+	}
+	fmt.Fprintf(out, "< %d %s (%s)\n", resp.StatusCode, http.StatusText(resp.StatusCode), time.Since(start).Round(time.Millisecond))
+	// Streams never end, so their bodies are left for the caller.
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return resp, nil
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	writeVerboseBody(out, "<", data)
+	return resp, nil
+}
+
+func writeVerboseBody(out io.Writer, prefix string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+	data = redactVerboseBody(data)
+	suffix := ""
+	if len(data) > verboseBodyLimit {
+		suffix = fmt.Sprintf("... (%d bytes truncated)", len(data)-verboseBodyLimit)
+		data = data[:verboseBodyLimit]
+	}
+	fmt.Fprintf(out, "%s %s%s\n", prefix, bytes.TrimRight(data, "\n"), suffix)
+}
+
+// redactVerboseBody replaces the values of verboseSecretFields in a JSON
+// body. Bodies without such fields, or that are not JSON, are returned as is.
+func redactVerboseBody(data []byte) []byte {
+	var body any
+	if json.Unmarshal(data, &body) != nil || !redactSecretFields(body) {
+		return data
+	}
+	redacted, err := json.Marshal(body)
+	if err != nil {
+		return data
+	}
+	return redacted
+}
+
+func redactSecretFields(v any) bool {
+	redacted := false
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			if verboseSecretFields[strings.ToLower(key)] {
+				if s, ok := value.(string); ok && s == "" {
+					continue
+				}
+				v[key] = "[REDACTED]"
+				redacted = true
+				continue
+			}
+			if redactSecretFields(value) {
+				redacted = true
+			}
+		}
+	case []any:
+		for _, item := range v {
+			if redactSecretFields(item) {
+				redacted = true
+			}
+		}
+	}
+	return redacted
+}
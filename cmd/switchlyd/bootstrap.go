@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+// accountsEnv holds a JSON array of accounts added at startup, for
+// containers that configure the daemon from the environment.
+const accountsEnv = "SWITCHLY_ACCOUNTS"
+
+// activeAccountEnv names the account activated after accountsEnv is loaded.
+const activeAccountEnv = "SWITCHLY_ACTIVE_ACCOUNT"
+
+// envAccount mirrors the body of POST /v1/accounts.
+type envAccount struct {
+	ID               string `json:"id"`
+	Provider         string `json:"provider"`
+	Email            string `json:"email"`
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	IDToken          string `json:"id_token"`
+	AccountID        string `json:"account_id"`
+	AccessExpiresAt  string `json:"access_expires_at"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+	Weight           int    `json:"weight"`
+}
+
+func (a envAccount) input() (core.AddAccountInput, error) {
+	var expiries [2]time.Time
+	for i, raw := range []string{a.AccessExpiresAt, a.RefreshExpiresAt} {
+		if strings.TrimSpace(raw) == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(raw))
+		if err != nil {
+			return core.AddAccountInput{}, fmt.Errorf("account %q: invalid expiry %q: %w", a.ID, raw, err)
+		}
+		expiries[i] = t
+	}
+	return core.AddAccountInput{
+		ID:       a.ID,
+		Provider: a.Provider,
+		Email:    a.Email,
+		Weight:   a.Weight,
+		Secrets: model.AuthSecrets{
+			AccessToken:      a.AccessToken,
+			RefreshToken:     a.RefreshToken,
+			IDToken:          a.IDToken,
+			AccountID:        a.AccountID,
+			AccessExpiresAt:  expiries[0],
+			RefreshExpiresAt: expiries[1],
+		},
+	}, nil
+}
+
+// bootstrapAccountsFromEnv adds the accounts in $SWITCHLY_ACCOUNTS that are
+// not stored yet and then activates $SWITCHLY_ACTIVE_ACCOUNT. Stored accounts
+// win: a restarted daemon inherits the same environment, and replacing them
+// would bring back rotated refresh tokens and drop the user's settings.
+func bootstrapAccountsFromEnv(ctx context.Context, manager *core.Manager, getenv func(string) string) error {
+	raw := strings.TrimSpace(getenv(accountsEnv))
+	active := strings.TrimSpace(getenv(activeAccountEnv))
+	if raw == "" && active == "" {
+		return nil
+	}
+
+	added := 0
+	if raw != "" {
+		var entries []envAccount
+		if err := json.Unmarshal([]byte(raw), &entries); err != nil {
+			return fmt.Errorf("parse %s: %w", accountsEnv, err)
+		}
+		inputs := make([]core.AddAccountInput, 0, len(entries))
+		for _, entry := range entries {
+			input, err := entry.input()
+			if err != nil {
+				return fmt.Errorf("%s: %w", accountsEnv, err)
+			}
+			inputs = append(inputs, input)
+		}
+
+		existing, err := manager.ListAccounts(ctx)
+		if err != nil {
+			return err
+		}
+		stored := make(map[string]bool, len(existing))
+		for _, acct := range existing {
+			stored[acct.ID] = true
+		}
+		var kept []string
+		for _, input := range inputs {
+			if stored[strings.TrimSpace(input.ID)] {
+				kept = append(kept, input.ID)
+				continue
+			}
+			if _, err := manager.AddAccount(ctx, input); err != nil {
+				return fmt.Errorf("%s: add account %q: %w", accountsEnv, input.ID, err)
+			}
+			added++
+		}
+		if len(kept) > 0 {
+			log.Printf("%s: the state file already has %s; the stored account(s) take precedence over the environment", accountsEnv, strings.Join(kept, ", "))
+		}
+		log.Printf("loaded %d account(s) from %s", added, accountsEnv)
+	}
+
+	if active != "" {
+		status, err := manager.Status(ctx)
+		if err != nil {
+			return err
+		}
+		// Once running, the daemon may have switched away on purpose.
+		if added == 0 && status.ActiveAccountID != "" {
+			if status.ActiveAccountID != active {
+				log.Printf("%s: keeping the stored active account %s", activeAccountEnv, status.ActiveAccountID)
+			}
+			return nil
+		}
+		if err := manager.SetActiveAccount(ctx, active); err != nil {
+			return fmt.Errorf("%s: %w", activeAccountEnv, err)
+		}
+	}
+	return nil
+}
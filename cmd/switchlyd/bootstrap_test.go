@@ -0,0 +1,166 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+	"switchly/internal/store"
+)
+
+type memSecrets struct {
+	mu      sync.Mutex
+	entries map[string]model.AuthSecrets
+}
+
+func (s *memSecrets) Put(id string, sec model.AuthSecrets) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = sec
+	return nil
+}
+
+func (s *memSecrets) Get(id string) (model.AuthSecrets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[id], nil
+}
+
+func (s *memSecrets) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *memSecrets) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func newBootstrapManager(t *testing.T) (*core.Manager, *memSecrets) {
+	t.Helper()
+	t.Setenv(store.StateFileEnv, filepath.Join(t.TempDir(), "state.json"))
+	stateStore, err := store.NewStateStore()
+	if err != nil {
+		t.Fatalf("new state store: %v", err)
+	}
+	secrets := &memSecrets{entries: map[string]model.AuthSecrets{}}
+	return core.NewManager(stateStore, secrets), secrets
+}
+
+func TestBootstrapAccountsFromEnv(t *testing.T) {
+	mgr, secrets := newBootstrapManager(t)
+	env := map[string]string{
+		accountsEnv: `[
+			{"id":"codex:a@example.com","provider":"codex","email":"a@example.com","access_token":"token-a","refresh_token":"refresh-a"},
+			{"id":"codex:b@example.com","provider":"codex","email":"b@example.com","access_token":"token-b","access_expires_at":"2026-11-01T00:00:00Z","weight":3}
+		]`,
+		activeAccountEnv: "codex:b@example.com",
+	}
+	if err := bootstrapAccountsFromEnv(context.Background(), mgr, func(k string) string { return env[k] }); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+
+	accounts, err := mgr.ListAccounts(context.Background())
+	if err != nil {
+		t.Fatalf("list accounts: %v", err)
+	}
+	if len(accounts) != 2 || accounts[0].ID != "codex:a@example.com" || accounts[1].ID != "codex:b@example.com" || accounts[1].Weight != 3 {
+		t.Fatalf("expected both accounts, got %#v", accounts)
+	}
+	if secrets.entries["codex:a@example.com"].RefreshToken != "refresh-a" {
+		t.Fatalf("expected secrets to be stored, got %#v", secrets.entries)
+	}
+	status, err := mgr.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.ActiveAccountID != "codex:b@example.com" {
+		t.Fatalf("expected %s to activate b, got %q", activeAccountEnv, status.ActiveAccountID)
+	}
+
+}
+
+func TestBootstrapAccountsFromEnvKeepsStoredAccounts(t *testing.T) {
+	ctx := context.Background()
+	mgr, secrets := newBootstrapManager(t)
+	env := map[string]string{
+		accountsEnv: `[
+			{"id":"codex:a@example.com","provider":"codex","access_token":"token-a","refresh_token":"refresh-a"},
+			{"id":"codex:b@example.com","provider":"codex","access_token":"token-b","refresh_token":"refresh-b"}
+		]`,
+		activeAccountEnv: "codex:a@example.com",
+	}
+	getenv := func(k string) string { return env[k] }
+	if err := bootstrapAccountsFromEnv(ctx, mgr, getenv); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+
+	// While running, the daemon refreshes a's tokens, the user renames it, and
+	// an automatic switch moves to b.
+	if err := secrets.Put("codex:a@example.com", model.AuthSecrets{AccessToken: "token-a2", RefreshToken: "refresh-a2"}); err != nil {
+		t.Fatalf("refresh: %v", err)
+	}
+	if _, err := mgr.SetAccountAlias(ctx, "codex:a@example.com", "work"); err != nil {
+		t.Fatalf("set alias: %v", err)
+	}
+	if err := mgr.SetActiveAccount(ctx, "codex:b@example.com"); err != nil {
+		t.Fatalf("switch: %v", err)
+	}
+
+	// A restart runs the bootstrap again with the same environment.
+	if err := bootstrapAccountsFromEnv(ctx, mgr, getenv); err != nil {
+		t.Fatalf("second bootstrap: %v", err)
+	}
+	if got := secrets.entries["codex:a@example.com"]; got.AccessToken != "token-a2" || got.RefreshToken != "refresh-a2" {
+		t.Fatalf("expected the refreshed tokens to survive, got %#v", got)
+	}
+	acct, err := mgr.GetAccount(ctx, "codex:a@example.com")
+	if err != nil || acct.Alias != "work" {
+		t.Fatalf("expected the alias to survive, got %#v (%v)", acct, err)
+	}
+	status, err := mgr.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.ActiveAccountID != "codex:b@example.com" {
+		t.Fatalf("expected the stored active account to be kept, got %q", status.ActiveAccountID)
+	}
+
+	// Entries not stored yet are still added.
+	env[accountsEnv] = `[{"id":"codex:c@example.com","provider":"codex","access_token":"token-c"}]`
+	if err := bootstrapAccountsFromEnv(ctx, mgr, getenv); err != nil {
+		t.Fatalf("third bootstrap: %v", err)
+	}
+	if _, err := mgr.GetAccount(ctx, "codex:c@example.com"); err != nil {
+		t.Fatalf("expected the new account to be added: %v", err)
+	}
+}
+
+func TestBootstrapAccountsFromEnvRejectsBadInput(t *testing.T) {
+	mgr, _ := newBootstrapManager(t)
+	for name, raw := range map[string]string{
+		"not json":     `{"id":"x"}`,
+		"bad expiry":   `[{"id":"x","provider":"codex","access_token":"t","access_expires_at":"tomorrow"}]`,
+		"missing data": `[{"id":"x","provider":"codex"}]`,
+	} {
+		env := map[string]string{accountsEnv: raw}
+		err := bootstrapAccountsFromEnv(context.Background(), mgr, func(k string) string { return env[k] })
+		if err == nil || !strings.Contains(err.Error(), accountsEnv) {
+			t.Fatalf("%s: expected an error naming %s, got %v", name, accountsEnv, err)
+		}
+	}
+	if err := bootstrapAccountsFromEnv(context.Background(), mgr, func(string) string { return "" }); err != nil {
+		t.Fatalf("expected no-op without the variables, got %v", err)
+	}
+}
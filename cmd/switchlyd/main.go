@@ -4,6 +4,7 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
@@ -17,27 +18,49 @@ import (
 
 	"switchly/internal/codexauth"
 	"switchly/internal/core"
+	"switchly/internal/metrics"
+	"switchly/internal/notify"
 	"switchly/internal/oauth"
+	"switchly/internal/quota"
 	"switchly/internal/secrets"
 	"switchly/internal/server"
 	"switchly/internal/store"
+	"switchly/internal/webhook"
 )
 
 type daemonController struct {
 	mu                sync.Mutex
 	addr              string
 	publicBaseURL     string
+	socketPath        string
+	stateFile         string
 	defaultRestartCmd string
+	apiToken          string
+	restartEnv        []string
 	httpServers       []*http.Server
 	oauthCallbacks    *oauthCallbackLeases
+	oauthDrainer      callbackDrainer
+	runtimeStats      bool
 	shuttingDown      bool
 }
 
-func newDaemonController(addr, publicBaseURL, restartCmd string, servers ...*http.Server) *daemonController {
+// callbackDrainer is implemented by oauth.Service.
+type callbackDrainer interface {
+	WaitForPendingCallbacks(ctx context.Context) error
+}
+
+// callbackDrainTimeout bounds how long shutdown waits for OAuth callbacks
+// that are exchanging a code.
+const callbackDrainTimeout = 5 * time.Second
+
+func newDaemonController(addr, publicBaseURL, restartCmd, apiToken string, servers ...*http.Server) *daemonController {
 	ctrl := &daemonController{
 		addr:          addr,
 		publicBaseURL: publicBaseURL,
+		apiToken:      apiToken,
 		httpServers:   servers,
+		runtimeStats:  true,
+		restartEnv:    restartEnv(flag.CommandLine),
 	}
 
 	if strings.TrimSpace(restartCmd) != "" {
@@ -55,20 +78,89 @@ func newDaemonController(addr, publicBaseURL, restartCmd string, servers ...*htt
 		return ctrl
 	}
 
-	ctrl.defaultRestartCmd = fmt.Sprintf("\"%s\" --addr %s --public-base-url %s", exe, addr, publicBaseURL)
+	ctrl.defaultRestartCmd = buildRestartCmd(exe, flag.CommandLine)
 	return ctrl
 }
 
+// secretFlagEnv maps the flags that hold secrets to the variables a
+// restarted daemon reads them from, so they stay out of its command line.
+var secretFlagEnv = map[string]string{
+	"api-token":      "SWITCHLY_API_TOKEN",
+	"webhook-secret": "SWITCHLY_WEBHOOK_SECRET",
+}
+
+// buildRestartCmd starts exe with every flag set on flags, so the
+// replacement daemon keeps TLS, the socket, metrics and the rest. Secret
+// flags are left to restartEnv.
+func buildRestartCmd(exe string, flags *flag.FlagSet) string {
+	parts := []string{shellQuote(exe)}
+	flags.Visit(func(f *flag.Flag) {
+		if _, secret := secretFlagEnv[f.Name]; !secret {
+			parts = append(parts, shellQuote("--"+f.Name+"="+f.Value.String()))
+		}
+	})
+	return strings.Join(parts, " ")
+}
+
+// restartEnv returns the variables that carry the secret flags set on flags
+// to the replacement daemon.
+func restartEnv(flags *flag.FlagSet) []string {
+	var env []string
+	flags.Visit(func(f *flag.Flag) {
+		if name, secret := secretFlagEnv[f.Name]; secret && f.Value.String() != "" {
+			env = append(env, name+"="+f.Value.String())
+		}
+	})
+	return env
+}
+
+// secretFlagsFromEnv sets the secret flags left off the command line from
+// their variables.
+func secretFlagsFromEnv(flags *flag.FlagSet, getenv func(string) string) error {
+	set := map[string]bool{}
+	flags.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	for name, env := range secretFlagEnv {
+		if value := getenv(env); value != "" && !set[name] && flags.Lookup(name) != nil {
+			if err := flags.Set(name, value); err != nil {
+				return fmt.Errorf("%s: %w", env, err)
+			}
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s for the shell Restart runs the command with.
+func shellQuote(s string) string {
+	if runtime.GOOS == "windows" {
+		return `"` + strings.ReplaceAll(s, `"`, `""`) + `"`
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
 func (d *daemonController) Info() server.DaemonInfo {
 	d.mu.Lock()
 	defer d.mu.Unlock()
-	return server.DaemonInfo{
+	info := server.DaemonInfo{
 		PID:               os.Getpid(),
 		Addr:              d.addr,
 		PublicBaseURL:     d.publicBaseURL,
+		SocketPath:        d.socketPath,
 		RestartSupported:  d.defaultRestartCmd != "",
-		DefaultRestartCmd: d.defaultRestartCmd,
+		DefaultRestartCmd: redactToken(d.defaultRestartCmd, d.apiToken),
+		StateFile:         d.stateFile,
+	}
+	if d.runtimeStats {
+		stats := server.ReadRuntimeStats()
+		info.Runtime = &stats
+	}
+	return info
+}
+
+func redactToken(s, token string) string {
+	if token == "" {
+		return s
 	}
+	return strings.ReplaceAll(s, token, "<redacted>")
 }
 
 func (d *daemonController) Shutdown() error {
@@ -80,9 +172,17 @@ func (d *daemonController) Shutdown() error {
 	d.shuttingDown = true
 	servers := append([]*http.Server(nil), d.httpServers...)
 	callbacks := d.oauthCallbacks
+	drainer := d.oauthDrainer
 	d.mu.Unlock()
 
 	go func() {
+		if drainer != nil {
+			ctx, cancel := context.WithTimeout(context.Background(), callbackDrainTimeout)
+			if err := drainer.WaitForPendingCallbacks(ctx); err != nil {
+				log.Printf("shutdown: %v", err)
+			}
+			cancel()
+		}
 		if callbacks != nil {
 			callbacks.CloseAll()
 		}
@@ -116,6 +216,9 @@ func (d *daemonController) Restart(startCmd string) error {
 	} else {
 		cmd = exec.Command("sh", "-c", cmdStr)
 	}
+	d.mu.Lock()
+	cmd.Env = append(os.Environ(), d.restartEnv...)
+	d.mu.Unlock()
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start replacement daemon: %w", err)
 	}
@@ -123,42 +226,241 @@ func (d *daemonController) Restart(startCmd string) error {
 	return d.Shutdown()
 }
 
+// openStateStore applies --state-file and --state-driver through the
+// environment so a daemon spawned by Restart inherits the same state store.
+func openStateStore(stateFile, driver string) (store.Store, error) {
+	if path := strings.TrimSpace(stateFile); path != "" {
+		if err := os.Setenv(store.StateFileEnv, path); err != nil {
+			return nil, err
+		}
+	}
+	if driver = strings.TrimSpace(driver); driver != "" {
+		if err := os.Setenv(store.StateDriverEnv, driver); err != nil {
+			return nil, err
+		}
+	}
+	return store.Open(driver)
+}
+
 func main() {
 	addr := flag.String("addr", "127.0.0.1:7777", "listen address")
 	publicBaseURL := flag.String("public-base-url", "http://localhost:7777", "public base URL used for OAuth callback")
 	restartCmd := flag.String("restart-cmd", "", "command used by /v1/daemon/restart to spawn replacement daemon")
+	noGitignore := flag.Bool("no-gitignore", false, "do not create a .gitignore next to the applied codex auth file")
+	codexAuthBackups := flag.Int("codex-auth-backups", 3, "timestamped copies of the previous codex auth file to keep (0 disables backups)")
+	switchHistoryLimit := flag.Int("switch-history-limit", 100, "number of account switch events kept in state")
+	socketPath := flag.String("socket-path", "", "also serve the API on this unix domain socket")
+	metricsAddr := flag.String("metrics-addr", "", "listen address for the Prometheus /metrics endpoint (empty disables)")
+	syncConcurrency := flag.Int("sync-concurrency", 1, "accounts whose quota is fetched at once when syncing all accounts (4 suits most setups)")
+	syncAccountTimeout := flag.Duration("sync-account-timeout", 10*time.Second, "give up on one account's quota after this long when syncing all accounts (0 disables)")
+	quotaSyncInterval := flag.Duration("quota-sync-interval", 0, "interval for background quota sync of all accounts (0 disables)")
+	quotaLogMaxFiles := flag.Int("quota-log-max-files", quota.DefaultScanMaxFiles, "newest codex session logs read when syncing quota from local logs (-1 for no limit)")
+	quotaLogMaxAgeDays := flag.Int("quota-log-max-age-days", quota.DefaultScanMaxAgeDays, "skip codex session logs older than this many days (-1 for no limit)")
+	tlsCert := flag.String("tls-cert", "", "TLS certificate file; serves HTTPS when set with --tls-key")
+	tlsKey := flag.String("tls-key", "", "TLS private key file")
+	tlsCA := flag.String("tls-ca", "", "CA bundle used to require and verify client certificates (mutual TLS)")
+	switchCooldown := flag.Duration("switch-cooldown", 60*time.Second, "pause automatic switching for this long after every account is exhausted (0 disables)")
+	apiToken := flag.String("api-token", "", "require this bearer token on every API endpoint except /v1/health (default $SWITCHLY_API_TOKEN)")
+	apiRateLimit := flag.Int("api-rate-limit", 100, "requests per second allowed from each client IP, with an equal burst (0 disables)")
+	watchState := flag.Bool("watch-state", false, "reload the state file when another process changes it")
+	postSwitchSync := flag.Bool("post-switch-sync", true, "sync the new account's quota in the background after each automatic switch")
+	notifySwitches := flag.Bool("notify", true, "show a desktop notification when the daemon switches accounts automatically")
+	webhookURL := flag.String("webhook-url", "", "POST account switch and quota sync failure events to this URL (empty disables)")
+	webhookSecret := flag.String("webhook-secret", "", "HMAC-SHA256 key used to sign webhook bodies in the X-Switchly-Signature header (default $SWITCHLY_WEBHOOK_SECRET)")
+	includeRuntimeStats := flag.Bool("include-runtime-stats", true, "report Go runtime stats (goroutines, heap, GC) from /v1/daemon/info")
+	otelEndpoint := flag.String("otel-endpoint", "", "OTLP/HTTP collector (host:port or URL) to export traces to (empty disables)")
+	otelServiceName := flag.String("otel-service-name", "switchly", "service.name reported with exported traces")
+	stateFile := flag.String("state-file", "", "absolute path of the state file (overrides $"+store.StateFileEnv+")")
+	stateDriver := flag.String("state-driver", "", "state store driver: json or sqlite (default $"+store.StateDriverEnv+", then json)")
+	logBufferLines := flag.Int("log-buffer-lines", server.DefaultLogBufferLines, "recent log lines kept in memory for /v1/daemon/logs")
 	flag.Parse()
+	if err := secretFlagsFromEnv(flag.CommandLine, os.Getenv); err != nil {
+		log.Fatalf("flags: %v", err)
+	}
+
+	logBuffer := server.NewLogBuffer(*logBufferLines)
+	log.SetOutput(io.MultiWriter(os.Stderr, logBuffer))
 
-	stateStore, err := store.NewStateStore()
+	tlsConfig, err := serverTLSConfig(*tlsCert, *tlsKey, *tlsCA)
+	if err != nil {
+		log.Fatalf("tls: %v", err)
+	}
+	tracerProvider, shutdownTracing, err := setupTracing(*otelEndpoint, *otelServiceName)
+	if err != nil {
+		log.Fatalf("tracing: %v", err)
+	}
+	defer shutdownTracing()
+
+	stateStore, err := openStateStore(*stateFile, *stateDriver)
 	if err != nil {
 		log.Fatalf("init state store: %v", err)
 	}
 	secretStore := secrets.NewDefaultStore()
-	authApplier := codexauth.NewDefaultFileApplier()
-	manager := core.NewManager(stateStore, secretStore, core.WithActiveAccountApplier(authApplier))
+	var applierOpts []codexauth.FileApplierOption
+	if *noGitignore {
+		applierOpts = append(applierOpts, codexauth.WithoutGitignore())
+	}
+	if *codexAuthBackups > 0 {
+		applierOpts = append(applierOpts, codexauth.WithBackupMaxCount(*codexAuthBackups))
+	} else {
+		applierOpts = append(applierOpts, codexauth.WithoutBackups())
+	}
+	authApplier := codexauth.NewDefaultFileApplier(applierOpts...)
+	metricsRecorder := metrics.NewRecorder()
+	var notifier core.Notifier
+	if *notifySwitches {
+		notifier = notify.NewDesktop()
+	}
+	var webhookDeliverer core.WebhookDeliverer
+	if strings.TrimSpace(*webhookURL) != "" {
+		deliverer, err := webhook.NewDeliverer(*webhookURL, *webhookSecret)
+		if err != nil {
+			log.Fatalf("init webhook: %v", err)
+		}
+		webhookDeliverer = deliverer
+	}
+	manager := core.NewManager(
+		stateStore,
+		secretStore,
+		core.WithNotifier(notifier),
+		core.WithWebhookDeliverer(webhookDeliverer),
+		core.WithActiveAccountApplier(authApplier),
+		core.WithSwitchHistoryLimit(*switchHistoryLimit),
+		core.WithMetricsRecorder(metricsRecorder),
+		core.WithSwitchCooldown(*switchCooldown),
+		core.WithPostSwitchQuotaSync(*postSwitchSync),
+		core.WithSyncConcurrency(*syncConcurrency),
+		core.WithSyncAccountTimeout(*syncAccountTimeout),
+		core.WithStateWatch(*watchState),
+		core.WithCodexLogScanOptions(quota.ScanOptions{MaxFiles: *quotaLogMaxFiles, MaxAgeDays: *quotaLogMaxAgeDays}),
+		core.WithTracerProvider(tracerProvider),
+	)
+	if err := metricsRecorder.RegisterStateCollector(manager); err != nil {
+		log.Fatalf("init metrics: %v", err)
+	}
+	if err := bootstrapAccountsFromEnv(context.Background(), manager, os.Getenv); err != nil {
+		log.Fatalf("bootstrap accounts: %v", err)
+	}
 	oauthLeases := newOAuthCallbackLeases(*addr, *publicBaseURL)
-	oauthService := oauth.NewService(manager, *publicBaseURL, oauth.WithCallbackLeaseManager(oauthLeases))
+	oauthOpts := []oauth.ServiceOption{oauth.WithCallbackLeaseManager(oauthLeases)}
+	if persister, err := oauth.NewFileSessionPersister(stateStore.Path()); err != nil {
+		log.Printf("warning: oauth sessions will not survive a restart: %v", err)
+	} else {
+		oauthOpts = append(oauthOpts, oauth.WithSessionPersister(persister))
+	}
+	oauthService, err := oauth.NewService(manager, *publicBaseURL, oauthOpts...)
+	if err != nil {
+		log.Fatalf("init oauth: %v", err)
+	}
+	defer oauthService.Stop()
 
 	httpServer := &http.Server{
 		Addr:              *addr,
 		ReadHeaderTimeout: 5 * time.Second,
+		TLSConfig:         tlsConfig,
+	}
+
+	var metricsServer *http.Server
+	if strings.TrimSpace(*metricsAddr) != "" {
+		metricsMux := http.NewServeMux()
+		metricsMux.Handle("/metrics", metricsRecorder.Handler())
+		metricsServer = &http.Server{
+			Addr:              *metricsAddr,
+			Handler:           metricsMux,
+			ReadHeaderTimeout: 5 * time.Second,
+		}
+	}
+
+	var socketServer *http.Server
+	var socketListener net.Listener
+	if strings.TrimSpace(*socketPath) != "" {
+		socketListener, err = listenUnixSocket(*socketPath)
+		if err != nil {
+			log.Fatalf("listen on socket: %v", err)
+		}
+		defer os.Remove(*socketPath)
+		socketServer = &http.Server{ReadHeaderTimeout: 5 * time.Second}
 	}
 
-	daemonCtl := newDaemonController(*addr, *publicBaseURL, *restartCmd, httpServer)
+	daemonCtl := newDaemonController(*addr, *publicBaseURL, *restartCmd, strings.TrimSpace(*apiToken), httpServer, metricsServer, socketServer)
 	daemonCtl.oauthCallbacks = oauthLeases
-	api := server.New(manager, oauthService, daemonCtl)
+	daemonCtl.oauthDrainer = oauthService
+	daemonCtl.socketPath = strings.TrimSpace(*socketPath)
+	daemonCtl.stateFile = stateStore.Path()
+	daemonCtl.runtimeStats = *includeRuntimeStats
+	quotaScheduler := core.NewQuotaScheduler(manager, *quotaSyncInterval)
+	api := server.New(manager, oauthService, daemonCtl, server.WithQuotaScheduler(quotaScheduler), server.WithAPIToken(strings.TrimSpace(*apiToken)), server.WithAPIRateLimit(*apiRateLimit, *apiRateLimit), server.WithTracerProvider(tracerProvider), server.WithLogBuffer(logBuffer))
 	httpServer.Handler = api.Handler()
+	if socketServer != nil {
+		socketServer.Handler = httpServer.Handler
+	}
+
+	schedulerCtx, stopScheduler := context.WithCancel(context.Background())
+	defer stopScheduler()
+	go quotaScheduler.Run(schedulerCtx)
+	if err := manager.WatchState(schedulerCtx); err != nil {
+		log.Fatalf("watch state file: %v", err)
+	}
 
-	fmt.Printf("switchlyd listening on http://%s\n", *addr)
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+	fmt.Printf("switchlyd listening on %s://%s\n", scheme, *addr)
 	fmt.Printf("state file: %s\n", stateStore.Path())
 	if daemonCtl.defaultRestartCmd == "" {
 		fmt.Println("daemon restart API: disabled (set --restart-cmd when running via go run)")
 	}
-	if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+	if *quotaSyncInterval > 0 {
+		fmt.Printf("background quota sync: every %s\n", *quotaSyncInterval)
+	}
+	if socketServer != nil {
+		fmt.Printf("socket: %s\n", *socketPath)
+		go func() {
+			if err := socketServer.Serve(socketListener); err != nil && err != http.ErrServerClosed {
+				log.Printf("socket server: %v", err)
+			}
+		}()
+	}
+	if metricsServer != nil {
+		fmt.Printf("metrics: http://%s/metrics\n", metricsServer.Addr)
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Printf("metrics server: %v", err)
+			}
+		}()
+	}
+	if tlsConfig != nil {
+		err = httpServer.ListenAndServeTLS(*tlsCert, *tlsKey)
+	} else {
+		err = httpServer.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
 		log.Fatal(err)
 	}
 }
 
+func listenUnixSocket(path string) (net.Listener, error) {
+	// A socket left behind by a crashed daemon would make Listen fail.
+	if info, err := os.Lstat(path); err == nil {
+		if info.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("%s exists and is not a socket", path)
+		}
+		if err := os.Remove(path); err != nil {
+			return nil, err
+		}
+	}
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		_ = ln.Close()
+		return nil, err
+	}
+	return ln, nil
+}
+
 type oauthCallbackLeases struct {
 	mu        sync.Mutex
 	listeners map[string]*oauthCallbackLease
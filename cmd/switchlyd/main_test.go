@@ -1,10 +1,20 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
+
+	"switchly/internal/oauth"
+	"switchly/internal/store"
 )
 
 func TestOAuthCallbackLeasesAcquireAndRelease(t *testing.T) {
@@ -55,3 +65,307 @@ func reserveTCPAddr(t *testing.T) string {
 	_ = ln.Close()
 	return addr
 }
+
+func TestListenUnixSocketReplacesStaleSocket(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets are not used on windows")
+	}
+	dir, err := os.MkdirTemp("", "swly")
+	if err != nil {
+		t.Fatalf("temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "d.sock")
+
+	stale, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatalf("seed socket: %v", err)
+	}
+	// Simulate a crash: the socket file outlives its listener.
+	stale.(*net.UnixListener).SetUnlinkOnClose(false)
+	_ = stale.Close()
+
+	ln, err := listenUnixSocket(path)
+	if err != nil {
+		t.Fatalf("listen over stale socket: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("stat socket: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Fatalf("expected socket mode 0600, got %o", perm)
+	}
+	_ = ln.Close()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("expected socket removed on close, got %v", err)
+	}
+
+	if err := os.WriteFile(path, []byte("x"), 0o600); err != nil {
+		t.Fatalf("seed regular file: %v", err)
+	}
+	if _, err := listenUnixSocket(path); err == nil {
+		t.Fatal("expected error when path is a regular file")
+	}
+}
+
+func restartTestFlags(t *testing.T, args ...string) *flag.FlagSet {
+	t.Helper()
+	fs := flag.NewFlagSet("switchlyd", flag.ContinueOnError)
+	for _, name := range []string{"addr", "api-token", "tls-cert", "tls-key", "tls-ca", "socket-path", "metrics-addr"} {
+		fs.String(name, "", "")
+	}
+	if err := fs.Parse(args); err != nil {
+		t.Fatalf("parse flags: %v", err)
+	}
+	return fs
+}
+
+func TestRestartPassesAPITokenInEnv(t *testing.T) {
+	flags := restartTestFlags(t, "--addr", "127.0.0.1:7777", "--api-token", `s3"cret`)
+	if cmd := buildRestartCmd("/usr/bin/switchlyd", flags); strings.Contains(cmd, "api-token") || strings.Contains(cmd, "cret") {
+		t.Fatalf("expected the token to stay out of the restart command, got %s", cmd)
+	}
+	if env := restartEnv(flags); len(env) != 1 || env[0] != `SWITCHLY_API_TOKEN=s3"cret` {
+		t.Fatalf("expected the token in the restart environment, got %v", env)
+	}
+	if env := restartEnv(restartTestFlags(t, "--addr", "127.0.0.1:7777")); len(env) != 0 {
+		t.Fatalf("expected no restart environment without a token, got %v", env)
+	}
+
+	fromEnv := restartTestFlags(t)
+	getenv := func(name string) string {
+		if name == "SWITCHLY_API_TOKEN" {
+			return "env-token"
+		}
+		return ""
+	}
+	if err := secretFlagsFromEnv(fromEnv, getenv); err != nil {
+		t.Fatalf("secret flags from env: %v", err)
+	}
+	if got := fromEnv.Lookup("api-token").Value.String(); got != "env-token" {
+		t.Fatalf("expected the token from the environment, got %q", got)
+	}
+	explicit := restartTestFlags(t, "--api-token", "flag-token")
+	if err := secretFlagsFromEnv(explicit, getenv); err != nil {
+		t.Fatalf("secret flags from env: %v", err)
+	}
+	if got := explicit.Lookup("api-token").Value.String(); got != "flag-token" {
+		t.Fatalf("expected --api-token to win over the environment, got %q", got)
+	}
+
+	ctrl := newDaemonController("127.0.0.1:7777", "http://localhost:7777", `"/usr/bin/switchlyd" --api-token s3cret`, "s3cret")
+	if info := ctrl.Info(); strings.Contains(info.DefaultRestartCmd, "s3cret") {
+		t.Fatalf("expected token to be redacted from daemon info, got %q", info.DefaultRestartCmd)
+	}
+}
+
+func TestRestartKeepsTLS(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script as the daemon")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "args")
+	exe := filepath.Join(dir, "switchly d")
+	script := "#!/bin/sh\nprintf '%s\\n' \"$@\" \"--api-token=$SWITCHLY_API_TOKEN\" > '" + out + ".tmp' && mv '" + out + ".tmp' '" + out + "'\n"
+	if err := os.WriteFile(exe, []byte(script), 0o700); err != nil {
+		t.Fatalf("write fake daemon: %v", err)
+	}
+
+	flags := restartTestFlags(t,
+		"--addr", "127.0.0.1:7777",
+		"--tls-cert", filepath.Join(dir, "it's cert.pem"),
+		"--tls-key", filepath.Join(dir, "key.pem"),
+		"--tls-ca", filepath.Join(dir, "ca.pem"),
+		"--socket-path", filepath.Join(dir, "switchly.sock"),
+		"--metrics-addr", "127.0.0.1:9090",
+		"--api-token", "s3cret",
+	)
+	ctrl := newDaemonController("127.0.0.1:7777", "http://localhost:7777", buildRestartCmd(exe, flags), "s3cret")
+	ctrl.restartEnv = restartEnv(flags)
+	if err := ctrl.Restart(""); err != nil {
+		t.Fatalf("restart: %v", err)
+	}
+
+	var data []byte
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		var err error
+		if data, err = os.ReadFile(out); err == nil && len(data) > 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("replacement daemon did not start: %v", err)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	got := restartTestFlags(t, strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")...)
+	for _, name := range []string{"addr", "tls-cert", "tls-key", "tls-ca", "socket-path", "metrics-addr", "api-token"} {
+		if got.Lookup(name).Value.String() != flags.Lookup(name).Value.String() {
+			t.Fatalf("expected --%s=%q after restart, got %q", name, flags.Lookup(name).Value, got.Lookup(name).Value)
+		}
+	}
+}
+
+type blockingDrainer struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (d *blockingDrainer) WaitForPendingCallbacks(ctx context.Context) error {
+	close(d.started)
+	select {
+	case <-d.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func TestShutdownDrainsOAuthCallbacksFirst(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(listener) }()
+
+	drainer := &blockingDrainer{started: make(chan struct{}), release: make(chan struct{})}
+	ctrl := newDaemonController(listener.Addr().String(), "http://"+listener.Addr().String(), "", "", srv)
+	ctrl.oauthDrainer = drainer
+	if err := ctrl.Shutdown(); err != nil {
+		t.Fatalf("shutdown: %v", err)
+	}
+	<-drainer.started
+
+	// While callbacks drain, the server keeps answering.
+	time.Sleep(200 * time.Millisecond)
+	resp, err := http.Get("http://" + listener.Addr().String() + "/")
+	if err != nil {
+		t.Fatalf("expected the server to stay up while draining: %v", err)
+	}
+	resp.Body.Close()
+
+	close(drainer.release)
+	select {
+	case err := <-served:
+		if err != http.ErrServerClosed {
+			t.Fatalf("unexpected serve error: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("server did not shut down after the drain")
+	}
+}
+
+func TestDaemonInfoRuntimeStats(t *testing.T) {
+	ctrl := newDaemonController("127.0.0.1:7777", "http://localhost:7777", "", "")
+	info := ctrl.Info()
+	if info.Runtime == nil {
+		t.Fatal("expected runtime stats by default")
+	}
+	if info.Runtime.NumGoroutines <= 0 {
+		t.Fatalf("expected goroutines > 0, got %d", info.Runtime.NumGoroutines)
+	}
+	if info.Runtime.GoVersion != runtime.Version() {
+		t.Fatalf("expected go version %s, got %s", runtime.Version(), info.Runtime.GoVersion)
+	}
+	if info.Runtime.HeapSysBytes == 0 {
+		t.Fatal("expected heap sys bytes to be reported")
+	}
+
+	ctrl.runtimeStats = false
+	if info := ctrl.Info(); info.Runtime != nil {
+		t.Fatalf("expected runtime stats omitted, got %+v", info.Runtime)
+	}
+}
+
+func TestOAuthStartFallsBackWhenCallbackPortIsBusy(t *testing.T) {
+	busy, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("occupy port: %v", err)
+	}
+	defer busy.Close()
+	busyURI := "http://" + busy.Addr().String() + "/auth/callback"
+	freeURI := "http://" + reserveTCPAddr(t) + "/auth/callback"
+
+	leases := newOAuthCallbackLeases()
+	defer leases.CloseAll()
+	svc, err := oauth.NewService(nil, "http://localhost:7777",
+		oauth.WithCallbackLeaseManager(leases),
+		oauth.WithProviderConfig(oauth.ProviderConfig{
+			Provider:     "codex",
+			AuthURL:      "https://auth.example.com/authorize",
+			RedirectURIs: []string{busyURI, freeURI},
+		}),
+	)
+	if err != nil {
+		t.Fatalf("new oauth service: %v", err)
+	}
+	defer svc.Stop()
+
+	snap, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if !strings.Contains(snap.AuthURL, url.QueryEscape(freeURI)) {
+		t.Fatalf("expected auth URL to use %s, got %s", freeURI, snap.AuthURL)
+	}
+	resp, err := http.Get(freeURI + "?state=unknown")
+	if err != nil {
+		t.Fatalf("expected callback listener on %s: %v", freeURI, err)
+	}
+	_ = resp.Body.Close()
+}
+
+func TestOpenStateStoreFlagOverridesEnv(t *testing.T) {
+	dir := t.TempDir()
+	envPath := filepath.Join(dir, "env.json")
+	flagPath := filepath.Join(dir, "flag.json")
+	t.Setenv(store.StateFileEnv, envPath)
+
+	st, err := openStateStore("", "")
+	if err != nil {
+		t.Fatalf("open with env: %v", err)
+	}
+	if st.Path() != envPath {
+		t.Fatalf("expected env path %s, got %s", envPath, st.Path())
+	}
+
+	st, err = openStateStore(flagPath, "")
+	if err != nil {
+		t.Fatalf("open with flag: %v", err)
+	}
+	if st.Path() != flagPath {
+		t.Fatalf("expected flag path %s, got %s", flagPath, st.Path())
+	}
+
+	if _, err := openStateStore("state.json", ""); err == nil {
+		t.Fatal("expected relative --state-file to be rejected")
+	}
+}
+
+func TestOpenStateStoreDriver(t *testing.T) {
+	dbPath := filepath.Join(t.TempDir(), "state.db")
+	t.Setenv(store.StateFileEnv, "")
+	t.Setenv(store.StateDriverEnv, "")
+
+	st, err := openStateStore(dbPath, store.DriverSQLite)
+	if err != nil {
+		t.Fatalf("open sqlite store: %v", err)
+	}
+	sqliteStore, ok := st.(*store.SQLiteStateStore)
+	if !ok {
+		t.Fatalf("expected a SQLite store, got %T", st)
+	}
+	defer sqliteStore.Close()
+	if got := os.Getenv(store.StateDriverEnv); got != store.DriverSQLite {
+		t.Fatalf("expected the driver to be exported for restarts, got %q", got)
+	}
+
+	if _, err := openStateStore(dbPath, "yaml"); err == nil {
+		t.Fatal("expected an unknown driver to be rejected")
+	}
+}
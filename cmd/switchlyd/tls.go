@@ -0,0 +1,49 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// serverTLSConfig validates the TLS flags and returns nil when TLS is off.
+// A CA bundle turns on mutual TLS.
+func serverTLSConfig(certFile, keyFile, caFile string) (*tls.Config, error) {
+	certFile, keyFile, caFile = strings.TrimSpace(certFile), strings.TrimSpace(keyFile), strings.TrimSpace(caFile)
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--tls-cert and --tls-key must be set together")
+	}
+	if certFile == "" {
+		if caFile != "" {
+			return nil, errors.New("--tls-ca requires --tls-cert and --tls-key")
+		}
+		return nil, nil
+	}
+
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile == "" {
+		return cfg, nil
+	}
+	pool, err := loadCertPool(caFile)
+	if err != nil {
+		return nil, err
+	}
+	cfg.ClientCAs = pool
+	cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	return cfg, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read CA bundle: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in %s", path)
+	}
+	return pool, nil
+}
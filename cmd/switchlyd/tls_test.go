@@ -0,0 +1,48 @@
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerTLSConfig(t *testing.T) {
+	if cfg, err := serverTLSConfig("", "", ""); cfg != nil || err != nil {
+		t.Fatalf("expected TLS to be off, got %v %v", cfg, err)
+	}
+	for _, args := range [][3]string{
+		{"cert.pem", "", ""},
+		{"", "key.pem", ""},
+		{"", "", "ca.pem"},
+	} {
+		if _, err := serverTLSConfig(args[0], args[1], args[2]); err == nil {
+			t.Fatalf("expected error for %v", args)
+		}
+	}
+
+	cfg, err := serverTLSConfig("cert.pem", "key.pem", "")
+	if err != nil {
+		t.Fatalf("serverTLSConfig: %v", err)
+	}
+	if cfg.ClientAuth != tls.NoClientCert {
+		t.Fatalf("expected no client auth without a CA, got %v", cfg.ClientAuth)
+	}
+
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	if err := os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err = serverTLSConfig("cert.pem", "key.pem", caFile)
+	if err != nil {
+		t.Fatalf("serverTLSConfig with CA: %v", err)
+	}
+	if cfg.ClientAuth != tls.RequireAndVerifyClientCert || cfg.ClientCAs == nil {
+		t.Fatalf("expected mutual TLS config, got %+v", cfg)
+	}
+}
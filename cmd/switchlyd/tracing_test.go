@@ -0,0 +1,44 @@
+package main
+
+import "testing"
+
+func TestOTLPEndpointOptions(t *testing.T) {
+	tests := []struct {
+		endpoint string
+		wantOpts int
+		wantErr  bool
+	}{
+		{endpoint: "localhost:4318", wantOpts: 2},
+		{endpoint: "http://collector:4318", wantOpts: 2},
+		{endpoint: "https://collector.example.com/custom/traces", wantOpts: 2},
+		{endpoint: "https://collector.example.com/", wantOpts: 1},
+		{endpoint: "grpc://collector:4317", wantErr: true},
+		{endpoint: "http://", wantErr: true},
+	}
+	for _, tt := range tests {
+		opts, err := otlpEndpointOptions(tt.endpoint)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected an error", tt.endpoint)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.endpoint, err)
+		}
+		if len(opts) != tt.wantOpts {
+			t.Fatalf("%s: expected %d options, got %d", tt.endpoint, tt.wantOpts, len(opts))
+		}
+	}
+}
+
+func TestSetupTracingDisabledWithoutEndpoint(t *testing.T) {
+	tp, shutdown, err := setupTracing("", "switchly")
+	if err != nil {
+		t.Fatalf("setupTracing: %v", err)
+	}
+	if tp != nil {
+		t.Fatal("expected no tracer provider without an endpoint")
+	}
+	shutdown()
+}
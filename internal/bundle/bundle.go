@@ -0,0 +1,130 @@
+// Package bundle encrypts account exports with a passphrase so they can be
+// moved between machines.
+package bundle
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+)
+
+const (
+	// Format identifies a Switchly bundle envelope.
+	Format = "switchly-bundle"
+	// Version is the envelope version written by Seal.
+	Version = 1
+
+	kdfArgon2id = "argon2id"
+	saltSize    = 16
+	keySize     = 32
+
+	// Ceilings on the key derivation cost Open accepts, so an uploaded
+	// bundle cannot make the daemon allocate gigabytes or spin.
+	maxKDFTime    = 10
+	maxKDFMemory  = 1024 * 1024 // KiB, i.e. 1 GiB
+	maxKDFThreads = 16
+)
+
+var (
+	ErrWrongPassphrase = errors.New("incorrect passphrase or corrupted bundle")
+	ErrEmptyPassphrase = errors.New("passphrase is required")
+)
+
+// KDFParams records how the key was derived so later versions can raise the
+// cost without breaking old bundles.
+type KDFParams struct {
+	Name    string `json:"name"`
+	Salt    []byte `json:"salt"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory_kib"`
+	Threads uint8  `json:"threads"`
+}
+
+// Envelope is the on-disk bundle. Byte fields are base64 encoded in JSON.
+type Envelope struct {
+	Format     string    `json:"format"`
+	Version    int       `json:"version"`
+	KDF        KDFParams `json:"kdf"`
+	Nonce      []byte    `json:"nonce"`
+	Ciphertext []byte    `json:"ciphertext"`
+}
+
+var defaultKDF = KDFParams{Name: kdfArgon2id, Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// Seal encrypts plaintext with AES-256-GCM under a key derived from
+// passphrase with Argon2id.
+func Seal(plaintext []byte, passphrase string) (Envelope, error) {
+	if passphrase == "" {
+		return Envelope{}, ErrEmptyPassphrase
+	}
+	kdf := defaultKDF
+	kdf.Salt = make([]byte, saltSize)
+	if _, err := rand.Read(kdf.Salt); err != nil {
+		return Envelope{}, fmt.Errorf("generate salt: %w", err)
+	}
+	aead, err := newAEAD(kdf, passphrase)
+	if err != nil {
+		return Envelope{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return Envelope{}, fmt.Errorf("generate nonce: %w", err)
+	}
+	env := Envelope{Format: Format, Version: Version, KDF: kdf, Nonce: nonce}
+	env.Ciphertext = aead.Seal(nil, nonce, plaintext, env.additionalData())
+	return env, nil
+}
+
+// Open decrypts an envelope produced by Seal. A wrong passphrase and a
+// tampered envelope are indistinguishable and both return ErrWrongPassphrase.
+func Open(env Envelope, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, ErrEmptyPassphrase
+	}
+	if env.Format != Format {
+		return nil, fmt.Errorf("not a switchly bundle (format %q)", env.Format)
+	}
+	if env.Version != Version {
+		return nil, fmt.Errorf("unsupported bundle version %d", env.Version)
+	}
+	if env.KDF.Name != kdfArgon2id {
+		return nil, fmt.Errorf("unsupported key derivation %q", env.KDF.Name)
+	}
+	if env.KDF.Time == 0 || env.KDF.Memory == 0 || env.KDF.Threads == 0 || len(env.KDF.Salt) == 0 {
+		return nil, errors.New("bundle key derivation parameters are incomplete")
+	}
+	if env.KDF.Time > maxKDFTime || env.KDF.Memory > maxKDFMemory || env.KDF.Threads > maxKDFThreads {
+		return nil, fmt.Errorf("bundle key derivation parameters exceed the limits (time %d, memory %d KiB, threads %d)", maxKDFTime, maxKDFMemory, maxKDFThreads)
+	}
+	aead, err := newAEAD(env.KDF, passphrase)
+	if err != nil {
+		return nil, err
+	}
+	if len(env.Nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	plaintext, err := aead.Open(nil, env.Nonce, env.Ciphertext, env.additionalData())
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return plaintext, nil
+}
+
+// additionalData binds the header to the ciphertext so the format and
+// version cannot be swapped without failing authentication.
+func (e Envelope) additionalData() []byte {
+	return []byte(fmt.Sprintf("%s/v%d", e.Format, e.Version))
+}
+
+func newAEAD(kdf KDFParams, passphrase string) (cipher.AEAD, error) {
+	key := argon2.IDKey([]byte(passphrase), kdf.Salt, kdf.Time, kdf.Memory, kdf.Threads, keySize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,83 @@
+package bundle
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	plaintext := []byte(`{"accounts":[{"id":"A"}]}`)
+	env, err := Seal(plaintext, "correct horse")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if env.Format != Format || env.Version != Version {
+		t.Fatalf("unexpected header: %s v%d", env.Format, env.Version)
+	}
+	if bytes.Contains(env.Ciphertext, []byte("accounts")) {
+		t.Fatal("ciphertext contains plaintext")
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var decoded Envelope
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	got, err := Open(decoded, "correct horse")
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if !bytes.Equal(got, plaintext) {
+		t.Fatalf("round trip mismatch: %s", got)
+	}
+}
+
+func TestOpenRejectsWrongPassphraseAndTampering(t *testing.T) {
+	env, err := Seal([]byte("secret"), "right")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	if _, err := Open(env, "wrong"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase, got %v", err)
+	}
+
+	tampered := env
+	tampered.Ciphertext = append([]byte(nil), env.Ciphertext...)
+	tampered.Ciphertext[0] ^= 0xff
+	if _, err := Open(tampered, "right"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected ErrWrongPassphrase for tampered data, got %v", err)
+	}
+
+	future := env
+	future.Version = Version + 1
+	if _, err := Open(future, "right"); err == nil || errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("expected an unsupported version error, got %v", err)
+	}
+	if _, err := Open(env, ""); !errors.Is(err, ErrEmptyPassphrase) {
+		t.Fatalf("expected ErrEmptyPassphrase, got %v", err)
+	}
+}
+
+func TestOpenRejectsOversizedKDFParams(t *testing.T) {
+	env, err := Seal([]byte("secret"), "right")
+	if err != nil {
+		t.Fatalf("Seal: %v", err)
+	}
+	for name, raise := range map[string]func(*KDFParams){
+		"memory":  func(k *KDFParams) { k.Memory = 4294967295 },
+		"time":    func(k *KDFParams) { k.Time = 1 << 30 },
+		"threads": func(k *KDFParams) { k.Threads = 255 },
+	} {
+		oversized := env
+		raise(&oversized.KDF)
+		if _, err := Open(oversized, "right"); err == nil || !strings.Contains(err.Error(), "exceed") {
+			t.Fatalf("%s: expected the parameters to be rejected, got %v", name, err)
+		}
+	}
+}
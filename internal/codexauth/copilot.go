@@ -0,0 +1,88 @@
+package codexauth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"switchly/internal/model"
+)
+
+// ProviderCopilot is the provider of accounts imported from GitHub Copilot.
+const ProviderCopilot = "copilot"
+
+var ErrCopilotTokenNotFound = errors.New("github copilot token not found")
+
+type copilotHost struct {
+	User       string `json:"user"`
+	OAuthToken string `json:"oauth_token"`
+}
+
+// CopilotHostsFilePath returns where the Copilot editor plugins keep their
+// GitHub token: %LOCALAPPDATA% on Windows, $XDG_CONFIG_HOME or ~/.config
+// elsewhere.
+func CopilotHostsFilePath() (string, error) {
+	return copilotHostsFilePath(os.Getenv)
+}
+
+func copilotHostsFilePath(getenv func(string) string) (string, error) {
+	if runtime.GOOS == "windows" {
+		if dir := getenv("LOCALAPPDATA"); dir != "" {
+			return filepath.Join(dir, "github-copilot", "hosts.json"), nil
+		}
+	}
+	if dir := getenv("XDG_CONFIG_HOME"); dir != "" {
+		return filepath.Join(dir, "github-copilot", "hosts.json"), nil
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".config", "github-copilot", "hosts.json"), nil
+}
+
+// LoadGitHubCopilotAccount reads the github.com token from the Copilot
+// hosts.json. Without one it falls back to GITHUB_TOKEN, which needs
+// GITHUB_USER to name the account.
+func LoadGitHubCopilotAccount() (LocalAccount, error) {
+	path, err := CopilotHostsFilePath()
+	if err != nil {
+		return LocalAccount{}, err
+	}
+	return loadGitHubCopilotAccount(path, os.Getenv)
+}
+
+func loadGitHubCopilotAccount(path string, getenv func(string) string) (LocalAccount, error) {
+	var host copilotHost
+	data, err := os.ReadFile(path)
+	switch {
+	case err == nil:
+		var hosts map[string]copilotHost
+		if err := json.Unmarshal(data, &hosts); err != nil {
+			return LocalAccount{}, fmt.Errorf("parse %s: %w", path, err)
+		}
+		host = hosts["github.com"]
+	case !errors.Is(err, os.ErrNotExist):
+		return LocalAccount{}, err
+	}
+	if strings.TrimSpace(host.OAuthToken) == "" {
+		host = copilotHost{User: getenv("GITHUB_USER"), OAuthToken: getenv("GITHUB_TOKEN")}
+	}
+
+	token := strings.TrimSpace(host.OAuthToken)
+	if token == "" {
+		return LocalAccount{}, fmt.Errorf("%w in %s or GITHUB_TOKEN", ErrCopilotTokenNotFound, path)
+	}
+	user := strings.ToLower(strings.TrimSpace(host.User))
+	if user == "" {
+		return LocalAccount{}, errors.New("github copilot token has no user name; set GITHUB_USER")
+	}
+	return LocalAccount{
+		ID:      ProviderCopilot + ":" + user,
+		Secrets: model.AuthSecrets{AccessToken: token},
+	}, nil
+}
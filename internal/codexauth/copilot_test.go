@@ -0,0 +1,49 @@
+package codexauth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadGitHubCopilotAccountFromHostsFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "hosts.json")
+	hosts := `{"github.com":{"user":"OctoCat","oauth_token":" gho_secret "},"ghe.example.com":{"user":"other","oauth_token":"ghu_other"}}`
+	if err := os.WriteFile(path, []byte(hosts), 0o600); err != nil {
+		t.Fatalf("write hosts.json: %v", err)
+	}
+	env := map[string]string{"GITHUB_TOKEN": "env-token", "GITHUB_USER": "env-user"}
+
+	acct, err := loadGitHubCopilotAccount(path, func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if acct.ID != "copilot:octocat" || acct.Secrets.AccessToken != "gho_secret" {
+		t.Fatalf("unexpected account: %#v", acct)
+	}
+}
+
+func TestLoadGitHubCopilotAccountFallsBackToEnv(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "hosts.json")
+	env := map[string]string{}
+	getenv := func(k string) string { return env[k] }
+
+	if _, err := loadGitHubCopilotAccount(missing, getenv); !errors.Is(err, ErrCopilotTokenNotFound) {
+		t.Fatalf("expected ErrCopilotTokenNotFound, got %v", err)
+	}
+
+	env["GITHUB_TOKEN"] = "ghp_env"
+	if _, err := loadGitHubCopilotAccount(missing, getenv); err == nil {
+		t.Fatal("expected an error without a user name")
+	}
+
+	env["GITHUB_USER"] = "hubot"
+	acct, err := loadGitHubCopilotAccount(missing, getenv)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if acct.ID != "copilot:hubot" || acct.Secrets.AccessToken != "ghp_env" {
+		t.Fatalf("unexpected account: %#v", acct)
+	}
+}
@@ -0,0 +1,78 @@
+package codexauth
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"switchly/internal/model"
+)
+
+// ErrNoEnvAccounts is returned when no account variables are set.
+var ErrNoEnvAccounts = errors.New("no SWITCHLY_ACCOUNT_<n>_ACCESS_TOKEN or SWITCHLY_ACCESS_TOKEN variables set")
+
+// LoadEnvAccounts reads accounts from the environment for headless setups
+// such as CI. Indexed accounts use SWITCHLY_ACCOUNT_<n>_ACCESS_TOKEN and the
+// matching _ID, _EMAIL, _REFRESH_TOKEN, _ID_TOKEN and _ACCOUNT_ID variables,
+// starting at 0 and stopping at the first index without an access token.
+// SWITCHLY_ACCESS_TOKEN and friends describe one more account without an
+// index.
+func LoadEnvAccounts() ([]LocalAccount, error) {
+	return loadEnvAccounts(os.Getenv)
+}
+
+func loadEnvAccounts(getenv func(string) string) ([]LocalAccount, error) {
+	var out []LocalAccount
+	for i := 0; ; i++ {
+		prefix := fmt.Sprintf("SWITCHLY_ACCOUNT_%d_", i)
+		if strings.TrimSpace(getenv(prefix+"ACCESS_TOKEN")) == "" {
+			break
+		}
+		acct, err := envAccount(getenv, prefix)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, acct)
+	}
+	if strings.TrimSpace(getenv("SWITCHLY_ACCESS_TOKEN")) != "" {
+		acct, err := envAccount(getenv, "SWITCHLY_")
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, acct)
+	}
+	if len(out) == 0 {
+		return nil, ErrNoEnvAccounts
+	}
+	return out, nil
+}
+
+func envAccount(getenv func(string) string, prefix string) (LocalAccount, error) {
+	get := func(name string) string { return strings.TrimSpace(getenv(prefix + name)) }
+
+	idToken := get("ID_TOKEN")
+	tokenEmail, tokenAccountID := DecodeEmailAndAccountID(idToken)
+	email := firstNonEmpty(get("EMAIL"), tokenEmail)
+	accountID := firstNonEmpty(get("ACCOUNT_ID"), tokenAccountID)
+
+	id := get("ID")
+	if id == "" {
+		// BuildCodexAccountID falls back to a timestamp, which would give
+		// every such account the same ID within one import.
+		if email == "" && accountID == "" {
+			return LocalAccount{}, fmt.Errorf("set %sID, %sEMAIL or %sACCOUNT_ID to identify the account", prefix, prefix, prefix)
+		}
+		id = BuildCodexAccountID(email, accountID)
+	}
+	return LocalAccount{
+		ID:    id,
+		Email: email,
+		Secrets: model.AuthSecrets{
+			AccessToken:  get("ACCESS_TOKEN"),
+			RefreshToken: get("REFRESH_TOKEN"),
+			IDToken:      idToken,
+			AccountID:    accountID,
+		},
+	}, nil
+}
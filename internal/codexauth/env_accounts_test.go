@@ -0,0 +1,55 @@
+package codexauth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestLoadEnvAccountsDerivesIDs(t *testing.T) {
+	claims, _ := json.Marshal(map[string]any{"email": "CI@Example.com"})
+	idToken := "h." + base64.RawURLEncoding.EncodeToString(claims) + ".s"
+	env := map[string]string{
+		"SWITCHLY_ACCOUNT_0_ID":            "explicit",
+		"SWITCHLY_ACCOUNT_0_ACCESS_TOKEN":  "access-0",
+		"SWITCHLY_ACCOUNT_0_REFRESH_TOKEN": "refresh-0",
+		"SWITCHLY_ACCOUNT_1_ACCESS_TOKEN":  "access-1",
+		"SWITCHLY_ACCOUNT_1_ID_TOKEN":      idToken,
+		// Index 2 is missing, so index 3 is never read.
+		"SWITCHLY_ACCOUNT_3_ACCESS_TOKEN": "access-3",
+		"SWITCHLY_ACCESS_TOKEN":           "access-single",
+		"SWITCHLY_REFRESH_TOKEN":          "refresh-single",
+		"SWITCHLY_ACCOUNT_ID":             "acct-single",
+	}
+	accounts, err := loadEnvAccounts(func(k string) string { return env[k] })
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	var ids []string
+	for _, acct := range accounts {
+		ids = append(ids, acct.ID)
+	}
+	if got := strings.Join(ids, ","); got != "explicit,codex:ci@example.com,codex:acct-single" {
+		t.Fatalf("unexpected ids: %s", got)
+	}
+	if accounts[0].Secrets.RefreshToken != "refresh-0" || accounts[1].Email != "CI@Example.com" {
+		t.Fatalf("unexpected accounts: %#v", accounts[:2])
+	}
+	if accounts[2].Secrets.AccessToken != "access-single" || accounts[2].Secrets.RefreshToken != "refresh-single" {
+		t.Fatalf("unexpected single account: %#v", accounts[2])
+	}
+}
+
+func TestLoadEnvAccountsErrors(t *testing.T) {
+	if _, err := loadEnvAccounts(func(string) string { return "" }); !errors.Is(err, ErrNoEnvAccounts) {
+		t.Fatalf("expected ErrNoEnvAccounts, got %v", err)
+	}
+
+	env := map[string]string{"SWITCHLY_ACCOUNT_0_ACCESS_TOKEN": "access-0"}
+	if _, err := loadEnvAccounts(func(k string) string { return env[k] }); err == nil || !strings.Contains(err.Error(), "SWITCHLY_ACCOUNT_0_ID") {
+		t.Fatalf("expected an error naming the missing id, got %v", err)
+	}
+}
@@ -7,34 +7,77 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
+	"time"
 
 	"switchly/internal/model"
+	"switchly/internal/platform"
 )
 
 type FileApplier struct {
-	path string
+	path           string
+	skipGitignore  bool
+	backupEnabled  bool
+	backupMaxCount int
 }
 
+type FileApplierOption func(*FileApplier)
+
 const codexAuthFilePathEnv = "SWITCHLY_CODEX_AUTH_FILE"
 
-func NewDefaultFileApplier() *FileApplier {
-	return &FileApplier{path: defaultAuthFilePath()}
+const defaultBackupMaxCount = 3
+
+// backupTimeFormat sorts lexically in time order.
+const backupTimeFormat = "20060102T150405.000000000Z"
+
+func NewDefaultFileApplier(opts ...FileApplierOption) *FileApplier {
+	return newFileApplier(defaultAuthFilePath(), opts)
+}
+
+func NewFileApplier(path string, opts ...FileApplierOption) *FileApplier {
+	return newFileApplier(strings.TrimSpace(path), opts)
+}
+
+func newFileApplier(path string, opts []FileApplierOption) *FileApplier {
+	a := &FileApplier{path: path, backupEnabled: true, backupMaxCount: defaultBackupMaxCount}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(a)
+		}
+	}
+	return a
+}
+
+func WithoutGitignore() FileApplierOption {
+	return func(a *FileApplier) {
+		a.skipGitignore = true
+	}
 }
 
-func NewFileApplier(path string) *FileApplier {
-	return &FileApplier{path: strings.TrimSpace(path)}
+// WithoutBackups stops Apply and Clear from keeping a copy of the previous
+// auth file.
+func WithoutBackups() FileApplierOption {
+	return func(a *FileApplier) {
+		a.backupEnabled = false
+	}
+}
+
+// WithBackupMaxCount sets how many auth.json.<timestamp>.bak files are kept.
+// Values below 1 keep the default.
+func WithBackupMaxCount(n int) FileApplierOption {
+	return func(a *FileApplier) {
+		if n > 0 {
+			a.backupMaxCount = n
+		}
+	}
 }
 
 func defaultAuthFilePath() string {
 	if explicit := strings.TrimSpace(os.Getenv(codexAuthFilePathEnv)); explicit != "" {
 		return explicit
 	}
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return ""
-	}
-	return filepath.Join(home, ".codex", "auth.json")
+	return platform.CodexAuthFilePath()
 }
 
 func (a *FileApplier) Apply(_ context.Context, account model.Account, secrets model.AuthSecrets) error {
@@ -49,9 +92,18 @@ func (a *FileApplier) Apply(_ context.Context, account model.Account, secrets mo
 	}
 
 	dir := filepath.Dir(a.path)
+	_, statErr := os.Stat(dir)
 	if err := os.MkdirAll(dir, 0o700); err != nil {
 		return fmt.Errorf("create codex auth dir: %w", err)
 	}
+	// Only a directory Switchly created gets a .gitignore; an auth file
+	// pointed into an existing directory (a project checkout, say) leaves
+	// that directory alone.
+	if errors.Is(statErr, os.ErrNotExist) {
+		if err := a.writeGitignore(dir); err != nil {
+			return err
+		}
+	}
 
 	doc := map[string]any{}
 	if data, err := os.ReadFile(a.path); err == nil && len(data) > 0 {
@@ -71,13 +123,17 @@ func (a *FileApplier) Apply(_ context.Context, account model.Account, secrets mo
 	tokens["id_token"] = secrets.IDToken
 	tokens["account_id"] = secrets.AccountID
 	doc["tokens"] = tokens
+	return a.writeDocument(doc)
+}
 
-	payload, err := json.MarshalIndent(doc, "", "  ")
-	if err != nil {
-		return fmt.Errorf("encode codex auth file: %w", err)
+func (a *FileApplier) writeGitignore(dir string) error {
+	if a.skipGitignore {
+		return nil
 	}
-	if err := os.WriteFile(a.path, payload, 0o600); err != nil {
-		return fmt.Errorf("write codex auth file: %w", err)
+	name := filepath.Base(a.path)
+	content := name + "\n" + name + ".bak\n" + name + ".*.bak\n"
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(content), 0o600); err != nil {
+		return fmt.Errorf("write codex gitignore: %w", err)
 	}
 	return nil
 }
@@ -126,8 +182,112 @@ func (a *FileApplier) writeDocument(doc map[string]any) error {
 	if err != nil {
 		return fmt.Errorf("encode codex auth file: %w", err)
 	}
+	// The backup must be on disk before the previous tokens are overwritten.
+	if err := a.backup(); err != nil {
+		return err
+	}
 	if err := os.WriteFile(a.path, payload, 0o600); err != nil {
 		return fmt.Errorf("write codex auth file: %w", err)
 	}
 	return nil
 }
+
+// backup copies the current auth file to auth.json.<timestamp>.bak and
+// prunes the oldest copies beyond backupMaxCount.
+func (a *FileApplier) backup() error {
+	if !a.backupEnabled {
+		return nil
+	}
+	data, err := os.ReadFile(a.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read codex auth file for backup: %w", err)
+	}
+
+	now := time.Now().UTC()
+	for {
+		name := a.path + "." + now.Format(backupTimeFormat) + ".bak"
+		f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			// Coarse clocks can repeat a timestamp; keep names unique.
+			now = now.Add(time.Nanosecond)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("back up codex auth file: %w", err)
+		}
+		_, err = f.Write(data)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			_ = os.Remove(name)
+			return fmt.Errorf("back up codex auth file: %w", err)
+		}
+		break
+	}
+	return a.pruneBackups()
+}
+
+// Backups lists the auth file backups, newest first.
+func (a *FileApplier) Backups() ([]string, error) {
+	dir, base := filepath.Split(a.path)
+	entries, err := os.ReadDir(filepath.Clean(dir))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		stamp, ok := strings.CutPrefix(name, base+".")
+		if entry.IsDir() || !ok || !strings.HasSuffix(stamp, ".bak") || stamp == "bak" {
+			continue
+		}
+		backups = append(backups, filepath.Join(dir, name))
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+	return backups, nil
+}
+
+func (a *FileApplier) pruneBackups() error {
+	backups, err := a.Backups()
+	if err != nil {
+		return fmt.Errorf("list codex auth backups: %w", err)
+	}
+	for i := a.backupMaxCount; i < len(backups); i++ {
+		if err := os.Remove(backups[i]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("prune codex auth backup: %w", err)
+		}
+	}
+	return nil
+}
+
+// RestoreLatestBackup puts the newest backup back in place of the auth file
+// and removes it, so repeated calls step further back. It returns the path
+// of the restored backup.
+func (a *FileApplier) RestoreLatestBackup() (string, error) {
+	backups, err := a.Backups()
+	if err != nil {
+		return "", fmt.Errorf("list codex auth backups: %w", err)
+	}
+	if len(backups) == 0 {
+		return "", errors.New("no codex auth backup to restore")
+	}
+	latest := backups[0]
+	data, err := os.ReadFile(latest)
+	if err != nil {
+		return "", fmt.Errorf("read codex auth backup: %w", err)
+	}
+	if err := os.WriteFile(a.path, data, 0o600); err != nil {
+		return "", fmt.Errorf("restore codex auth file: %w", err)
+	}
+	if err := os.Remove(latest); err != nil {
+		return "", fmt.Errorf("remove restored codex auth backup: %w", err)
+	}
+	return latest, nil
+}
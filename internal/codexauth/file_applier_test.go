@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"switchly/internal/model"
+	"switchly/internal/platform"
 )
 
 func TestDefaultFileApplierUsesGlobalPath(t *testing.T) {
@@ -35,6 +37,21 @@ func TestDefaultFileApplierUsesEnvOverride(t *testing.T) {
 	}
 }
 
+func TestDefaultAuthPathsFollowCodexDir(t *testing.T) {
+	codexDir := t.TempDir()
+	t.Setenv(codexAuthFilePathEnv, "")
+	t.Setenv(platform.CodexDirEnv, codexDir)
+	want := filepath.Join(codexDir, "auth.json")
+
+	if applier := NewDefaultFileApplier(); applier.path != want {
+		t.Fatalf("applier path mismatch: got %q want %q", applier.path, want)
+	}
+	got, err := DefaultAuthFilePath()
+	if err != nil || got != want {
+		t.Fatalf("DefaultAuthFilePath: got %q (%v), want %q", got, err, want)
+	}
+}
+
 func TestApplyUpdatesTokensAndPreservesOtherFields(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "auth.json")
 	seed := map[string]any{
@@ -90,6 +107,84 @@ func TestApplyUpdatesTokensAndPreservesOtherFields(t *testing.T) {
 	}
 }
 
+func TestApplyCreatesGitignore(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".codex")
+	applier := NewFileApplier(filepath.Join(dir, "auth.json"))
+	if err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: "x"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatalf("read gitignore: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 || lines[0] != "auth.json" || lines[1] != "auth.json.bak" || lines[2] != "auth.json.*.bak" {
+		t.Fatalf("unexpected gitignore content: %q", string(data))
+	}
+}
+
+func TestApplyNamesGitignoreAfterAuthFile(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "nested", "codex")
+	applier := NewFileApplier(filepath.Join(dir, "tokens.json"))
+	if err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: "x"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	if err != nil {
+		t.Fatalf("read gitignore: %v", err)
+	}
+	if string(data) != "tokens.json\ntokens.json.bak\ntokens.json.*.bak\n" {
+		t.Fatalf("unexpected gitignore content: %q", string(data))
+	}
+}
+
+func TestApplyLeavesExistingDirectoryAlone(t *testing.T) {
+	dir := t.TempDir()
+	applier := NewFileApplier(filepath.Join(dir, "auth.json"))
+	for range 2 {
+		if err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: "x"}); err != nil {
+			t.Fatalf("apply: %v", err)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); !os.IsNotExist(err) {
+		t.Fatalf("gitignore should not be written into an existing directory, stat err=%v", err)
+	}
+}
+
+func TestApplyKeepsExistingGitignore(t *testing.T) {
+	dir := t.TempDir()
+	gitignore := filepath.Join(dir, ".gitignore")
+	if err := os.WriteFile(gitignore, []byte("custom\n"), 0o600); err != nil {
+		t.Fatalf("seed gitignore: %v", err)
+	}
+
+	applier := NewFileApplier(filepath.Join(dir, "auth.json"))
+	if err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: "x"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+
+	data, err := os.ReadFile(gitignore)
+	if err != nil {
+		t.Fatalf("read gitignore: %v", err)
+	}
+	if string(data) != "custom\n" {
+		t.Fatalf("existing gitignore should be preserved, got %q", string(data))
+	}
+}
+
+func TestApplySkipsGitignoreWhenDisabled(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), ".codex")
+	applier := NewFileApplier(filepath.Join(dir, "auth.json"), WithoutGitignore())
+	if err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: "x"}); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".gitignore")); !os.IsNotExist(err) {
+		t.Fatalf("gitignore should not be created when disabled")
+	}
+}
+
 func TestApplySkipsNonCodexProvider(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "auth.json")
 	applier := NewFileApplier(path)
@@ -135,3 +230,90 @@ func TestClearRemovesTokensAndPreservesOtherFields(t *testing.T) {
 		t.Fatalf("expected tokens removed, got %#v", updated["tokens"])
 	}
 }
+
+func TestApplyBacksUpAndPrunesPreviousAuthFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	applier := NewFileApplier(path, WithoutGitignore(), WithBackupMaxCount(2))
+	apply := func(token string) {
+		t.Helper()
+		if err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: token}); err != nil {
+			t.Fatalf("apply %s: %v", token, err)
+		}
+	}
+
+	apply("first")
+	if backups, err := applier.Backups(); err != nil || len(backups) != 0 {
+		t.Fatalf("expected no backup when there was no previous file, got %v, %v", backups, err)
+	}
+	for _, token := range []string{"second", "third", "fourth"} {
+		apply(token)
+	}
+
+	backups, err := applier.Backups()
+	if err != nil {
+		t.Fatalf("list backups: %v", err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("expected backups pruned to 2, got %v", backups)
+	}
+	if got := readAccessToken(t, backups[0]); got != "third" {
+		t.Fatalf("expected newest backup to hold the previous tokens, got %q", got)
+	}
+	if got := readAccessToken(t, backups[1]); got != "second" {
+		t.Fatalf("expected older backup to hold %q, got %q", "second", got)
+	}
+
+	restored, err := applier.RestoreLatestBackup()
+	if err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored != backups[0] {
+		t.Fatalf("expected %s to be restored, got %s", backups[0], restored)
+	}
+	if got := readAccessToken(t, path); got != "third" {
+		t.Fatalf("expected rollback to the previous tokens, got %q", got)
+	}
+}
+
+func TestApplyFailsWhenBackupCannotBeWritten(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "auth.json")
+	if err := os.WriteFile(path, []byte(`{"tokens":{"access_token":"old"}}`), 0o600); err != nil {
+		t.Fatalf("seed file: %v", err)
+	}
+	if err := os.Chmod(dir, 0o500); err != nil {
+		t.Fatalf("chmod: %v", err)
+	}
+	defer os.Chmod(dir, 0o700)
+	if f, err := os.Create(filepath.Join(dir, "probe")); err == nil {
+		f.Close()
+		t.Skip("directory permissions are not enforced (running as root?)")
+	}
+
+	applier := NewFileApplier(path, WithoutGitignore())
+	err := applier.Apply(context.Background(), model.Account{Provider: "codex"}, model.AuthSecrets{AccessToken: "new"})
+	if err == nil || !strings.Contains(err.Error(), "back up codex auth file") {
+		t.Fatalf("expected a backup error, got %v", err)
+	}
+	if got := readAccessToken(t, path); got != "old" {
+		t.Fatalf("expected the auth file to be untouched, got %q", got)
+	}
+}
+
+func readAccessToken(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	var doc struct {
+		Tokens struct {
+			AccessToken string `json:"access_token"`
+		} `json:"tokens"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatalf("decode %s: %v", path, err)
+	}
+	return doc.Tokens.AccessToken
+}
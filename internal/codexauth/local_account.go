@@ -6,11 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"os"
-	"path/filepath"
 	"strings"
 	"time"
 
 	"switchly/internal/model"
+	"switchly/internal/platform"
 )
 
 var ErrAuthFileNotFound = errors.New("codex auth file not found")
@@ -31,11 +31,11 @@ type LocalAccount struct {
 }
 
 func DefaultAuthFilePath() (string, error) {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return "", err
+	path := platform.CodexAuthFilePath()
+	if path == "" {
+		return "", errors.New("cannot determine the codex directory")
 	}
-	return filepath.Join(home, ".codex", "auth.json"), nil
+	return path, nil
 }
 
 func ReadAuthFile(path string) (AuthFile, error) {
@@ -0,0 +1,81 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"switchly/internal/model"
+)
+
+// AccountFilter narrows the account list. Empty fields match every account;
+// the quota thresholds are pointers so that 0 ("any usage") can be asked for.
+type AccountFilter struct {
+	Status       model.AccountStatus
+	Provider     string
+	SessionAbove *int
+	WeeklyAbove  *int
+	// Limit caps the page size; 0 returns every match after Offset.
+	Limit  int
+	Offset int
+}
+
+func (f AccountFilter) validate() error {
+	switch f.Status {
+	case "", model.AccountReady, model.AccountWarning, model.AccountNeedReauth, model.AccountDisabled:
+	default:
+		return fmt.Errorf("invalid status filter: %s", f.Status)
+	}
+	if f.Limit < 0 {
+		return errors.New("limit must not be negative")
+	}
+	if f.Offset < 0 {
+		return errors.New("offset must not be negative")
+	}
+	return nil
+}
+
+func (f AccountFilter) matches(acct model.Account) bool {
+	if f.Status != "" && acct.Status != f.Status {
+		return false
+	}
+	if f.Provider != "" && !strings.EqualFold(acct.Provider, f.Provider) {
+		return false
+	}
+	if f.SessionAbove != nil && acct.Quota.Session.UsedPercent <= *f.SessionAbove {
+		return false
+	}
+	if f.WeeklyAbove != nil && acct.Quota.Weekly.UsedPercent <= *f.WeeklyAbove {
+		return false
+	}
+	return true
+}
+
+// FilterAccounts returns one page of the accounts matching filter, in
+// ListAccounts order, and the number of matches before paging. An offset
+// past the end yields an empty page.
+func (m *Manager) FilterAccounts(ctx context.Context, filter AccountFilter) ([]model.Account, int, error) {
+	if err := filter.validate(); err != nil {
+		return nil, 0, err
+	}
+	accounts, err := m.ListAccounts(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matched := accounts[:0]
+	for _, acct := range accounts {
+		if filter.matches(acct) {
+			matched = append(matched, acct)
+		}
+	}
+	total := len(matched)
+
+	start := min(filter.Offset, total)
+	end := total
+	if filter.Limit > 0 {
+		end = min(start+filter.Limit, total)
+	}
+	return matched[start:end], total, nil
+}
@@ -0,0 +1,74 @@
+package core
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func TestFilterAccounts(t *testing.T) {
+	quota := func(session, weekly int) model.QuotaSnapshot {
+		return model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: session}, Weekly: model.QuotaWindow{UsedPercent: weekly}}
+	}
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version: 1,
+			Accounts: map[string]model.Account{
+				"a": {ID: "a", Provider: "codex", Status: model.AccountReady, Quota: quota(10, 90)},
+				"b": {ID: "b", Provider: "codex", Status: model.AccountDisabled, Quota: quota(60, 20)},
+				"c": {ID: "c", Provider: "Claude", Status: model.AccountReady, Quota: quota(70, 85)},
+				"d": {ID: "d", Provider: "codex", Status: model.AccountReady, Quota: quota(50, 0)},
+			},
+		},
+	}
+	mgr := NewManager(state, &fakeSecretStore{})
+	intPtr := func(v int) *int { return &v }
+
+	tests := []struct {
+		name   string
+		filter AccountFilter
+		want   string
+		total  int
+	}{
+		{name: "none", filter: AccountFilter{}, want: "a,b,c,d", total: 4},
+		{name: "status", filter: AccountFilter{Status: model.AccountReady}, want: "a,c,d", total: 3},
+		{name: "provider ignores case", filter: AccountFilter{Provider: "claude"}, want: "c", total: 1},
+		{name: "session strictly above", filter: AccountFilter{SessionAbove: intPtr(50)}, want: "b,c", total: 2},
+		{name: "weekly above zero", filter: AccountFilter{WeeklyAbove: intPtr(0)}, want: "a,b,c", total: 3},
+		{name: "combined", filter: AccountFilter{Status: model.AccountReady, Provider: "codex", WeeklyAbove: intPtr(80)}, want: "a", total: 1},
+		{name: "limit", filter: AccountFilter{Limit: 2}, want: "a,b", total: 4},
+		{name: "offset and limit", filter: AccountFilter{Offset: 1, Limit: 2}, want: "b,c", total: 4},
+		{name: "limit past end", filter: AccountFilter{Offset: 3, Limit: 5}, want: "d", total: 4},
+		{name: "offset at end", filter: AccountFilter{Offset: 4}, want: "", total: 4},
+		{name: "offset past end", filter: AccountFilter{Status: model.AccountReady, Offset: 10}, want: "", total: 3},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			accounts, total, err := mgr.FilterAccounts(context.Background(), tc.filter)
+			if err != nil {
+				t.Fatalf("filter: %v", err)
+			}
+			ids := make([]string, 0, len(accounts))
+			for _, acct := range accounts {
+				ids = append(ids, acct.ID)
+			}
+			if got := strings.Join(ids, ","); got != tc.want || total != tc.total {
+				t.Fatalf("got %q total=%d, want %q total=%d", got, total, tc.want, tc.total)
+			}
+			if accounts == nil {
+				t.Fatal("expected an empty slice, not nil")
+			}
+		})
+	}
+}
+
+func TestFilterAccountsRejectsInvalidFilter(t *testing.T) {
+	mgr := NewManager(&fakeStateStore{state: model.DefaultState()}, &fakeSecretStore{})
+	for _, filter := range []AccountFilter{{Status: "bogus"}, {Limit: -1}, {Offset: -1}} {
+		if _, _, err := mgr.FilterAccounts(context.Background(), filter); err == nil {
+			t.Fatalf("expected error for %+v", filter)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"switchly/internal/model"
+)
+
+const (
+	EventAccountSwitched = "account.switched"
+	EventQuotaSynced     = "quota.synced"
+	EventQuotaSyncFailed = "quota.sync_failed"
+	EventAccountAdded    = "account.added"
+	EventAccountDeleted  = "account.deleted"
+	EventDaemonShutdown  = "daemon.shutdown"
+)
+
+const eventBufferSize = 64
+
+type Event struct {
+	Type          string               `json:"type"`
+	AccountID     string               `json:"account_id,omitempty"`
+	FromAccountID string               `json:"from_account_id,omitempty"`
+	Reason        string               `json:"reason,omitempty"`
+	Quota         *model.QuotaSnapshot `json:"quota,omitempty"`
+	Error         string               `json:"error,omitempty"`
+	Time          time.Time            `json:"time"`
+}
+
+// Subscribe returns a channel that receives every event emitted from now on,
+// and a function that stops delivery and closes the channel. Each subscriber
+// has its own buffer, so consumers never take events from one another.
+func (m *Manager) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+	m.eventsMu.Lock()
+	if m.eventSubs == nil {
+		m.eventSubs = map[chan Event]struct{}{}
+	}
+	m.eventSubs[ch] = struct{}{}
+	m.eventsMu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.eventsMu.Lock()
+			delete(m.eventSubs, ch)
+			m.eventsMu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// emit never blocks; a subscriber that falls behind misses events.
+func (m *Manager) emit(evt Event) {
+	if evt.Time.IsZero() {
+		evt.Time = time.Now().UTC()
+	}
+	if evt.Type == EventAccountSwitched && m.metrics != nil {
+		m.metrics.ObserveSwitch(evt.Reason)
+	}
+	m.deliverWebhook(evt)
+	m.eventsMu.Lock()
+	defer m.eventsMu.Unlock()
+	for ch := range m.eventSubs {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"fmt"
+)
+
+const (
+	HealthCheckStateFile = "state_file"
+	HealthCheckAccounts  = "accounts"
+	HealthCheckSecrets   = "secrets"
+)
+
+// HealthCheck is the outcome of one subsystem check.
+type HealthCheck struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+// HealthReport is the result of Manager.Health.
+type HealthReport struct {
+	Healthy bool                   `json:"healthy"`
+	Checks  map[string]HealthCheck `json:"checks"`
+}
+
+// Health checks that the state file can be read, that at least one account
+// exists, and that the secret store has the active account's tokens.
+func (m *Manager) Health(ctx context.Context) HealthReport {
+	_ = ctx
+	report := HealthReport{Checks: make(map[string]HealthCheck, 3)}
+	record := func(name string, err error) {
+		if err != nil {
+			report.Checks[name] = HealthCheck{Error: err.Error()}
+			return
+		}
+		report.Checks[name] = HealthCheck{OK: true}
+	}
+
+	state, err := m.stateStore.Load()
+	record(HealthCheckStateFile, err)
+	if err != nil {
+		record(HealthCheckAccounts, fmt.Errorf("state file unreadable"))
+		record(HealthCheckSecrets, fmt.Errorf("state file unreadable"))
+		return report
+	}
+
+	if len(state.Accounts) == 0 {
+		record(HealthCheckAccounts, fmt.Errorf("no accounts configured"))
+	} else {
+		record(HealthCheckAccounts, nil)
+	}
+
+	switch {
+	case state.ActiveAccountID == "":
+		record(HealthCheckSecrets, fmt.Errorf("no active account"))
+	default:
+		if _, err := m.secrets.Get(state.ActiveAccountID); err != nil {
+			record(HealthCheckSecrets, fmt.Errorf("load secrets for the active account: %w", err))
+		} else {
+			record(HealthCheckSecrets, nil)
+		}
+	}
+
+	report.Healthy = true
+	for _, check := range report.Checks {
+		report.Healthy = report.Healthy && check.OK
+	}
+	return report
+}
@@ -0,0 +1,127 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"switchly/internal/model"
+)
+
+const maxAccountHTTPTimeoutSeconds = 600
+
+// SetAccountHTTPConfig stores per-account proxy, timeout and TLS settings.
+// A zero config removes the override so the shared client is used again.
+func (m *Manager) SetAccountHTTPConfig(ctx context.Context, accountID string, cfg model.HTTPClientConfig) (model.Account, error) {
+	_ = ctx
+	cfg.ProxyURL = strings.TrimSpace(cfg.ProxyURL)
+	if err := validateHTTPClientConfig(cfg); err != nil {
+		return model.Account{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	if cfg.IsZero() {
+		acct.HTTPConfig = nil
+	} else {
+		acct.HTTPConfig = &cfg
+	}
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+func validateHTTPClientConfig(cfg model.HTTPClientConfig) error {
+	if cfg.TimeoutSeconds < 0 || cfg.TimeoutSeconds > maxAccountHTTPTimeoutSeconds {
+		return fmt.Errorf("timeout must be between 0 and %d seconds", maxAccountHTTPTimeoutSeconds)
+	}
+	if cfg.ProxyURL == "" {
+		return nil
+	}
+	_, err := parseProxyURL(cfg.ProxyURL)
+	return err
+}
+
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid proxy url: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("invalid proxy url %q: scheme must be http, https or socks5", raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("invalid proxy url %q: missing host", raw)
+	}
+	return u, nil
+}
+
+// httpClientFor returns the client used for requests on behalf of acct.
+// Accounts without overrides share m.httpClient; the rest get a client per
+// distinct config so connections are still pooled.
+func (m *Manager) httpClientFor(acct model.Account) (*http.Client, error) {
+	if acct.HTTPConfig == nil || acct.HTTPConfig.IsZero() {
+		return m.httpClient, nil
+	}
+	cfg := *acct.HTTPConfig
+
+	m.accountClientsMu.Lock()
+	defer m.accountClientsMu.Unlock()
+	if c, ok := m.accountClients[cfg]; ok {
+		return c, nil
+	}
+	c, err := m.newAccountHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if m.accountClients == nil {
+		m.accountClients = make(map[model.HTTPClientConfig]*http.Client)
+	}
+	m.accountClients[cfg] = c
+	return c, nil
+}
+
+func (m *Manager) newAccountHTTPClient(cfg model.HTTPClientConfig) (*http.Client, error) {
+	base, ok := m.httpClient.Transport.(*http.Transport)
+	if !ok || base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	transport := base.Clone()
+	if cfg.ProxyURL != "" {
+		proxy, err := parseProxyURL(cfg.ProxyURL)
+		if err != nil {
+			return nil, err
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	if cfg.SkipTLSVerify {
+		if transport.TLSClientConfig == nil {
+			transport.TLSClientConfig = &tls.Config{}
+		}
+		transport.TLSClientConfig.InsecureSkipVerify = true
+	}
+
+	timeout := m.httpClient.Timeout
+	if cfg.TimeoutSeconds > 0 {
+		timeout = time.Duration(cfg.TimeoutSeconds) * time.Second
+	}
+	return &http.Client{Transport: transport, Timeout: timeout}, nil
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+	"switchly/internal/quota"
+)
+
+func TestSyncQuotaUsesAccountProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["B"] = model.Account{ID: "B", Provider: "codex", Status: model.AccountReady}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{
+		"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+	}}
+	var directHost string
+	defaultClient := &http.Client{Timeout: 5 * time.Second, Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		directHost = r.URL.Host
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody, Request: r}, nil
+	})}
+	var gotClient *http.Client
+	mgr := NewManager(state, secrets,
+		WithHTTPClient(defaultClient),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			gotClient = httpClient
+			resp, err := httpClient.Get("http://quota.example.test/usage")
+			if err != nil {
+				return quota.Snapshot{}, err
+			}
+			resp.Body.Close()
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 10}, Weekly: &quota.Window{UsedPercent: 20}}, nil
+		}),
+	)
+
+	if _, err := mgr.SetAccountHTTPConfig(context.Background(), "A", model.HTTPClientConfig{ProxyURL: proxy.URL, TimeoutSeconds: 30}); err != nil {
+		t.Fatalf("set http config: %v", err)
+	}
+	if _, err := mgr.SyncQuotaFromCodexAPI(context.Background(), "A"); err != nil {
+		t.Fatalf("sync via proxy: %v", err)
+	}
+	if proxiedHost != "quota.example.test" {
+		t.Fatalf("expected request to go through the account proxy, proxy saw host %q", proxiedHost)
+	}
+	if gotClient == defaultClient || gotClient.Timeout != 30*time.Second {
+		t.Fatalf("expected a per-account client with a 30s timeout, got %#v", gotClient)
+	}
+
+	// B has no overrides and keeps the shared client.
+	proxiedHost = ""
+	if _, err := mgr.SyncQuotaFromCodexAPI(context.Background(), "B"); err != nil {
+		t.Fatalf("sync without proxy: %v", err)
+	}
+	if gotClient != defaultClient || directHost != "quota.example.test" || proxiedHost != "" {
+		t.Fatalf("expected the default client for B, got %#v (proxy saw %q)", gotClient, proxiedHost)
+	}
+}
+
+func TestSetAccountHTTPConfigValidates(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+
+	for _, cfg := range []model.HTTPClientConfig{
+		{ProxyURL: "ftp://proxy:21"},
+		{ProxyURL: "http://"},
+		{TimeoutSeconds: -1},
+	} {
+		if _, err := mgr.SetAccountHTTPConfig(context.Background(), "A", cfg); err == nil {
+			t.Fatalf("expected %#v to be rejected", cfg)
+		}
+	}
+	if _, err := mgr.SetAccountHTTPConfig(context.Background(), "missing", model.HTTPClientConfig{TimeoutSeconds: 5}); err == nil {
+		t.Fatal("expected missing account to fail")
+	}
+	if state.saveCalls != 0 {
+		t.Fatalf("expected no saves for rejected configs, got %d", state.saveCalls)
+	}
+}
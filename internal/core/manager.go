@@ -6,11 +6,17 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"slices"
 	"sort"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 
 	"switchly/internal/model"
 	"switchly/internal/quota"
@@ -24,14 +30,22 @@ type AddAccountInput struct {
 	ID       string
 	Provider string
 	Email    string
+	Weight   int
 	Secrets  model.AuthSecrets
 }
 
+type SwitchRulesSnapshot struct {
+	Defaults model.SwitchRules `json:"defaults"`
+	User     model.SwitchRules `json:"user"`
+}
+
 type SwitchDecision struct {
 	Switched      bool   `json:"switched"`
 	FromAccountID string `json:"from_account_id,omitempty"`
 	ToAccountID   string `json:"to_account_id,omitempty"`
 	Reason        string `json:"reason,omitempty"`
+	DryRun        bool   `json:"dry_run,omitempty"`
+	WouldSwitch   bool   `json:"would_switch,omitempty"`
 }
 
 type DeleteAccountResult struct {
@@ -46,6 +60,11 @@ type StatusSnapshot struct {
 	ActiveAccountID string                `json:"active_account_id,omitempty"`
 	Strategy        model.RoutingStrategy `json:"strategy"`
 	Accounts        []model.Account       `json:"accounts"`
+	LastError       string                `json:"last_error,omitempty"`
+	LastErrorAt     time.Time             `json:"last_error_at,omitzero"`
+	CooldownActive  bool                  `json:"cooldown_active"`
+	CooldownUntil   time.Time             `json:"cooldown_until,omitzero"`
+	Warnings        []QuotaWarning        `json:"warnings"`
 }
 
 type QuotaSyncResult struct {
@@ -54,6 +73,36 @@ type QuotaSyncResult struct {
 	SourceTimestamp time.Time           `json:"source_timestamp"`
 }
 
+// WarmupResult is returned by RunCodexWarmup: SyncQuotaWithWarmup's quota
+// plus the id of the Codex thread that warmed it up.
+type WarmupResult struct {
+	ThreadID string              `json:"thread_id"`
+	Quota    model.QuotaSnapshot `json:"quota"`
+}
+
+type ExportedAccount struct {
+	Account model.Account     `json:"account"`
+	Secrets model.AuthSecrets `json:"secrets"`
+}
+
+type QuotaResetResult struct {
+	EarliestResetAt time.Time `json:"earliest_reset_at"`
+	AccountID       string    `json:"account_id"`
+	Window          string    `json:"window"`
+}
+
+type RefreshTokenResult struct {
+	AccountID       string    `json:"account_id"`
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+}
+
+type RotateResult struct {
+	AccountID       string    `json:"account_id"`
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+	LastRefreshAt   time.Time `json:"last_refresh_at"`
+	LastAppliedAt   time.Time `json:"last_applied_at"`
+}
+
 type QuotaSyncAllItem struct {
 	AccountID string           `json:"account_id"`
 	Success   bool             `json:"success"`
@@ -68,11 +117,29 @@ type QuotaSyncAllResult struct {
 	Results    []QuotaSyncAllItem `json:"results"`
 	StartedAt  time.Time          `json:"started_at"`
 	FinishedAt time.Time          `json:"finished_at"`
+	ElapsedMS  int64              `json:"elapsed_ms"`
 }
 
+const (
+	defaultSwitchHistoryLimit = 100
+	defaultSwitchCooldown     = 60 * time.Second
+	defaultSyncAccountTimeout = 10 * time.Second
+	// One entry per 5-minute sync over 24 hours.
+	quotaHistoryLimit = 288
+
+	maxAccountAliasLength = 64
+	maxAccountNotesLength = 500
+	maxAccountMetaKeys    = 32
+	maxAccountMetaKeyLen  = 64
+)
+
 var (
-	ErrPersistSecrets = errors.New("persist secrets failed")
-	ErrPersistState   = errors.New("persist state failed")
+	ErrPersistSecrets      = errors.New("persist secrets failed")
+	ErrPersistState        = errors.New("persist state failed")
+	ErrAccountTokenExpired = errors.New("account access token expired")
+	ErrAccountNotFound     = errors.New("not found")
+	ErrReauthRequired      = errors.New("reauth required")
+	ErrNoQuotaReset        = errors.New("no upcoming quota reset known")
 )
 
 type ActiveAccountApplier interface {
@@ -80,6 +147,17 @@ type ActiveAccountApplier interface {
 	Clear(ctx context.Context) error
 }
 
+// Notifier shows a desktop notification. Calls run outside the request path,
+// so implementations may block.
+type Notifier interface {
+	Notify(title, body string) error
+}
+
+type MetricsRecorder interface {
+	ObserveSwitch(reason string)
+	ObserveQuotaSync(duration time.Duration)
+}
+
 type ManagerOption func(*Manager)
 
 func WithActiveAccountApplier(applier ActiveAccountApplier) ManagerOption {
@@ -98,8 +176,38 @@ type Manager struct {
 	stateStore stateStore
 	secrets    secrets.Store
 	applier    ActiveAccountApplier
+	notifier   Notifier
+	webhook    WebhookDeliverer
 	httpClient *http.Client
 	quotaFetch func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error)
+	codexExec  quota.CodexExecFunc
+	codexLogs  string
+	logScan    quota.ScanOptions
+	tracer     trace.Tracer
+	historyMax int
+	metrics    MetricsRecorder
+	cooldown   time.Duration
+	// postSwitchSync refreshes the new account's quota after an automatic
+	// switch, since its stored quota may be stale.
+	postSwitchSync bool
+	// syncConcurrency caps the accounts synced at once by syncQuotas.
+	syncConcurrency int
+	// syncAccountTimeout bounds each account's sync within syncQuotas.
+	syncAccountTimeout time.Duration
+	stateWatch         bool
+	tokenCheckURLs     map[string]string
+
+	openSecretStore func(backend string) (secrets.Store, error)
+
+	eventsMu  sync.Mutex
+	eventSubs map[chan Event]struct{}
+
+	accountClientsMu sync.Mutex
+	accountClients   map[model.HTTPClientConfig]*http.Client
+
+	// cooldownUntil mirrors AppState.CooldownUntil so requests arriving during
+	// a cooldown are answered without loading state.
+	cooldownUntil time.Time
 }
 
 func NewManager(stateStore stateStore, secretStore secrets.Store, opts ...ManagerOption) *Manager {
@@ -108,6 +216,14 @@ func NewManager(stateStore stateStore, secretStore secrets.Store, opts ...Manage
 		secrets:    secretStore,
 		httpClient: &http.Client{Timeout: 20 * time.Second},
 		quotaFetch: quota.FetchCodexSnapshot,
+		codexExec:  quota.ExecCodex,
+		tracer:     defaultTracer(),
+		historyMax: defaultSwitchHistoryLimit,
+		cooldown:   defaultSwitchCooldown,
+
+		syncConcurrency:    1,
+		syncAccountTimeout: defaultSyncAccountTimeout,
+		openSecretStore:    secrets.Open,
 	}
 	for _, opt := range opts {
 		if opt != nil {
@@ -117,20 +233,113 @@ func NewManager(stateStore stateStore, secretStore secrets.Store, opts ...Manage
 	return m
 }
 
+func WithNotifier(n Notifier) ManagerOption {
+	return func(m *Manager) {
+		m.notifier = n
+	}
+}
+
+// WithPostSwitchQuotaSync syncs the newly active account's quota in the
+// background after every automatic switch.
+func WithPostSwitchQuotaSync(enabled bool) ManagerOption {
+	return func(m *Manager) {
+		m.postSwitchSync = enabled
+	}
+}
+
+func WithMetricsRecorder(recorder MetricsRecorder) ManagerOption {
+	return func(m *Manager) {
+		m.metrics = recorder
+	}
+}
+
+func WithSwitchHistoryLimit(limit int) ManagerOption {
+	return func(m *Manager) {
+		if limit > 0 {
+			m.historyMax = limit
+		}
+	}
+}
+
+// WithSwitchCooldown sets how long HandleQuotaError stops searching for an
+// account after all candidates were exhausted. Zero disables the cooldown.
+func WithSwitchCooldown(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		if d >= 0 {
+			m.cooldown = d
+		}
+	}
+}
+
+// WithSyncConcurrency sets how many accounts a sync of all accounts fetches
+// at once. The default of 1 syncs them one after another.
+func WithSyncConcurrency(n int) ManagerOption {
+	return func(m *Manager) {
+		if n > 0 {
+			m.syncConcurrency = n
+		}
+	}
+}
+
+// WithSyncAccountTimeout bounds how long a sync of all accounts waits for
+// any one account, so a slow usage API can't stall the whole sync. Zero
+// disables the limit and leaves only the HTTP client's timeout.
+func WithSyncAccountTimeout(d time.Duration) ManagerOption {
+	return func(m *Manager) {
+		if d >= 0 {
+			m.syncAccountTimeout = d
+		}
+	}
+}
+
+// WithHTTPClient sets the client used for token refreshes and quota fetches.
+func WithHTTPClient(c *http.Client) ManagerOption {
+	return func(m *Manager) {
+		if c != nil {
+			m.httpClient = c
+		}
+	}
+}
+
 func WithCodexQuotaFetcher(fetcher func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error)) ManagerOption {
 	return func(m *Manager) {
 		m.quotaFetch = fetcher
 	}
 }
 
-func (m *Manager) AddAccount(ctx context.Context, in AddAccountInput) (model.Account, error) {
+// WithCodexExecFunc replaces how SyncQuotaWithWarmup runs the Codex CLI.
+func WithCodexExecFunc(fn quota.CodexExecFunc) ManagerOption {
+	return func(m *Manager) {
+		m.codexExec = fn
+	}
+}
+
+// WithCodexSessionsDir sets the Codex CLI session log directory read by
+// SyncQuotaFromLocalLogs. By default quota.DefaultCodexSessionsDir is used.
+func WithCodexSessionsDir(dir string) ManagerOption {
+	return func(m *Manager) {
+		m.codexLogs = dir
+	}
+}
+
+// WithCodexLogScanOptions limits how many and how old session logs
+// SyncQuotaFromLocalLogs reads.
+func WithCodexLogScanOptions(opts quota.ScanOptions) ManagerOption {
+	return func(m *Manager) {
+		m.logScan = opts
+	}
+}
+
+func (m *Manager) AddAccount(ctx context.Context, in AddAccountInput) (_ model.Account, err error) {
+	ctx, span := m.startSpan(ctx, "manager.AddAccount", attrAccountID.String(in.ID), attrProvider.String(in.Provider))
+	defer func() { endSpan(span, err) }()
 	_ = ctx
 	if err := validateAddAccountInput(in); err != nil {
 		return model.Account{}, err
 	}
 
 	now := time.Now().UTC()
-	in.Secrets = normalizeAddAccountSecrets(in.Secrets, now)
+	in.Secrets = normalizeAddAccountSecrets(in.Provider, in.Secrets, now)
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -144,9 +353,15 @@ func (m *Manager) AddAccount(ctx context.Context, in AddAccountInput) (model.Acc
 	createdAt := now
 	if ok {
 		createdAt = existing.CreatedAt
+		if in.Weight == 0 {
+			in.Weight = existing.Weight
+		}
 	}
 
 	acct := buildAccountRecord(in, createdAt, now)
+	if ok {
+		keepUserSettings(&acct, existing)
+	}
 
 	state.Accounts[in.ID] = acct
 	if state.ActiveAccountID == "" {
@@ -163,6 +378,7 @@ func (m *Manager) AddAccount(ctx context.Context, in AddAccountInput) (model.Acc
 		return model.Account{}, fmt.Errorf("%w: %v", ErrPersistState, err)
 	}
 
+	m.emit(Event{Type: EventAccountAdded, AccountID: acct.ID, Time: now})
 	return acct, nil
 }
 
@@ -176,16 +392,175 @@ func (m *Manager) ListAccounts(ctx context.Context) ([]model.Account, error) {
 	for _, a := range state.Accounts {
 		accounts = append(accounts, a)
 	}
+	// Accounts sort by their display name: the alias when set, else the ID.
 	sort.Slice(accounts, func(i, j int) bool {
-		if accounts[i].UpdatedAt.Equal(accounts[j].UpdatedAt) {
+		left, right := accountSortKey(accounts[i]), accountSortKey(accounts[j])
+		if left == right {
 			return accounts[i].ID < accounts[j].ID
 		}
-		return accounts[i].UpdatedAt.After(accounts[j].UpdatedAt)
+		return left < right
 	})
 	return accounts, nil
 }
 
-func (m *Manager) SetActiveAccount(ctx context.Context, accountID string) error {
+func accountSortKey(acct model.Account) string {
+	if acct.Alias != "" {
+		return strings.ToLower(acct.Alias)
+	}
+	return strings.ToLower(acct.ID)
+}
+
+// ExportAccounts returns every account together with its secrets, ordered by
+// ID, for moving accounts to another machine.
+func (m *Manager) ExportAccounts(ctx context.Context) ([]ExportedAccount, error) {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(state.Accounts))
+	for id := range state.Accounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	out := make([]ExportedAccount, 0, len(ids))
+	for _, id := range ids {
+		sec, err := m.secrets.Get(id)
+		if err != nil {
+			return nil, fmt.Errorf("load secrets for account %s: %w", id, err)
+		}
+		out = append(out, ExportedAccount{Account: state.Accounts[id], Secrets: sec})
+	}
+	return out, nil
+}
+
+func (m *Manager) GetAccount(ctx context.Context, accountID string) (model.Account, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	return acct, nil
+}
+
+// RefreshToken refreshes the access token regardless of its remaining
+// lifetime. A missing or expired refresh token yields ErrReauthRequired.
+func (m *Manager) RefreshToken(ctx context.Context, accountID string) (RefreshTokenResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return RefreshTokenResult{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return RefreshTokenResult{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+
+	if err := m.refreshAccountToken(ctx, &acct, true); err != nil {
+		if shouldMarkNeedReauth(err) {
+			markNeedReauth(&acct, err)
+			state.Accounts[accountID] = acct
+			if saveErr := m.stateStore.Save(state); saveErr != nil {
+				return RefreshTokenResult{}, fmt.Errorf("refresh token for account %s: %v (also failed to persist state: %v)", accountID, err, saveErr)
+			}
+		}
+		return RefreshTokenResult{}, fmt.Errorf("refresh token for account %s: %w", accountID, err)
+	}
+
+	if acct.Status == model.AccountNeedReauth {
+		acct.Status = model.AccountReady
+		acct.LastError = ""
+	}
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return RefreshTokenResult{}, fmt.Errorf("%w: %v", ErrPersistState, err)
+	}
+	return RefreshTokenResult{AccountID: accountID, AccessExpiresAt: acct.AccessExpiresAt}, nil
+}
+
+// RotateAccount refreshes the account's token and applies it as the active
+// account under one lock, saving state once. A refreshed token cannot be
+// taken back, so if applying fails the new expiry is still saved and the
+// active account is left unchanged.
+func (m *Manager) RotateAccount(ctx context.Context, accountID string) (RotateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return RotateResult{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return RotateResult{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	if acct.Status == model.AccountDisabled {
+		return RotateResult{}, fmt.Errorf("account %s is disabled", accountID)
+	}
+
+	if err := m.refreshAccountToken(ctx, &acct, true); err != nil {
+		if shouldMarkNeedReauth(err) {
+			markNeedReauth(&acct, err)
+			state.Accounts[accountID] = acct
+			if saveErr := m.stateStore.Save(state); saveErr != nil {
+				return RotateResult{}, fmt.Errorf("refresh token for account %s: %v (also failed to persist state: %v)", accountID, err, saveErr)
+			}
+		}
+		return RotateResult{}, fmt.Errorf("refresh token for account %s: %w", accountID, err)
+	}
+	if acct.Status == model.AccountNeedReauth {
+		acct.Status = model.AccountReady
+		acct.LastError = ""
+	}
+
+	now := time.Now().UTC()
+	acct.UpdatedAt = now
+	result := RotateResult{AccountID: accountID, AccessExpiresAt: acct.AccessExpiresAt, LastRefreshAt: acct.LastRefreshAt}
+	if applyErr := m.applyAccount(ctx, acct); applyErr != nil {
+		state.Accounts[accountID] = acct
+		if err := m.stateStore.Save(state); err != nil {
+			return result, fmt.Errorf("apply account %s: %v (also failed to persist refreshed token: %v)", accountID, applyErr, err)
+		}
+		return result, fmt.Errorf("apply account %s: %w", accountID, applyErr)
+	}
+
+	prevActiveID := state.ActiveAccountID
+	acct.LastAppliedAt = now
+	result.LastAppliedAt = now
+	state.Accounts[accountID] = acct
+	state.ActiveAccountID = accountID
+	if prevActiveID != accountID {
+		m.recordSwitch(&state, model.SwitchEvent{
+			Timestamp:     now,
+			FromAccountID: prevActiveID,
+			ToAccountID:   accountID,
+			Reason:        "manual",
+		})
+	}
+	if err := m.stateStore.Save(state); err != nil {
+		return result, fmt.Errorf("%w: %v", ErrPersistState, err)
+	}
+	if prevActiveID != accountID {
+		m.emit(Event{Type: EventAccountSwitched, AccountID: accountID, FromAccountID: prevActiveID, Reason: "manual", Time: now})
+	}
+	return result, nil
+}
+
+func (m *Manager) SetActiveAccount(ctx context.Context, accountID string) (err error) {
+	ctx, span := m.startSpan(ctx, "manager.SetActiveAccount", attrAccountID.String(accountID))
+	defer func() { endSpan(span, err) }()
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -196,11 +571,15 @@ func (m *Manager) SetActiveAccount(ctx context.Context, accountID string) error
 
 	acct, ok := state.Accounts[accountID]
 	if !ok {
-		return fmt.Errorf("account %s not found", accountID)
+		return fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
 	}
+	span.SetAttributes(attrProvider.String(acct.Provider), attrRoutingStrategy.String(string(state.Strategy)))
 	if acct.Status == model.AccountNeedReauth || acct.Status == model.AccountDisabled {
 		return fmt.Errorf("account %s is not ready", accountID)
 	}
+	if err := m.ensureActivatableToken(ctx, &acct); err != nil {
+		return err
+	}
 
 	prevActiveID := state.ActiveAccountID
 	prevAcct, hadPrev := state.Accounts[prevActiveID]
@@ -214,6 +593,12 @@ func (m *Manager) SetActiveAccount(ctx context.Context, accountID string) error
 	acct.LastAppliedAt = appliedAt
 	acct.UpdatedAt = appliedAt
 	state.Accounts[accountID] = acct
+	m.recordSwitch(&state, model.SwitchEvent{
+		Timestamp:     appliedAt,
+		FromAccountID: prevActiveID,
+		ToAccountID:   accountID,
+		Reason:        "manual",
+	})
 	if err := m.stateStore.Save(state); err != nil {
 		if hadPrev && prevActiveID != accountID {
 			if rollbackErr := m.applyAccount(ctx, prevAcct); rollbackErr != nil {
@@ -222,65 +607,499 @@ func (m *Manager) SetActiveAccount(ctx context.Context, accountID string) error
 		}
 		return err
 	}
+	m.emit(Event{Type: EventAccountSwitched, AccountID: accountID, FromAccountID: prevActiveID, Reason: "manual", Time: appliedAt})
 	return nil
 }
 
-func (m *Manager) SetStrategy(ctx context.Context, strategy model.RoutingStrategy) error {
-	_ = ctx
-	if strategy != model.RoutingRoundRobin && strategy != model.RoutingFillFirst {
-		return fmt.Errorf("invalid strategy: %s", strategy)
-	}
+type ForceActivateResult struct {
+	AccountID string `json:"account_id"`
+	// ApplierError is set when the account could not be applied; the
+	// account is active in state regardless.
+	ApplierError string `json:"applier_error,omitempty"`
+}
+
+// ForceSetActiveAccount makes accountID the active account even if it is not
+// ready or the applier fails, so a broken setup can be recovered from. Only
+// an unknown account or a failed save is an error; applier failures are
+// logged and returned in the result.
+func (m *Manager) ForceSetActiveAccount(ctx context.Context, accountID string) (_ ForceActivateResult, err error) {
+	ctx, span := m.startSpan(ctx, "manager.ForceSetActiveAccount", attrAccountID.String(accountID))
+	defer func() { endSpan(span, err) }()
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
+
 	state, err := m.stateStore.Load()
 	if err != nil {
-		return err
+		return ForceActivateResult{}, err
 	}
-	state.Strategy = strategy
-	return m.stateStore.Save(state)
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return ForceActivateResult{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+
+	result := ForceActivateResult{AccountID: accountID}
+	now := time.Now().UTC()
+	if err := m.applyAccount(ctx, acct); err != nil {
+		log.Printf("force activate %s: apply failed: %v", accountID, err)
+		result.ApplierError = err.Error()
+	} else {
+		acct.LastAppliedAt = now
+	}
+
+	prevActiveID := state.ActiveAccountID
+	state.ActiveAccountID = accountID
+	acct.UpdatedAt = now
+	state.Accounts[accountID] = acct
+	m.recordSwitch(&state, model.SwitchEvent{
+		Timestamp:     now,
+		FromAccountID: prevActiveID,
+		ToAccountID:   accountID,
+		Reason:        "manual-force",
+	})
+	if err := m.stateStore.Save(state); err != nil {
+		return ForceActivateResult{}, fmt.Errorf("%w: %v", ErrPersistState, err)
+	}
+	m.emit(Event{Type: EventAccountSwitched, AccountID: accountID, FromAccountID: prevActiveID, Reason: "manual-force", Time: now})
+	return result, nil
 }
 
-func (m *Manager) DeleteAccount(ctx context.Context, accountID string) (DeleteAccountResult, error) {
+func (m *Manager) ensureActivatableToken(ctx context.Context, account *model.Account) error {
+	secretsData, err := m.secrets.Get(account.ID)
+	if err != nil {
+		return fmt.Errorf("load secrets for account %s: %w", account.ID, err)
+	}
+	if secretsData.AccessExpiresAt.IsZero() || secretsData.AccessExpiresAt.After(time.Now().UTC()) {
+		return nil
+	}
+	if strings.TrimSpace(secretsData.RefreshToken) == "" {
+		return fmt.Errorf("%w: account %s has no refresh token", ErrAccountTokenExpired, account.ID)
+	}
+	if err := m.ensureFreshToken(ctx, account); err != nil {
+		return fmt.Errorf("refresh token for account %s: %w", account.ID, err)
+	}
+	return nil
+}
+
+func (m *Manager) SetAccountStatus(ctx context.Context, accountID string, status model.AccountStatus) (model.Account, error) {
+	_ = ctx
+	if status != model.AccountReady && status != model.AccountDisabled {
+		return model.Account{}, fmt.Errorf("invalid status: %s", status)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	state, err := m.stateStore.Load()
 	if err != nil {
-		return DeleteAccountResult{}, err
+		return model.Account{}, err
 	}
-
 	acct, ok := state.Accounts[accountID]
 	if !ok {
-		return DeleteAccountResult{}, fmt.Errorf("account %s not found", accountID)
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
 	}
 
-	originalState := cloneAppState(state)
-	wasActive := state.ActiveAccountID == accountID
-	result := DeleteAccountResult{
-		DeletedAccountID: accountID,
-		WasActive:        wasActive,
+	now := time.Now().UTC()
+	if status == model.AccountReady && acct.Status != model.AccountReady {
+		secretsData, err := m.secrets.Get(accountID)
+		if err != nil {
+			return model.Account{}, fmt.Errorf("load secrets for account %s: %w", accountID, err)
+		}
+		if !secretsData.AccessExpiresAt.IsZero() && secretsData.AccessExpiresAt.Before(now) {
+			return model.Account{}, fmt.Errorf("%w: account %s cannot be enabled; run quota sync to refresh it or re-authenticate", ErrAccountTokenExpired, accountID)
+		}
 	}
 
-	if wasActive {
-		order := orderedCandidates(state, accountID)
-		for _, candidateID := range order {
-			candidate := state.Accounts[candidateID]
-			if candidate.Status == model.AccountDisabled {
-				continue
-			}
+	acct.Status = status
+	applyQuotaWarning(&acct, now)
+	acct.UpdatedAt = now
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
 
-			if err := m.ensureFreshToken(ctx, &candidate); err != nil {
-				candidate.Status = model.AccountNeedReauth
-				candidate.LastError = err.Error()
-				candidate.UpdatedAt = time.Now().UTC()
-				state.Accounts[candidateID] = candidate
-				continue
-			}
+func (m *Manager) Strategy(ctx context.Context) (model.RoutingStrategy, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return "", err
+	}
+	return state.Strategy, nil
+}
 
-			now := time.Now().UTC()
-			candidate.Status = model.AccountReady
-			candidate.LastError = ""
+// SetStrategy changes the routing strategy. An empty strategy resets it to
+// model.DefaultRoutingStrategy.
+func (m *Manager) SetStrategy(ctx context.Context, strategy model.RoutingStrategy) error {
+	_ = ctx
+	if strategy == "" {
+		strategy = model.DefaultRoutingStrategy
+	}
+	if !validStrategy(strategy) {
+		return fmt.Errorf("invalid strategy: %s", strategy)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return err
+	}
+	state.Strategy = strategy
+	return m.stateStore.Save(state)
+}
+
+func (m *Manager) Priorities(ctx context.Context) ([]string, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{}, state.Priorities...), nil
+}
+
+// SetPriorities replaces the account order used by the priority strategy.
+// IDs that do not (yet) exist are kept and skipped during routing.
+func (m *Manager) SetPriorities(ctx context.Context, ids []string) ([]string, error) {
+	_ = ctx
+	priorities := make([]string, 0, len(ids))
+	seen := make(map[string]struct{}, len(ids))
+	for _, raw := range ids {
+		id := strings.TrimSpace(raw)
+		if id == "" {
+			return nil, errors.New("priorities must not contain empty account ids")
+		}
+		if _, dup := seen[id]; dup {
+			return nil, fmt.Errorf("duplicate account id in priorities: %s", id)
+		}
+		seen[id] = struct{}{}
+		priorities = append(priorities, id)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	state.Priorities = priorities
+	if err := m.stateStore.Save(state); err != nil {
+		return nil, err
+	}
+	return append([]string{}, priorities...), nil
+}
+
+func validStrategy(strategy model.RoutingStrategy) bool {
+	return slices.Contains(model.RoutingStrategies, strategy)
+}
+
+func (m *Manager) SetAccountWeight(ctx context.Context, accountID string, weight int) (model.Account, error) {
+	_ = ctx
+	if weight < 1 || weight > maxAccountWeight {
+		return model.Account{}, fmt.Errorf("weight must be between 1 and %d", maxAccountWeight)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	acct.Weight = weight
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+// SetAccountAlias sets the display alias of an account; an empty alias clears
+// it. Aliases are unique, ignoring case.
+func (m *Manager) SetAccountAlias(ctx context.Context, accountID, alias string) (model.Account, error) {
+	_ = ctx
+	alias, err := normalizeAlias(alias)
+	if err != nil {
+		return model.Account{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	if err := checkAliasAvailable(state, accountID, alias); err != nil {
+		return model.Account{}, err
+	}
+	acct.Alias = alias
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+// SetAccountNotes stores free-form notes about an account; empty notes clear
+// them.
+func (m *Manager) SetAccountNotes(ctx context.Context, accountID, notes string) (model.Account, error) {
+	_ = ctx
+	notes, err := normalizeNotes(notes)
+	if err != nil {
+		return model.Account{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	acct.Notes = notes
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+// SetAccountMeta merges meta into the account's provider metadata. An empty
+// value removes its key.
+func (m *Manager) SetAccountMeta(ctx context.Context, accountID string, meta map[string]string) (model.Account, error) {
+	_ = ctx
+	if len(meta) == 0 {
+		return model.Account{}, errors.New("meta has no keys to update")
+	}
+	for key := range meta {
+		if strings.TrimSpace(key) == "" || len(key) > maxAccountMetaKeyLen {
+			return model.Account{}, fmt.Errorf("meta key %q must be 1 to %d characters", key, maxAccountMetaKeyLen)
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	// The map is copied, not updated in place, because loaded states share
+	// it with earlier copies.
+	merged := make(map[string]string, len(acct.ProviderMeta)+len(meta))
+	for key, value := range acct.ProviderMeta {
+		merged[key] = value
+	}
+	for key, value := range meta {
+		if value == "" {
+			delete(merged, key)
+		} else {
+			merged[key] = value
+		}
+	}
+	if len(merged) > maxAccountMetaKeys {
+		return model.Account{}, fmt.Errorf("meta must have at most %d keys", maxAccountMetaKeys)
+	}
+	if len(merged) == 0 {
+		merged = nil
+	}
+	acct.ProviderMeta = merged
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+func normalizeNotes(notes string) (string, error) {
+	notes = strings.TrimSpace(notes)
+	if utf8.RuneCountInString(notes) > maxAccountNotesLength {
+		return "", fmt.Errorf("notes must be at most %d characters", maxAccountNotesLength)
+	}
+	return notes, nil
+}
+
+func normalizeAlias(alias string) (string, error) {
+	alias = strings.TrimSpace(alias)
+	if len(alias) > maxAccountAliasLength {
+		return "", fmt.Errorf("alias must be at most %d characters", maxAccountAliasLength)
+	}
+	return alias, nil
+}
+
+func checkAliasAvailable(state model.AppState, accountID, alias string) error {
+	if alias == "" {
+		return nil
+	}
+	for id, other := range state.Accounts {
+		if id != accountID && strings.EqualFold(other.Alias, alias) {
+			return fmt.Errorf("alias %q is already used by account %s", alias, id)
+		}
+	}
+	return nil
+}
+
+// AccountPatch lists the account fields that can be edited in place. Nil
+// fields are left unchanged.
+type AccountPatch struct {
+	Email      *string                 `json:"email,omitempty"`
+	Alias      *string                 `json:"alias,omitempty"`
+	Notes      *string                 `json:"notes,omitempty"`
+	HTTPConfig *model.HTTPClientConfig `json:"http_client_config,omitempty"`
+}
+
+func (m *Manager) PatchAccount(ctx context.Context, accountID string, patch AccountPatch) (model.Account, error) {
+	_ = ctx
+	if patch.Email == nil && patch.Alias == nil && patch.Notes == nil && patch.HTTPConfig == nil {
+		return model.Account{}, errors.New("patch has no fields to update")
+	}
+	var email, alias, notes string
+	if patch.Email != nil {
+		email = strings.TrimSpace(*patch.Email)
+		if email != "" && !strings.Contains(email, "@") {
+			return model.Account{}, fmt.Errorf("invalid email %q", email)
+		}
+	}
+	if patch.Alias != nil {
+		var err error
+		if alias, err = normalizeAlias(*patch.Alias); err != nil {
+			return model.Account{}, err
+		}
+	}
+	if patch.Notes != nil {
+		var err error
+		if notes, err = normalizeNotes(*patch.Notes); err != nil {
+			return model.Account{}, err
+		}
+	}
+	var httpConfig model.HTTPClientConfig
+	if patch.HTTPConfig != nil {
+		httpConfig = *patch.HTTPConfig
+		httpConfig.ProxyURL = strings.TrimSpace(httpConfig.ProxyURL)
+		if err := validateHTTPClientConfig(httpConfig); err != nil {
+			return model.Account{}, err
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	if patch.Email != nil {
+		acct.Email = email
+	}
+	if patch.Alias != nil {
+		if err := checkAliasAvailable(state, accountID, alias); err != nil {
+			return model.Account{}, err
+		}
+		acct.Alias = alias
+	}
+	if patch.Notes != nil {
+		acct.Notes = notes
+	}
+	if patch.HTTPConfig != nil {
+		if httpConfig.IsZero() {
+			acct.HTTPConfig = nil
+		} else {
+			acct.HTTPConfig = &httpConfig
+		}
+	}
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+func (m *Manager) SetAccountPinned(ctx context.Context, accountID string, pinned bool) (model.Account, error) {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	acct.Pinned = pinned
+	acct.UpdatedAt = time.Now().UTC()
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+func (m *Manager) DeleteAccount(ctx context.Context, accountID string) (DeleteAccountResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return DeleteAccountResult{}, err
+	}
+
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return DeleteAccountResult{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+
+	originalState := cloneAppState(state)
+	wasActive := state.ActiveAccountID == accountID
+	result := DeleteAccountResult{
+		DeletedAccountID: accountID,
+		WasActive:        wasActive,
+	}
+
+	if wasActive {
+		order := orderedCandidates(state, accountID)
+		for _, candidateID := range order {
+			candidate := state.Accounts[candidateID]
+			if candidate.Status == model.AccountDisabled {
+				continue
+			}
+
+			if err := m.ensureFreshToken(ctx, &candidate); err != nil {
+				markNeedReauth(&candidate, err)
+				state.Accounts[candidateID] = candidate
+				continue
+			}
+
+			now := time.Now().UTC()
+			markReady(&candidate)
 			candidate.UpdatedAt = now
 
 			if err := m.applyAccount(ctx, candidate); err != nil {
@@ -293,6 +1112,13 @@ func (m *Manager) DeleteAccount(ctx context.Context, accountID string) (DeleteAc
 			candidate.LastAppliedAt = now
 			state.Accounts[candidateID] = candidate
 			state.ActiveAccountID = candidateID
+			advanceRoutingCursor(&state, candidateID)
+			m.recordSwitch(&state, model.SwitchEvent{
+				Timestamp:     now,
+				FromAccountID: accountID,
+				ToAccountID:   candidateID,
+				Reason:        "account-deleted",
+			})
 			result.Switched = true
 			result.SwitchedToAccount = candidateID
 			break
@@ -310,13 +1136,15 @@ func (m *Manager) DeleteAccount(ctx context.Context, accountID string) (DeleteAc
 
 	result.ActiveAccountID = state.ActiveAccountID
 
+	// State is saved before the secrets are deleted, so a failed save
+	// leaves the secrets untouched and nothing needs restoring.
 	if err := m.stateStore.Save(state); err != nil {
 		if wasActive {
 			if rollbackErr := m.applyAccount(ctx, acct); rollbackErr != nil {
-				return DeleteAccountResult{}, fmt.Errorf("save state failed: %v (apply rollback failed: %v)", err, rollbackErr)
+				return DeleteAccountResult{}, fmt.Errorf("%w: %v (apply rollback failed: %v)", ErrPersistState, err, rollbackErr)
 			}
 		}
-		return DeleteAccountResult{}, err
+		return DeleteAccountResult{}, fmt.Errorf("%w: %v", ErrPersistState, err)
 	}
 
 	if err := m.secrets.Delete(accountID); err != nil {
@@ -324,17 +1152,21 @@ func (m *Manager) DeleteAccount(ctx context.Context, accountID string) (DeleteAc
 		if wasActive {
 			if applyRollbackErr := m.applyAccount(ctx, acct); applyRollbackErr != nil {
 				if rollbackErr != nil {
-					return DeleteAccountResult{}, fmt.Errorf("delete secrets for account %s: %v (state rollback failed: %v, apply rollback failed: %v)", accountID, err, rollbackErr, applyRollbackErr)
+					return DeleteAccountResult{}, fmt.Errorf("%w: delete secrets for account %s: %v (state rollback failed: %v, apply rollback failed: %v)", ErrPersistSecrets, accountID, err, rollbackErr, applyRollbackErr)
 				}
-				return DeleteAccountResult{}, fmt.Errorf("delete secrets for account %s: %v (apply rollback failed: %v)", accountID, err, applyRollbackErr)
+				return DeleteAccountResult{}, fmt.Errorf("%w: delete secrets for account %s: %v (apply rollback failed: %v)", ErrPersistSecrets, accountID, err, applyRollbackErr)
 			}
 		}
 		if rollbackErr != nil {
-			return DeleteAccountResult{}, fmt.Errorf("delete secrets for account %s: %v (state rollback failed: %v)", accountID, err, rollbackErr)
+			return DeleteAccountResult{}, fmt.Errorf("%w: delete secrets for account %s: %v (state rollback failed: %v)", ErrPersistSecrets, accountID, err, rollbackErr)
 		}
-		return DeleteAccountResult{}, fmt.Errorf("delete secrets for account %s: %w", accountID, err)
+		return DeleteAccountResult{}, fmt.Errorf("%w: delete secrets for account %s: %w", ErrPersistSecrets, accountID, err)
 	}
 
+	m.emit(Event{Type: EventAccountDeleted, AccountID: accountID})
+	if result.Switched {
+		m.emit(Event{Type: EventAccountSwitched, AccountID: result.SwitchedToAccount, FromAccountID: accountID, Reason: "account-deleted"})
+	}
 	return result, nil
 }
 
@@ -347,26 +1179,129 @@ func (m *Manager) Status(ctx context.Context) (StatusSnapshot, error) {
 	if err != nil {
 		return StatusSnapshot{}, err
 	}
+	warnings := []QuotaWarning{}
+	for _, acct := range accounts {
+		if acct.Status == model.AccountWarning {
+			warnings = append(warnings, quotaWarnings(acct)...)
+		}
+	}
 	return StatusSnapshot{
 		ActiveAccountID: state.ActiveAccountID,
 		Strategy:        state.Strategy,
 		Accounts:        accounts,
+		LastError:       state.LastGlobalError,
+		LastErrorAt:     state.LastGlobalErrorAt,
+		CooldownActive:  time.Now().Before(state.CooldownUntil),
+		CooldownUntil:   state.CooldownUntil,
+		Warnings:        warnings,
 	}, nil
 }
 
-func (m *Manager) CodexImportStatus(ctx context.Context, accountID string, incoming model.AuthSecrets) (exists bool, needsImport bool, err error) {
+// ResetCooldown lets the next quota error search for an account again.
+func (m *Manager) ResetCooldown(ctx context.Context) error {
 	_ = ctx
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
 	state, err := m.stateStore.Load()
 	if err != nil {
-		return false, false, err
+		return err
 	}
-
-	_, exists = state.Accounts[accountID]
-	if !exists {
-		return false, true, nil
+	m.cooldownUntil = time.Time{}
+	if state.CooldownUntil.IsZero() {
+		return nil
+	}
+	state.CooldownUntil = time.Time{}
+	return m.stateStore.Save(state)
+}
+
+func (m *Manager) SwitchRules(ctx context.Context) (SwitchRulesSnapshot, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return SwitchRulesSnapshot{}, err
+	}
+	user, err := normalizeSwitchRules(state.SwitchRules)
+	if err != nil {
+		return SwitchRulesSnapshot{}, err
+	}
+	return SwitchRulesSnapshot{Defaults: DefaultSwitchRules(), User: user}, nil
+}
+
+func (m *Manager) SetSwitchRules(ctx context.Context, rules model.SwitchRules) (SwitchRulesSnapshot, error) {
+	_ = ctx
+	user, err := normalizeSwitchRules(rules)
+	if err != nil {
+		return SwitchRulesSnapshot{}, err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return SwitchRulesSnapshot{}, err
+	}
+	state.SwitchRules = user
+	if err := m.stateStore.Save(state); err != nil {
+		return SwitchRulesSnapshot{}, err
+	}
+	return SwitchRulesSnapshot{Defaults: DefaultSwitchRules(), User: user}, nil
+}
+
+func (m *Manager) SwitchHistory(ctx context.Context, limit int) ([]model.SwitchEvent, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	history := state.SwitchHistory
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	out := make([]model.SwitchEvent, 0, len(history))
+	for i := len(history) - 1; i >= 0; i-- {
+		out = append(out, history[i])
+	}
+	return out, nil
+}
+
+func (m *Manager) OrphanedSecrets(ctx context.Context) ([]string, error) {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	ids, err := m.secrets.List()
+	if err != nil {
+		return nil, fmt.Errorf("list secrets: %w", err)
+	}
+	orphaned := make([]string, 0)
+	for _, id := range ids {
+		if _, ok := state.Accounts[id]; !ok {
+			orphaned = append(orphaned, id)
+		}
+	}
+	sort.Strings(orphaned)
+	return orphaned, nil
+}
+
+func (m *Manager) CodexImportStatus(ctx context.Context, accountID string, incoming model.AuthSecrets) (exists bool, needsImport bool, err error) {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return false, false, err
+	}
+
+	_, exists = state.Accounts[accountID]
+	if !exists {
+		return false, true, nil
 	}
 
 	current, err := m.secrets.Get(accountID)
@@ -377,104 +1312,419 @@ func (m *Manager) CodexImportStatus(ctx context.Context, accountID string, incom
 	return true, codexSecretsNeedImport(current, incoming), nil
 }
 
-func (m *Manager) UpdateQuota(ctx context.Context, accountID string, quota model.QuotaSnapshot) error {
-	_ = ctx
+func (m *Manager) UpdateQuota(ctx context.Context, accountID string, quota model.QuotaSnapshot) error {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	now := time.Now().UTC()
+	quota.LastUpdated = now
+	acct.Quota = quota
+	acct.QuotaHistory = appendQuotaHistory(acct.QuotaHistory, quota)
+	applyQuotaWarning(&acct, now)
+	acct.UpdatedAt = now
+	state.Accounts[accountID] = acct
+	return m.stateStore.Save(state)
+}
+
+// QuotaHistory returns the recorded quota snapshots of an account, oldest
+// first. A positive limit keeps only the newest entries.
+func (m *Manager) QuotaHistory(ctx context.Context, accountID string, limit int) ([]model.QuotaSnapshot, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return nil, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	history := acct.QuotaHistory
+	if limit > 0 && len(history) > limit {
+		history = history[len(history)-limit:]
+	}
+	return append([]model.QuotaSnapshot{}, history...), nil
+}
+
+// NextQuotaReset returns the earliest future session or weekly reset across
+// enabled accounts. Reset times are only known after a quota sync.
+func (m *Manager) NextQuotaReset(ctx context.Context) (QuotaResetResult, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return QuotaResetResult{}, err
+	}
+	now := time.Now().UTC()
+	var best QuotaResetResult
+	consider := func(id, window string, resetAt time.Time) {
+		if !resetAt.After(now) {
+			return
+		}
+		if best.AccountID == "" || resetAt.Before(best.EarliestResetAt) ||
+			(resetAt.Equal(best.EarliestResetAt) && id < best.AccountID) {
+			best = QuotaResetResult{EarliestResetAt: resetAt, AccountID: id, Window: window}
+		}
+	}
+	for id, acct := range state.Accounts {
+		if acct.Status == model.AccountDisabled {
+			continue
+		}
+		consider(id, "session", acct.Quota.Session.ResetAt)
+		consider(id, "weekly", acct.Quota.Weekly.ResetAt)
+	}
+	if best.AccountID == "" {
+		return QuotaResetResult{}, ErrNoQuotaReset
+	}
+	return best, nil
+}
+
+func appendQuotaHistory(history []model.QuotaSnapshot, snap model.QuotaSnapshot) []model.QuotaSnapshot {
+	start := 0
+	if len(history) >= quotaHistoryLimit {
+		start = len(history) - quotaHistoryLimit + 1
+	}
+	out := make([]model.QuotaSnapshot, 0, len(history)-start+1)
+	out = append(out, history[start:]...)
+	return append(out, snap)
+}
+
+func (m *Manager) SyncQuotaFromCodexAPI(ctx context.Context, accountID string) (_ QuotaSyncResult, err error) {
+	ctx, span := m.startSpan(ctx, "manager.SyncQuotaFromCodexAPI")
+	defer func() { endSpan(span, err) }()
+
+	if m.metrics != nil {
+		startedAt := time.Now()
+		defer func() { m.metrics.ObserveQuotaSync(time.Since(startedAt)) }()
+	}
+
+	job, err := m.prepareQuotaSync(ctx, accountID)
+	if job.accountID != "" {
+		defer func() {
+			if err != nil {
+				m.emit(Event{Type: EventQuotaSyncFailed, AccountID: job.accountID, Error: err.Error()})
+			}
+		}()
+	}
+	if err != nil {
+		return QuotaSyncResult{}, err
+	}
+	if job.result != nil {
+		return *job.result, nil
+	}
+
+	// The usage API is called without holding m.mu so several accounts can
+	// be synced at once; finishQuotaSync applies the result to fresh state.
+	snap, fetchErr := m.quotaFetch(ctx, job.client, job.secrets.AccessToken, job.secrets.AccountID)
+	return m.finishQuotaSync(job, snap, fetchErr)
+}
+
+// quotaSyncJob carries a quota sync from prepareQuotaSync to
+// finishQuotaSync.
+type quotaSyncJob struct {
+	accountID string
+	// account holds the token expiry fields learned by the refresh.
+	account model.Account
+	secrets model.AuthSecrets
+	client  *http.Client
+	// result is set when the sync already completed from the local logs.
+	result *QuotaSyncResult
+}
+
+// prepareQuotaSync resolves the account and refreshes its token. accountID
+// in the returned job is set once failures should be reported as
+// EventQuotaSyncFailed.
+func (m *Manager) prepareQuotaSync(ctx context.Context, accountID string) (quotaSyncJob, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	span := trace.SpanFromContext(ctx)
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return quotaSyncJob{}, err
+	}
+
+	targetID := strings.TrimSpace(accountID)
+	if targetID == "" {
+		targetID = strings.TrimSpace(state.ActiveAccountID)
+	}
+	if targetID == "" {
+		return quotaSyncJob{}, errors.New("no active account configured")
+	}
+
+	span.SetAttributes(attrAccountID.String(targetID))
+
+	acct, ok := state.Accounts[targetID]
+	if !ok {
+		return quotaSyncJob{}, fmt.Errorf("account %s %w", targetID, ErrAccountNotFound)
+	}
+	span.SetAttributes(attrProvider.String(acct.Provider))
+	if strings.ToLower(acct.Provider) != "codex" {
+		return quotaSyncJob{}, fmt.Errorf("quota sync not supported for provider %s", acct.Provider)
+	}
+	job := quotaSyncJob{accountID: targetID}
+
+	if err := m.ensureFreshToken(ctx, &acct); err != nil {
+		markNeedReauth(&acct, err)
+		state.Accounts[targetID] = acct
+		if saveErr := m.stateStore.Save(state); saveErr != nil {
+			return job, fmt.Errorf("refresh token for account %s: %v (also failed to persist state: %v)", targetID, err, saveErr)
+		}
+		if result, localErr := m.syncQuotaFromLocalLogsLocked(&state, targetID); localErr == nil {
+			job.result = &result
+			return job, nil
+		}
+		return job, fmt.Errorf("refresh token for account %s: %w", targetID, err)
+	}
+
+	secretsData, err := m.secrets.Get(targetID)
+	if err != nil {
+		return job, fmt.Errorf("load secrets for account %s: %w", targetID, err)
+	}
+
+	if m.quotaFetch == nil {
+		return job, errors.New("quota fetcher is not configured")
+	}
+
+	client, err := m.httpClientFor(acct)
+	if err != nil {
+		return job, fmt.Errorf("http client for account %s: %w", targetID, err)
+	}
+	job.account = acct
+	job.secrets = secretsData
+	job.client = client
+	return job, nil
+}
+
+func (m *Manager) finishQuotaSync(job quotaSyncJob, snap quota.Snapshot, fetchErr error) (QuotaSyncResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	targetID := job.accountID
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return QuotaSyncResult{}, err
+	}
+	acct, ok := state.Accounts[targetID]
+	if !ok {
+		return QuotaSyncResult{}, fmt.Errorf("account %s %w", targetID, ErrAccountNotFound)
+	}
+	acct.AccessExpiresAt = job.account.AccessExpiresAt
+	acct.RefreshExpiresAt = job.account.RefreshExpiresAt
+	acct.LastRefreshAt = job.account.LastRefreshAt
+
+	if err := fetchErr; err != nil {
+		if shouldMarkNeedReauth(err) {
+			acct.Status = model.AccountNeedReauth
+			acct.LastError = err.Error()
+			acct.UpdatedAt = time.Now().UTC()
+			state.Accounts[targetID] = acct
+			if saveErr := m.stateStore.Save(state); saveErr != nil {
+				return QuotaSyncResult{}, fmt.Errorf("quota fetch for account %s: %v (also failed to persist state: %v)", targetID, err, saveErr)
+			}
+		}
+		if result, localErr := m.syncQuotaFromLocalLogsLocked(&state, targetID); localErr == nil {
+			return result, nil
+		}
+		return QuotaSyncResult{}, err
+	}
+
+	if snap.AccountIDMismatch {
+		log.Printf("quota sync for account %s: usage API answered for account %q instead of %q", targetID, snap.ReturnedAccountID, job.secrets.AccountID)
+	}
+	now := time.Now().UTC()
+	nextQuota := mergeQuotaSnapshot(acct.Quota, snap, now)
+
+	markReady(&acct)
+	acct.Quota = nextQuota
+	acct.QuotaHistory = appendQuotaHistory(acct.QuotaHistory, nextQuota)
+	applyQuotaWarning(&acct, now)
+	acct.UpdatedAt = now
+	state.Accounts[targetID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return QuotaSyncResult{}, err
+	}
+
+	m.emit(Event{Type: EventQuotaSynced, AccountID: targetID, Quota: &nextQuota, Time: now})
+	return QuotaSyncResult{
+		AccountID:       targetID,
+		Quota:           nextQuota,
+		SourceTimestamp: snap.SourceTimestamp,
+	}, nil
+}
+
+// SyncQuotaFromLocalLogs updates an account's quota from the newest snapshot
+// in the Codex CLI session logs. The logs describe whichever account the CLI
+// is signed in as, so only the active account can be synced this way.
+func (m *Manager) SyncQuotaFromLocalLogs(ctx context.Context, accountID string) (QuotaSyncResult, error) {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return QuotaSyncResult{}, err
+	}
+
+	targetID := strings.TrimSpace(accountID)
+	if targetID == "" {
+		targetID = strings.TrimSpace(state.ActiveAccountID)
+	}
+	if targetID == "" {
+		return QuotaSyncResult{}, errors.New("no active account configured")
+	}
+	return m.syncQuotaFromLocalLogsLocked(&state, targetID)
+}
+
+func (m *Manager) syncQuotaFromLocalLogsLocked(state *model.AppState, targetID string) (QuotaSyncResult, error) {
+	acct, ok := state.Accounts[targetID]
+	if !ok {
+		return QuotaSyncResult{}, fmt.Errorf("account %s %w", targetID, ErrAccountNotFound)
+	}
+	if strings.ToLower(acct.Provider) != "codex" {
+		return QuotaSyncResult{}, fmt.Errorf("quota sync not supported for provider %s", acct.Provider)
+	}
+	if targetID != state.ActiveAccountID {
+		return QuotaSyncResult{}, fmt.Errorf("local quota logs only describe the active account, not %s", targetID)
+	}
+
+	dir, err := m.codexSessionsDir()
+	if err != nil {
+		return QuotaSyncResult{}, err
+	}
+	snap, err := quota.LatestCodexSnapshotFromDirWithOptions(dir, m.logScan)
+	if err != nil {
+		return QuotaSyncResult{}, fmt.Errorf("read local quota logs: %w", err)
+	}
+	if snap.SourceTimestamp.Before(acct.Quota.LastUpdated) {
+		return QuotaSyncResult{}, fmt.Errorf("local quota logs (%s) are older than the stored quota", snap.SourceTimestamp.Format(time.RFC3339))
+	}
+	return m.applyLogSnapshotLocked(state, targetID, snap)
+}
+
+func (m *Manager) codexSessionsDir() (string, error) {
+	if m.codexLogs != "" {
+		return m.codexLogs, nil
+	}
+	dir, err := quota.DefaultCodexSessionsDir()
+	if err != nil {
+		return "", fmt.Errorf("resolve codex sessions dir: %w", err)
+	}
+	return dir, nil
+}
+
+// applyLogSnapshotLocked merges a snapshot read from the session logs into
+// the account's quota, keeping stored windows that are newer.
+func (m *Manager) applyLogSnapshotLocked(state *model.AppState, targetID string, snap quota.Snapshot) (QuotaSyncResult, error) {
+	acct, ok := state.Accounts[targetID]
+	if !ok {
+		return QuotaSyncResult{}, fmt.Errorf("account %s %w", targetID, ErrAccountNotFound)
+	}
+	nextQuota := mergeQuotaSnapshot(acct.Quota, snap, snap.SourceTimestamp)
+	acct.Quota = nextQuota
+	acct.QuotaHistory = appendQuotaHistory(acct.QuotaHistory, nextQuota)
+	acct.UpdatedAt = time.Now().UTC()
+	applyQuotaWarning(&acct, acct.UpdatedAt)
+	state.Accounts[targetID] = acct
+	if err := m.stateStore.Save(*state); err != nil {
+		return QuotaSyncResult{}, err
+	}
+
+	m.emit(Event{Type: EventQuotaSynced, AccountID: targetID, Quota: &nextQuota, Time: acct.UpdatedAt})
+	return QuotaSyncResult{
+		AccountID:       targetID,
+		Quota:           nextQuota,
+		SourceTimestamp: snap.SourceTimestamp,
+	}, nil
+}
+
+// SyncQuotaWithWarmup runs a one-line Codex CLI prompt so the account's
+// rate limits are live, reads them from that thread's session log and
+// merges them with the usage API's, keeping whichever window is newer. The
+// CLI is signed in as the active account, so only that account can be
+// warmed up. A failed usage API call is logged; the warmup reading is
+// still applied.
+func (m *Manager) SyncQuotaWithWarmup(ctx context.Context, accountID string) (QuotaSyncResult, error) {
+	_, result, err := m.syncQuotaWithWarmup(ctx, accountID)
+	return result, err
+}
+
+// RunCodexWarmup is SyncQuotaWithWarmup for the active account that also
+// reports the warmup thread's id.
+func (m *Manager) RunCodexWarmup(ctx context.Context) (WarmupResult, error) {
+	threadID, result, err := m.syncQuotaWithWarmup(ctx, "")
+	if err != nil {
+		return WarmupResult{}, err
+	}
+	return WarmupResult{ThreadID: threadID, Quota: result.Quota}, nil
+}
+
+func (m *Manager) syncQuotaWithWarmup(ctx context.Context, accountID string) (string, QuotaSyncResult, error) {
+	targetID, err := m.warmupTarget(accountID)
+	if err != nil {
+		return "", QuotaSyncResult{}, err
+	}
+	if m.codexExec == nil {
+		return "", QuotaSyncResult{}, errors.New("codex exec is not configured")
+	}
+	dir, err := m.codexSessionsDir()
+	if err != nil {
+		return "", QuotaSyncResult{}, err
+	}
+
+	// The CLI round trip takes seconds, so it runs without holding m.mu.
+	threadID, err := quota.RunCodexWarmup(ctx, m.codexExec)
+	if err != nil {
+		return "", QuotaSyncResult{}, fmt.Errorf("codex warmup: %w", err)
+	}
+	snap, err := quota.LatestCodexSnapshotForThread(dir, threadID)
+	if err != nil {
+		return threadID, QuotaSyncResult{}, fmt.Errorf("read warmup quota: %w", err)
+	}
+	if _, err := m.SyncQuotaFromCodexAPI(ctx, targetID); err != nil {
+		log.Printf("quota sync with warmup: usage API failed for %s, using the warmup reading only: %v", targetID, err)
+	}
+
 	m.mu.Lock()
 	defer m.mu.Unlock()
-
 	state, err := m.stateStore.Load()
 	if err != nil {
-		return err
+		return threadID, QuotaSyncResult{}, err
 	}
-	acct, ok := state.Accounts[accountID]
-	if !ok {
-		return fmt.Errorf("account %s not found", accountID)
-	}
-	quota.LastUpdated = time.Now().UTC()
-	acct.Quota = quota
-	acct.UpdatedAt = time.Now().UTC()
-	state.Accounts[accountID] = acct
-	return m.stateStore.Save(state)
+	result, err := m.applyLogSnapshotLocked(&state, targetID, snap)
+	return threadID, result, err
 }
 
-func (m *Manager) SyncQuotaFromCodexAPI(ctx context.Context, accountID string) (QuotaSyncResult, error) {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
+func (m *Manager) warmupTarget(accountID string) (string, error) {
 	state, err := m.stateStore.Load()
 	if err != nil {
-		return QuotaSyncResult{}, err
+		return "", err
 	}
-
 	targetID := strings.TrimSpace(accountID)
 	if targetID == "" {
 		targetID = strings.TrimSpace(state.ActiveAccountID)
 	}
 	if targetID == "" {
-		return QuotaSyncResult{}, errors.New("no active account configured")
+		return "", errors.New("no active account configured")
 	}
-
 	acct, ok := state.Accounts[targetID]
 	if !ok {
-		return QuotaSyncResult{}, fmt.Errorf("account %s not found", targetID)
+		return "", fmt.Errorf("account %s %w", targetID, ErrAccountNotFound)
 	}
 	if strings.ToLower(acct.Provider) != "codex" {
-		return QuotaSyncResult{}, fmt.Errorf("quota sync not supported for provider %s", acct.Provider)
-	}
-
-	if err := m.ensureFreshToken(ctx, &acct); err != nil {
-		acct.Status = model.AccountNeedReauth
-		acct.LastError = err.Error()
-		acct.UpdatedAt = time.Now().UTC()
-		state.Accounts[targetID] = acct
-		if saveErr := m.stateStore.Save(state); saveErr != nil {
-			return QuotaSyncResult{}, fmt.Errorf("refresh token for account %s: %v (also failed to persist state: %v)", targetID, err, saveErr)
-		}
-		return QuotaSyncResult{}, fmt.Errorf("refresh token for account %s: %w", targetID, err)
-	}
-
-	secretsData, err := m.secrets.Get(targetID)
-	if err != nil {
-		return QuotaSyncResult{}, fmt.Errorf("load secrets for account %s: %w", targetID, err)
-	}
-
-	if m.quotaFetch == nil {
-		return QuotaSyncResult{}, errors.New("quota fetcher is not configured")
+		return "", fmt.Errorf("quota sync not supported for provider %s", acct.Provider)
 	}
-
-	snap, err := m.quotaFetch(ctx, m.httpClient, secretsData.AccessToken, secretsData.AccountID)
-	if err != nil {
-		if shouldMarkNeedReauth(err) {
-			acct.Status = model.AccountNeedReauth
-			acct.LastError = err.Error()
-			acct.UpdatedAt = time.Now().UTC()
-			state.Accounts[targetID] = acct
-			if saveErr := m.stateStore.Save(state); saveErr != nil {
-				return QuotaSyncResult{}, fmt.Errorf("quota fetch for account %s: %v (also failed to persist state: %v)", targetID, err, saveErr)
-			}
-		}
-		return QuotaSyncResult{}, err
-	}
-
-	now := time.Now().UTC()
-	nextQuota := mergeQuotaSnapshot(acct.Quota, snap, now)
-
-	acct.Status = model.AccountReady
-	acct.LastError = ""
-	acct.AccessExpiresAt = secretsData.AccessExpiresAt
-	acct.RefreshExpiresAt = secretsData.RefreshExpiresAt
-	acct.Quota = nextQuota
-	acct.UpdatedAt = now
-	state.Accounts[targetID] = acct
-	if err := m.stateStore.Save(state); err != nil {
-		return QuotaSyncResult{}, err
+	if targetID != state.ActiveAccountID {
+		return "", fmt.Errorf("the codex CLI is signed in as the active account, not %s", targetID)
 	}
-
-	return QuotaSyncResult{
-		AccountID:       targetID,
-		Quota:           nextQuota,
-		SourceTimestamp: snap.SourceTimestamp,
-	}, nil
+	return targetID, nil
 }
 
 func validateAddAccountInput(in AddAccountInput) error {
@@ -487,10 +1737,22 @@ func validateAddAccountInput(in AddAccountInput) error {
 	if strings.TrimSpace(in.Secrets.AccessToken) == "" {
 		return errors.New("access_token is required")
 	}
+	if in.Weight < 0 {
+		return errors.New("weight must be positive")
+	}
+	if in.Weight > maxAccountWeight {
+		return fmt.Errorf("weight must be at most %d", maxAccountWeight)
+	}
 	return nil
 }
 
-func normalizeAddAccountSecrets(sec model.AuthSecrets, now time.Time) model.AuthSecrets {
+// normalizeAddAccountSecrets assumes a short-lived Codex access token when
+// no expiry is given. Other providers' tokens, such as Copilot's GitHub
+// token, do not expire on a schedule and cannot be refreshed.
+func normalizeAddAccountSecrets(provider string, sec model.AuthSecrets, now time.Time) model.AuthSecrets {
+	if !strings.EqualFold(strings.TrimSpace(provider), "codex") {
+		return sec
+	}
 	if sec.AccessExpiresAt.IsZero() {
 		sec.AccessExpiresAt = now.Add(50 * time.Minute)
 	}
@@ -503,6 +1765,7 @@ func buildAccountRecord(in AddAccountInput, createdAt, now time.Time) model.Acco
 		Provider:         strings.ToLower(strings.TrimSpace(in.Provider)),
 		Email:            strings.TrimSpace(in.Email),
 		Status:           model.AccountReady,
+		Weight:           in.Weight,
 		AccessExpiresAt:  in.Secrets.AccessExpiresAt.UTC(),
 		RefreshExpiresAt: in.Secrets.RefreshExpiresAt.UTC(),
 		CreatedAt:        createdAt,
@@ -510,31 +1773,73 @@ func buildAccountRecord(in AddAccountInput, createdAt, now time.Time) model.Acco
 	}
 }
 
+// keepUserSettings carries what the user set on an account over to its
+// re-added record, so a re-login or re-import only replaces the credentials.
+func keepUserSettings(acct *model.Account, existing model.Account) {
+	if acct.Email == "" {
+		acct.Email = existing.Email
+	}
+	acct.Alias = existing.Alias
+	acct.Notes = existing.Notes
+	acct.ProviderMeta = existing.ProviderMeta
+	acct.Pinned = existing.Pinned
+	acct.HTTPConfig = existing.HTTPConfig
+	acct.Thresholds = existing.Thresholds
+}
+
+// mergeQuotaSnapshot applies snap on top of current. Windows missing from
+// snap are kept, and so are stored windows that are newer than the incoming
+// ones, e.g. when the usage API serves cached data older than a local log.
 func mergeQuotaSnapshot(current model.QuotaSnapshot, snap quota.Snapshot, now time.Time) model.QuotaSnapshot {
 	next := current
+	stale := false
 	if snap.SessionUnsupported {
-		next.Session = model.QuotaWindow{}
-		supported := false
-		next.SessionSupported = &supported
+		if quotaWindowIsNewer(current.Session, quota.Window{}, snap.SourceTimestamp) {
+			next.Session = model.QuotaWindow{SourceTimestamp: snap.SourceTimestamp}
+			supported := false
+			next.SessionSupported = &supported
+		} else {
+			stale = true
+		}
 	} else if snap.Session != nil {
-		next.Session.UsedPercent = snap.Session.UsedPercent
-		next.Session.ResetAt = snap.Session.ResetAt
-		supported := true
-		next.SessionSupported = &supported
+		if quotaWindowIsNewer(current.Session, *snap.Session, snap.SourceTimestamp) {
+			next.Session = model.QuotaWindow{UsedPercent: snap.Session.UsedPercent, ResetAt: snap.Session.ResetAt, SourceTimestamp: snap.SourceTimestamp}
+			supported := true
+			next.SessionSupported = &supported
+		} else {
+			stale = true
+		}
 	}
 	if snap.Weekly != nil {
-		next.Weekly.UsedPercent = snap.Weekly.UsedPercent
-		next.Weekly.ResetAt = snap.Weekly.ResetAt
+		if quotaWindowIsNewer(current.Weekly, *snap.Weekly, snap.SourceTimestamp) {
+			next.Weekly = model.QuotaWindow{UsedPercent: snap.Weekly.UsedPercent, ResetAt: snap.Weekly.ResetAt, SourceTimestamp: snap.SourceTimestamp}
+		} else {
+			stale = true
+		}
 	}
 	sessionLimitReached := next.Session.UsedPercent >= 100
 	if next.SessionSupported != nil && !*next.SessionSupported {
 		sessionLimitReached = false
 	}
-	next.LimitReached = snap.LimitReached || sessionLimitReached || next.Weekly.UsedPercent >= 100
+	next.LimitReached = (snap.LimitReached && !stale) || sessionLimitReached || next.Weekly.UsedPercent >= 100
 	next.LastUpdated = now
 	return next
 }
 
+// quotaWindowIsNewer reports whether incoming, observed at observedAt, should
+// replace current. Source timestamps are compared when both are known;
+// otherwise a later reset time marks the more recent window. Ties go to the
+// incoming window.
+func quotaWindowIsNewer(current model.QuotaWindow, incoming quota.Window, observedAt time.Time) bool {
+	if !current.SourceTimestamp.IsZero() && !observedAt.IsZero() {
+		return !observedAt.Before(current.SourceTimestamp)
+	}
+	if !current.ResetAt.IsZero() && !incoming.ResetAt.IsZero() {
+		return !incoming.ResetAt.Before(current.ResetAt)
+	}
+	return true
+}
+
 func codexSecretsNeedImport(current, incoming model.AuthSecrets) bool {
 	incomingAccountID := strings.TrimSpace(incoming.AccountID)
 	currentAccountID := strings.TrimSpace(current.AccountID)
@@ -551,55 +1856,131 @@ func codexSecretsNeedImport(current, incoming model.AuthSecrets) bool {
 	return strings.TrimSpace(incoming.AccessToken) != strings.TrimSpace(current.AccessToken)
 }
 
-func (m *Manager) SyncAllQuotasFromCodexAPI(ctx context.Context) (QuotaSyncAllResult, error) {
+func (m *Manager) SyncAllQuotasFromCodexAPI(ctx context.Context, providers []string) (_ QuotaSyncAllResult, err error) {
+	ctx, span := m.startSpan(ctx, "manager.SyncAllQuotasFromCodexAPI", attrProvider.StringSlice(providers))
+	defer func() { endSpan(span, err) }()
+
+	startedAt := time.Now().UTC()
+
+	accountIDs, err := m.sortedAccountIDs(providers)
+	if err != nil {
+		return QuotaSyncAllResult{}, err
+	}
+	return m.syncQuotas(ctx, accountIDs, startedAt), nil
+}
+
+func (m *Manager) SyncStaleQuotasFromCodexAPI(ctx context.Context, updatedBefore time.Time) (QuotaSyncAllResult, error) {
 	startedAt := time.Now().UTC()
 
-	accountIDs, err := m.sortedAccountIDs()
+	accountIDs, err := m.staleAccountIDs(updatedBefore)
 	if err != nil {
 		return QuotaSyncAllResult{}, err
 	}
+	return m.syncQuotas(ctx, accountIDs, startedAt), nil
+}
+
+func (m *Manager) syncAccountContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if m.syncAccountTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, m.syncAccountTimeout)
+}
 
+func (m *Manager) syncQuotas(ctx context.Context, accountIDs []string, startedAt time.Time) QuotaSyncAllResult {
 	out := QuotaSyncAllResult{
 		Total:     len(accountIDs),
 		Results:   make([]QuotaSyncAllItem, 0, len(accountIDs)),
 		StartedAt: startedAt,
 	}
 
-	for _, accountID := range accountIDs {
-		item := QuotaSyncAllItem{
-			AccountID: accountID,
-		}
-
-		result, err := m.SyncQuotaFromCodexAPI(ctx, accountID)
-		if err != nil {
-			item.Success = false
-			item.Error = err.Error()
-			out.Failed++
-		} else {
-			item.Success = true
-			item.Result = &result
-			out.Succeeded++
-		}
-
-		out.Results = append(out.Results, item)
+	out.Results = out.Results[:len(accountIDs)]
+
+	// sem bounds the number of accounts fetched at once.
+	sem := make(chan struct{}, m.syncConcurrency)
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for i, accountID := range accountIDs {
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			item := QuotaSyncAllItem{
+				AccountID: accountID,
+			}
+			accountCtx, cancel := m.syncAccountContext(ctx)
+			result, err := m.SyncQuotaFromCodexAPI(accountCtx, accountID)
+			if err != nil && ctx.Err() == nil && errors.Is(accountCtx.Err(), context.DeadlineExceeded) {
+				err = fmt.Errorf("timeout after %s", m.syncAccountTimeout)
+			}
+			cancel()
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				item.Success = false
+				item.Error = err.Error()
+				out.Failed++
+			} else {
+				item.Success = true
+				item.Result = &result
+				out.Succeeded++
+			}
+			out.Results[i] = item
+		}()
 	}
+	wg.Wait()
 
 	out.FinishedAt = time.Now().UTC()
-	return out, nil
+	out.ElapsedMS = out.FinishedAt.Sub(startedAt).Milliseconds()
+	return out
 }
 
 func (m *Manager) HandleQuotaError(ctx context.Context, statusCode int, errorMessage string) (SwitchDecision, error) {
-	if !shouldSwitch(statusCode, errorMessage) {
-		return SwitchDecision{Switched: false, Reason: "not-switchable-error"}, nil
-	}
+	return m.handleQuotaError(ctx, statusCode, errorMessage, false)
+}
+
+// DryRunQuotaError runs the same account selection as HandleQuotaError,
+// including token refreshes, but neither applies the chosen account nor saves
+// state. The decision reports WouldSwitch instead of Switched.
+func (m *Manager) DryRunQuotaError(ctx context.Context, statusCode int, errorMessage string) (SwitchDecision, error) {
+	return m.handleQuotaError(ctx, statusCode, errorMessage, true)
+}
+
+func (m *Manager) handleQuotaError(ctx context.Context, statusCode int, errorMessage string, dryRun bool) (decision SwitchDecision, err error) {
+	ctx, span := m.startSpan(ctx, "manager.HandleQuotaError", attribute.Int("upstream.status_code", statusCode), attribute.Bool("switch.dry_run", dryRun))
+	defer func() {
+		decision.DryRun = dryRun
+		span.SetAttributes(attribute.Bool("switch.switched", decision.Switched), attribute.String("switch.reason", decision.Reason))
+		if decision.ToAccountID != "" {
+			span.SetAttributes(attribute.String("switch.to_account_id", decision.ToAccountID))
+		}
+		endSpan(span, err)
+	}()
 
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if time.Now().Before(m.cooldownUntil) {
+		return SwitchDecision{Switched: false, Reason: "cooldown"}, nil
+	}
+
 	state, err := m.stateStore.Load()
 	if err != nil {
 		return SwitchDecision{}, err
 	}
+	span.SetAttributes(attrAccountID.String(state.ActiveAccountID), attrRoutingStrategy.String(string(state.Strategy)))
+	if time.Now().Before(state.CooldownUntil) {
+		m.cooldownUntil = state.CooldownUntil
+		return SwitchDecision{Switched: false, Reason: "cooldown"}, nil
+	}
+	if !shouldSwitch(statusCode, errorMessage, state.SwitchRules) {
+		return SwitchDecision{Switched: false, Reason: "not-switchable-error"}, nil
+	}
 	if state.ActiveAccountID == "" {
 		return SwitchDecision{}, errors.New("no active account configured")
 	}
@@ -608,6 +1989,9 @@ func (m *Manager) HandleQuotaError(ctx context.Context, statusCode int, errorMes
 	}
 
 	activeID := state.ActiveAccountID
+	if active, ok := state.Accounts[activeID]; ok && active.Pinned && active.Status != model.AccountDisabled {
+		return SwitchDecision{Switched: false, Reason: "pinned-account"}, nil
+	}
 	order := orderedCandidates(state, activeID)
 	for _, accountID := range order {
 		acct := state.Accounts[accountID]
@@ -616,16 +2000,17 @@ func (m *Manager) HandleQuotaError(ctx context.Context, statusCode int, errorMes
 		}
 
 		if err := m.ensureFreshToken(ctx, &acct); err != nil {
-			acct.Status = model.AccountNeedReauth
-			acct.LastError = err.Error()
-			acct.UpdatedAt = time.Now().UTC()
+			markNeedReauth(&acct, err)
 			state.Accounts[accountID] = acct
 			continue
 		}
 
+		if dryRun {
+			return SwitchDecision{WouldSwitch: true, FromAccountID: activeID, ToAccountID: accountID, Reason: "quota-exceeded"}, nil
+		}
+
 		now := time.Now().UTC()
-		acct.Status = model.AccountReady
-		acct.LastError = ""
+		markReady(&acct)
 		acct.UpdatedAt = now
 
 		if err := m.applyAccount(ctx, acct); err != nil {
@@ -637,12 +2022,31 @@ func (m *Manager) HandleQuotaError(ctx context.Context, statusCode int, errorMes
 
 		acct.LastAppliedAt = now
 		state.Accounts[accountID] = acct
+		if from, ok := state.Accounts[activeID]; ok {
+			from.SwitchCount++
+			state.Accounts[activeID] = from
+		}
 		state.ActiveAccountID = accountID
+		advanceRoutingCursor(&state, accountID)
+		m.recordSwitch(&state, model.SwitchEvent{
+			Timestamp:     now,
+			FromAccountID: activeID,
+			ToAccountID:   accountID,
+			Reason:        "quota-exceeded",
+			StatusCode:    statusCode,
+			ErrorMessage:  errorMessage,
+		})
+		state.LastGlobalError = ""
+		state.LastGlobalErrorAt = time.Time{}
+		state.CooldownUntil = time.Time{}
 
 		if err := m.stateStore.Save(state); err != nil {
 			return SwitchDecision{}, err
 		}
 
+		m.emit(Event{Type: EventAccountSwitched, AccountID: accountID, FromAccountID: activeID, Reason: "quota-exceeded", Time: now})
+		m.notifySwitch(activeID, accountID)
+		m.syncAfterSwitch(ctx, accountID)
 		return SwitchDecision{
 			Switched:      true,
 			FromAccountID: activeID,
@@ -651,12 +2055,47 @@ func (m *Manager) HandleQuotaError(ctx context.Context, statusCode int, errorMes
 		}, nil
 	}
 
+	if dryRun {
+		return SwitchDecision{Switched: false, FromAccountID: activeID, Reason: "no-available-account"}, nil
+	}
+	state.LastGlobalError = "no available account to switch to"
+	state.LastGlobalErrorAt = time.Now().UTC()
+	if m.cooldown > 0 {
+		state.CooldownUntil = state.LastGlobalErrorAt.Add(m.cooldown)
+	}
 	if err := m.stateStore.Save(state); err != nil {
 		return SwitchDecision{}, err
 	}
+	m.cooldownUntil = state.CooldownUntil
 	return SwitchDecision{Switched: false, FromAccountID: activeID, Reason: "no-available-account"}, nil
 }
 
+// syncAfterSwitch runs after the caller releases m.mu; the sync waits for the
+// lock, so the switch decision is returned first.
+func (m *Manager) syncAfterSwitch(ctx context.Context, accountID string) {
+	if !m.postSwitchSync {
+		return
+	}
+	ctx = context.WithoutCancel(ctx)
+	go func() {
+		if _, err := m.SyncQuotaFromCodexAPI(ctx, accountID); err != nil {
+			log.Printf("post-switch quota sync for %s failed: %v", accountID, err)
+		}
+	}()
+}
+
+func (m *Manager) notifySwitch(fromID, toID string) {
+	if m.notifier == nil {
+		return
+	}
+	body := fmt.Sprintf("Quota exhausted on %s, switched to %s.", fromID, toID)
+	go func() {
+		if err := m.notifier.Notify("Switchly switched accounts", body); err != nil {
+			log.Printf("switch notification failed: %v", err)
+		}
+	}()
+}
+
 func (m *Manager) applyAccount(ctx context.Context, account model.Account) error {
 	if m.applier == nil {
 		return nil
@@ -675,13 +2114,26 @@ func (m *Manager) clearAppliedAccount(ctx context.Context) error {
 	return m.applier.Clear(ctx)
 }
 
+// orderedCandidates never offers a pinned account: pinning keeps an account
+// active once selected, it does not make it a switch target. Only accounts of
+// the active account's provider are offered, since switching applies that
+// provider's credentials and a client on one provider cannot use another's.
 func orderedCandidates(state model.AppState, activeID string) []string {
+	provider := ""
+	if active, ok := state.Accounts[activeID]; ok {
+		provider = active.Provider
+	}
 	ids := make([]string, 0, len(state.Accounts))
-	for id := range state.Accounts {
-		if id == activeID {
+	candidates := make(map[string]struct{}, len(state.Accounts))
+	for id, acct := range state.Accounts {
+		if id == activeID || acct.Pinned {
+			continue
+		}
+		if provider != "" && !strings.EqualFold(acct.Provider, provider) {
 			continue
 		}
 		ids = append(ids, id)
+		candidates[id] = struct{}{}
 	}
 
 	if state.Strategy == model.RoutingFillFirst {
@@ -689,19 +2141,153 @@ func orderedCandidates(state model.AppState, activeID string) []string {
 			left := state.Accounts[ids[i]].Quota.Session.UsedPercent + state.Accounts[ids[i]].Quota.Weekly.UsedPercent
 			right := state.Accounts[ids[j]].Quota.Session.UsedPercent + state.Accounts[ids[j]].Quota.Weekly.UsedPercent
 			if left == right {
-				return ids[i] < ids[j]
+				return leastRecentlyApplied(state.Accounts[ids[i]], state.Accounts[ids[j]])
 			}
 			return left < right
 		})
 		return ids
 	}
 
-	// round-robin fallback: deterministic by ID for now.
-	sort.Strings(ids)
+	if state.Strategy == model.RoutingLeastQuota {
+		sort.Slice(ids, func(i, j int) bool {
+			left, right := state.Accounts[ids[i]], state.Accounts[ids[j]]
+			if lp, rp := peakUsedPercent(left), peakUsedPercent(right); lp != rp {
+				return lp < rp
+			}
+			if ls, rs := left.Quota.Session.UsedPercent+left.Quota.Weekly.UsedPercent, right.Quota.Session.UsedPercent+right.Quota.Weekly.UsedPercent; ls != rs {
+				return ls < rs
+			}
+			return leastRecentlyApplied(left, right)
+		})
+		return ids
+	}
+
+	if state.Strategy == model.RoutingPriority {
+		return priorityOrder(state, ids)
+	}
+
+	if state.Strategy == model.RoutingWeightedRoundRobin {
+		seq := weightedSequence(state)
+		start := cursorStart(state.RoutingCursor, len(seq))
+		out := make([]string, 0, len(ids))
+		seen := make(map[string]struct{}, len(ids))
+		for i := range seq {
+			id := seq[(start+i)%len(seq)]
+			if _, ok := candidates[id]; !ok {
+				continue
+			}
+			if _, dup := seen[id]; dup {
+				continue
+			}
+			seen[id] = struct{}{}
+			out = append(out, id)
+		}
+		return out
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return leastRecentlyApplied(state.Accounts[ids[i]], state.Accounts[ids[j]])
+	})
 	return ids
 }
 
-func (m *Manager) sortedAccountIDs() ([]string, error) {
+// peakUsedPercent is the usage of whichever quota window is closest to its
+// limit, i.e. how little headroom the account has left.
+func peakUsedPercent(acct model.Account) int {
+	return max(acct.Quota.Session.UsedPercent, acct.Quota.Weekly.UsedPercent)
+}
+
+// priorityOrder lists ids in the configured priority order, followed by any
+// unlisted accounts alphabetically.
+func priorityOrder(state model.AppState, ids []string) []string {
+	candidates := make(map[string]struct{}, len(ids))
+	for _, id := range ids {
+		candidates[id] = struct{}{}
+	}
+	out := make([]string, 0, len(ids))
+	for _, id := range state.Priorities {
+		if _, ok := candidates[id]; !ok {
+			continue
+		}
+		delete(candidates, id)
+		out = append(out, id)
+	}
+	rest := make([]string, 0, len(candidates))
+	for id := range candidates {
+		rest = append(rest, id)
+	}
+	sort.Strings(rest)
+	return append(out, rest...)
+}
+
+func leastRecentlyApplied(left, right model.Account) bool {
+	if left.LastAppliedAt.Equal(right.LastAppliedAt) {
+		return left.ID < right.ID
+	}
+	return left.LastAppliedAt.Before(right.LastAppliedAt)
+}
+
+// maxAccountWeight bounds the weighted sequence, which has one slot per unit
+// of weight across all accounts.
+const maxAccountWeight = 1000
+
+// accountWeight also clamps weights from hand-edited state files.
+func accountWeight(acct model.Account) int {
+	return min(max(acct.Weight, 1), maxAccountWeight)
+}
+
+// cursorStart maps a stored routing cursor onto a sequence of n slots; a
+// hand-edited state file may hold a negative or oversized cursor.
+func cursorStart(cursor, n int) int {
+	return ((cursor % n) + n) % n
+}
+
+// weightedSequence interleaves account IDs in proportion to their weights
+// using the greatest-remainder method, so every prefix of the sequence stays
+// as close as possible to the declared ratios.
+func weightedSequence(state model.AppState) []string {
+	ids := make([]string, 0, len(state.Accounts))
+	total := 0
+	for id, acct := range state.Accounts {
+		ids = append(ids, id)
+		total += accountWeight(acct)
+	}
+	sort.Strings(ids)
+
+	seq := make([]string, 0, total)
+	assigned := make(map[string]int, len(ids))
+	for slot := 1; slot <= total; slot++ {
+		best := ""
+		bestRemainder := 0
+		for _, id := range ids {
+			remainder := slot*accountWeight(state.Accounts[id]) - assigned[id]*total
+			if best == "" || remainder > bestRemainder {
+				best = id
+				bestRemainder = remainder
+			}
+		}
+		assigned[best]++
+		seq = append(seq, best)
+	}
+	return seq
+}
+
+func advanceRoutingCursor(state *model.AppState, chosenID string) {
+	if state.Strategy != model.RoutingWeightedRoundRobin {
+		return
+	}
+	seq := weightedSequence(*state)
+	start := cursorStart(state.RoutingCursor, len(seq))
+	for i := range seq {
+		pos := (start + i) % len(seq)
+		if seq[pos] == chosenID {
+			state.RoutingCursor = (pos + 1) % len(seq)
+			return
+		}
+	}
+}
+
+func (m *Manager) sortedAccountIDs(providers []string) ([]string, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -710,36 +2296,124 @@ func (m *Manager) sortedAccountIDs() ([]string, error) {
 		return nil, err
 	}
 
+	var allowed map[string]struct{}
+	if providers != nil {
+		allowed = make(map[string]struct{}, len(providers))
+		for _, p := range providers {
+			if v := strings.ToLower(strings.TrimSpace(p)); v != "" {
+				allowed[v] = struct{}{}
+			}
+		}
+	}
+
 	ids := make([]string, 0, len(state.Accounts))
-	for id := range state.Accounts {
+	for id, acct := range state.Accounts {
+		if allowed != nil {
+			if _, ok := allowed[strings.ToLower(acct.Provider)]; !ok {
+				continue
+			}
+		}
 		ids = append(ids, id)
 	}
 	sort.Strings(ids)
 	return ids, nil
 }
 
-func shouldSwitch(statusCode int, message string) bool {
-	switch statusCode {
-	case 429, 500, 503:
-		return true
-	case 200, 201, 202, 203, 204, 205, 206, 207, 208, 226, 400, 401, 403, 422:
-		// Continue to pattern checks below.
-	default:
-		// Continue to pattern checks below.
+func (m *Manager) staleAccountIDs(updatedBefore time.Time) ([]string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
 	}
 
-	lower := strings.ToLower(message)
-	patterns := []string{
+	// Only accounts an explicit sync would accept are picked, so the
+	// scheduler doesn't fail on the same disabled or non-codex accounts
+	// every tick.
+	ids := make([]string, 0, len(state.Accounts))
+	for id, acct := range state.Accounts {
+		if acct.Status == model.AccountDisabled || !strings.EqualFold(acct.Provider, "codex") {
+			continue
+		}
+		if acct.Quota.LastUpdated.After(updatedBefore) {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+var (
+	defaultSwitchStatusCodes = []int{429, 500, 503}
+	defaultSwitchPatterns    = []string{
 		"quota exceeded", "rate limit", "limit reached", "insufficient_quota",
 		"resource_exhausted", "overloaded", "capacity", "too many requests",
 		"throttl", "authentication", "unauthorized", "access denied",
 	}
-	for _, p := range patterns {
-		if strings.Contains(lower, p) {
-			return true
+)
+
+func DefaultSwitchRules() model.SwitchRules {
+	return model.SwitchRules{
+		StatusCodes:     append([]int(nil), defaultSwitchStatusCodes...),
+		MessagePatterns: append([]string(nil), defaultSwitchPatterns...),
+	}
+}
+
+func shouldSwitch(statusCode int, message string, userRules model.SwitchRules) bool {
+	for _, rules := range []model.SwitchRules{DefaultSwitchRules(), userRules} {
+		for _, code := range rules.StatusCodes {
+			if statusCode == code {
+				return true
+			}
+		}
+	}
+
+	lower := strings.ToLower(message)
+	for _, rules := range []model.SwitchRules{DefaultSwitchRules(), userRules} {
+		for _, p := range rules.MessagePatterns {
+			if p != "" && strings.Contains(lower, strings.ToLower(p)) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func normalizeSwitchRules(in model.SwitchRules) (model.SwitchRules, error) {
+	out := model.SwitchRules{StatusCodes: []int{}, MessagePatterns: []string{}}
+	seenCodes := map[int]struct{}{}
+	for _, code := range in.StatusCodes {
+		if code < 100 || code > 599 {
+			return model.SwitchRules{}, fmt.Errorf("invalid status code: %d", code)
+		}
+		if _, ok := seenCodes[code]; ok {
+			continue
+		}
+		seenCodes[code] = struct{}{}
+		out.StatusCodes = append(out.StatusCodes, code)
+	}
+	seenPatterns := map[string]struct{}{}
+	for _, pattern := range in.MessagePatterns {
+		p := strings.ToLower(strings.TrimSpace(pattern))
+		if p == "" {
+			return model.SwitchRules{}, errors.New("message patterns must not be empty")
+		}
+		if _, ok := seenPatterns[p]; ok {
+			continue
 		}
+		seenPatterns[p] = struct{}{}
+		out.MessagePatterns = append(out.MessagePatterns, p)
+	}
+	return out, nil
+}
+
+func (m *Manager) recordSwitch(state *model.AppState, evt model.SwitchEvent) {
+	state.SwitchHistory = append(state.SwitchHistory, evt)
+	if len(state.SwitchHistory) > m.historyMax {
+		state.SwitchHistory = append([]model.SwitchEvent(nil), state.SwitchHistory[len(state.SwitchHistory)-m.historyMax:]...)
 	}
-	return statusCode == 429 || statusCode == 503 || statusCode == 500
 }
 
 func cloneAppState(in model.AppState) model.AppState {
@@ -748,6 +2422,11 @@ func cloneAppState(in model.AppState) model.AppState {
 	for id, account := range in.Accounts {
 		out.Accounts[id] = account
 	}
+	out.SwitchHistory = append([]model.SwitchEvent(nil), in.SwitchHistory...)
+	out.Priorities = append([]string(nil), in.Priorities...)
+	out.SwitchRules.StatusCodes = append([]int(nil), in.SwitchRules.StatusCodes...)
+	out.SwitchRules.MessagePatterns = append([]string(nil), in.SwitchRules.MessagePatterns...)
+	out.OAuthProviders = append([]model.OAuthProvider(nil), in.OAuthProviders...)
 	return out
 }
 
@@ -775,31 +2454,56 @@ func shouldMarkNeedReauth(err error) bool {
 	return false
 }
 
+// markNeedReauth flags an account whose token could not be refreshed.
+func markNeedReauth(acct *model.Account, err error) {
+	acct.Status = model.AccountNeedReauth
+	acct.LastError = err.Error()
+	acct.ErrorCount++
+	acct.UpdatedAt = time.Now().UTC()
+}
+
+// markReady clears a previous failure. An outstanding quota warning is kept
+// since it still describes the account's usage.
+func markReady(acct *model.Account) {
+	if acct.Status != model.AccountWarning {
+		acct.Status = model.AccountReady
+	}
+	acct.LastError = ""
+}
+
 func (m *Manager) ensureFreshToken(ctx context.Context, account *model.Account) error {
+	return m.refreshAccountToken(ctx, account, false)
+}
+
+func (m *Manager) refreshAccountToken(ctx context.Context, account *model.Account, force bool) error {
 	secretsData, err := m.secrets.Get(account.ID)
 	if err != nil {
 		return err
 	}
 
 	now := time.Now().UTC()
-	if secretsData.AccessExpiresAt.IsZero() || secretsData.AccessExpiresAt.After(now.Add(tokenRefreshLeadTime)) {
+	if !force && (secretsData.AccessExpiresAt.IsZero() || secretsData.AccessExpiresAt.After(now.Add(tokenRefreshLeadTime))) {
 		account.AccessExpiresAt = secretsData.AccessExpiresAt
 		account.RefreshExpiresAt = secretsData.RefreshExpiresAt
 		return nil
 	}
 
 	if strings.TrimSpace(secretsData.RefreshToken) == "" {
-		return errors.New("refresh token missing")
+		return fmt.Errorf("refresh token missing: %w", ErrReauthRequired)
 	}
 	if !secretsData.RefreshExpiresAt.IsZero() && secretsData.RefreshExpiresAt.Before(now) {
-		return errors.New("refresh token expired")
+		return fmt.Errorf("refresh token expired: %w", ErrReauthRequired)
 	}
 
 	if strings.ToLower(account.Provider) != "codex" {
 		return fmt.Errorf("provider %s refresh is not implemented", account.Provider)
 	}
 
-	updated, err := m.refreshCodexAccessToken(ctx, secretsData.RefreshToken)
+	client, err := m.httpClientFor(*account)
+	if err != nil {
+		return fmt.Errorf("http client for account %s: %w", account.ID, err)
+	}
+	updated, err := m.refreshCodexAccessToken(ctx, client, secretsData.RefreshToken)
 	if err != nil {
 		return err
 	}
@@ -825,7 +2529,7 @@ type codexRefreshResponse struct {
 	ExpiresIn   int    `json:"expires_in"`
 }
 
-func (m *Manager) refreshCodexAccessToken(ctx context.Context, refreshToken string) (model.AuthSecrets, error) {
+func (m *Manager) refreshCodexAccessToken(ctx context.Context, client *http.Client, refreshToken string) (model.AuthSecrets, error) {
 	body := map[string]string{
 		"grant_type":    "refresh_token",
 		"refresh_token": refreshToken,
@@ -839,7 +2543,7 @@ func (m *Manager) refreshCodexAccessToken(ctx context.Context, refreshToken stri
 	}
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := m.httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return model.AuthSecrets{}, err
 	}
@@ -1,34 +1,68 @@
 package core
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log"
 	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"switchly/internal/model"
 	"switchly/internal/quota"
+	"switchly/internal/webhook"
 )
 
+// TestMain points CODEX_HOME at an empty directory so quota syncs never fall
+// back to the session logs of a Codex CLI installed on the test machine.
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "switchly-core-test")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv("CODEX_HOME", dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestShouldSwitch(t *testing.T) {
+	userRules := model.SwitchRules{
+		StatusCodes:     []int{418},
+		MessagePatterns: []string{"context_length_exceeded"},
+	}
 	tests := []struct {
 		name    string
 		status  int
 		message string
+		rules   model.SwitchRules
 		want    bool
 	}{
 		{name: "status 429", status: 429, message: "", want: true},
 		{name: "quota pattern", status: 400, message: "insufficient_quota", want: true},
 		{name: "rate limit text", status: 200, message: "Rate limit exceeded", want: true},
 		{name: "regular 200", status: 200, message: "ok", want: false},
+		{name: "user pattern without rules", status: 400, message: "context_length_exceeded", want: false},
+		{name: "user pattern", status: 400, message: "error: Context_Length_Exceeded", rules: userRules, want: true},
+		{name: "user status code", status: 418, message: "", rules: userRules, want: true},
+		{name: "default status with user rules", status: 503, message: "", rules: userRules, want: true},
+		{name: "default pattern with user rules", status: 400, message: "insufficient_quota", rules: userRules, want: true},
+		{name: "no match with user rules", status: 200, message: "ok", rules: userRules, want: false},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got := shouldSwitch(tt.status, tt.message)
+			got := shouldSwitch(tt.status, tt.message, tt.rules)
 			if got != tt.want {
 				t.Fatalf("shouldSwitch(%d,%q)=%v want %v", tt.status, tt.message, got, tt.want)
 			}
@@ -36,6 +70,35 @@ func TestShouldSwitch(t *testing.T) {
 	}
 }
 
+func TestSetSwitchRulesNormalizesAndPersists(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+
+	out, err := mgr.SetSwitchRules(context.Background(), model.SwitchRules{
+		StatusCodes:     []int{418, 418},
+		MessagePatterns: []string{" Context_Length_Exceeded ", "context_length_exceeded"},
+	})
+	if err != nil {
+		t.Fatalf("set rules: %v", err)
+	}
+	if len(out.User.StatusCodes) != 1 || len(out.User.MessagePatterns) != 1 || out.User.MessagePatterns[0] != "context_length_exceeded" {
+		t.Fatalf("unexpected user rules: %#v", out.User)
+	}
+	if len(out.Defaults.StatusCodes) == 0 || len(out.Defaults.MessagePatterns) == 0 {
+		t.Fatalf("expected defaults in snapshot: %#v", out.Defaults)
+	}
+	if len(state.state.SwitchRules.MessagePatterns) != 1 {
+		t.Fatalf("expected rules persisted, got %#v", state.state.SwitchRules)
+	}
+
+	if _, err := mgr.SetSwitchRules(context.Background(), model.SwitchRules{StatusCodes: []int{42}}); err == nil {
+		t.Fatal("expected invalid status code error")
+	}
+	if _, err := mgr.SetSwitchRules(context.Background(), model.SwitchRules{MessagePatterns: []string{"  "}}); err == nil {
+		t.Fatal("expected empty pattern error")
+	}
+}
+
 func TestValidateAddAccountInput(t *testing.T) {
 	tests := []struct {
 		name    string
@@ -183,6 +246,63 @@ func TestMergeQuotaSnapshot(t *testing.T) {
 	}
 }
 
+func TestMergeQuotaSnapshotKeepsNewerStoredWindows(t *testing.T) {
+	now := time.Date(2026, 2, 23, 10, 0, 0, 0, time.UTC)
+	logAt := now.Add(-5 * time.Minute)
+	current := model.QuotaSnapshot{
+		Session: model.QuotaWindow{UsedPercent: 70, ResetAt: now.Add(3 * time.Hour), SourceTimestamp: logAt},
+		Weekly:  model.QuotaWindow{UsedPercent: 40, ResetAt: now.Add(4 * 24 * time.Hour), SourceTimestamp: logAt},
+	}
+
+	// The usage API answered from a cache filled three days ago.
+	cached := quota.Snapshot{
+		Session:         &quota.Window{UsedPercent: 100, ResetAt: now.Add(-3*24*time.Hour + 2*time.Hour)},
+		Weekly:          &quota.Window{UsedPercent: 10, ResetAt: now.Add(4 * 24 * time.Hour)},
+		SourceTimestamp: now.Add(-3 * 24 * time.Hour),
+		LimitReached:    true,
+	}
+	got := mergeQuotaSnapshot(current, cached, now)
+	if got.Session != current.Session || got.Weekly != current.Weekly {
+		t.Fatalf("expected stored windows to win over a stale snapshot, got %#v", got)
+	}
+	if got.LimitReached {
+		t.Fatal("expected the stale snapshot's limit signal to be ignored")
+	}
+	if !got.LastUpdated.Equal(now) {
+		t.Fatalf("expected LastUpdated %s, got %s", now, got.LastUpdated)
+	}
+
+	fresh := quota.Snapshot{
+		Session:         &quota.Window{UsedPercent: 75, ResetAt: now.Add(3 * time.Hour)},
+		SourceTimestamp: now,
+	}
+	got = mergeQuotaSnapshot(current, fresh, now)
+	if got.Session.UsedPercent != 75 || !got.Session.SourceTimestamp.Equal(now) {
+		t.Fatalf("expected the fresher session window to be applied, got %#v", got.Session)
+	}
+	if got.Weekly != current.Weekly {
+		t.Fatalf("expected weekly window to be preserved, got %#v", got.Weekly)
+	}
+}
+
+func TestMergeQuotaSnapshotFallsBackToResetAt(t *testing.T) {
+	now := time.Date(2026, 2, 23, 10, 0, 0, 0, time.UTC)
+	// Stored before source timestamps were tracked.
+	current := model.QuotaSnapshot{
+		Session: model.QuotaWindow{UsedPercent: 30, ResetAt: now.Add(4 * time.Hour)},
+	}
+
+	older := quota.Snapshot{Session: &quota.Window{UsedPercent: 90, ResetAt: now.Add(-time.Hour)}}
+	if got := mergeQuotaSnapshot(current, older, now); got.Session.UsedPercent != 30 {
+		t.Fatalf("expected a window from an earlier reset period to be ignored, got %#v", got.Session)
+	}
+
+	sameWindow := quota.Snapshot{Session: &quota.Window{UsedPercent: 45, ResetAt: now.Add(4 * time.Hour)}}
+	if got := mergeQuotaSnapshot(current, sameWindow, now); got.Session.UsedPercent != 45 {
+		t.Fatalf("expected a window with the same reset time to be applied, got %#v", got.Session)
+	}
+}
+
 func boolPtr(v bool) *bool {
 	return &v
 }
@@ -210,6 +330,341 @@ func TestOrderedCandidatesFillFirst(t *testing.T) {
 	}
 }
 
+func TestOrderedCandidatesLeastQuota(t *testing.T) {
+	accounts := map[string]model.Account{
+		"A": {ID: "A"},
+		"B": {ID: "B", Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 10}, Weekly: model.QuotaWindow{UsedPercent: 80}}},
+		"C": {ID: "C", Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 60}, Weekly: model.QuotaWindow{UsedPercent: 50}}},
+		"D": {ID: "D", Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 60}, Weekly: model.QuotaWindow{UsedPercent: 35}}},
+	}
+
+	// Sums: B=90, D=95, C=110.
+	fillFirst := orderedCandidates(model.AppState{Strategy: model.RoutingFillFirst, Accounts: accounts}, "A")
+	if want := "B,D,C"; strings.Join(fillFirst, ",") != want {
+		t.Fatalf("fill-first order = %v, want %s", fillFirst, want)
+	}
+
+	// Peak usage: B=80, C=60, D=60; C and D tie and D wins on the lower sum.
+	leastQuota := orderedCandidates(model.AppState{Strategy: model.RoutingLeastQuota, Accounts: accounts}, "A")
+	if want := "D,C,B"; strings.Join(leastQuota, ",") != want {
+		t.Fatalf("least-quota order = %v, want %s", leastQuota, want)
+	}
+}
+
+func TestHandleQuotaErrorLeastQuotaDiffersFromFillFirst(t *testing.T) {
+	for _, tc := range []struct {
+		strategy model.RoutingStrategy
+		want     string
+	}{
+		{strategy: model.RoutingFillFirst, want: "B"},
+		{strategy: model.RoutingLeastQuota, want: "C"},
+	} {
+		t.Run(string(tc.strategy), func(t *testing.T) {
+			state := &fakeStateStore{
+				state: model.AppState{
+					Version:         1,
+					ActiveAccountID: "A",
+					Strategy:        model.RoutingRoundRobin,
+					Accounts: map[string]model.Account{
+						"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+						// B: sum 110, peak 80. C: sum 115, peak 60.
+						"B": {ID: "B", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 30}, Weekly: model.QuotaWindow{UsedPercent: 80}}},
+						"C": {ID: "C", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 60}, Weekly: model.QuotaWindow{UsedPercent: 55}}},
+					},
+				},
+			}
+			secrets := &fakeSecretStore{
+				entries: map[string]model.AuthSecrets{
+					"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+					"C": {AccessToken: "token-c", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+				},
+			}
+			mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}))
+			if err := mgr.SetStrategy(context.Background(), tc.strategy); err != nil {
+				t.Fatalf("set strategy: %v", err)
+			}
+			decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+			if err != nil || !decision.Switched || decision.ToAccountID != tc.want {
+				t.Fatalf("expected switch to %s, got %#v err=%v", tc.want, decision, err)
+			}
+		})
+	}
+}
+
+func TestOrderedCandidatesPriority(t *testing.T) {
+	state := model.AppState{
+		Strategy:   model.RoutingPriority,
+		Priorities: []string{"C", "gone", "A", "E"},
+		Accounts: map[string]model.Account{
+			"A": {ID: "A"},
+			"B": {ID: "B", LastAppliedAt: time.Now()},
+			"C": {ID: "C"},
+			"D": {ID: "D"},
+			"E": {ID: "E"},
+		},
+	}
+
+	got := orderedCandidates(state, "E")
+	want := []string{"C", "A", "B", "D"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected order: %v", got)
+	}
+}
+
+func TestOrderedCandidatesSkipsPinnedAccounts(t *testing.T) {
+	state := model.AppState{
+		Strategy: model.RoutingRoundRobin,
+		Accounts: map[string]model.Account{
+			"A": {ID: "A"},
+			"B": {ID: "B", Pinned: true},
+			"C": {ID: "C"},
+		},
+	}
+
+	got := orderedCandidates(state, "A")
+	if len(got) != 1 || got[0] != "C" {
+		t.Fatalf("unexpected order: %#v", got)
+	}
+}
+
+func TestHandleQuotaErrorStaysWithinActiveProvider(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "codex-a",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"codex-a":   {ID: "codex-a", Provider: "codex", Status: model.AccountReady},
+				"copilot-b": {ID: "copilot-b", Provider: "copilot", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"copilot-b": {AccessToken: "token-b"},
+		},
+	}
+	applier := &fakeApplier{}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
+
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil {
+		t.Fatalf("handle quota: %v", err)
+	}
+	if decision.Switched || decision.Reason != "no-available-account" {
+		t.Fatalf("expected no switch to the copilot account, got %#v", decision)
+	}
+	if state.state.ActiveAccountID != "codex-a" || applier.calls != 0 {
+		t.Fatalf("expected codex-a to stay active without applying, got %q (%d applies)", state.state.ActiveAccountID, applier.calls)
+	}
+
+	state.state.Strategy = model.RoutingWeightedRoundRobin
+	state.state.CooldownUntil = time.Time{}
+	if got := orderedCandidates(state.state, "codex-a"); len(got) != 0 {
+		t.Fatalf("expected no weighted candidates across providers, got %v", got)
+	}
+}
+
+func TestHandleQuotaErrorKeepsPinnedActiveAccount(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}))
+
+	acct, err := mgr.SetAccountPinned(context.Background(), "A", true)
+	if err != nil || !acct.Pinned {
+		t.Fatalf("pin: %#v err=%v", acct, err)
+	}
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil {
+		t.Fatalf("handle quota: %v", err)
+	}
+	if decision.Switched || decision.Reason != "pinned-account" {
+		t.Fatalf("expected pinned-account decision, got %#v", decision)
+	}
+	if state.state.ActiveAccountID != "A" {
+		t.Fatalf("expected active account to stay A, got %q", state.state.ActiveAccountID)
+	}
+
+	if _, err := mgr.SetAccountPinned(context.Background(), "A", false); err != nil {
+		t.Fatalf("unpin: %v", err)
+	}
+	decision, err = mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || !decision.Switched || decision.ToAccountID != "B" {
+		t.Fatalf("expected switch after unpin, got %#v err=%v", decision, err)
+	}
+
+	if _, err := mgr.SetAccountPinned(context.Background(), "missing", true); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func TestSetPrioritiesValidatesAndPersists(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+
+	got, err := mgr.SetPriorities(context.Background(), []string{" A ", "B"})
+	if err != nil {
+		t.Fatalf("SetPriorities: %v", err)
+	}
+	if strings.Join(got, ",") != "A,B" || strings.Join(state.state.Priorities, ",") != "A,B" {
+		t.Fatalf("unexpected priorities: got=%v stored=%v", got, state.state.Priorities)
+	}
+	if _, err := mgr.SetPriorities(context.Background(), []string{"A", "A"}); err == nil {
+		t.Fatal("expected duplicate ids to be rejected")
+	}
+	if _, err := mgr.SetPriorities(context.Background(), []string{"A", " "}); err == nil {
+		t.Fatal("expected empty ids to be rejected")
+	}
+	if strings.Join(state.state.Priorities, ",") != "A,B" {
+		t.Fatalf("expected rejected updates to leave priorities unchanged, got %v", state.state.Priorities)
+	}
+}
+
+func TestWeightedSequenceInterleavesByWeight(t *testing.T) {
+	state := model.AppState{
+		Accounts: map[string]model.Account{
+			"A": {ID: "A", Weight: 2},
+			"B": {ID: "B"},
+			"C": {ID: "C", Weight: 1},
+		},
+	}
+
+	got := weightedSequence(state)
+	want := []string{"A", "B", "C", "A"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected sequence: %v", got)
+	}
+}
+
+func TestWeightsAreBounded(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingWeightedRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+	if _, err := mgr.SetAccountWeight(context.Background(), "A", maxAccountWeight+1); err == nil {
+		t.Fatal("expected an oversized weight to be rejected")
+	}
+	if _, err := mgr.SetAccountWeight(context.Background(), "A", maxAccountWeight); err != nil {
+		t.Fatalf("set max weight: %v", err)
+	}
+	if _, err := mgr.AddAccount(context.Background(), AddAccountInput{ID: "C", Provider: "codex", Weight: 1e9, Secrets: model.AuthSecrets{AccessToken: "token"}}); err == nil {
+		t.Fatal("expected an oversized weight to be rejected on add")
+	}
+
+	// A hand-edited state file can hold any weight or cursor.
+	state.state.Accounts["B"] = model.Account{ID: "B", Provider: "codex", Weight: 1e9}
+	state.state.RoutingCursor = -7
+	if got := len(weightedSequence(state.state)); got != 2*maxAccountWeight {
+		t.Fatalf("expected weights to be clamped, got a sequence of %d", got)
+	}
+	if got := orderedCandidates(state.state, "A"); len(got) != 1 || got[0] != "B" {
+		t.Fatalf("unexpected candidates with a negative cursor: %v", got)
+	}
+	advanceRoutingCursor(&state.state, "B")
+	if state.state.RoutingCursor < 0 {
+		t.Fatalf("expected the cursor to be normalised, got %d", state.state.RoutingCursor)
+	}
+}
+
+func TestHandleQuotaErrorWeightedRoundRobinDistribution(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "Z",
+			Strategy:        model.RoutingWeightedRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady, Weight: 3},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady, Weight: 1},
+				"Z": {ID: "Z", Provider: "codex", Status: model.AccountDisabled},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}))
+
+	const switches = 8
+	counts := map[string]int{}
+	for i := 0; i < switches; i++ {
+		state.state.ActiveAccountID = "Z"
+		decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+		if err != nil {
+			t.Fatalf("handle quota: %v", err)
+		}
+		if !decision.Switched {
+			t.Fatalf("expected switch, got %#v", decision)
+		}
+		counts[decision.ToAccountID]++
+	}
+
+	for id, weight := range map[string]int{"A": 3, "B": 1} {
+		want := switches * weight / 4
+		if diff := counts[id] - want; diff < -1 || diff > 1 {
+			t.Fatalf("account %s switched %d times, want %d±1 (counts=%v)", id, counts[id], want, counts)
+		}
+	}
+}
+
+func TestAddAccountKeepsUserSettingsOnReAdd(t *testing.T) {
+	created := time.Date(2026, 9, 1, 0, 0, 0, 0, time.UTC)
+	existing := model.Account{
+		ID:           "A",
+		Provider:     "codex",
+		Email:        "a@example.com",
+		Alias:        "work",
+		Notes:        "team seat",
+		ProviderMeta: map[string]string{"org": "acme"},
+		Status:       model.AccountNeedReauth,
+		Weight:       3,
+		Pinned:       true,
+		HTTPConfig:   &model.HTTPClientConfig{ProxyURL: "http://proxy.example:8080"},
+		Thresholds:   &model.QuotaThresholds{SessionWarnAt: 50, WeeklyWarnAt: 60},
+		CreatedAt:    created,
+	}
+	state := &fakeStateStore{state: model.AppState{Version: 1, Accounts: map[string]model.Account{"A": existing}}}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+
+	acct, err := mgr.AddAccount(context.Background(), AddAccountInput{ID: "A", Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "new-token"}})
+	if err != nil {
+		t.Fatalf("re-add: %v", err)
+	}
+	if acct.Status != model.AccountReady {
+		t.Fatalf("expected the re-added account to be ready, got %s", acct.Status)
+	}
+	acct.Status, acct.UpdatedAt, acct.AccessExpiresAt = existing.Status, existing.UpdatedAt, existing.AccessExpiresAt
+	if !reflect.DeepEqual(acct, existing) {
+		t.Fatalf("expected the user's settings to be kept:\n got %#v\nwant %#v", acct, existing)
+	}
+	if !reflect.DeepEqual(state.state.Accounts["A"].ProviderMeta, existing.ProviderMeta) {
+		t.Fatalf("expected the kept settings to be saved, got %#v", state.state.Accounts["A"])
+	}
+}
+
 func TestAddAccountDoesNotPersistStateWhenSecretWriteFails(t *testing.T) {
 	state := &fakeStateStore{state: model.DefaultState()}
 	secretErr := errors.New("secret write failed")
@@ -339,98 +794,285 @@ func TestSetActiveAccountDoesNotChangeStateWhenApplyFails(t *testing.T) {
 	}
 }
 
-func TestDeleteAccountRemovesInactiveAccount(t *testing.T) {
+func TestForceSetActiveAccountPersistsWhenApplyFails(t *testing.T) {
 	state := &fakeStateStore{
 		state: model.AppState{
 			Version:         1,
-			ActiveAccountID: "A",
+			ActiveAccountID: "codex:old@example.com",
 			Strategy:        model.RoutingRoundRobin,
 			Accounts: map[string]model.Account{
-				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
-				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+				"codex:old@example.com": {ID: "codex:old@example.com", Provider: "codex", Status: model.AccountReady},
+				"codex:new@example.com": {ID: "codex:new@example.com", Provider: "codex", Status: model.AccountNeedReauth},
 			},
 		},
 	}
 	secrets := &fakeSecretStore{
 		entries: map[string]model.AuthSecrets{
-			"A": {AccessToken: "token-a"},
-			"B": {AccessToken: "token-b"},
+			"codex:old@example.com": {AccessToken: "old"},
+			"codex:new@example.com": {AccessToken: "new"},
 		},
 	}
-	applier := &fakeApplier{}
+	applier := &fakeApplier{applyErr: errors.New("auth file is read-only")}
 	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
 
-	result, err := mgr.DeleteAccount(context.Background(), "B")
+	if err := mgr.SetActiveAccount(context.Background(), "codex:new@example.com"); err == nil {
+		t.Fatal("expected the normal switch to refuse a blocked account")
+	}
+
+	result, err := mgr.ForceSetActiveAccount(context.Background(), "codex:new@example.com")
 	if err != nil {
-		t.Fatalf("delete account: %v", err)
+		t.Fatalf("force set active: %v", err)
 	}
-	if result.WasActive || result.Switched {
-		t.Fatalf("unexpected delete result: %#v", result)
+	if result.ApplierError != "auth file is read-only" {
+		t.Fatalf("expected the applier error in the result, got %#v", result)
 	}
-	if result.ActiveAccountID != "A" {
-		t.Fatalf("active account mismatch: %s", result.ActiveAccountID)
+	if state.state.ActiveAccountID != "codex:new@example.com" || state.saveCalls != 1 {
+		t.Fatalf("expected the forced switch to be saved, active=%s saves=%d", state.state.ActiveAccountID, state.saveCalls)
 	}
-	if _, ok := state.state.Accounts["B"]; ok {
-		t.Fatal("deleted account should be removed from state")
+	if !state.state.Accounts["codex:new@example.com"].LastAppliedAt.IsZero() {
+		t.Fatal("expected last_applied_at to stay unset when apply failed")
 	}
-	if _, ok := secrets.entries["B"]; ok {
-		t.Fatal("deleted account secrets should be removed")
+	history := state.state.SwitchHistory
+	if len(history) != 1 || history[0].Reason != "manual-force" || history[0].FromAccountID != "codex:old@example.com" {
+		t.Fatalf("unexpected switch history: %#v", history)
 	}
-	if applier.calls != 0 || applier.clearCalls != 0 {
-		t.Fatalf("inactive delete should not apply or clear, got apply=%d clear=%d", applier.calls, applier.clearCalls)
+
+	if _, err := mgr.ForceSetActiveAccount(context.Background(), "missing"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
 	}
 }
 
-func TestDeleteActiveAccountSwitchesToReplacement(t *testing.T) {
+func TestSetActiveAccountRejectsExpiredTokenWithoutRefreshToken(t *testing.T) {
 	state := &fakeStateStore{
 		state: model.AppState{
 			Version:         1,
-			ActiveAccountID: "A",
+			ActiveAccountID: "codex:old@example.com",
 			Strategy:        model.RoutingRoundRobin,
 			Accounts: map[string]model.Account{
-				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
-				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+				"codex:old@example.com": {ID: "codex:old@example.com", Provider: "codex", Status: model.AccountReady},
+				"codex:new@example.com": {ID: "codex:new@example.com", Provider: "codex", Status: model.AccountReady},
 			},
 		},
 	}
 	secrets := &fakeSecretStore{
 		entries: map[string]model.AuthSecrets{
-			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
-			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			"codex:old@example.com": {AccessToken: "old"},
+			"codex:new@example.com": {AccessToken: "new", AccessExpiresAt: time.Now().UTC().Add(-time.Minute)},
 		},
 	}
 	applier := &fakeApplier{}
 	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
 
-	result, err := mgr.DeleteAccount(context.Background(), "A")
-	if err != nil {
-		t.Fatalf("delete account: %v", err)
-	}
-	if !result.WasActive || !result.Switched || result.SwitchedToAccount != "B" || result.ActiveAccountID != "B" {
-		t.Fatalf("unexpected delete result: %#v", result)
-	}
-	if state.state.ActiveAccountID != "B" {
-		t.Fatalf("active account mismatch: %s", state.state.ActiveAccountID)
-	}
-	if _, ok := state.state.Accounts["A"]; ok {
-		t.Fatal("deleted active account should be removed from state")
+	err := mgr.SetActiveAccount(context.Background(), "codex:new@example.com")
+	if !errors.Is(err, ErrAccountTokenExpired) {
+		t.Fatalf("expected ErrAccountTokenExpired, got %v", err)
 	}
-	if _, ok := secrets.entries["A"]; ok {
-		t.Fatal("deleted active account secrets should be removed")
+	if applier.calls != 0 {
+		t.Fatalf("expected no apply calls, got %d", applier.calls)
 	}
-	if applier.lastAccountID != "B" || applier.clearCalls != 0 {
-		t.Fatalf("expected replacement apply only, got last=%s clear=%d", applier.lastAccountID, applier.clearCalls)
+	if state.state.ActiveAccountID != "codex:old@example.com" {
+		t.Fatalf("active account should remain old, got %s", state.state.ActiveAccountID)
 	}
 }
 
-func TestDeleteActiveAccountClearsAppliedStateWhenNoReplacement(t *testing.T) {
+func TestSetActiveAccountRefreshesExpiredToken(t *testing.T) {
 	state := &fakeStateStore{
 		state: model.AppState{
-			Version:         1,
-			ActiveAccountID: "A",
-			Strategy:        model.RoutingRoundRobin,
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
 			Accounts: map[string]model.Account{
-				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"codex:new@example.com": {ID: "codex:new@example.com", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"codex:new@example.com": {
+				AccessToken:     "token-old",
+				RefreshToken:    "refresh-new",
+				AccessExpiresAt: time.Now().UTC().Add(-time.Minute),
+			},
+		},
+	}
+	applier := &fakeApplier{}
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return jsonHTTPResponse(http.StatusOK, `{"access_token":"token-new","expires_in":3600}`), nil
+		}),
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier), WithHTTPClient(httpClient))
+
+	if err := mgr.SetActiveAccount(context.Background(), "codex:new@example.com"); err != nil {
+		t.Fatalf("set active: %v", err)
+	}
+	if got := secrets.entries["codex:new@example.com"].AccessToken; got != "token-new" {
+		t.Fatalf("expected refreshed token persisted, got %q", got)
+	}
+	if state.state.ActiveAccountID != "codex:new@example.com" {
+		t.Fatalf("active account mismatch: %s", state.state.ActiveAccountID)
+	}
+	if state.state.Accounts["codex:new@example.com"].LastRefreshAt.IsZero() {
+		t.Fatal("expected last_refresh_at to be updated")
+	}
+}
+
+func TestSetAccountStatus(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name       string
+		current    model.AccountStatus
+		secrets    model.AuthSecrets
+		target     model.AccountStatus
+		wantErr    error
+		wantStatus model.AccountStatus
+	}{
+		{
+			name:       "disable ready account",
+			current:    model.AccountReady,
+			secrets:    model.AuthSecrets{AccessToken: "token", AccessExpiresAt: now.Add(time.Hour)},
+			target:     model.AccountDisabled,
+			wantStatus: model.AccountDisabled,
+		},
+		{
+			name:       "enable disabled account with valid token",
+			current:    model.AccountDisabled,
+			secrets:    model.AuthSecrets{AccessToken: "token", AccessExpiresAt: now.Add(time.Hour)},
+			target:     model.AccountReady,
+			wantStatus: model.AccountReady,
+		},
+		{
+			name:       "enable disabled account with expired token",
+			current:    model.AccountDisabled,
+			secrets:    model.AuthSecrets{AccessToken: "token", AccessExpiresAt: now.Add(-time.Hour)},
+			target:     model.AccountReady,
+			wantErr:    ErrAccountTokenExpired,
+			wantStatus: model.AccountDisabled,
+		},
+		{
+			name:       "unknown account",
+			target:     model.AccountDisabled,
+			wantErr:    ErrAccountNotFound,
+			wantStatus: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state := &fakeStateStore{state: model.DefaultState()}
+			secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{}}
+			if tt.current != "" {
+				state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: tt.current}
+				secrets.entries["A"] = tt.secrets
+			}
+			mgr := NewManager(state, secrets)
+
+			_, err := mgr.SetAccountStatus(context.Background(), "A", tt.target)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("expected %v, got %v", tt.wantErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := state.state.Accounts["A"].Status; got != tt.wantStatus {
+				t.Fatalf("status mismatch: got %q want %q", got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestDeleteAccountRemovesInactiveAccount(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a"},
+			"B": {AccessToken: "token-b"},
+		},
+	}
+	applier := &fakeApplier{}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
+
+	result, err := mgr.DeleteAccount(context.Background(), "B")
+	if err != nil {
+		t.Fatalf("delete account: %v", err)
+	}
+	if result.WasActive || result.Switched {
+		t.Fatalf("unexpected delete result: %#v", result)
+	}
+	if result.ActiveAccountID != "A" {
+		t.Fatalf("active account mismatch: %s", result.ActiveAccountID)
+	}
+	if _, ok := state.state.Accounts["B"]; ok {
+		t.Fatal("deleted account should be removed from state")
+	}
+	if _, ok := secrets.entries["B"]; ok {
+		t.Fatal("deleted account secrets should be removed")
+	}
+	if applier.calls != 0 || applier.clearCalls != 0 {
+		t.Fatalf("inactive delete should not apply or clear, got apply=%d clear=%d", applier.calls, applier.clearCalls)
+	}
+}
+
+func TestDeleteActiveAccountSwitchesToReplacement(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	applier := &fakeApplier{}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
+
+	result, err := mgr.DeleteAccount(context.Background(), "A")
+	if err != nil {
+		t.Fatalf("delete account: %v", err)
+	}
+	if !result.WasActive || !result.Switched || result.SwitchedToAccount != "B" || result.ActiveAccountID != "B" {
+		t.Fatalf("unexpected delete result: %#v", result)
+	}
+	if state.state.ActiveAccountID != "B" {
+		t.Fatalf("active account mismatch: %s", state.state.ActiveAccountID)
+	}
+	if _, ok := state.state.Accounts["A"]; ok {
+		t.Fatal("deleted active account should be removed from state")
+	}
+	if _, ok := secrets.entries["A"]; ok {
+		t.Fatal("deleted active account secrets should be removed")
+	}
+	if applier.lastAccountID != "B" || applier.clearCalls != 0 {
+		t.Fatalf("expected replacement apply only, got last=%s clear=%d", applier.lastAccountID, applier.clearCalls)
+	}
+}
+
+func TestDeleteActiveAccountClearsAppliedStateWhenNoReplacement(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
 			},
 		},
 	}
@@ -519,8 +1161,8 @@ func TestDeleteAccountRollsBackOnSecretsDeleteFailure(t *testing.T) {
 	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
 
 	errResult, err := mgr.DeleteAccount(context.Background(), "A")
-	if err == nil {
-		t.Fatal("expected error, got nil")
+	if !errors.Is(err, ErrPersistSecrets) {
+		t.Fatalf("expected ErrPersistSecrets, got %v", err)
 	}
 	if errResult != (DeleteAccountResult{}) {
 		t.Fatalf("expected zero result on failure, got %#v", errResult)
@@ -536,6 +1178,40 @@ func TestDeleteAccountRollsBackOnSecretsDeleteFailure(t *testing.T) {
 	}
 }
 
+func TestDeleteAccountKeepsSecretsOnStateSaveFailure(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+		saveErr: errors.New("disk full"),
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	applier := &fakeApplier{}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
+
+	if _, err := mgr.DeleteAccount(context.Background(), "A"); !errors.Is(err, ErrPersistState) {
+		t.Fatalf("expected ErrPersistState, got %v", err)
+	}
+	if _, ok := secrets.entries["A"]; !ok {
+		t.Fatal("secrets should be kept when the state cannot be saved")
+	}
+	if _, ok := state.state.Accounts["A"]; !ok {
+		t.Fatal("account should still be in the saved state")
+	}
+	if applier.lastAccountID != "A" {
+		t.Fatalf("expected the deleted account to be re-applied, got %q", applier.lastAccountID)
+	}
+}
+
 func TestHandleQuotaErrorAppliesSwitchedAccount(t *testing.T) {
 	state := &fakeStateStore{
 		state: model.AppState{
@@ -572,6 +1248,104 @@ func TestHandleQuotaErrorAppliesSwitchedAccount(t *testing.T) {
 	}
 }
 
+func TestHandleQuotaErrorCountsSwitchesAndRefreshErrors(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingPriority,
+			Priorities:      []string{"C", "B", "A"},
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+				"C": {ID: "C", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			// C's token has expired and cannot be refreshed, so every attempt
+			// to switch to it fails before falling through to the next account.
+			"C": {AccessToken: "token-c", AccessExpiresAt: time.Now().UTC().Add(-time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}), WithSwitchCooldown(0))
+
+	for i, want := range []string{"B", "A", "B"} {
+		decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+		if err != nil {
+			t.Fatalf("switch %d: %v", i, err)
+		}
+		if !decision.Switched || decision.ToAccountID != want {
+			t.Fatalf("switch %d: expected switch to %s, got %#v", i, want, decision)
+		}
+	}
+
+	accounts := state.state.Accounts
+	if accounts["A"].SwitchCount != 2 || accounts["B"].SwitchCount != 1 || accounts["C"].SwitchCount != 0 {
+		t.Fatalf("unexpected switch counts: A=%d B=%d C=%d", accounts["A"].SwitchCount, accounts["B"].SwitchCount, accounts["C"].SwitchCount)
+	}
+	if accounts["C"].ErrorCount != 3 || accounts["C"].Status != model.AccountNeedReauth {
+		t.Fatalf("expected C to record 3 refresh errors, got %d (%s)", accounts["C"].ErrorCount, accounts["C"].Status)
+	}
+	if accounts["A"].ErrorCount != 0 || accounts["B"].ErrorCount != 0 {
+		t.Fatalf("expected no errors on healthy accounts, got A=%d B=%d", accounts["A"].ErrorCount, accounts["B"].ErrorCount)
+	}
+}
+
+func TestHandleQuotaErrorRecordsAndClearsGlobalError(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountDisabled},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}), WithSwitchCooldown(0))
+
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil {
+		t.Fatalf("handle quota: %v", err)
+	}
+	if decision.Switched || decision.Reason != "no-available-account" {
+		t.Fatalf("unexpected decision: %#v", decision)
+	}
+	status, err := mgr.Status(context.Background())
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.LastError == "" || status.LastErrorAt.IsZero() {
+		t.Fatalf("expected global error to be recorded, got %#v", status)
+	}
+
+	b := state.state.Accounts["B"]
+	b.Status = model.AccountReady
+	state.state.Accounts["B"] = b
+
+	decision, err = mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil {
+		t.Fatalf("handle quota: %v", err)
+	}
+	if !decision.Switched {
+		t.Fatalf("expected switch, got %#v", decision)
+	}
+	if state.state.LastGlobalError != "" || !state.state.LastGlobalErrorAt.IsZero() {
+		t.Fatalf("expected global error to be cleared, got %q at %s", state.state.LastGlobalError, state.state.LastGlobalErrorAt)
+	}
+}
+
 func TestSyncQuotaFromCodexAPIFetchesViaAPI(t *testing.T) {
 	state := &fakeStateStore{
 		state: model.AppState{
@@ -623,6 +1397,38 @@ func TestSyncQuotaFromCodexAPIFetchesViaAPI(t *testing.T) {
 	if result.Quota.Session.UsedPercent != 21 || result.Quota.Weekly.UsedPercent != 33 {
 		t.Fatalf("unexpected quota result: %#v", result.Quota)
 	}
+	history := state.state.Accounts["A"].QuotaHistory
+	if len(history) != 1 || history[0].Session.UsedPercent != 21 {
+		t.Fatalf("expected synced quota recorded in history, got %#v", history)
+	}
+}
+
+func TestSyncQuotaFromCodexAPIMarksSessionUnsupported(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["free"] = model.Account{
+		ID:       "free",
+		Provider: "codex",
+		Status:   model.AccountReady,
+		Quota:    model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 30}},
+	}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{
+		"free": {AccessToken: "token-free", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+	}}
+	mgr := NewManager(state, secrets, WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+		return quota.Snapshot{SessionUnsupported: true, Weekly: &quota.Window{UsedPercent: 15}}, nil
+	}))
+
+	result, err := mgr.SyncQuotaFromCodexAPI(context.Background(), "free")
+	if err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	got := state.state.Accounts["free"].Quota
+	if got.SessionSupported == nil || *got.SessionSupported || got.Session.UsedPercent != 0 || got.Weekly.UsedPercent != 15 {
+		t.Fatalf("expected an unsupported session window, got %#v", got)
+	}
+	if result.Quota.SessionSupported == nil || *result.Quota.SessionSupported {
+		t.Fatalf("expected the sync result to report the session as unsupported, got %#v", result.Quota)
+	}
 }
 
 func TestSyncQuotaFromCodexAPIReturnsFetcherError(t *testing.T) {
@@ -775,18 +1581,7 @@ func TestSyncQuotaFromCodexAPIRefreshesWhenTokenIsWithinLeadWindow(t *testing.T)
 	}
 
 	var gotAccessToken string
-	mgr := NewManager(
-		state,
-		secrets,
-		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
-			gotAccessToken = accessToken
-			return quota.Snapshot{
-				Session: &quota.Window{UsedPercent: 21},
-				Weekly:  &quota.Window{UsedPercent: 33},
-			}, nil
-		}),
-	)
-	mgr.httpClient = &http.Client{
+	httpClient := &http.Client{
 		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
 			if r.Method != http.MethodPost || r.URL.String() != "https://auth.openai.com/oauth/token" {
 				t.Fatalf("unexpected refresh request: %s %s", r.Method, r.URL.String())
@@ -794,6 +1589,18 @@ func TestSyncQuotaFromCodexAPIRefreshesWhenTokenIsWithinLeadWindow(t *testing.T)
 			return jsonHTTPResponse(http.StatusOK, `{"access_token":"token-new","expires_in":3600}`), nil
 		}),
 	}
+	mgr := NewManager(
+		state,
+		secrets,
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			gotAccessToken = accessToken
+			return quota.Snapshot{
+				Session: &quota.Window{UsedPercent: 21},
+				Weekly:  &quota.Window{UsedPercent: 33},
+			}, nil
+		}),
+		WithHTTPClient(httpClient),
+	)
 
 	if _, err := mgr.SyncQuotaFromCodexAPI(context.Background(), ""); err != nil {
 		t.Fatalf("expected sync success, got err: %v", err)
@@ -833,6 +1640,12 @@ func TestSyncQuotaFromCodexAPIDoesNotRefreshWhenTokenOutsideLeadWindow(t *testin
 	}
 
 	var gotAccessToken string
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			t.Fatalf("refresh endpoint should not be called: %s %s", r.Method, r.URL.String())
+			return nil, nil
+		}),
+	}
 	mgr := NewManager(
 		state,
 		secrets,
@@ -843,13 +1656,8 @@ func TestSyncQuotaFromCodexAPIDoesNotRefreshWhenTokenOutsideLeadWindow(t *testin
 				Weekly:  &quota.Window{UsedPercent: 33},
 			}, nil
 		}),
+		WithHTTPClient(httpClient),
 	)
-	mgr.httpClient = &http.Client{
-		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
-			t.Fatalf("refresh endpoint should not be called: %s %s", r.Method, r.URL.String())
-			return nil, nil
-		}),
-	}
 
 	if _, err := mgr.SyncQuotaFromCodexAPI(context.Background(), ""); err != nil {
 		t.Fatalf("expected sync success, got err: %v", err)
@@ -895,7 +1703,7 @@ func TestSyncAllQuotasFromCodexAPIContinuesOnPerAccountFailures(t *testing.T) {
 		}),
 	)
 
-	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background())
+	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("sync all err: %v", err)
 	}
@@ -931,12 +1739,134 @@ func TestSyncAllQuotasFromCodexAPIContinuesOnPerAccountFailures(t *testing.T) {
 	}
 }
 
+func TestSyncAllQuotasFromCodexAPIRunsConcurrently(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{state: model.DefaultState()}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{}}
+	for i := 0; i < 6; i++ {
+		id := fmt.Sprintf("acc-%d", i)
+		state.state.Accounts[id] = model.Account{ID: id, Provider: "codex", Status: model.AccountReady}
+		secrets.entries[id] = model.AuthSecrets{AccessToken: "token", AccountID: id, AccessExpiresAt: now.Add(2 * time.Hour)}
+	}
+
+	var inFlight, peak atomic.Int32
+	mgr := NewManager(state, secrets,
+		WithSyncConcurrency(3),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				p := peak.Load()
+				if n <= p || peak.CompareAndSwap(p, n) {
+					break
+				}
+			}
+			time.Sleep(50 * time.Millisecond)
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 10}, Weekly: &quota.Window{UsedPercent: 20}}, nil
+		}),
+	)
+
+	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("sync all: %v", err)
+	}
+	if out.Total != 6 || out.Succeeded != 6 || out.Failed != 0 {
+		t.Fatalf("unexpected totals: %+v", out)
+	}
+	for i, item := range out.Results {
+		if want := fmt.Sprintf("acc-%d", i); item.AccountID != want || !item.Success {
+			t.Fatalf("unexpected result %d: %+v", i, item)
+		}
+	}
+	if got := peak.Load(); got != 3 {
+		t.Fatalf("expected 3 concurrent fetches, peak was %d", got)
+	}
+	if out.ElapsedMS >= 200 {
+		t.Fatalf("expected two rounds of 50ms fetches, took %dms", out.ElapsedMS)
+	}
+	for id, acct := range state.state.Accounts {
+		if acct.Quota.Weekly.UsedPercent != 20 {
+			t.Fatalf("expected quota stored for %s, got %+v", id, acct.Quota)
+		}
+	}
+}
+
+func TestSyncAllQuotasFromCodexAPITimesOutSlowAccounts(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["slow"] = model.Account{ID: "slow", Provider: "codex", Status: model.AccountReady}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{
+		"slow": {AccessToken: "token", AccountID: "slow", AccessExpiresAt: now.Add(2 * time.Hour)},
+	}}
+	mgr := NewManager(state, secrets,
+		WithSyncAccountTimeout(50*time.Millisecond),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			select {
+			case <-time.After(200 * time.Millisecond):
+				return quota.Snapshot{Session: &quota.Window{UsedPercent: 10}}, nil
+			case <-ctx.Done():
+				return quota.Snapshot{}, ctx.Err()
+			}
+		}),
+	)
+
+	started := time.Now()
+	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("sync all: %v", err)
+	}
+	if elapsed := time.Since(started); elapsed >= 300*time.Millisecond {
+		t.Fatalf("expected the slow account to be cut off, took %s", elapsed)
+	}
+	if out.Failed != 1 || out.Results[0].Success || out.Results[0].Error != "timeout after 50ms" {
+		t.Fatalf("expected a timeout failure, got %+v", out)
+	}
+}
+
+func TestSyncAllQuotasFromCodexAPIFiltersByProvider(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"C": {ID: "C", Provider: "other", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccountID: "acct-a", AccessExpiresAt: now.Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(
+		state,
+		secrets,
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 12}}, nil
+		}),
+	)
+
+	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background(), []string{"Codex"})
+	if err != nil {
+		t.Fatalf("sync all err: %v", err)
+	}
+	if out.Total != 1 || out.Succeeded != 1 || out.Failed != 0 {
+		t.Fatalf("unexpected counters: %#v", out)
+	}
+	if out.Results[0].AccountID != "A" {
+		t.Fatalf("expected only codex account to sync, got %#v", out.Results)
+	}
+}
+
 func TestSyncAllQuotasFromCodexAPIWithNoAccounts(t *testing.T) {
 	state := &fakeStateStore{state: model.DefaultState()}
 	secrets := &fakeSecretStore{}
 	mgr := NewManager(state, secrets)
 
-	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background())
+	out, err := mgr.SyncAllQuotasFromCodexAPI(context.Background(), nil)
 	if err != nil {
 		t.Fatalf("sync all should not fail on empty state: %v", err)
 	}
@@ -945,6 +1875,33 @@ func TestSyncAllQuotasFromCodexAPIWithNoAccounts(t *testing.T) {
 	}
 }
 
+func TestOrphanedSecretsReturnsSecretsMissingFromState(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version: 1,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a"},
+			"Z": {AccessToken: "token-z"},
+			"B": {AccessToken: "token-b"},
+		},
+	}
+	mgr := NewManager(state, secrets)
+
+	got, err := mgr.OrphanedSecrets(context.Background())
+	if err != nil {
+		t.Fatalf("orphaned secrets: %v", err)
+	}
+	if len(got) != 2 || got[0] != "B" || got[1] != "Z" {
+		t.Fatalf("unexpected orphaned ids: %v", got)
+	}
+}
+
 func TestCodexSecretsNeedImport(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -1112,6 +2069,14 @@ func (s *fakeSecretStore) Delete(accountID string) error {
 	return nil
 }
 
+func (s *fakeSecretStore) List() ([]string, error) {
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func cloneState(in model.AppState) model.AppState {
 	out := in
 	out.Accounts = map[string]model.Account{}
@@ -1134,3 +2099,1078 @@ func jsonHTTPResponse(status int, body string) *http.Response {
 		Body:       io.NopCloser(strings.NewReader(body)),
 	}
 }
+
+func TestManagerEmitsEventsForAccountLifecycle(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{}}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}))
+	events, unsubscribe := mgr.Subscribe()
+	defer unsubscribe()
+
+	for _, id := range []string{"A", "B"} {
+		if _, err := mgr.AddAccount(context.Background(), AddAccountInput{ID: id, Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "token-" + id}}); err != nil {
+			t.Fatalf("add %s: %v", id, err)
+		}
+	}
+	if err := mgr.SetActiveAccount(context.Background(), "B"); err != nil {
+		t.Fatalf("set active: %v", err)
+	}
+
+	want := []Event{
+		{Type: EventAccountAdded, AccountID: "A"},
+		{Type: EventAccountAdded, AccountID: "B"},
+		{Type: EventAccountSwitched, AccountID: "B", FromAccountID: "A"},
+	}
+	for _, w := range want {
+		select {
+		case got := <-events:
+			if got.Type != w.Type || got.AccountID != w.AccountID || got.FromAccountID != w.FromAccountID {
+				t.Fatalf("unexpected event: got %#v want %#v", got, w)
+			}
+		default:
+			t.Fatalf("missing event %#v", w)
+		}
+	}
+}
+
+func TestSubscribeFansOutEvents(t *testing.T) {
+	mgr := NewManager(&fakeStateStore{state: model.DefaultState()}, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+	first, unsubscribeFirst := mgr.Subscribe()
+	second, unsubscribeSecond := mgr.Subscribe()
+	defer unsubscribeSecond()
+
+	if _, err := mgr.AddAccount(context.Background(), AddAccountInput{ID: "A", Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "token-a"}}); err != nil {
+		t.Fatalf("add: %v", err)
+	}
+	for i, ch := range []<-chan Event{first, second} {
+		select {
+		case evt := <-ch:
+			if evt.Type != EventAccountAdded || evt.AccountID != "A" {
+				t.Fatalf("subscriber %d: unexpected event %#v", i, evt)
+			}
+		default:
+			t.Fatalf("subscriber %d missed the event", i)
+		}
+	}
+
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Fatal("expected unsubscribe to close the channel")
+	}
+	if _, err := mgr.DeleteAccount(context.Background(), "A"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if evt := <-second; evt.Type != EventAccountDeleted {
+		t.Fatalf("expected the remaining subscriber to get %s, got %#v", EventAccountDeleted, evt)
+	}
+}
+
+func TestHandleQuotaErrorRecordsBoundedSwitchHistory(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}), WithSwitchHistoryLimit(2))
+
+	for i := 0; i < 3; i++ {
+		if _, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded"); err != nil {
+			t.Fatalf("handle quota %d: %v", i, err)
+		}
+	}
+
+	if got := len(state.state.SwitchHistory); got != 2 {
+		t.Fatalf("expected history trimmed to 2, got %d", got)
+	}
+	history, err := mgr.SwitchHistory(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("switch history: %v", err)
+	}
+	if len(history) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(history))
+	}
+	latest := history[0]
+	if latest.FromAccountID != "A" || latest.ToAccountID != "B" || latest.Reason != "quota-exceeded" || latest.StatusCode != 429 || latest.ErrorMessage != "quota exceeded" {
+		t.Fatalf("unexpected latest event: %#v", latest)
+	}
+}
+
+func TestOrderedCandidatesPrefersLeastRecentlyApplied(t *testing.T) {
+	now := time.Now().UTC()
+	equalQuota := model.QuotaSnapshot{
+		Session: model.QuotaWindow{UsedPercent: 40},
+		Weekly:  model.QuotaWindow{UsedPercent: 10},
+	}
+	accounts := map[string]model.Account{
+		"A": {ID: "A", Status: model.AccountReady, Quota: equalQuota},
+		"B": {ID: "B", Status: model.AccountReady, Quota: equalQuota, LastAppliedAt: now.Add(-1 * time.Hour)},
+		"C": {ID: "C", Status: model.AccountReady, Quota: equalQuota, LastAppliedAt: now.Add(-3 * time.Hour)},
+		"D": {ID: "D", Status: model.AccountReady, Quota: equalQuota, LastAppliedAt: now.Add(-2 * time.Hour)},
+	}
+
+	for _, strategy := range []model.RoutingStrategy{model.RoutingFillFirst, model.RoutingRoundRobin} {
+		t.Run(string(strategy), func(t *testing.T) {
+			state := model.AppState{Strategy: strategy, ActiveAccountID: "A", Accounts: accounts}
+			got := strings.Join(orderedCandidates(state, "A"), ",")
+			if got != "C,D,B" {
+				t.Fatalf("expected least-recently-applied order C,D,B, got %s", got)
+			}
+		})
+	}
+}
+
+func TestHandleQuotaErrorRoundRobinRotatesByLastApplied(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady, LastAppliedAt: now},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady, LastAppliedAt: now.Add(-1 * time.Hour)},
+				"C": {ID: "C", Provider: "codex", Status: model.AccountReady, LastAppliedAt: now.Add(-2 * time.Hour)},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: now.Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccessExpiresAt: now.Add(2 * time.Hour)},
+			"C": {AccessToken: "token-c", AccessExpiresAt: now.Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}))
+
+	var order []string
+	for i := 0; i < 3; i++ {
+		decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+		if err != nil {
+			t.Fatalf("handle quota %d: %v", i, err)
+		}
+		order = append(order, decision.ToAccountID)
+	}
+	if got := strings.Join(order, ","); got != "C,B,A" {
+		t.Fatalf("expected fair rotation C,B,A, got %s", got)
+	}
+}
+
+func TestRefreshTokenIgnoresLeadWindow(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-old", RefreshToken: "refresh-a", AccessExpiresAt: now.Add(6 * time.Hour)},
+		},
+	}
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return jsonHTTPResponse(http.StatusOK, `{"access_token":"token-new","expires_in":3600}`), nil
+		}),
+	}
+	mgr := NewManager(state, secrets, WithHTTPClient(httpClient))
+
+	result, err := mgr.RefreshToken(context.Background(), "A")
+	if err != nil {
+		t.Fatalf("RefreshToken error: %v", err)
+	}
+	if got := secrets.entries["A"].AccessToken; got != "token-new" {
+		t.Fatalf("expected refreshed token persisted, got %q", got)
+	}
+	if !result.AccessExpiresAt.Equal(secrets.entries["A"].AccessExpiresAt) || !result.AccessExpiresAt.Before(now.Add(2*time.Hour)) {
+		t.Fatalf("unexpected access_expires_at: %v", result.AccessExpiresAt)
+	}
+	if state.state.Accounts["A"].LastRefreshAt.IsZero() {
+		t.Fatal("expected last_refresh_at to be updated")
+	}
+}
+
+func TestRefreshTokenRequiresReauthWithoutRefreshToken(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{"A": {AccessToken: "token-a"}}}
+	mgr := NewManager(state, secrets)
+
+	_, err := mgr.RefreshToken(context.Background(), "A")
+	if !errors.Is(err, ErrReauthRequired) {
+		t.Fatalf("expected ErrReauthRequired, got %v", err)
+	}
+	if got := state.state.Accounts["A"].Status; got != model.AccountNeedReauth {
+		t.Fatalf("expected need_reauth status, got %s", got)
+	}
+
+	if _, err := mgr.RefreshToken(context.Background(), "missing"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+func TestRotateAccountRefreshesAndApplies(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-old", RefreshToken: "refresh-a", AccessExpiresAt: time.Now().UTC().Add(6 * time.Hour)},
+		},
+	}
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return jsonHTTPResponse(http.StatusOK, `{"access_token":"token-new","expires_in":3600}`), nil
+		}),
+	}
+	applier := &fakeApplier{}
+	mgr := NewManager(state, secrets, WithHTTPClient(httpClient), WithActiveAccountApplier(applier))
+
+	result, err := mgr.RotateAccount(context.Background(), "A")
+	if err != nil {
+		t.Fatalf("RotateAccount error: %v", err)
+	}
+	acct := state.state.Accounts["A"]
+	if acct.LastRefreshAt.IsZero() || acct.LastAppliedAt.IsZero() {
+		t.Fatalf("expected last_refresh_at and last_applied_at to be set, got %+v", acct)
+	}
+	if applier.calls != 1 || applier.lastAccountID != "A" {
+		t.Fatalf("expected one apply of A, got %d calls (last %q)", applier.calls, applier.lastAccountID)
+	}
+	if got := secrets.entries["A"].AccessToken; got != "token-new" {
+		t.Fatalf("expected refreshed token persisted, got %q", got)
+	}
+	if !result.AccessExpiresAt.Equal(secrets.entries["A"].AccessExpiresAt) {
+		t.Fatalf("unexpected access_expires_at: %v", result.AccessExpiresAt)
+	}
+}
+
+func TestRotateAccountKeepsActiveWhenApplyFails(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a"},
+			"B": {AccessToken: "token-b-old", RefreshToken: "refresh-b"},
+		},
+	}
+	httpClient := &http.Client{
+		Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			return jsonHTTPResponse(http.StatusOK, `{"access_token":"token-b-new","expires_in":3600}`), nil
+		}),
+	}
+	applier := &fakeApplier{applyErr: errors.New("auth file is read-only")}
+	mgr := NewManager(state, secrets, WithHTTPClient(httpClient), WithActiveAccountApplier(applier))
+
+	result, err := mgr.RotateAccount(context.Background(), "B")
+	if err == nil {
+		t.Fatal("expected apply error, got nil")
+	}
+	if state.state.ActiveAccountID != "A" {
+		t.Fatalf("active account should remain A, got %s", state.state.ActiveAccountID)
+	}
+	if state.state.Accounts["B"].LastRefreshAt.IsZero() || result.AccessExpiresAt.IsZero() {
+		t.Fatalf("expected the refresh to be kept, got %+v", result)
+	}
+	if got := secrets.entries["B"].AccessToken; got != "token-b-new" {
+		t.Fatalf("expected refreshed token persisted, got %q", got)
+	}
+}
+
+func TestUpdateQuotaCapsHistoryWithNewestLast(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+
+	total := quotaHistoryLimit + 12
+	for i := 0; i < total; i++ {
+		if err := mgr.UpdateQuota(context.Background(), "A", model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: i % 101}, Weekly: model.QuotaWindow{UsedPercent: i}}); err != nil {
+			t.Fatalf("UpdateQuota #%d: %v", i, err)
+		}
+	}
+
+	history := state.state.Accounts["A"].QuotaHistory
+	if len(history) != quotaHistoryLimit {
+		t.Fatalf("expected %d history entries, got %d", quotaHistoryLimit, len(history))
+	}
+	if got := history[len(history)-1].Weekly.UsedPercent; got != total-1 {
+		t.Fatalf("expected newest entry last, got weekly %d", got)
+	}
+	if got := history[0].Weekly.UsedPercent; got != total-quotaHistoryLimit {
+		t.Fatalf("expected oldest entries trimmed, first weekly %d", got)
+	}
+
+	recent, err := mgr.QuotaHistory(context.Background(), "A", 5)
+	if err != nil {
+		t.Fatalf("QuotaHistory: %v", err)
+	}
+	if len(recent) != 5 || recent[4].Weekly.UsedPercent != total-1 {
+		t.Fatalf("unexpected limited history: %+v", recent)
+	}
+	if _, err := mgr.QuotaHistory(context.Background(), "missing", 0); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+}
+
+type countingStateStore struct {
+	fakeStateStore
+	loads int
+}
+
+func (s *countingStateStore) Load() (model.AppState, error) {
+	s.loads++
+	return s.fakeStateStore.Load()
+}
+
+func TestHandleQuotaErrorCooldownAfterExhaustingCandidates(t *testing.T) {
+	state := &countingStateStore{fakeStateStore: fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountDisabled},
+			},
+		},
+	}}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}), WithSwitchCooldown(50*time.Millisecond))
+
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || decision.Reason != "no-available-account" {
+		t.Fatalf("unexpected first decision: %#v err=%v", decision, err)
+	}
+	if state.state.CooldownUntil.IsZero() {
+		t.Fatal("expected cooldown to be persisted")
+	}
+	status, err := mgr.Status(context.Background())
+	if err != nil || !status.CooldownActive {
+		t.Fatalf("expected status to report active cooldown, got %#v err=%v", status, err)
+	}
+
+	b := state.state.Accounts["B"]
+	b.Status = model.AccountReady
+	state.state.Accounts["B"] = b
+
+	loads := state.loads
+	decision, err = mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || decision.Switched || decision.Reason != "cooldown" {
+		t.Fatalf("expected cooldown decision, got %#v err=%v", decision, err)
+	}
+	if state.loads != loads {
+		t.Fatalf("expected cooldown to short-circuit without loading state, got %d loads", state.loads-loads)
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	decision, err = mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || !decision.Switched || decision.ToAccountID != "B" {
+		t.Fatalf("expected switch after cooldown expired, got %#v err=%v", decision, err)
+	}
+	if !state.state.CooldownUntil.IsZero() {
+		t.Fatalf("expected cooldown to be cleared after a switch, got %s", state.state.CooldownUntil)
+	}
+}
+
+func TestResetCooldown(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.CooldownUntil = time.Now().Add(time.Hour)
+	state.state.ActiveAccountID = "A"
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || decision.Reason != "cooldown" {
+		t.Fatalf("expected persisted cooldown to apply, got %#v err=%v", decision, err)
+	}
+	if err := mgr.ResetCooldown(context.Background()); err != nil {
+		t.Fatalf("ResetCooldown: %v", err)
+	}
+	if !state.state.CooldownUntil.IsZero() {
+		t.Fatal("expected cooldown to be cleared")
+	}
+	decision, err = mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || decision.Reason != "no-available-account" {
+		t.Fatalf("expected a fresh search after reset, got %#v err=%v", decision, err)
+	}
+}
+
+const codexRateLimitLine = `{"timestamp":"2026-10-02T09:10:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":20,"window_minutes":300,"resets_at":1790000000},"secondary":{"used_percent":41,"window_minutes":10080,"resets_at":1790500000}}}}`
+
+func writeCodexSessionLog(t *testing.T, line string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rollout.jsonl"), []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write session log: %v", err)
+	}
+	return dir
+}
+
+func TestSyncQuotaFromCodexAPILogsAccountMismatch(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{
+		"A": {AccessToken: "token-a", AccountID: "acct-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+	}}
+	mgr := NewManager(state, secrets,
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 5}, AccountIDMismatch: true, ReturnedAccountID: "acct-b"}, nil
+		}),
+	)
+
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+	if _, err := mgr.SyncQuotaFromCodexAPI(context.Background(), "A"); err != nil {
+		t.Fatalf("sync: %v", err)
+	}
+	if !strings.Contains(logs.String(), `answered for account "acct-b" instead of "acct-a"`) {
+		t.Fatalf("expected the mismatch to be logged, got %q", logs.String())
+	}
+}
+
+func TestSyncStaleQuotasSkipsUnsyncableAccounts(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["stale"] = model.Account{ID: "stale", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["fresh"] = model.Account{ID: "fresh", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{LastUpdated: now}}
+	state.state.Accounts["disabled"] = model.Account{ID: "disabled", Provider: "codex", Status: model.AccountDisabled}
+	state.state.Accounts["copilot"] = model.Account{ID: "copilot", Provider: "copilot", Status: model.AccountReady}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{
+		"stale": {AccessToken: "token", AccessExpiresAt: now.Add(2 * time.Hour)},
+	}}
+	mgr := NewManager(state, secrets,
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 5}}, nil
+		}),
+	)
+
+	result, err := mgr.SyncStaleQuotasFromCodexAPI(context.Background(), now.Add(-time.Minute))
+	if err != nil {
+		t.Fatalf("sync stale: %v", err)
+	}
+	if len(result.Results) != 1 || result.Results[0].AccountID != "stale" || !result.Results[0].Success {
+		t.Fatalf("expected only the stale codex account to be synced, got %#v", result.Results)
+	}
+}
+
+func TestSyncQuotaFromCodexAPIFallsBackToLocalLogs(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets,
+		WithCodexSessionsDir(writeCodexSessionLog(t, codexRateLimitLine)),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{}, errors.New("quota usage request failed: status 401")
+		}),
+	)
+
+	result, err := mgr.SyncQuotaFromCodexAPI(context.Background(), "")
+	if err != nil {
+		t.Fatalf("expected local log fallback, got %v", err)
+	}
+	wantAt := time.Date(2026, 10, 2, 9, 10, 0, 0, time.UTC)
+	if !result.SourceTimestamp.Equal(wantAt) {
+		t.Fatalf("unexpected source timestamp: %s", result.SourceTimestamp)
+	}
+	got := state.state.Accounts["A"]
+	if got.Quota.Session.UsedPercent != 20 || got.Quota.Weekly.UsedPercent != 41 {
+		t.Fatalf("unexpected quota: %#v", got.Quota)
+	}
+	if !got.Quota.LastUpdated.Equal(wantAt) {
+		t.Fatalf("expected LastUpdated to be the log timestamp, got %s", got.Quota.LastUpdated)
+	}
+	if got.Status != model.AccountNeedReauth {
+		t.Fatalf("expected the failed fetch to still mark need_reauth, got %s", got.Status)
+	}
+	if len(got.QuotaHistory) != 1 {
+		t.Fatalf("expected one history entry, got %d", len(got.QuotaHistory))
+	}
+}
+
+func TestSyncQuotaWithWarmupReadsTheWarmupThread(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	dir := t.TempDir()
+	// Another session logged later must not be mistaken for the warmup.
+	for name, line := range map[string]string{
+		"rollout-2026-10-02T09-00-00-thread-123.jsonl": codexRateLimitLine,
+		"rollout-2026-10-02T09-30-00-thread-456.jsonl": `{"timestamp":"2026-10-02T09:40:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":99,"window_minutes":300,"resets_at":1790000000}}}}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(line+"\n"), 0o600); err != nil {
+			t.Fatalf("write session log: %v", err)
+		}
+	}
+	execCalls := 0
+	mgr := NewManager(state, secrets,
+		WithCodexSessionsDir(dir),
+		WithCodexExecFunc(func(ctx context.Context, args ...string) ([]byte, error) {
+			execCalls++
+			return []byte(`{"type":"thread.started","thread_id":"thread-123"}` + "\n"), nil
+		}),
+		// The usage API reports a newer weekly window and no session window.
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{
+				Weekly:          &quota.Window{UsedPercent: 60},
+				SourceTimestamp: time.Date(2026, 10, 2, 9, 20, 0, 0, time.UTC),
+			}, nil
+		}),
+	)
+
+	result, err := mgr.SyncQuotaWithWarmup(context.Background(), "")
+	if err != nil {
+		t.Fatalf("SyncQuotaWithWarmup: %v", err)
+	}
+	if execCalls != 1 {
+		t.Fatalf("expected one warmup, got %d", execCalls)
+	}
+	if result.AccountID != "A" || result.Quota.Session.UsedPercent != 20 {
+		t.Fatalf("expected the session window from thread-123's log, got %#v", result)
+	}
+	if result.Quota.Weekly.UsedPercent != 60 {
+		t.Fatalf("expected the newer weekly window from the usage API, got %#v", result.Quota.Weekly)
+	}
+	if got := state.state.Accounts["A"].Quota; got.Session.UsedPercent != 20 || got.Weekly.UsedPercent != 60 {
+		t.Fatalf("expected the merged quota to be saved, got %#v", got)
+	}
+
+	if _, err := mgr.SyncQuotaWithWarmup(context.Background(), "B"); err == nil || !strings.Contains(err.Error(), "active account") {
+		t.Fatalf("expected a non-active account to be rejected, got %v", err)
+	}
+	if execCalls != 1 {
+		t.Fatalf("expected no warmup for a rejected account, got %d calls", execCalls)
+	}
+}
+
+func TestRunCodexWarmupReportsTheWarmupThread(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "rollout-2026-10-02T09-00-00-thread-123.jsonl"), []byte(codexRateLimitLine+"\n"), 0o600); err != nil {
+		t.Fatalf("write session log: %v", err)
+	}
+	var calls []string
+	mgr := NewManager(state, secrets,
+		WithCodexSessionsDir(dir),
+		WithCodexExecFunc(func(ctx context.Context, args ...string) ([]byte, error) {
+			calls = append(calls, "exec")
+			return []byte(`{"type":"thread.started","thread_id":"thread-123"}` + "\n"), nil
+		}),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			calls = append(calls, "fetch")
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 42}, SourceTimestamp: time.Now().UTC()}, nil
+		}),
+	)
+
+	if _, err := mgr.RunCodexWarmup(context.Background()); err == nil || len(calls) != 0 {
+		t.Fatalf("expected no warmup without an active account, got err=%v calls=%v", err, calls)
+	}
+
+	state.state.ActiveAccountID = "A"
+	result, err := mgr.RunCodexWarmup(context.Background())
+	if err != nil {
+		t.Fatalf("RunCodexWarmup: %v", err)
+	}
+	if result.ThreadID != "thread-123" || result.Quota.Session.UsedPercent != 42 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	if strings.Join(calls, ",") != "exec,fetch" {
+		t.Fatalf("expected the warmup before the sync, got %v", calls)
+	}
+	if result.Quota.Weekly.UsedPercent != 41 {
+		t.Fatalf("expected the weekly window from the warmup thread's log, got %#v", result.Quota.Weekly)
+	}
+	if got := state.state.Accounts["A"].Quota; got.Session.UsedPercent != 42 || got.Weekly.UsedPercent != 41 {
+		t.Fatalf("expected the merged quota to be saved, got %#v", got)
+	}
+}
+
+func TestSyncQuotaFromLocalLogs(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}},
+		WithCodexSessionsDir(writeCodexSessionLog(t, codexRateLimitLine)))
+
+	if _, err := mgr.SyncQuotaFromLocalLogs(context.Background(), "B"); err == nil {
+		t.Fatal("expected an error for a non-active account")
+	}
+	if _, err := mgr.SyncQuotaFromLocalLogs(context.Background(), "missing"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected ErrAccountNotFound, got %v", err)
+	}
+
+	result, err := mgr.SyncQuotaFromLocalLogs(context.Background(), "")
+	if err != nil {
+		t.Fatalf("SyncQuotaFromLocalLogs: %v", err)
+	}
+	if result.AccountID != "A" || result.Quota.Session.UsedPercent != 20 {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+
+	acct := state.state.Accounts["A"]
+	acct.Quota.LastUpdated = time.Now().UTC()
+	state.state.Accounts["A"] = acct
+	if _, err := mgr.SyncQuotaFromLocalLogs(context.Background(), ""); err == nil {
+		t.Fatal("expected stale local logs to be rejected")
+	}
+}
+
+type fakeNotifier struct {
+	calls chan [2]string
+}
+
+func (n *fakeNotifier) Notify(title, body string) error {
+	n.calls <- [2]string{title, body}
+	return errors.New("no notification daemon")
+}
+
+func TestHandleQuotaErrorNotifiesOnSwitch(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	notifier := &fakeNotifier{calls: make(chan [2]string, 4)}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}), WithNotifier(notifier))
+
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || !decision.Switched {
+		t.Fatalf("expected a switch despite the failing notifier, got %#v err=%v", decision, err)
+	}
+	select {
+	case call := <-notifier.calls:
+		if !strings.Contains(call[1], "A") || !strings.Contains(call[1], "B") {
+			t.Fatalf("expected the notification to name both accounts, got %q", call[1])
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected a notification")
+	}
+
+	// No switch, no notification.
+	decision, err = mgr.HandleQuotaError(context.Background(), 400, "bad request")
+	if err != nil || decision.Switched {
+		t.Fatalf("unexpected decision: %#v err=%v", decision, err)
+	}
+	select {
+	case call := <-notifier.calls:
+		t.Fatalf("unexpected notification: %v", call)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestWebhookDeliversSwitchAndSyncFailure(t *testing.T) {
+	bodies := make(chan []byte, 4)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		if r.Header.Get(webhook.SignatureHeader) != webhook.Sign("hook-secret", body) {
+			t.Errorf("bad signature %q", r.Header.Get(webhook.SignatureHeader))
+		}
+		bodies <- body
+	}))
+	defer srv.Close()
+	deliverer, err := webhook.NewDeliverer(srv.URL, "hook-secret")
+	if err != nil {
+		t.Fatalf("new deliverer: %v", err)
+	}
+
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	mgr := NewManager(state, secrets,
+		WithActiveAccountApplier(&fakeApplier{}),
+		WithWebhookDeliverer(deliverer),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{}, errors.New("usage api down")
+		}),
+	)
+
+	receive := func() webhook.Event {
+		t.Helper()
+		select {
+		case body := <-bodies:
+			var evt webhook.Event
+			if err := json.Unmarshal(body, &evt); err != nil {
+				t.Fatalf("decode webhook body %s: %v", body, err)
+			}
+			return evt
+		case <-time.After(2 * time.Second):
+			t.Fatal("expected a webhook delivery")
+		}
+		return webhook.Event{}
+	}
+
+	if decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded"); err != nil || !decision.Switched {
+		t.Fatalf("expected switch, got %#v err=%v", decision, err)
+	}
+	evt := receive()
+	if evt.Event != webhook.EventAccountSwitched || evt.From != "A" || evt.To != "B" || evt.Reason != "quota-exceeded" || evt.TS.IsZero() {
+		t.Fatalf("unexpected switch webhook: %#v", evt)
+	}
+
+	if _, err := mgr.SyncQuotaFromCodexAPI(context.Background(), "B"); err == nil {
+		t.Fatal("expected sync error")
+	}
+	evt = receive()
+	if evt.Event != webhook.EventQuotaSyncFailed || evt.AccountID != "B" || !strings.Contains(evt.Error, "usage api down") {
+		t.Fatalf("unexpected sync failure webhook: %#v", evt)
+	}
+}
+
+func TestSetAccountNotes(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := NewManager(state, &fakeSecretStore{})
+	ctx := context.Background()
+
+	acct, err := mgr.SetAccountNotes(ctx, "A", "  shared by the ops team  ")
+	if err != nil || acct.Notes != "shared by the ops team" {
+		t.Fatalf("set notes: %#v err=%v", acct, err)
+	}
+	if got := state.state.Accounts["A"].Notes; got != "shared by the ops team" {
+		t.Fatalf("expected notes to persist, got %q", got)
+	}
+
+	saves := state.saveCalls
+	if _, err := mgr.SetAccountNotes(ctx, "A", strings.Repeat("n", maxAccountNotesLength+1)); err == nil || !strings.Contains(err.Error(), "at most 500") {
+		t.Fatalf("expected a length error, got %v", err)
+	}
+	tooLong := strings.Repeat("n", maxAccountNotesLength+1)
+	if _, err := mgr.PatchAccount(ctx, "A", AccountPatch{Notes: &tooLong}); err == nil {
+		t.Fatal("expected PatchAccount to reject long notes")
+	}
+	if state.saveCalls != saves {
+		t.Fatalf("expected no saves for rejected notes, got %d", state.saveCalls-saves)
+	}
+	// The limit counts characters, not bytes.
+	if _, err := mgr.SetAccountNotes(ctx, "A", strings.Repeat("é", maxAccountNotesLength)); err != nil {
+		t.Fatalf("expected %d multi-byte characters to fit: %v", maxAccountNotesLength, err)
+	}
+	if _, err := mgr.SetAccountNotes(ctx, "missing", "x"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSetAccountMeta(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := NewManager(state, &fakeSecretStore{})
+	ctx := context.Background()
+
+	acct, err := mgr.SetAccountMeta(ctx, "A", map[string]string{"org": "acme", "region": "eu"})
+	if err != nil || len(acct.ProviderMeta) != 2 {
+		t.Fatalf("set meta: %#v err=%v", acct, err)
+	}
+	before := acct.ProviderMeta
+
+	// Keys not in the patch are kept; an empty value deletes its key.
+	acct, err = mgr.SetAccountMeta(ctx, "A", map[string]string{"org": "globex", "region": ""})
+	if err != nil {
+		t.Fatalf("update meta: %v", err)
+	}
+	if got := state.state.Accounts["A"].ProviderMeta; len(got) != 1 || got["org"] != "globex" {
+		t.Fatalf("expected org to be overwritten and region removed, got %#v", got)
+	}
+	if before["org"] != "acme" || before["region"] != "eu" {
+		t.Fatalf("expected the earlier map to be left alone, got %#v", before)
+	}
+
+	if acct, err = mgr.SetAccountMeta(ctx, "A", map[string]string{"org": ""}); err != nil || acct.ProviderMeta != nil {
+		t.Fatalf("expected meta to be cleared, got %#v err=%v", acct, err)
+	}
+
+	saves := state.saveCalls
+	for _, meta := range []map[string]string{nil, {" ": "x"}, {strings.Repeat("k", maxAccountMetaKeyLen+1): "x"}} {
+		if _, err := mgr.SetAccountMeta(ctx, "A", meta); err == nil {
+			t.Fatalf("expected %#v to be rejected", meta)
+		}
+	}
+	if state.saveCalls != saves {
+		t.Fatalf("expected no saves for rejected meta, got %d", state.saveCalls-saves)
+	}
+	if _, err := mgr.SetAccountMeta(ctx, "missing", map[string]string{"org": "x"}); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+}
+
+func TestSetAccountAlias(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"codex:b@example.com": {ID: "codex:b@example.com", Provider: "codex", UpdatedAt: now},
+				"codex:c@example.com": {ID: "codex:c@example.com", Provider: "codex", UpdatedAt: now},
+				"codex:z@example.com": {ID: "codex:z@example.com", Provider: "codex", UpdatedAt: now},
+			},
+		},
+	}
+	mgr := NewManager(state, &fakeSecretStore{})
+	ctx := context.Background()
+
+	acct, err := mgr.SetAccountAlias(ctx, "codex:z@example.com", "  Home ")
+	if err != nil || acct.Alias != "Home" {
+		t.Fatalf("set alias: %#v err=%v", acct, err)
+	}
+	if got := state.state.Accounts["codex:z@example.com"].Alias; got != "Home" {
+		t.Fatalf("expected alias to persist, got %q", got)
+	}
+	if _, err := mgr.SetAccountAlias(ctx, "codex:b@example.com", "home"); err == nil || !strings.Contains(err.Error(), "already used") {
+		t.Fatalf("expected duplicate alias error, got %v", err)
+	}
+	if _, err := mgr.SetAccountAlias(ctx, "missing", "x"); !errors.Is(err, ErrAccountNotFound) {
+		t.Fatalf("expected not found, got %v", err)
+	}
+
+	// Sort keys are the alias or ID: "codex:b@...", "home", "work".
+	if _, err := mgr.SetAccountAlias(ctx, "codex:c@example.com", "work"); err != nil {
+		t.Fatalf("set alias: %v", err)
+	}
+	accounts, err := mgr.ListAccounts(ctx)
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	var order []string
+	for _, a := range accounts {
+		order = append(order, a.ID)
+	}
+	if want := "codex:b@example.com,codex:z@example.com,codex:c@example.com"; strings.Join(order, ",") != want {
+		t.Fatalf("list order = %v, want %s", order, want)
+	}
+
+	if acct, err := mgr.SetAccountAlias(ctx, "codex:z@example.com", ""); err != nil || acct.Alias != "" {
+		t.Fatalf("clear alias: %#v err=%v", acct, err)
+	}
+	if got := state.state.Accounts["codex:z@example.com"].Alias; got != "" {
+		t.Fatalf("expected alias cleared, got %q", got)
+	}
+}
+
+func TestDryRunQuotaErrorLeavesStateUntouched(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	applier := &fakeApplier{}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(applier))
+	before := cloneState(state.state)
+
+	decision, err := mgr.DryRunQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if !decision.DryRun || !decision.WouldSwitch || decision.Switched || decision.ToAccountID != "B" || decision.FromAccountID != "A" {
+		t.Fatalf("unexpected dry-run decision: %#v", decision)
+	}
+	if state.saveCalls != 0 || applier.calls != 0 {
+		t.Fatalf("dry run must not save or apply: saves=%d applies=%d", state.saveCalls, applier.calls)
+	}
+	if !reflect.DeepEqual(state.state, before) {
+		t.Fatalf("dry run modified state: %#v", state.state)
+	}
+
+	// With no candidate left the dry run must not start a cooldown either.
+	delete(state.state.Accounts, "B")
+	decision, err = mgr.DryRunQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || decision.WouldSwitch || decision.Reason != "no-available-account" {
+		t.Fatalf("unexpected dry-run decision: %#v err=%v", decision, err)
+	}
+	if state.saveCalls != 0 || !state.state.CooldownUntil.IsZero() || state.state.LastGlobalError != "" {
+		t.Fatalf("dry run recorded a failure: %#v", state.state)
+	}
+	if decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded"); err != nil || decision.Reason != "no-available-account" {
+		t.Fatalf("expected a real search after dry runs, got %#v err=%v", decision, err)
+	}
+}
+
+func TestHandleQuotaErrorSyncsQuotaAfterSwitch(t *testing.T) {
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"B": {AccessToken: "token-b", AccountID: "acct-b", AccessExpiresAt: time.Now().UTC().Add(2 * time.Hour)},
+		},
+	}
+	fetched := make(chan string, 1)
+	release := make(chan struct{})
+	mgr := NewManager(state, secrets,
+		WithActiveAccountApplier(&fakeApplier{}),
+		WithPostSwitchQuotaSync(true),
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			fetched <- accountID
+			<-release
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 37}, Weekly: &quota.Window{UsedPercent: 12}, SourceTimestamp: time.Now().UTC()}, nil
+		}),
+	)
+	events, unsubscribe := mgr.Subscribe()
+	defer unsubscribe()
+
+	// The fetcher blocks until released, so returning here proves the sync
+	// does not hold up the decision.
+	decision, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded")
+	if err != nil || !decision.Switched || decision.ToAccountID != "B" {
+		t.Fatalf("expected switch to B, got %#v err=%v", decision, err)
+	}
+	select {
+	case id := <-fetched:
+		if id != "acct-b" {
+			t.Fatalf("expected quota fetch for acct-b, got %q", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected a post-switch quota sync")
+	}
+	close(release)
+
+	deadline := time.After(2 * time.Second)
+	for {
+		select {
+		case evt := <-events:
+			if evt.Type != EventQuotaSynced {
+				continue
+			}
+			if evt.AccountID != "B" || evt.Quota == nil || evt.Quota.Session.UsedPercent != 37 {
+				t.Fatalf("unexpected sync event: %#v", evt)
+			}
+			if got := state.state.Accounts["B"].Quota.Session.UsedPercent; got != 37 {
+				t.Fatalf("expected stored session quota 37, got %d", got)
+			}
+			return
+		case <-deadline:
+			t.Fatal("expected the synced quota to be saved")
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"context"
+	"slices"
+
+	"switchly/internal/model"
+)
+
+// OAuthProviders returns the OAuth providers registered at runtime.
+func (m *Manager) OAuthProviders(ctx context.Context) ([]model.OAuthProvider, error) {
+	_ = ctx
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return nil, err
+	}
+	return append([]model.OAuthProvider(nil), state.OAuthProviders...), nil
+}
+
+// SaveOAuthProvider stores p, replacing a provider with the same name.
+// Validation is left to the oauth package.
+func (m *Manager) SaveOAuthProvider(ctx context.Context, p model.OAuthProvider) error {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return err
+	}
+	state.OAuthProviders = slices.DeleteFunc(state.OAuthProviders, func(existing model.OAuthProvider) bool {
+		return existing.Provider == p.Provider
+	})
+	state.OAuthProviders = append(state.OAuthProviders, p)
+	return m.stateStore.Save(state)
+}
+
+// DeleteOAuthProvider removes a registered provider. Removing an unknown
+// provider is not an error.
+func (m *Manager) DeleteOAuthProvider(ctx context.Context, name string) error {
+	_ = ctx
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return err
+	}
+	n := len(state.OAuthProviders)
+	state.OAuthProviders = slices.DeleteFunc(state.OAuthProviders, func(existing model.OAuthProvider) bool {
+		return existing.Provider == name
+	})
+	if len(state.OAuthProviders) == n {
+		return nil
+	}
+	return m.stateStore.Save(state)
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+)
+
+type QuotaSchedule struct {
+	Enabled         bool      `json:"enabled"`
+	Interval        string    `json:"interval"`
+	IntervalSeconds int64     `json:"interval_seconds"`
+	LastSyncAt      time.Time `json:"last_sync_at,omitzero"`
+	NextSyncAt      time.Time `json:"next_sync_at,omitzero"`
+}
+
+type QuotaScheduler struct {
+	mu         sync.Mutex
+	manager    *Manager
+	interval   time.Duration
+	lastSyncAt time.Time
+	nextSyncAt time.Time
+}
+
+func NewQuotaScheduler(manager *Manager, interval time.Duration) *QuotaScheduler {
+	return &QuotaScheduler{manager: manager, interval: interval}
+}
+
+func (s *QuotaScheduler) Run(ctx context.Context) {
+	if s.interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	s.setNext(time.Now().UTC().Add(s.interval))
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.runOnce(ctx)
+			s.setNext(time.Now().UTC().Add(s.interval))
+		}
+	}
+}
+
+func (s *QuotaScheduler) runOnce(ctx context.Context) {
+	now := time.Now().UTC()
+	// Accounts synced within the last half-interval (manually or by a previous
+	// tick) are skipped so bursts of syncs do not pile up on the provider.
+	result, err := s.manager.SyncStaleQuotasFromCodexAPI(ctx, now.Add(-s.interval/2))
+	if err != nil {
+		log.Printf("scheduled quota sync failed: %v", err)
+		return
+	}
+	for _, item := range result.Results {
+		if !item.Success {
+			log.Printf("scheduled quota sync failed account_id=%s err=%s", item.AccountID, item.Error)
+		}
+	}
+
+	s.mu.Lock()
+	s.lastSyncAt = result.FinishedAt
+	s.mu.Unlock()
+}
+
+func (s *QuotaScheduler) setNext(next time.Time) {
+	s.mu.Lock()
+	s.nextSyncAt = next
+	s.mu.Unlock()
+}
+
+func (s *QuotaScheduler) Schedule() QuotaSchedule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return QuotaSchedule{
+		Enabled:         s.interval > 0,
+		Interval:        s.interval.String(),
+		IntervalSeconds: int64(s.interval / time.Second),
+		LastSyncAt:      s.lastSyncAt,
+		NextSyncAt:      s.nextSyncAt,
+	}
+}
@@ -0,0 +1,69 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+	"switchly/internal/quota"
+)
+
+func TestQuotaSchedulerSkipsRecentlySyncedAccounts(t *testing.T) {
+	now := time.Now().UTC()
+	state := &fakeStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "A",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"A": {ID: "A", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{LastUpdated: now.Add(-time.Minute)}},
+				"B": {ID: "B", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{LastUpdated: now.Add(-time.Hour)}},
+			},
+		},
+	}
+	secrets := &fakeSecretStore{
+		entries: map[string]model.AuthSecrets{
+			"A": {AccessToken: "token-a", AccountID: "acct-a", AccessExpiresAt: now.Add(2 * time.Hour)},
+			"B": {AccessToken: "token-b", AccountID: "acct-b", AccessExpiresAt: now.Add(2 * time.Hour)},
+		},
+	}
+	var fetched []string
+	mgr := NewManager(
+		state,
+		secrets,
+		WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			fetched = append(fetched, accountID)
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 10}}, nil
+		}),
+	)
+
+	scheduler := NewQuotaScheduler(mgr, 10*time.Minute)
+	scheduler.runOnce(context.Background())
+
+	if len(fetched) != 1 || fetched[0] != "acct-b" {
+		t.Fatalf("expected only stale account B to be synced, got %v", fetched)
+	}
+	schedule := scheduler.Schedule()
+	if !schedule.Enabled || schedule.IntervalSeconds != 600 || schedule.LastSyncAt.IsZero() {
+		t.Fatalf("unexpected schedule: %#v", schedule)
+	}
+}
+
+func TestQuotaSchedulerDisabledReturnsImmediately(t *testing.T) {
+	scheduler := NewQuotaScheduler(nil, 0)
+	done := make(chan struct{})
+	go func() {
+		scheduler.Run(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("expected disabled scheduler to return")
+	}
+	if scheduler.Schedule().Enabled {
+		t.Fatal("expected schedule disabled")
+	}
+}
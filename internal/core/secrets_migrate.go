@@ -0,0 +1,89 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"switchly/internal/secrets"
+)
+
+type SecretsMigrationResult struct {
+	From     string            `json:"from"`
+	To       string            `json:"to"`
+	Migrated []string          `json:"migrated"`
+	Failed   map[string]string `json:"failed,omitempty"`
+}
+
+// WithSecretStoreOpener replaces how MigrateSecrets opens stores by backend
+// name. It defaults to secrets.Open.
+func WithSecretStoreOpener(open func(backend string) (secrets.Store, error)) ManagerOption {
+	return func(m *Manager) {
+		if open != nil {
+			m.openSecretStore = open
+		}
+	}
+}
+
+// SecretsBackend names the backend the manager reads secrets from.
+func (m *Manager) SecretsBackend() string {
+	return secrets.BackendOf(m.secrets)
+}
+
+// MigrateSecrets copies the secrets of every known account from one backend
+// to another. Accounts that fail are listed in the result rather than
+// aborting the migration.
+func (m *Manager) MigrateSecrets(ctx context.Context, srcType, dstType string) (SecretsMigrationResult, error) {
+	_ = ctx
+	srcType = strings.TrimSpace(srcType)
+	dstType = strings.TrimSpace(dstType)
+	if srcType == "" || dstType == "" {
+		return SecretsMigrationResult{}, errors.New("source and destination secret stores are required")
+	}
+	if srcType == dstType {
+		return SecretsMigrationResult{}, fmt.Errorf("source and destination are both %q", srcType)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return SecretsMigrationResult{}, err
+	}
+	src, err := m.openSecretStore(srcType)
+	if err != nil {
+		return SecretsMigrationResult{}, fmt.Errorf("open source store: %w", err)
+	}
+	dst, err := m.openSecretStore(dstType)
+	if err != nil {
+		return SecretsMigrationResult{}, fmt.Errorf("open destination store: %w", err)
+	}
+
+	ids := make([]string, 0, len(state.Accounts))
+	for id := range state.Accounts {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	result := SecretsMigrationResult{From: srcType, To: dstType, Migrated: []string{}}
+	var migrationErr *secrets.MigrationError
+	if err := secrets.Migrate(src, dst, ids); err != nil && !errors.As(err, &migrationErr) {
+		return SecretsMigrationResult{}, err
+	}
+	for _, id := range ids {
+		if migrationErr != nil {
+			if failure, ok := migrationErr.Failed[id]; ok {
+				if result.Failed == nil {
+					result.Failed = map[string]string{}
+				}
+				result.Failed[id] = failure.Error()
+				continue
+			}
+		}
+		result.Migrated = append(result.Migrated, id)
+	}
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"switchly/internal/model"
+	"switchly/internal/secrets"
+)
+
+func TestMigrateSecretsCopiesKnownAccounts(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["B"] = model.Account{ID: "B", Provider: "codex", Status: model.AccountReady}
+	stores := map[string]*fakeSecretStore{
+		"file":  {entries: map[string]model.AuthSecrets{"A": {AccessToken: "token-a"}, "orphan": {AccessToken: "x"}}},
+		"dpapi": {entries: map[string]model.AuthSecrets{}},
+	}
+	mgr := NewManager(state, stores["file"], WithSecretStoreOpener(func(backend string) (secrets.Store, error) {
+		store, ok := stores[backend]
+		if !ok {
+			return nil, fmt.Errorf("secret store %q is not available", backend)
+		}
+		return store, nil
+	}))
+
+	result, err := mgr.MigrateSecrets(context.Background(), "file", "dpapi")
+	if err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if !reflect.DeepEqual(result.Migrated, []string{"A"}) || len(result.Failed) != 1 || result.Failed["B"] == "" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+	dst := stores["dpapi"].entries
+	if len(dst) != 1 || dst["A"].AccessToken != "token-a" {
+		t.Fatalf("expected only A's secrets in the destination, got %#v", dst)
+	}
+
+	if _, err := mgr.MigrateSecrets(context.Background(), "file", "keychain"); err == nil {
+		t.Fatal("expected an unavailable destination to fail")
+	}
+	if _, err := mgr.MigrateSecrets(context.Background(), "file", "file"); err == nil {
+		t.Fatal("expected migrating a store onto itself to fail")
+	}
+}
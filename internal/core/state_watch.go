@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+	"errors"
+
+	"switchly/internal/model"
+)
+
+type stateWatcher interface {
+	Watch(ctx context.Context) (<-chan model.AppState, error)
+}
+
+// WithStateWatch makes WatchState follow changes that other processes write
+// to the state file.
+func WithStateWatch(enabled bool) ManagerOption {
+	return func(m *Manager) {
+		m.stateWatch = enabled
+	}
+}
+
+// WatchState refreshes the manager's in-memory view of the state whenever
+// the state store reports a change, until ctx is done. It does nothing
+// unless WithStateWatch(true) was given.
+func (m *Manager) WatchState(ctx context.Context) error {
+	if !m.stateWatch {
+		return nil
+	}
+	watcher, ok := m.stateStore.(stateWatcher)
+	if !ok {
+		return errors.New("state store does not support watching")
+	}
+	changes, err := watcher.Watch(ctx)
+	if err != nil {
+		return err
+	}
+	go func() {
+		for state := range changes {
+			m.mu.Lock()
+			m.cooldownUntil = state.CooldownUntil
+			m.mu.Unlock()
+		}
+	}()
+	return nil
+}
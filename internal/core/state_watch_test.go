@@ -0,0 +1,56 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+)
+
+type watchingStateStore struct {
+	*fakeStateStore
+	changes chan model.AppState
+}
+
+func (s *watchingStateStore) Watch(ctx context.Context) (<-chan model.AppState, error) {
+	return s.changes, nil
+}
+
+func TestWatchStateRefreshesCooldown(t *testing.T) {
+	state := &watchingStateStore{fakeStateStore: &fakeStateStore{state: model.DefaultState()}, changes: make(chan model.AppState)}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}}, WithStateWatch(true))
+	mgr.cooldownUntil = time.Now().Add(time.Hour)
+
+	if err := mgr.WatchState(context.Background()); err != nil {
+		t.Fatalf("watch state: %v", err)
+	}
+	// Another process reset the cooldown.
+	state.changes <- model.DefaultState()
+	close(state.changes)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mgr.mu.Lock()
+		cleared := mgr.cooldownUntil.IsZero()
+		mgr.mu.Unlock()
+		if cleared {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the in-memory cooldown to follow the external change")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestWatchStateDisabledByDefault(t *testing.T) {
+	mgr := NewManager(&fakeStateStore{state: model.DefaultState()}, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+	if err := mgr.WatchState(context.Background()); err != nil {
+		t.Fatalf("expected no error without WithStateWatch, got %v", err)
+	}
+	mgr = NewManager(&fakeStateStore{state: model.DefaultState()}, &fakeSecretStore{entries: map[string]model.AuthSecrets{}}, WithStateWatch(true))
+	if err := mgr.WatchState(context.Background()); err == nil {
+		t.Fatal("expected an error for a store that cannot be watched")
+	}
+}
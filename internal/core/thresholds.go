@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"switchly/internal/model"
+)
+
+const (
+	QuotaWarningSession = "session_quota"
+	QuotaWarningWeekly  = "weekly_quota"
+)
+
+// QuotaWarning reports one quota window that is at or above its threshold.
+type QuotaWarning struct {
+	AccountID string `json:"account_id"`
+	Type      string `json:"type"`
+	Value     int    `json:"value"`
+}
+
+// ThresholdsPatch changes the warning thresholds of an account. Nil fields
+// keep their current value.
+type ThresholdsPatch struct {
+	SessionWarnAt *int `json:"session_warn_at"`
+	WeeklyWarnAt  *int `json:"weekly_warn_at"`
+}
+
+// SetAccountThresholds updates the quota warning thresholds of an account and
+// re-evaluates its warning against the stored quota.
+func (m *Manager) SetAccountThresholds(ctx context.Context, accountID string, patch ThresholdsPatch) (model.Account, error) {
+	_ = ctx
+	if patch.SessionWarnAt == nil && patch.WeeklyWarnAt == nil {
+		return model.Account{}, fmt.Errorf("no thresholds to update")
+	}
+	for _, v := range []*int{patch.SessionWarnAt, patch.WeeklyWarnAt} {
+		if v != nil && (*v < 0 || *v > 100) {
+			return model.Account{}, fmt.Errorf("threshold must be between 0 and 100")
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	state, err := m.stateStore.Load()
+	if err != nil {
+		return model.Account{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	if !ok {
+		return model.Account{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+	thresholds := acct.WarnThresholds()
+	if patch.SessionWarnAt != nil {
+		thresholds.SessionWarnAt = *patch.SessionWarnAt
+	}
+	if patch.WeeklyWarnAt != nil {
+		thresholds.WeeklyWarnAt = *patch.WeeklyWarnAt
+	}
+	acct.Thresholds = &thresholds
+
+	now := time.Now().UTC()
+	applyQuotaWarning(&acct, now)
+	acct.UpdatedAt = now
+	state.Accounts[accountID] = acct
+	if err := m.stateStore.Save(state); err != nil {
+		return model.Account{}, err
+	}
+	return acct, nil
+}
+
+// quotaWarnings lists the quota windows of acct at or above its thresholds.
+func quotaWarnings(acct model.Account) []QuotaWarning {
+	thresholds := acct.WarnThresholds()
+	var out []QuotaWarning
+	sessionSupported := acct.Quota.SessionSupported == nil || *acct.Quota.SessionSupported
+	if sessionSupported && thresholds.SessionWarnAt > 0 && acct.Quota.Session.UsedPercent >= thresholds.SessionWarnAt {
+		out = append(out, QuotaWarning{AccountID: acct.ID, Type: QuotaWarningSession, Value: acct.Quota.Session.UsedPercent})
+	}
+	if thresholds.WeeklyWarnAt > 0 && acct.Quota.Weekly.UsedPercent >= thresholds.WeeklyWarnAt {
+		out = append(out, QuotaWarning{AccountID: acct.ID, Type: QuotaWarningWeekly, Value: acct.Quota.Weekly.UsedPercent})
+	}
+	return out
+}
+
+// applyQuotaWarning moves a ready account to AccountWarning when its quota
+// crosses a threshold, and back once usage drops. Disabled and need_reauth
+// accounts keep their status.
+func applyQuotaWarning(acct *model.Account, now time.Time) {
+	crossed := len(quotaWarnings(*acct)) > 0
+	switch {
+	case crossed && acct.Status == model.AccountReady:
+		acct.Status = model.AccountWarning
+		acct.LastWarningAt = now
+	case !crossed && acct.Status == model.AccountWarning:
+		acct.Status = model.AccountReady
+	}
+}
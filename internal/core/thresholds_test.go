@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func TestUpdateQuotaRaisesAndClearsWarning(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+	ctx := context.Background()
+
+	update := func(session, weekly int) model.Account {
+		t.Helper()
+		if err := mgr.UpdateQuota(ctx, "A", model.QuotaSnapshot{
+			Session: model.QuotaWindow{UsedPercent: session},
+			Weekly:  model.QuotaWindow{UsedPercent: weekly},
+		}); err != nil {
+			t.Fatalf("update quota: %v", err)
+		}
+		return state.state.Accounts["A"]
+	}
+
+	if acct := update(79, 10); acct.Status != model.AccountReady || !acct.LastWarningAt.IsZero() {
+		t.Fatalf("expected no warning below the threshold, got %s", acct.Status)
+	}
+	acct := update(85, 10)
+	if acct.Status != model.AccountWarning || acct.LastWarningAt.IsZero() {
+		t.Fatalf("expected a warning at 85%%, got %s (last warning %v)", acct.Status, acct.LastWarningAt)
+	}
+	status, err := mgr.Status(ctx)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if len(status.Warnings) != 1 || status.Warnings[0] != (QuotaWarning{AccountID: "A", Type: QuotaWarningSession, Value: 85}) {
+		t.Fatalf("unexpected warnings: %#v", status.Warnings)
+	}
+
+	if acct := update(20, 10); acct.Status != model.AccountReady {
+		t.Fatalf("expected the warning to clear once usage drops, got %s", acct.Status)
+	}
+	if status, _ := mgr.Status(ctx); len(status.Warnings) != 0 {
+		t.Fatalf("expected no warnings, got %#v", status.Warnings)
+	}
+
+	// Disabled accounts keep their status whatever the usage.
+	disabled := state.state.Accounts["A"]
+	disabled.Status = model.AccountDisabled
+	state.state.Accounts["A"] = disabled
+	if acct := update(95, 95); acct.Status != model.AccountDisabled {
+		t.Fatalf("expected disabled account to stay disabled, got %s", acct.Status)
+	}
+}
+
+func TestSetAccountThresholds(t *testing.T) {
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{
+		ID:       "A",
+		Provider: "codex",
+		Status:   model.AccountReady,
+		Quota:    model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 75}, Weekly: model.QuotaWindow{UsedPercent: 40}},
+	}
+	mgr := NewManager(state, &fakeSecretStore{entries: map[string]model.AuthSecrets{}})
+	ctx := context.Background()
+	intPtr := func(v int) *int { return &v }
+
+	acct, err := mgr.SetAccountThresholds(ctx, "A", ThresholdsPatch{SessionWarnAt: intPtr(70)})
+	if err != nil {
+		t.Fatalf("set thresholds: %v", err)
+	}
+	if got := acct.WarnThresholds(); got != (model.QuotaThresholds{SessionWarnAt: 70, WeeklyWarnAt: 80}) {
+		t.Fatalf("expected the weekly default to be kept, got %#v", got)
+	}
+	if acct.Status != model.AccountWarning {
+		t.Fatalf("expected lowering the threshold to raise a warning, got %s", acct.Status)
+	}
+
+	acct, err = mgr.SetAccountThresholds(ctx, "A", ThresholdsPatch{SessionWarnAt: intPtr(0)})
+	if err != nil {
+		t.Fatalf("disable session threshold: %v", err)
+	}
+	if acct.Status != model.AccountReady {
+		t.Fatalf("expected the warning to clear when disabled, got %s", acct.Status)
+	}
+
+	for _, patch := range []ThresholdsPatch{{}, {WeeklyWarnAt: intPtr(101)}, {SessionWarnAt: intPtr(-1)}} {
+		if _, err := mgr.SetAccountThresholds(ctx, "A", patch); err == nil {
+			t.Fatalf("expected %#v to be rejected", patch)
+		}
+	}
+	if _, err := mgr.SetAccountThresholds(ctx, "missing", ThresholdsPatch{SessionWarnAt: intPtr(50)}); err == nil {
+		t.Fatal("expected missing account to fail")
+	}
+}
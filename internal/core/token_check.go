@@ -0,0 +1,99 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultTokenCheckURLs are lightweight authenticated endpoints used to check
+// whether a provider still accepts an account's access token.
+var defaultTokenCheckURLs = map[string]string{
+	"codex": "https://api.openai.com/v1/models",
+}
+
+type TokenCheckResult struct {
+	AccountID  string `json:"account_id"`
+	Provider   string `json:"provider"`
+	Valid      bool   `json:"valid"`
+	HTTPStatus int    `json:"http_status,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+}
+
+// WithTokenCheckURL overrides the endpoint CheckAccountToken requests for
+// provider.
+func WithTokenCheckURL(provider, url string) ManagerOption {
+	return func(m *Manager) {
+		if m.tokenCheckURLs == nil {
+			m.tokenCheckURLs = make(map[string]string)
+		}
+		m.tokenCheckURLs[strings.ToLower(provider)] = url
+	}
+}
+
+// CheckAccountToken sends an authenticated HEAD request to the provider to
+// confirm the stored access token is still accepted. A 401 or 403 is
+// reported as an invalid token rather than an error. The request goes
+// through the account's HTTP config, and the stored state is not modified.
+func (m *Manager) CheckAccountToken(ctx context.Context, accountID string) (TokenCheckResult, error) {
+	m.mu.Lock()
+	state, err := m.stateStore.Load()
+	if err != nil {
+		m.mu.Unlock()
+		return TokenCheckResult{}, err
+	}
+	acct, ok := state.Accounts[accountID]
+	m.mu.Unlock()
+	if !ok {
+		return TokenCheckResult{}, fmt.Errorf("account %s %w", accountID, ErrAccountNotFound)
+	}
+
+	provider := strings.ToLower(acct.Provider)
+	target, ok := m.tokenCheckURLs[provider]
+	if !ok {
+		target, ok = defaultTokenCheckURLs[provider]
+	}
+	if !ok {
+		return TokenCheckResult{}, fmt.Errorf("token check not supported for provider %s", acct.Provider)
+	}
+	sec, err := m.secrets.Get(accountID)
+	if err != nil {
+		return TokenCheckResult{}, fmt.Errorf("load secrets for account %s: %w", accountID, err)
+	}
+	if strings.TrimSpace(sec.AccessToken) == "" {
+		return TokenCheckResult{}, fmt.Errorf("account %s has no access token", accountID)
+	}
+	client, err := m.httpClientFor(acct)
+	if err != nil {
+		return TokenCheckResult{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, target, nil)
+	if err != nil {
+		return TokenCheckResult{}, err
+	}
+	req.Header.Set("Authorization", "Bearer "+sec.AccessToken)
+	started := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return TokenCheckResult{}, fmt.Errorf("token check for account %s: %w", accountID, err)
+	}
+	resp.Body.Close()
+
+	result := TokenCheckResult{
+		AccountID: accountID,
+		Provider:  acct.Provider,
+		LatencyMS: time.Since(started).Milliseconds(),
+	}
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode <= 299:
+		result.Valid = true
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		result.HTTPStatus = resp.StatusCode
+	default:
+		return TokenCheckResult{}, fmt.Errorf("token check for account %s: unexpected status %d", accountID, resp.StatusCode)
+	}
+	return result, nil
+}
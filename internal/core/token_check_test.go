@@ -0,0 +1,88 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func TestCheckAccountToken(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodHead || r.URL.Path != "/v1/models" {
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		if r.Header.Get("Authorization") != "Bearer good-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer provider.Close()
+
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["B"] = model.Account{ID: "B", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["C"] = model.Account{ID: "C", Provider: "other", Status: model.AccountReady}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{
+		"A": {AccessToken: "good-token"},
+		"B": {AccessToken: "revoked-token"},
+		"C": {AccessToken: "token"},
+	}}
+	mgr := NewManager(state, secrets, WithTokenCheckURL("codex", provider.URL+"/v1/models"))
+
+	got, err := mgr.CheckAccountToken(context.Background(), "A")
+	if err != nil {
+		t.Fatalf("check A: %v", err)
+	}
+	if !got.Valid || got.Provider != "codex" || got.HTTPStatus != 0 {
+		t.Fatalf("expected A to be valid, got %#v", got)
+	}
+
+	got, err = mgr.CheckAccountToken(context.Background(), "B")
+	if err != nil {
+		t.Fatalf("check B: %v", err)
+	}
+	if got.Valid || got.HTTPStatus != http.StatusUnauthorized {
+		t.Fatalf("expected B to be rejected with 401, got %#v", got)
+	}
+
+	if _, err := mgr.CheckAccountToken(context.Background(), "C"); err == nil {
+		t.Fatal("expected an unsupported provider to fail")
+	}
+	if _, err := mgr.CheckAccountToken(context.Background(), "missing"); err == nil {
+		t.Fatal("expected a missing account to fail")
+	}
+	if state.saveCalls != 0 {
+		t.Fatalf("expected token checks not to modify state, got %d saves", state.saveCalls)
+	}
+}
+
+func TestCheckAccountTokenUsesAccountProxy(t *testing.T) {
+	var proxiedHost string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxiedHost = r.Host
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer proxy.Close()
+
+	state := &fakeStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{
+		ID:         "A",
+		Provider:   "codex",
+		Status:     model.AccountReady,
+		HTTPConfig: &model.HTTPClientConfig{ProxyURL: proxy.URL},
+	}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{"A": {AccessToken: "token"}}}
+	mgr := NewManager(state, secrets, WithTokenCheckURL("codex", "http://models.example.test/v1/models"))
+
+	got, err := mgr.CheckAccountToken(context.Background(), "A")
+	if err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if !got.Valid || proxiedHost != "models.example.test" {
+		t.Fatalf("expected the check to go through the account proxy, got %#v (proxy saw %q)", got, proxiedHost)
+	}
+}
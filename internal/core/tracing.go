@@ -0,0 +1,45 @@
+package core
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const tracerName = "switchly/internal/core"
+
+// Span attribute keys shared by the manager spans.
+const (
+	attrAccountID       = attribute.Key("account.id")
+	attrProvider        = attribute.Key("provider")
+	attrRoutingStrategy = attribute.Key("routing.strategy")
+)
+
+// WithTracerProvider sets the provider Manager spans are created with. By
+// default the global provider is used, which is a no-op until one is set.
+func WithTracerProvider(tp trace.TracerProvider) ManagerOption {
+	return func(m *Manager) {
+		if tp != nil {
+			m.tracer = tp.Tracer(tracerName)
+		}
+	}
+}
+
+func defaultTracer() trace.Tracer {
+	return otel.Tracer(tracerName)
+}
+
+func (m *Manager) startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return m.tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
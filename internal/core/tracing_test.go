@@ -0,0 +1,84 @@
+package core
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"switchly/internal/model"
+)
+
+func spanAttr(span tracetest.SpanStub, key attribute.Key) (attribute.Value, bool) {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value, true
+		}
+	}
+	return attribute.Value{}, false
+}
+
+func TestManagerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	state := &fakeStateStore{state: model.DefaultState()}
+	secrets := &fakeSecretStore{entries: map[string]model.AuthSecrets{}}
+	mgr := NewManager(state, secrets, WithActiveAccountApplier(&fakeApplier{}), WithTracerProvider(tp))
+
+	for _, id := range []string{"A", "B"} {
+		if _, err := mgr.AddAccount(context.Background(), AddAccountInput{
+			ID:       id,
+			Provider: "codex",
+			Secrets:  model.AuthSecrets{AccessToken: "token-" + id, AccessExpiresAt: time.Now().Add(time.Hour)},
+		}); err != nil {
+			t.Fatalf("add %s: %v", id, err)
+		}
+	}
+	if err := mgr.SetActiveAccount(context.Background(), "A"); err != nil {
+		t.Fatalf("set active: %v", err)
+	}
+	if _, err := mgr.HandleQuotaError(context.Background(), 429, "quota exceeded"); err != nil {
+		t.Fatalf("handle quota: %v", err)
+	}
+	if err := mgr.SetActiveAccount(context.Background(), "missing"); err == nil {
+		t.Fatal("expected an error for a missing account")
+	}
+
+	spans := exporter.GetSpans()
+	wantNames := []string{
+		"manager.AddAccount",
+		"manager.AddAccount",
+		"manager.SetActiveAccount",
+		"manager.HandleQuotaError",
+		"manager.SetActiveAccount",
+	}
+	if len(spans) != len(wantNames) {
+		t.Fatalf("expected %d spans, got %d", len(wantNames), len(spans))
+	}
+	for i, want := range wantNames {
+		if spans[i].Name != want {
+			t.Fatalf("span %d: expected %s, got %s", i, want, spans[i].Name)
+		}
+	}
+
+	if v, _ := spanAttr(spans[0], attrProvider); v.AsString() != "codex" {
+		t.Fatalf("expected provider attribute on AddAccount, got %q", v.AsString())
+	}
+	handle := spans[3]
+	if v, _ := spanAttr(handle, attrAccountID); v.AsString() != "A" {
+		t.Fatalf("expected account.id=A on HandleQuotaError, got %q", v.AsString())
+	}
+	if v, _ := spanAttr(handle, attrRoutingStrategy); v.AsString() != string(model.RoutingRoundRobin) {
+		t.Fatalf("expected routing.strategy on HandleQuotaError, got %q", v.AsString())
+	}
+	if v, _ := spanAttr(handle, "switch.to_account_id"); v.AsString() != "B" {
+		t.Fatalf("expected switch.to_account_id=B, got %q", v.AsString())
+	}
+	if got := spans[4].Status.Code.String(); got != "Error" {
+		t.Fatalf("expected failed SetActiveAccount span to have error status, got %s", got)
+	}
+}
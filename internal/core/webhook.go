@@ -0,0 +1,47 @@
+package core
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"switchly/internal/webhook"
+)
+
+const webhookDeliveryTimeout = 30 * time.Second
+
+// WebhookDeliverer posts events to an external endpoint. Send runs in a
+// background goroutine and may block while it retries.
+type WebhookDeliverer interface {
+	Send(ctx context.Context, event webhook.Event) error
+}
+
+func WithWebhookDeliverer(d WebhookDeliverer) ManagerOption {
+	return func(m *Manager) {
+		m.webhook = d
+	}
+}
+
+// deliverWebhook forwards switches and failed quota syncs; other events are
+// only published on the event stream.
+func (m *Manager) deliverWebhook(evt Event) {
+	if m.webhook == nil {
+		return
+	}
+	var payload webhook.Event
+	switch evt.Type {
+	case EventAccountSwitched:
+		payload = webhook.Event{Event: webhook.EventAccountSwitched, From: evt.FromAccountID, To: evt.AccountID, Reason: evt.Reason, TS: evt.Time}
+	case EventQuotaSyncFailed:
+		payload = webhook.Event{Event: webhook.EventQuotaSyncFailed, AccountID: evt.AccountID, Error: evt.Error, TS: evt.Time}
+	default:
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookDeliveryTimeout)
+		defer cancel()
+		if err := m.webhook.Send(ctx, payload); err != nil {
+			log.Printf("webhook delivery failed: %v", err)
+		}
+	}()
+}
@@ -0,0 +1,129 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+const statusTimeout = 5 * time.Second
+
+type StatusSource interface {
+	Status(ctx context.Context) (core.StatusSnapshot, error)
+}
+
+type Recorder struct {
+	registry  *prometheus.Registry
+	switches  *prometheus.CounterVec
+	quotaSync prometheus.Histogram
+}
+
+func NewRecorder() *Recorder {
+	r := &Recorder{
+		registry: prometheus.NewRegistry(),
+		switches: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "switchly_switches_total",
+			Help: "Number of active account switches, by reason.",
+		}, []string{"reason"}),
+		quotaSync: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "switchly_quota_sync_duration_seconds",
+			Help:    "Duration of per-account quota syncs.",
+			Buckets: prometheus.DefBuckets,
+		}),
+	}
+	r.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		r.switches,
+		r.quotaSync,
+	)
+	return r
+}
+
+func (r *Recorder) ObserveSwitch(reason string) {
+	r.switches.WithLabelValues(reason).Inc()
+}
+
+func (r *Recorder) ObserveQuotaSync(duration time.Duration) {
+	r.quotaSync.Observe(duration.Seconds())
+}
+
+func (r *Recorder) RegisterStateCollector(source StatusSource) error {
+	return r.registry.Register(newStateCollector(source))
+}
+
+func (r *Recorder) Handler() http.Handler {
+	return promhttp.HandlerFor(r.registry, promhttp.HandlerOpts{})
+}
+
+type stateCollector struct {
+	source        StatusSource
+	accounts      *prometheus.Desc
+	activeInfo    *prometheus.Desc
+	tokenExpiry   *prometheus.Desc
+	knownStatuses []model.AccountStatus
+}
+
+func newStateCollector(source StatusSource) *stateCollector {
+	return &stateCollector{
+		source: source,
+		accounts: prometheus.NewDesc(
+			"switchly_accounts_total",
+			"Number of configured accounts, by status.",
+			[]string{"status"}, nil,
+		),
+		activeInfo: prometheus.NewDesc(
+			"switchly_active_account_info",
+			"The currently active account.",
+			[]string{"account_id", "provider"}, nil,
+		),
+		tokenExpiry: prometheus.NewDesc(
+			"switchly_token_expiry_seconds",
+			"Seconds until the account access token expires (negative once expired).",
+			[]string{"account_id"}, nil,
+		),
+		knownStatuses: []model.AccountStatus{model.AccountReady, model.AccountWarning, model.AccountNeedReauth, model.AccountDisabled},
+	}
+}
+
+func (c *stateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.accounts
+	ch <- c.activeInfo
+	ch <- c.tokenExpiry
+}
+
+func (c *stateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), statusTimeout)
+	defer cancel()
+
+	status, err := c.source.Status(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(c.accounts, err)
+		return
+	}
+
+	counts := make(map[model.AccountStatus]int, len(c.knownStatuses))
+	for _, s := range c.knownStatuses {
+		counts[s] = 0
+	}
+	now := time.Now()
+	for _, acct := range status.Accounts {
+		counts[acct.Status]++
+		if acct.ID == status.ActiveAccountID {
+			ch <- prometheus.MustNewConstMetric(c.activeInfo, prometheus.GaugeValue, 1, acct.ID, acct.Provider)
+		}
+		if !acct.AccessExpiresAt.IsZero() {
+			ch <- prometheus.MustNewConstMetric(c.tokenExpiry, prometheus.GaugeValue, acct.AccessExpiresAt.Sub(now).Seconds(), acct.ID)
+		}
+	}
+	for s, n := range counts {
+		ch <- prometheus.MustNewConstMetric(c.accounts, prometheus.GaugeValue, float64(n), string(s))
+	}
+}
@@ -0,0 +1,61 @@
+package metrics
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+type fakeStatusSource struct {
+	status core.StatusSnapshot
+}
+
+func (f fakeStatusSource) Status(context.Context) (core.StatusSnapshot, error) {
+	return f.status, nil
+}
+
+func TestRecorderExposesMetrics(t *testing.T) {
+	rec := NewRecorder()
+	err := rec.RegisterStateCollector(fakeStatusSource{status: core.StatusSnapshot{
+		ActiveAccountID: "acc-a",
+		Accounts: []model.Account{
+			{ID: "acc-a", Provider: "codex", Status: model.AccountReady, AccessExpiresAt: time.Now().Add(time.Hour)},
+			{ID: "acc-b", Provider: "codex", Status: model.AccountDisabled},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("register state collector: %v", err)
+	}
+	rec.ObserveSwitch("quota-exceeded")
+	rec.ObserveSwitch("quota-exceeded")
+	rec.ObserveQuotaSync(250 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	rec.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d", http.StatusOK, w.Code)
+	}
+	body := w.Body.String()
+	for _, want := range []string{
+		`switchly_switches_total{reason="quota-exceeded"} 2`,
+		`switchly_quota_sync_duration_seconds_count 1`,
+		`switchly_accounts_total{status="ready"} 1`,
+		`switchly_accounts_total{status="disabled"} 1`,
+		`switchly_accounts_total{status="need_reauth"} 0`,
+		`switchly_active_account_info{account_id="acc-a",provider="codex"} 1`,
+		`switchly_token_expiry_seconds{account_id="acc-a"}`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expected %q in metrics output:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, `switchly_token_expiry_seconds{account_id="acc-b"}`) {
+		t.Fatal("expected no token expiry for account without expiry")
+	}
+}
@@ -5,21 +5,53 @@ import "time"
 type RoutingStrategy string
 
 const (
-	RoutingRoundRobin RoutingStrategy = "round-robin"
-	RoutingFillFirst  RoutingStrategy = "fill-first"
+	RoutingRoundRobin         RoutingStrategy = "round-robin"
+	RoutingFillFirst          RoutingStrategy = "fill-first"
+	RoutingWeightedRoundRobin RoutingStrategy = "weighted-round-robin"
+	RoutingPriority           RoutingStrategy = "priority"
+	RoutingLeastQuota         RoutingStrategy = "least-quota"
 )
 
+// DefaultRoutingStrategy is used for new state and when the strategy is reset.
+const DefaultRoutingStrategy = RoutingRoundRobin
+
+// RoutingStrategies lists every supported strategy. A new strategy added here
+// is accepted by the API and advertised by GET /v1/strategy.
+var RoutingStrategies = []RoutingStrategy{
+	RoutingRoundRobin,
+	RoutingFillFirst,
+	RoutingWeightedRoundRobin,
+	RoutingPriority,
+	RoutingLeastQuota,
+}
+
 type AccountStatus string
 
 const (
 	AccountReady      AccountStatus = "ready"
 	AccountNeedReauth AccountStatus = "need_reauth"
 	AccountDisabled   AccountStatus = "disabled"
+	// AccountWarning is a ready account whose quota usage has crossed one of
+	// its warning thresholds. It is still used for routing.
+	AccountWarning AccountStatus = "warning"
 )
 
+// QuotaThresholds sets the used percentages at which an account is flagged
+// with AccountWarning. Zero disables the warning for that window.
+type QuotaThresholds struct {
+	SessionWarnAt int `json:"session_warn_at"`
+	WeeklyWarnAt  int `json:"weekly_warn_at"`
+}
+
+// DefaultQuotaThresholds applies to accounts without their own thresholds.
+var DefaultQuotaThresholds = QuotaThresholds{SessionWarnAt: 80, WeeklyWarnAt: 80}
+
 type QuotaWindow struct {
 	UsedPercent int       `json:"used_percent"`
 	ResetAt     time.Time `json:"reset_at,omitempty"`
+	// SourceTimestamp is when the source (usage API or local log) observed
+	// this window. It is zero for windows stored before it was tracked.
+	SourceTimestamp time.Time `json:"source_timestamp,omitzero"`
 }
 
 type QuotaSnapshot struct {
@@ -31,18 +63,50 @@ type QuotaSnapshot struct {
 }
 
 type Account struct {
-	ID               string        `json:"id"`
-	Provider         string        `json:"provider"`
-	Email            string        `json:"email,omitempty"`
-	Status           AccountStatus `json:"status"`
-	LastAppliedAt    time.Time     `json:"last_applied_at,omitempty"`
-	AccessExpiresAt  time.Time     `json:"access_expires_at,omitempty"`
-	RefreshExpiresAt time.Time     `json:"refresh_expires_at,omitempty"`
-	LastRefreshAt    time.Time     `json:"last_refresh_at,omitempty"`
-	LastError        string        `json:"last_error,omitempty"`
-	Quota            QuotaSnapshot `json:"quota"`
-	CreatedAt        time.Time     `json:"created_at"`
-	UpdatedAt        time.Time     `json:"updated_at"`
+	ID               string            `json:"id"`
+	Provider         string            `json:"provider"`
+	Email            string            `json:"email,omitempty"`
+	Alias            string            `json:"alias,omitempty"`
+	Notes            string            `json:"notes,omitempty"`
+	ProviderMeta     map[string]string `json:"provider_meta,omitempty"`
+	Status           AccountStatus     `json:"status"`
+	Weight           int               `json:"weight,omitempty"`
+	Pinned           bool              `json:"pinned,omitempty"`
+	HTTPConfig       *HTTPClientConfig `json:"http_config,omitempty"`
+	Thresholds       *QuotaThresholds  `json:"thresholds,omitempty"`
+	LastAppliedAt    time.Time         `json:"last_applied_at,omitempty"`
+	AccessExpiresAt  time.Time         `json:"access_expires_at,omitempty"`
+	RefreshExpiresAt time.Time         `json:"refresh_expires_at,omitempty"`
+	LastRefreshAt    time.Time         `json:"last_refresh_at,omitempty"`
+	LastError        string            `json:"last_error,omitempty"`
+	LastWarningAt    time.Time         `json:"last_warning_at,omitzero"`
+	SwitchCount      int               `json:"switch_count"`
+	ErrorCount       int               `json:"error_count"`
+	Quota            QuotaSnapshot     `json:"quota"`
+	QuotaHistory     []QuotaSnapshot   `json:"quota_history,omitempty"`
+	CreatedAt        time.Time         `json:"created_at"`
+	UpdatedAt        time.Time         `json:"updated_at"`
+}
+
+// WarnThresholds returns the account's quota thresholds, falling back to
+// DefaultQuotaThresholds.
+func (a Account) WarnThresholds() QuotaThresholds {
+	if a.Thresholds == nil {
+		return DefaultQuotaThresholds
+	}
+	return *a.Thresholds
+}
+
+// HTTPClientConfig overrides how requests made on behalf of one account reach
+// the provider. The zero value means the shared default client.
+type HTTPClientConfig struct {
+	ProxyURL       string `json:"proxy_url,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+	SkipTLSVerify  bool   `json:"skip_tls_verify,omitempty"`
+}
+
+func (c HTTPClientConfig) IsZero() bool {
+	return c == HTTPClientConfig{}
 }
 
 type AuthSecrets struct {
@@ -2,18 +2,55 @@ package model
 
 import "time"
 
+// StateVersion is the schema version of AppState written by this build.
+const StateVersion = 2
+
 type AppState struct {
-	Version         int                `json:"version"`
-	ActiveAccountID string             `json:"active_account_id,omitempty"`
-	Strategy        RoutingStrategy    `json:"strategy"`
-	Accounts        map[string]Account `json:"accounts"`
-	UpdatedAt       time.Time          `json:"updated_at"`
+	Version           int                `json:"version"`
+	ActiveAccountID   string             `json:"active_account_id,omitempty"`
+	Strategy          RoutingStrategy    `json:"strategy"`
+	RoutingCursor     int                `json:"routing_cursor,omitempty"`
+	Priorities        []string           `json:"priorities,omitempty"`
+	Accounts          map[string]Account `json:"accounts"`
+	LastGlobalError   string             `json:"last_error,omitempty"`
+	LastGlobalErrorAt time.Time          `json:"last_error_at,omitzero"`
+	CooldownUntil     time.Time          `json:"cooldown_until,omitzero"`
+	SwitchHistory     []SwitchEvent      `json:"switch_history,omitempty"`
+	SwitchRules       SwitchRules        `json:"switch_rules"`
+	OAuthProviders    []OAuthProvider    `json:"oauth_providers,omitempty"`
+	UpdatedAt         time.Time          `json:"updated_at"`
+}
+
+// OAuthProvider is an OAuth provider registered at runtime through the API.
+type OAuthProvider struct {
+	Provider         string            `json:"provider"`
+	ClientID         string            `json:"client_id"`
+	AuthURL          string            `json:"auth_url"`
+	TokenURL         string            `json:"token_url"`
+	RedirectURI      string            `json:"redirect_uri,omitempty"`
+	RedirectURIs     []string          `json:"redirect_uris,omitempty"`
+	Scopes           []string          `json:"scopes,omitempty"`
+	AdditionalParams map[string]string `json:"additional_params,omitempty"`
+}
+
+type SwitchRules struct {
+	StatusCodes     []int    `json:"status_codes"`
+	MessagePatterns []string `json:"message_patterns"`
+}
+
+type SwitchEvent struct {
+	Timestamp     time.Time `json:"timestamp"`
+	FromAccountID string    `json:"from_account_id,omitempty"`
+	ToAccountID   string    `json:"to_account_id"`
+	Reason        string    `json:"reason"`
+	StatusCode    int       `json:"status_code,omitempty"`
+	ErrorMessage  string    `json:"error_message,omitempty"`
 }
 
 func DefaultState() AppState {
 	return AppState{
-		Version:  1,
-		Strategy: RoutingRoundRobin,
+		Version:  StateVersion,
+		Strategy: DefaultRoutingStrategy,
 		Accounts: map[string]Account{},
 	}
 }
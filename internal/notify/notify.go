@@ -0,0 +1,45 @@
+// Package notify shows OS-native desktop notifications by shelling out to the
+// platform's notification tool.
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const commandTimeout = 10 * time.Second
+
+// ErrUnsupported is returned on platforms without a notification tool.
+var ErrUnsupported = errors.New("desktop notifications are not supported on this platform")
+
+// Desktop sends notifications through the platform's notification tool.
+type Desktop struct {
+	// command builds the process to run; tests replace it.
+	command func(ctx context.Context, title, body string) *exec.Cmd
+}
+
+func NewDesktop() *Desktop {
+	return &Desktop{command: platformCommand}
+}
+
+func (d *Desktop) Notify(title, body string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	cmd := d.command(ctx, title, body)
+	if cmd == nil {
+		return ErrUnsupported
+	}
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s: %w: %s", cmd.Path, err, msg)
+		}
+		return fmt.Errorf("%s: %w", cmd.Path, err)
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build darwin
+
+package notify
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+func platformCommand(ctx context.Context, title, body string) *exec.Cmd {
+	script := "display notification " + appleScriptString(body) + " with title " + appleScriptString(title)
+	return exec.CommandContext(ctx, "osascript", "-e", script)
+}
+
+func appleScriptString(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, `"`, `\"`)
+	return `"` + s + `"`
+}
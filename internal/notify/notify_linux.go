@@ -0,0 +1,12 @@
+//go:build linux
+
+package notify
+
+import (
+	"context"
+	"os/exec"
+)
+
+func platformCommand(ctx context.Context, title, body string) *exec.Cmd {
+	return exec.CommandContext(ctx, "notify-send", "--app-name=Switchly", "--", title, body)
+}
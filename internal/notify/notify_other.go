@@ -0,0 +1,12 @@
+//go:build !darwin && !linux && !windows
+
+package notify
+
+import (
+	"context"
+	"os/exec"
+)
+
+func platformCommand(context.Context, string, string) *exec.Cmd {
+	return nil
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDesktopNotifyRunsCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	var gotTitle, gotBody string
+	d := &Desktop{command: func(ctx context.Context, title, body string) *exec.Cmd {
+		gotTitle, gotBody = title, body
+		return exec.CommandContext(ctx, "sh", "-c", "exit 0")
+	}}
+	if err := d.Notify("Switchly", "switched to B"); err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	if gotTitle != "Switchly" || gotBody != "switched to B" {
+		t.Fatalf("unexpected arguments: %q %q", gotTitle, gotBody)
+	}
+
+	d.command = func(ctx context.Context, _, _ string) *exec.Cmd {
+		return exec.CommandContext(ctx, "sh", "-c", "echo no display >&2; exit 1")
+	}
+	if err := d.Notify("Switchly", "body"); err == nil || !strings.Contains(err.Error(), "no display") {
+		t.Fatalf("expected command output in error, got %v", err)
+	}
+}
+
+func TestDesktopNotifyUnsupported(t *testing.T) {
+	d := &Desktop{command: func(context.Context, string, string) *exec.Cmd { return nil }}
+	if err := d.Notify("a", "b"); !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("expected ErrUnsupported, got %v", err)
+	}
+}
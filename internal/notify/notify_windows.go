@@ -0,0 +1,20 @@
+//go:build windows
+
+package notify
+
+import (
+	"context"
+	"os/exec"
+	"strings"
+)
+
+// platformCommand uses the BurntToast PowerShell module, which must be
+// installed (Install-Module BurntToast).
+func platformCommand(ctx context.Context, title, body string) *exec.Cmd {
+	script := "New-BurntToastNotification -Text " + powerShellString(title) + ", " + powerShellString(body)
+	return exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", script)
+}
+
+func powerShellString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
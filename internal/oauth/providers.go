@@ -0,0 +1,150 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+var (
+	ErrProviderNotFound = errors.New("oauth provider not found")
+	ErrBuiltinProvider  = errors.New("oauth provider is built in")
+)
+
+var providerNamePattern = regexp.MustCompile(`^[a-z0-9]+$`)
+
+// RegisterProvider adds or replaces a third-party provider and persists it
+// in the app state so it survives restarts. Built-in providers cannot be
+// replaced.
+func (s *Service) RegisterProvider(ctx context.Context, cfg ProviderConfig) (ProviderConfig, error) {
+	cfg.Provider = strings.TrimSpace(cfg.Provider)
+	cfg.ClientID = strings.TrimSpace(cfg.ClientID)
+	cfg.AuthURL = strings.TrimSpace(cfg.AuthURL)
+	cfg.TokenURL = strings.TrimSpace(cfg.TokenURL)
+	if !providerNamePattern.MatchString(cfg.Provider) {
+		return ProviderConfig{}, fmt.Errorf("provider name %q must be lower-case letters and digits", cfg.Provider)
+	}
+	if cfg.ClientID == "" {
+		return ProviderConfig{}, errors.New("client_id is required")
+	}
+	if err := validateProviderURL(cfg.AuthURL); err != nil {
+		return ProviderConfig{}, fmt.Errorf("auth_url: %w", err)
+	}
+	if err := validateProviderURL(cfg.TokenURL); err != nil {
+		return ProviderConfig{}, fmt.Errorf("token_url: %w", err)
+	}
+	// The unnormalized config is saved so a provider without redirect URIs
+	// keeps following the daemon's public base URL.
+	saved := providerToModel(cfg)
+	cfg = normalizeRedirectURIs(cfg, s.baseURL+"/auth/callback")
+	if err := validateProviderConfig(cfg); err != nil {
+		return ProviderConfig{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.builtin[cfg.Provider] {
+		return ProviderConfig{}, fmt.Errorf("%s: %w", cfg.Provider, ErrBuiltinProvider)
+	}
+	if s.manager != nil {
+		if err := s.manager.SaveOAuthProvider(ctx, saved); err != nil {
+			return ProviderConfig{}, fmt.Errorf("%w: %v", core.ErrPersistState, err)
+		}
+	}
+	s.providers[cfg.Provider] = cfg
+	return cfg, nil
+}
+
+// RemoveProvider deletes a provider added with RegisterProvider. Sessions
+// already started for it fail at the callback.
+func (s *Service) RemoveProvider(ctx context.Context, name string) error {
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.builtin[name] {
+		return fmt.Errorf("%s: %w", name, ErrBuiltinProvider)
+	}
+	if _, ok := s.providers[name]; !ok {
+		return fmt.Errorf("%s: %w", name, ErrProviderNotFound)
+	}
+	if s.manager != nil {
+		if err := s.manager.DeleteOAuthProvider(ctx, name); err != nil {
+			return fmt.Errorf("%w: %v", core.ErrPersistState, err)
+		}
+	}
+	delete(s.providers, name)
+	return nil
+}
+
+// loadRegisteredProviders restores the providers saved by RegisterProvider.
+// Entries that clash with a built-in provider or no longer validate are
+// skipped so a bad entry cannot keep the daemon from starting.
+func (s *Service) loadRegisteredProviders() error {
+	if s.manager == nil {
+		return nil
+	}
+	saved, err := s.manager.OAuthProviders(s.ctx)
+	if err != nil {
+		return fmt.Errorf("load oauth providers: %w", err)
+	}
+	for _, p := range saved {
+		if s.builtin[p.Provider] {
+			log.Printf("oauth: ignoring saved provider %s: name is built in", p.Provider)
+			continue
+		}
+		cfg := normalizeRedirectURIs(providerFromModel(p), s.baseURL+"/auth/callback")
+		if err := validateProviderConfig(cfg); err != nil {
+			log.Printf("oauth: ignoring saved provider %s: %v", p.Provider, err)
+			continue
+		}
+		s.providers[cfg.Provider] = cfg
+	}
+	return nil
+}
+
+func validateProviderURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid url %q: %w", raw, err)
+	}
+	if u.Scheme != "https" || u.Host == "" {
+		return fmt.Errorf("url %q must be an absolute https URL", raw)
+	}
+	return nil
+}
+
+func providerToModel(cfg ProviderConfig) model.OAuthProvider {
+	return model.OAuthProvider{
+		Provider:         cfg.Provider,
+		ClientID:         cfg.ClientID,
+		AuthURL:          cfg.AuthURL,
+		TokenURL:         cfg.TokenURL,
+		RedirectURI:      cfg.RedirectURI,
+		RedirectURIs:     slices.Clone(cfg.RedirectURIs),
+		Scopes:           slices.Clone(cfg.Scopes),
+		AdditionalParams: maps.Clone(cfg.AdditionalAuthParams),
+	}
+}
+
+func providerFromModel(p model.OAuthProvider) ProviderConfig {
+	return ProviderConfig{
+		Provider:             p.Provider,
+		ClientID:             p.ClientID,
+		AuthURL:              p.AuthURL,
+		TokenURL:             p.TokenURL,
+		RedirectURI:          p.RedirectURI,
+		RedirectURIs:         slices.Clone(p.RedirectURIs),
+		Scopes:               slices.Clone(p.Scopes),
+		AdditionalAuthParams: maps.Clone(p.AdditionalParams),
+	}
+}
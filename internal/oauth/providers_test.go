@@ -0,0 +1,97 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"switchly/internal/core"
+	"switchly/internal/store"
+)
+
+func TestRegisterProviderPersistsAcrossRestarts(t *testing.T) {
+	t.Setenv(store.StateFileEnv, filepath.Join(t.TempDir(), "state.json"))
+	stateStore, err := store.NewStateStore()
+	if err != nil {
+		t.Fatalf("new state store: %v", err)
+	}
+	mgr := core.NewManager(stateStore, nil)
+
+	svc, err := NewService(mgr, "http://localhost:7777")
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	cfg, err := svc.RegisterProvider(context.Background(), ProviderConfig{
+		Provider:             "acme",
+		ClientID:             "acme-client",
+		AuthURL:              "https://auth.acme.test/authorize",
+		TokenURL:             "https://auth.acme.test/token",
+		Scopes:               []string{"openid"},
+		AdditionalAuthParams: map[string]string{"audience": "api"},
+	})
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if cfg.RedirectURI != "http://localhost:7777/auth/callback" {
+		t.Fatalf("expected the default callback, got %q", cfg.RedirectURI)
+	}
+
+	restarted, err := NewService(mgr, "http://localhost:7777")
+	if err != nil {
+		t.Fatalf("new service after restart: %v", err)
+	}
+	snap, err := restarted.Start("acme")
+	if err != nil {
+		t.Fatalf("start registered provider: %v", err)
+	}
+	if !strings.HasPrefix(snap.AuthURL, "https://auth.acme.test/authorize?") || !strings.Contains(snap.AuthURL, "audience=api") {
+		t.Fatalf("unexpected auth url: %s", snap.AuthURL)
+	}
+
+	if err := restarted.RemoveProvider(context.Background(), "acme"); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	providers, err := mgr.OAuthProviders(context.Background())
+	if err != nil {
+		t.Fatalf("list persisted providers: %v", err)
+	}
+	if len(providers) != 0 {
+		t.Fatalf("expected the provider to be removed from state, got %#v", providers)
+	}
+}
+
+func TestRegisterProviderValidates(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777")
+	valid := ProviderConfig{
+		Provider: "acme",
+		ClientID: "acme-client",
+		AuthURL:  "https://auth.acme.test/authorize",
+		TokenURL: "https://auth.acme.test/token",
+	}
+	for name, mutate := range map[string]func(*ProviderConfig){
+		"bad name":      func(c *ProviderConfig) { c.Provider = "Acme Corp" },
+		"no client id":  func(c *ProviderConfig) { c.ClientID = "" },
+		"http auth url": func(c *ProviderConfig) { c.AuthURL = "http://auth.acme.test/authorize" },
+		"no token url":  func(c *ProviderConfig) { c.TokenURL = "" },
+	} {
+		cfg := valid
+		mutate(&cfg)
+		if _, err := svc.RegisterProvider(context.Background(), cfg); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+
+	builtin := valid
+	builtin.Provider = "codex"
+	if _, err := svc.RegisterProvider(context.Background(), builtin); !errors.Is(err, ErrBuiltinProvider) {
+		t.Fatalf("expected ErrBuiltinProvider when replacing codex, got %v", err)
+	}
+	if err := svc.RemoveProvider(context.Background(), "codex"); !errors.Is(err, ErrBuiltinProvider) {
+		t.Fatalf("expected ErrBuiltinProvider when removing codex, got %v", err)
+	}
+	if err := svc.RemoveProvider(context.Background(), "missing"); !errors.Is(err, ErrProviderNotFound) {
+		t.Fatalf("expected ErrProviderNotFound, got %v", err)
+	}
+}
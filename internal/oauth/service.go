@@ -12,6 +12,8 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
 	"time"
@@ -23,20 +25,29 @@ import (
 type SessionStatus string
 
 const (
-	SessionPending SessionStatus = "pending"
-	SessionSuccess SessionStatus = "success"
-	SessionError   SessionStatus = "error"
-	SessionExpired SessionStatus = "expired"
+	SessionPending   SessionStatus = "pending"
+	SessionSuccess   SessionStatus = "success"
+	SessionError     SessionStatus = "error"
+	SessionExpired   SessionStatus = "expired"
+	SessionCancelled SessionStatus = "cancelled"
+)
+
+var (
+	ErrSessionNotFound = errors.New("state not found")
+	ErrSessionFinished = errors.New("oauth session already finished")
 )
 
 type ProviderConfig struct {
-	Provider             string
-	ClientID             string
-	AuthURL              string
-	TokenURL             string
-	RedirectURI          string
-	Scopes               []string
-	AdditionalAuthParams map[string]string
+	Provider    string `json:"provider"`
+	ClientID    string `json:"client_id"`
+	AuthURL     string `json:"auth_url"`
+	TokenURL    string `json:"token_url"`
+	RedirectURI string `json:"redirect_uri,omitempty"`
+	// RedirectURIs are tried in order when the callback listener for an
+	// earlier one cannot be bound. RedirectURI, if set, is tried first.
+	RedirectURIs         []string          `json:"redirect_uris,omitempty"`
+	Scopes               []string          `json:"scopes,omitempty"`
+	AdditionalAuthParams map[string]string `json:"additional_params,omitempty"`
 }
 
 type CallbackLeaseManager interface {
@@ -46,14 +57,25 @@ type CallbackLeaseManager interface {
 
 type ServiceOption func(*Service)
 
+const (
+	defaultSessionGCInterval = 5 * time.Minute
+	defaultSessionTTL        = 30 * time.Minute
+	// Cancelled sessions stay visible this long so a polling client can
+	// observe the cancellation before the session is removed.
+	defaultCancelGracePeriod = 30 * time.Second
+)
+
 type SessionSnapshot struct {
 	State     string        `json:"state"`
 	Provider  string        `json:"provider"`
 	Status    SessionStatus `json:"status"`
 	AuthURL   string        `json:"auth_url,omitempty"`
 	AccountID string        `json:"account_id,omitempty"`
-	Error     string        `json:"error,omitempty"`
-	ExpiresAt time.Time     `json:"expires_at"`
+	// ExpectedAccountID is the account the login will add, known once the
+	// callback's token exchange has identified the user.
+	ExpectedAccountID string    `json:"expected_account_id,omitempty"`
+	Error             string    `json:"error,omitempty"`
+	ExpiresAt         time.Time `json:"expires_at"`
 }
 
 type session struct {
@@ -70,27 +92,169 @@ type Service struct {
 	providers  map[string]ProviderConfig
 	sessions   map[string]*session
 	callbacks  CallbackLeaseManager
+	persister  SessionPersister
+	// builtin names the providers configured in code, which cannot be
+	// replaced or removed through RegisterProvider and RemoveProvider.
+	builtin map[string]bool
+
+	// inflight counts running callbacks. Once draining is set no new
+	// callback is admitted, so Add never races WaitForPendingCallbacks.
+	inflight sync.WaitGroup
+	draining bool
+
+	ctx         context.Context
+	stop        context.CancelFunc
+	sessionTTL  time.Duration
+	gcInterval  time.Duration
+	cancelGrace time.Duration
 }
 
-func NewService(manager *core.Manager, baseURL string, opts ...ServiceOption) *Service {
+// NewService returns an error when a provider's redirect URI is not a usable
+// http(s) callback URL. A provider without one uses baseURL + "/auth/callback".
+func NewService(manager *core.Manager, baseURL string, opts ...ServiceOption) (*Service, error) {
 	providers := map[string]ProviderConfig{}
 	for _, p := range defaultProviders() {
 		providers[p.Provider] = p
 	}
 
 	svc := &Service{
-		manager:    manager,
-		httpClient: &http.Client{Timeout: 20 * time.Second},
-		baseURL:    strings.TrimRight(baseURL, "/"),
-		providers:  providers,
-		sessions:   map[string]*session{},
+		manager:     manager,
+		httpClient:  &http.Client{Timeout: 20 * time.Second},
+		baseURL:     strings.TrimRight(baseURL, "/"),
+		providers:   providers,
+		sessions:    map[string]*session{},
+		ctx:         context.Background(),
+		sessionTTL:  defaultSessionTTL,
+		gcInterval:  defaultSessionGCInterval,
+		cancelGrace: defaultCancelGracePeriod,
 	}
 	for _, opt := range opts {
 		if opt != nil {
 			opt(svc)
 		}
 	}
-	return svc
+	for name, cfg := range svc.providers {
+		cfg = normalizeRedirectURIs(cfg, svc.baseURL+"/auth/callback")
+		if err := validateProviderConfig(cfg); err != nil {
+			return nil, fmt.Errorf("oauth provider %s: %w", name, err)
+		}
+		svc.providers[name] = cfg
+	}
+	svc.builtin = make(map[string]bool, len(svc.providers))
+	for name := range svc.providers {
+		svc.builtin[name] = true
+	}
+	if err := svc.loadRegisteredProviders(); err != nil {
+		return nil, err
+	}
+	svc.restoreSessions()
+	svc.ctx, svc.stop = context.WithCancel(svc.ctx)
+	svc.startSessionGC(svc.gcInterval)
+	return svc, nil
+}
+
+// normalizeRedirectURIs merges RedirectURI into RedirectURIs, dropping blanks
+// and duplicates, and falls back to fallback when none is configured.
+// RedirectURI is left as the first candidate.
+func normalizeRedirectURIs(cfg ProviderConfig, fallback string) ProviderConfig {
+	candidates := append([]string{cfg.RedirectURI}, cfg.RedirectURIs...)
+	uris := make([]string, 0, len(candidates))
+	for _, raw := range candidates {
+		uri := strings.TrimSpace(raw)
+		if uri != "" && !slices.Contains(uris, uri) {
+			uris = append(uris, uri)
+		}
+	}
+	if len(uris) == 0 {
+		uris = append(uris, fallback)
+	}
+	cfg.RedirectURI = uris[0]
+	cfg.RedirectURIs = uris
+	return cfg
+}
+
+// validateProviderConfig catches redirect URIs the authorization server would
+// reject, so a misconfiguration fails at startup instead of mid-login.
+func validateProviderConfig(cfg ProviderConfig) error {
+	uris := cfg.RedirectURIs
+	if len(uris) == 0 {
+		uris = []string{cfg.RedirectURI}
+	}
+	for _, uri := range uris {
+		if err := validateRedirectURI(uri); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateRedirectURI(raw string) error {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return errors.New("redirect URI is empty")
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid redirect URI %q: %w", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("redirect URI %q must use http or https", raw)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("redirect URI %q has no host", raw)
+	}
+	if u.Path == "" || u.Path == "/" {
+		return fmt.Errorf("redirect URI %q has no callback path", raw)
+	}
+	return nil
+}
+
+// WithContext bounds the lifetime of the service's background work.
+func WithContext(ctx context.Context) ServiceOption {
+	return func(s *Service) {
+		if ctx != nil {
+			s.ctx = ctx
+		}
+	}
+}
+
+// WithSessionTTL sets how long finished or expired sessions are kept after
+// their expiry before being garbage collected.
+func WithSessionTTL(ttl time.Duration) ServiceOption {
+	return func(s *Service) {
+		if ttl >= 0 {
+			s.sessionTTL = ttl
+		}
+	}
+}
+
+func WithSessionGCInterval(interval time.Duration) ServiceOption {
+	return func(s *Service) {
+		if interval > 0 {
+			s.gcInterval = interval
+		}
+	}
+}
+
+// WithCancelGracePeriod sets how long a cancelled session is kept before it
+// is removed.
+func WithCancelGracePeriod(d time.Duration) ServiceOption {
+	return func(s *Service) {
+		if d >= 0 {
+			s.cancelGrace = d
+		}
+	}
+}
+
+// WithProviderConfig adds a provider or replaces the default one with the
+// same name.
+func WithProviderConfig(cfg ProviderConfig) ServiceOption {
+	return func(s *Service) {
+		cfg.Provider = strings.ToLower(strings.TrimSpace(cfg.Provider))
+		if cfg.Provider != "" {
+			s.providers[cfg.Provider] = cfg
+		}
+	}
 }
 
 func WithCallbackLeaseManager(manager CallbackLeaseManager) ServiceOption {
@@ -99,6 +263,14 @@ func WithCallbackLeaseManager(manager CallbackLeaseManager) ServiceOption {
 	}
 }
 
+// WithSessionPersister saves sessions as they change and restores them in
+// NewService, so a login started before a restart can still complete.
+func WithSessionPersister(p SessionPersister) ServiceOption {
+	return func(s *Service) {
+		s.persister = p
+	}
+}
+
 func defaultProviders() []ProviderConfig {
 	return []ProviderConfig{
 		{
@@ -132,11 +304,7 @@ func (s *Service) RedirectURIs() []string {
 	defer s.mu.Unlock()
 	out := make([]string, 0, len(s.providers))
 	for _, cfg := range s.providers {
-		uri := strings.TrimSpace(cfg.RedirectURI)
-		if uri == "" {
-			uri = s.baseURL + "/auth/callback"
-		}
-		out = append(out, uri)
+		out = append(out, cfg.RedirectURIs...)
 	}
 	return out
 }
@@ -160,14 +328,12 @@ func (s *Service) Start(provider string) (SessionSnapshot, error) {
 	}
 	challenge := pkceS256(verifier)
 
-	redirectURI := strings.TrimSpace(cfg.RedirectURI)
-	if redirectURI == "" {
-		redirectURI = s.baseURL + "/auth/callback"
+	if err := validateProviderConfig(cfg); err != nil {
+		return SessionSnapshot{}, fmt.Errorf("oauth provider %s: %w", cfg.Provider, err)
 	}
-	if s.callbacks != nil {
-		if err := s.callbacks.Acquire(redirectURI, http.HandlerFunc(s.HandleCallback)); err != nil {
-			return SessionSnapshot{}, fmt.Errorf("reserve oauth callback listener: %w", err)
-		}
+	redirectURI, err := s.acquireRedirectURILocked(cfg)
+	if err != nil {
+		return SessionSnapshot{}, err
 	}
 	q := url.Values{}
 	q.Set("response_type", "code")
@@ -190,40 +356,126 @@ func (s *Service) Start(provider string) (SessionSnapshot, error) {
 		ExpiresAt: time.Now().UTC().Add(10 * time.Minute),
 	}
 	s.sessions[state] = &session{SessionSnapshot: snap, codeVerifier: verifier, redirectURI: redirectURI}
+	s.persistSessionsLocked()
 	go s.expireSession(state, snap.ExpiresAt)
 	return snap, nil
 }
 
+// acquireRedirectURILocked reserves the callback listener of the first
+// redirect URI that can be bound. Without a lease manager the first URI is
+// used as is.
+func (s *Service) acquireRedirectURILocked(cfg ProviderConfig) (string, error) {
+	if s.callbacks == nil {
+		return cfg.RedirectURIs[0], nil
+	}
+	var lastErr error
+	failures := make([]string, 0, len(cfg.RedirectURIs))
+	for _, uri := range cfg.RedirectURIs {
+		lastErr = s.callbacks.Acquire(uri, http.HandlerFunc(s.HandleCallback))
+		if lastErr == nil {
+			return uri, nil
+		}
+		failures = append(failures, fmt.Sprintf("%s (%v)", uri, lastErr))
+	}
+	if len(failures) == 1 {
+		return "", fmt.Errorf("reserve oauth callback listener: %w", lastErr)
+	}
+	return "", fmt.Errorf("reserve oauth callback listener: tried %s", strings.Join(failures, ", "))
+}
+
 func (s *Service) Status(state string) (SessionSnapshot, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	sess, ok := s.sessions[state]
 	if !ok {
-		return SessionSnapshot{}, errors.New("state not found")
+		return SessionSnapshot{}, ErrSessionNotFound
+	}
+	s.expireIfOverdueLocked(sess, time.Now().UTC())
+	return sess.SessionSnapshot, nil
+}
+
+// Sessions lists the sessions the service still tracks, optionally only
+// those with the given status, ordered by expiry. The lock is held only
+// while copying.
+func (s *Service) Sessions(status SessionStatus) []SessionSnapshot {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	out := make([]SessionSnapshot, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		s.expireIfOverdueLocked(sess, now)
+		out = append(out, sess.SessionSnapshot)
+	}
+	s.mu.Unlock()
+
+	if status != "" {
+		out = slices.DeleteFunc(out, func(snap SessionSnapshot) bool { return snap.Status != status })
 	}
-	if sess.Status == SessionPending && time.Now().UTC().After(sess.ExpiresAt) {
+	sort.Slice(out, func(i, j int) bool {
+		if !out[i].ExpiresAt.Equal(out[j].ExpiresAt) {
+			return out[i].ExpiresAt.Before(out[j].ExpiresAt)
+		}
+		return out[i].State < out[j].State
+	})
+	return out
+}
+
+func (s *Service) expireIfOverdueLocked(sess *session, now time.Time) {
+	if sess.Status == SessionPending && now.After(sess.ExpiresAt) {
 		sess.Status = SessionExpired
 		sess.Error = "oauth session expired"
 		s.releaseCallbackLocked(sess)
 	}
-	return sess.SessionSnapshot, nil
 }
 
+// ValidSessionStatus reports whether status is one a session can have.
+func ValidSessionStatus(status SessionStatus) bool {
+	switch status {
+	case SessionPending, SessionSuccess, SessionError, SessionExpired, SessionCancelled:
+		return true
+	}
+	return false
+}
+
+// Cancel aborts a pending login. The session is marked cancelled and its
+// callback released at once, then removed after the cancel grace period.
 func (s *Service) Cancel(state string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
 	sess, ok := s.sessions[state]
 	if !ok {
-		return errors.New("state not found")
+		return ErrSessionNotFound
 	}
+	s.expireIfOverdueLocked(sess, time.Now().UTC())
+	if sess.Status != SessionPending {
+		return fmt.Errorf("%w: %s", ErrSessionFinished, sess.Status)
+	}
+	sess.Status = SessionCancelled
+	sess.Error = "oauth session cancelled"
 	s.releaseCallbackLocked(sess)
-	delete(s.sessions, state)
+	s.persistSessionsLocked()
+	time.AfterFunc(s.cancelGrace, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.sessions[state] == sess {
+			delete(s.sessions, state)
+		}
+	})
 	return nil
 }
 
 func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	if s.draining {
+		s.mu.Unlock()
+		writeOAuthHTML(w, false, "Switchly is shutting down; retry the login once it is running again")
+		return
+	}
+	s.inflight.Add(1)
+	s.mu.Unlock()
+	defer s.inflight.Done()
+
 	state := r.URL.Query().Get("state")
 	if state == "" {
 		writeOAuthHTML(w, false, "missing state")
@@ -237,6 +489,11 @@ func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
 		writeOAuthHTML(w, false, "unknown state")
 		return
 	}
+	if sess.Status == SessionCancelled {
+		s.mu.Unlock()
+		writeOAuthHTML(w, false, sess.Error)
+		return
+	}
 	cfg, ok := s.providers[sess.Provider]
 	if !ok {
 		sess.Status = SessionError
@@ -252,6 +509,17 @@ func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
 		writeOAuthHTML(w, false, sess.Error)
 		return
 	}
+	// The token endpoint requires the redirect URI the login started with.
+	redirectURI := sess.redirectURI
+	if redirectURI == "" {
+		redirectURI = cfg.RedirectURI
+	} else if !slices.Contains(cfg.RedirectURIs, redirectURI) {
+		s.mu.Unlock()
+		msg := "oauth redirect URI changed since the login started; please retry"
+		s.failSession(state, msg)
+		writeOAuthHTML(w, false, msg)
+		return
+	}
 	s.mu.Unlock()
 
 	if errMsg := r.URL.Query().Get("error"); errMsg != "" {
@@ -267,7 +535,7 @@ func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	tokens, err := s.exchangeCode(r.Context(), cfg, code, sess.codeVerifier)
+	tokens, err := s.exchangeCode(r.Context(), cfg, redirectURI, code, sess.codeVerifier)
 	if err != nil {
 		log.Printf("oauth callback token exchange failed provider=%s state=%s err=%v", cfg.Provider, state, err)
 		s.failSession(state, err.Error())
@@ -276,7 +544,8 @@ func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	email, tokenAccountID := decodeIdentityFromIDToken(tokens.IDToken)
-	accountID := buildAccountID(cfg.Provider, email, tokenAccountID)
+	accountID := buildAccountID(cfg.Provider, email, tokenAccountID, state)
+	s.setExpectedAccount(state, accountID)
 
 	acct, err := s.manager.AddAccount(r.Context(), core.AddAccountInput{
 		ID:       accountID,
@@ -303,6 +572,26 @@ func (s *Service) HandleCallback(w http.ResponseWriter, r *http.Request) {
 	writeOAuthHTML(w, true, "Switchly login succeeded. You can close this tab.")
 }
 
+// WaitForPendingCallbacks stops admitting OAuth callbacks and waits for the
+// running ones to finish storing their accounts, or for ctx to end.
+func (s *Service) WaitForPendingCallbacks(ctx context.Context) error {
+	s.mu.Lock()
+	s.draining = true
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.inflight.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("wait for oauth callbacks: %w", ctx.Err())
+	}
+}
+
 func classifyAddAccountError(err error) (message, stage string) {
 	if errors.Is(err, core.ErrPersistSecrets) {
 		return "failed to store OAuth credentials locally", "secret_persist"
@@ -320,6 +609,15 @@ func (s *Service) failSession(state, msg string) {
 		sess.Status = SessionError
 		sess.Error = msg
 		s.releaseCallbackLocked(sess)
+		s.persistSessionsLocked()
+	}
+}
+
+func (s *Service) setExpectedAccount(state, accountID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sess, ok := s.sessions[state]; ok {
+		sess.ExpectedAccountID = accountID
 	}
 }
 
@@ -331,6 +629,7 @@ func (s *Service) completeSession(state, accountID string) {
 		sess.AccountID = accountID
 		sess.Error = ""
 		s.releaseCallbackLocked(sess)
+		s.persistSessionsLocked()
 	}
 }
 
@@ -360,6 +659,109 @@ func (s *Service) expireSession(state string, expiresAt time.Time) {
 	sess.Status = SessionExpired
 	sess.Error = "oauth session expired"
 	s.releaseCallbackLocked(sess)
+	s.persistSessionsLocked()
+}
+
+// Stop ends the session garbage collector.
+func (s *Service) Stop() {
+	s.stop()
+}
+
+func (s *Service) startSessionGC(interval time.Duration) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-s.ctx.Done():
+				return
+			case now := <-ticker.C:
+				s.collectSessions(now.UTC())
+			}
+		}
+	}()
+}
+
+func (s *Service) collectSessions(now time.Time) {
+	cutoff := now.Add(-s.sessionTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	removed := false
+	for state, sess := range s.sessions {
+		if !sess.ExpiresAt.Before(cutoff) {
+			continue
+		}
+		s.releaseCallbackLocked(sess)
+		delete(s.sessions, state)
+		removed = true
+	}
+	if removed {
+		s.persistSessionsLocked()
+	}
+}
+
+// persistSessionsLocked saves every tracked session. A failed save is only
+// logged: the login itself can still finish while the daemon keeps running.
+func (s *Service) persistSessionsLocked() {
+	if s.persister == nil {
+		return
+	}
+	saved := make([]PersistedSession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		saved = append(saved, PersistedSession{
+			SessionSnapshot: sess.SessionSnapshot,
+			CodeVerifier:    sess.codeVerifier,
+			RedirectURI:     sess.redirectURI,
+		})
+	}
+	sort.Slice(saved, func(i, j int) bool { return saved[i].State < saved[j].State })
+	if err := s.persister.Save(saved); err != nil {
+		log.Printf("oauth: persist sessions: %v", err)
+	}
+}
+
+// restoreSessions loads the persisted sessions. Pending ones that have
+// passed their expiry are marked expired; the rest get their callback
+// listener back and resume waiting for the browser.
+func (s *Service) restoreSessions() {
+	if s.persister == nil {
+		return
+	}
+	saved, err := s.persister.Load()
+	if err != nil {
+		log.Printf("oauth: ignoring saved sessions: %v", err)
+		return
+	}
+	now := time.Now().UTC()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range saved {
+		if p.State == "" {
+			continue
+		}
+		sess := &session{SessionSnapshot: p.SessionSnapshot, codeVerifier: p.CodeVerifier, redirectURI: p.RedirectURI}
+		if sess.Status == SessionPending {
+			switch {
+			case now.After(sess.ExpiresAt):
+				sess.Status = SessionExpired
+				sess.Error = "oauth session expired"
+				sess.redirectURI = ""
+			case s.callbacks != nil && sess.redirectURI != "":
+				if err := s.callbacks.Acquire(sess.redirectURI, http.HandlerFunc(s.HandleCallback)); err != nil {
+					sess.Status = SessionError
+					sess.Error = fmt.Sprintf("reserve oauth callback listener: %v", err)
+					sess.redirectURI = ""
+				}
+			}
+			if sess.Status == SessionPending {
+				go s.expireSession(sess.State, sess.ExpiresAt)
+			}
+		}
+		s.sessions[sess.State] = sess
+	}
+	s.persistSessionsLocked()
 }
 
 type tokenResponse struct {
@@ -370,11 +772,7 @@ type tokenResponse struct {
 	RefreshTokenExpiresIn int    `json:"refresh_token_expires_in"`
 }
 
-func (s *Service) exchangeCode(ctx context.Context, cfg ProviderConfig, code, verifier string) (model.AuthSecrets, error) {
-	redirectURI := strings.TrimSpace(cfg.RedirectURI)
-	if redirectURI == "" {
-		redirectURI = s.baseURL + "/auth/callback"
-	}
+func (s *Service) exchangeCode(ctx context.Context, cfg ProviderConfig, redirectURI, code, verifier string) (model.AuthSecrets, error) {
 	values := url.Values{}
 	values.Set("grant_type", "authorization_code")
 	values.Set("code", code)
@@ -426,7 +824,10 @@ func (s *Service) exchangeCode(ctx context.Context, cfg ProviderConfig, code, ve
 	return out, nil
 }
 
-func buildAccountID(provider, email, accountID string) string {
+// buildAccountID derives the account ID from the token identity. Without
+// one it falls back to a timestamp plus part of the session state, so two
+// anonymous logins finishing in the same second get different accounts.
+func buildAccountID(provider, email, accountID, state string) string {
 	if email != "" {
 		return fmt.Sprintf("%s:%s", provider, strings.ToLower(strings.TrimSpace(email)))
 	}
@@ -434,7 +835,10 @@ func buildAccountID(provider, email, accountID string) string {
 		return fmt.Sprintf("%s:%s", provider, accountID)
 	}
 	stamp := time.Now().UTC().Format("20060102150405")
-	return fmt.Sprintf("%s:%s", provider, stamp)
+	if len(state) > 8 {
+		state = state[:8]
+	}
+	return fmt.Sprintf("%s:%s-%s", provider, stamp, state)
 }
 
 func decodeIdentityFromIDToken(idToken string) (email, accountID string) {
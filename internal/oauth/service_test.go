@@ -1,15 +1,23 @@
 package oauth
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"switchly/internal/core"
+	"switchly/internal/model"
+	"switchly/internal/store"
 )
 
 func TestPKCES256RFCExample(t *testing.T) {
@@ -86,7 +94,7 @@ func TestClassifyAddAccountError(t *testing.T) {
 
 func TestStartAcquiresAndCancelReleasesCallbackLease(t *testing.T) {
 	manager := &fakeCallbackLeaseManager{}
-	svc := NewService(nil, "http://localhost:7777", WithCallbackLeaseManager(manager))
+	svc := mustNewService(t, "http://localhost:7777", WithCallbackLeaseManager(manager))
 
 	snap, err := svc.Start("codex")
 	if err != nil {
@@ -103,9 +111,62 @@ func TestStartAcquiresAndCancelReleasesCallbackLease(t *testing.T) {
 	}
 }
 
+func TestCancelMarksSessionThenRemovesIt(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777", WithCancelGracePeriod(20*time.Millisecond))
+
+	snap, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := svc.Cancel(snap.State); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	got, err := svc.Status(snap.State)
+	if err != nil {
+		t.Fatalf("status after cancel: %v", err)
+	}
+	if got.Status != SessionCancelled || got.Error == "" {
+		t.Fatalf("expected a cancelled session, got %#v", got)
+	}
+	if err := svc.Cancel(snap.State); !errors.Is(err, ErrSessionFinished) {
+		t.Fatalf("expected a second cancel to fail with ErrSessionFinished, got %v", err)
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, err := svc.Status(snap.State); errors.Is(err, ErrSessionNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the cancelled session to be removed after the grace period")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if err := svc.Cancel("unknown"); !errors.Is(err, ErrSessionNotFound) {
+		t.Fatalf("expected ErrSessionNotFound, got %v", err)
+	}
+}
+
+func TestCallbackRejectsCancelledSession(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777")
+	snap, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := svc.Cancel(snap.State); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+
+	rec := httptest.NewRecorder()
+	svc.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state="+snap.State+"&code=abc", nil))
+	if got, _ := svc.Status(snap.State); got.Status != SessionCancelled {
+		t.Fatalf("expected the session to stay cancelled, got %#v", got)
+	}
+}
+
 func TestStartReleasesCallbackLeaseOnExpire(t *testing.T) {
 	manager := &fakeCallbackLeaseManager{}
-	svc := NewService(nil, "http://localhost:7777", WithCallbackLeaseManager(manager))
+	svc := mustNewService(t, "http://localhost:7777", WithCallbackLeaseManager(manager))
 
 	svc.mu.Lock()
 	svc.sessions["expired"] = &session{
@@ -129,7 +190,7 @@ func TestStartReleasesCallbackLeaseOnExpire(t *testing.T) {
 
 func TestStartReturnsAcquireError(t *testing.T) {
 	manager := &fakeCallbackLeaseManager{acquireErr: errors.New("port busy")}
-	svc := NewService(nil, "http://localhost:7777", WithCallbackLeaseManager(manager))
+	svc := mustNewService(t, "http://localhost:7777", WithCallbackLeaseManager(manager))
 
 	_, err := svc.Start("codex")
 	if err == nil || err.Error() != "reserve oauth callback listener: port busy" {
@@ -137,16 +198,66 @@ func TestStartReturnsAcquireError(t *testing.T) {
 	}
 }
 
+func TestSessionGCRemovesExpiredSessions(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777", WithSessionGCInterval(10*time.Millisecond))
+	defer svc.Stop()
+
+	expiredAt := time.Now().UTC().Add(-time.Hour)
+	svc.mu.Lock()
+	for i := 0; i < 1000; i++ {
+		state := fmt.Sprintf("expired-%d", i)
+		svc.sessions[state] = &session{
+			SessionSnapshot: SessionSnapshot{State: state, Provider: "codex", Status: SessionExpired, ExpiresAt: expiredAt},
+		}
+	}
+	svc.mu.Unlock()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		svc.mu.Lock()
+		remaining := len(svc.sessions)
+		svc.mu.Unlock()
+		if remaining == 0 {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected sessions to be collected, %d remain", remaining)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestSessionGCKeepsSessionsWithinTTL(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777")
+	svc.Stop()
+
+	now := time.Now().UTC()
+	svc.sessions["recent"] = &session{SessionSnapshot: SessionSnapshot{State: "recent", ExpiresAt: now.Add(-10 * time.Minute)}}
+	svc.sessions["stale"] = &session{SessionSnapshot: SessionSnapshot{State: "stale", ExpiresAt: now.Add(-31 * time.Minute)}}
+	svc.collectSessions(now)
+
+	if _, ok := svc.sessions["recent"]; !ok {
+		t.Fatal("expected recently expired session to be kept")
+	}
+	if _, ok := svc.sessions["stale"]; ok {
+		t.Fatal("expected stale session to be removed")
+	}
+}
+
 type fakeCallbackLeaseManager struct {
-	acquired   []string
-	released   []string
-	acquireErr error
+	acquired        []string
+	released        []string
+	acquireErr      error
+	acquireErrByURI map[string]error
 }
 
 func (f *fakeCallbackLeaseManager) Acquire(redirectURI string, _ http.Handler) error {
 	if f.acquireErr != nil {
 		return f.acquireErr
 	}
+	if err := f.acquireErrByURI[redirectURI]; err != nil {
+		return err
+	}
 	f.acquired = append(f.acquired, redirectURI)
 	return nil
 }
@@ -156,3 +267,355 @@ func (f *fakeCallbackLeaseManager) Release(redirectURI string) {
 }
 
 var _ CallbackLeaseManager = (*fakeCallbackLeaseManager)(nil)
+
+func mustNewService(t *testing.T, baseURL string, opts ...ServiceOption) *Service {
+	t.Helper()
+	svc, err := NewService(nil, baseURL, opts...)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	return svc
+}
+
+func TestValidateProviderConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		uri     string
+		wantErr string
+	}{
+		{name: "valid http", uri: "http://localhost:1455/auth/callback"},
+		{name: "valid https", uri: "https://switchly.example.com/auth/callback"},
+		{name: "empty", uri: "  ", wantErr: "empty"},
+		{name: "non-http scheme", uri: "ftp://localhost/auth/callback", wantErr: "http or https"},
+		{name: "no host", uri: "http:///auth/callback", wantErr: "no host"},
+		{name: "no path", uri: "http://localhost:1455", wantErr: "no callback path"},
+		{name: "unparseable", uri: "http://local host/cb", wantErr: "invalid redirect URI"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateProviderConfig(ProviderConfig{Provider: "codex", RedirectURI: tt.uri})
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("expected valid config, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestNewServiceValidatesProviders(t *testing.T) {
+	_, err := NewService(nil, "http://localhost:7777", WithProviderConfig(ProviderConfig{Provider: "codex", RedirectURI: "localhost:1455/auth/callback"}))
+	if err == nil || !strings.Contains(err.Error(), "oauth provider codex") {
+		t.Fatalf("expected invalid provider error, got %v", err)
+	}
+
+	// An empty redirect URI falls back to the base URL, which must be usable.
+	svc := mustNewService(t, "http://localhost:7777/", WithProviderConfig(ProviderConfig{Provider: "codex"}))
+	defer svc.Stop()
+	if uris := svc.RedirectURIs(); len(uris) != 1 || uris[0] != "http://localhost:7777/auth/callback" {
+		t.Fatalf("unexpected redirect URIs: %v", uris)
+	}
+	if _, err := NewService(nil, "", WithProviderConfig(ProviderConfig{Provider: "codex"})); err == nil {
+		t.Fatal("expected an error for an empty base URL fallback")
+	}
+}
+
+func TestHandleCallbackRejectsChangedRedirectURI(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777")
+	defer svc.Stop()
+	snap, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+
+	svc.mu.Lock()
+	cfg := svc.providers["codex"]
+	cfg.RedirectURI = "http://localhost:1456/auth/callback"
+	cfg.RedirectURIs = []string{cfg.RedirectURI}
+	svc.providers["codex"] = cfg
+	svc.mu.Unlock()
+
+	rec := httptest.NewRecorder()
+	svc.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state="+snap.State+"&code=abc", nil))
+	status, err := svc.Status(snap.State)
+	if err != nil {
+		t.Fatalf("status: %v", err)
+	}
+	if status.Status != SessionError || !strings.Contains(status.Error, "redirect URI changed") {
+		t.Fatalf("expected redirect mismatch failure, got %#v", status)
+	}
+}
+
+func TestStartFallsBackToNextRedirectURI(t *testing.T) {
+	busy := "http://localhost:1455/auth/callback"
+	manager := &fakeCallbackLeaseManager{acquireErrByURI: map[string]error{busy: errors.New("port busy")}}
+	svc := mustNewService(t, "http://localhost:7777",
+		WithCallbackLeaseManager(manager),
+		WithProviderConfig(ProviderConfig{
+			Provider:     "codex",
+			AuthURL:      "https://auth.example.com/authorize",
+			RedirectURI:  busy,
+			RedirectURIs: []string{busy, "http://localhost:1457/auth/callback", "http://localhost:1458/auth/callback"},
+		}),
+	)
+	defer svc.Stop()
+
+	snap, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if !strings.Contains(snap.AuthURL, url.QueryEscape("http://localhost:1457/auth/callback")) {
+		t.Fatalf("expected the second redirect URI in the auth URL, got %s", snap.AuthURL)
+	}
+	if len(manager.acquired) != 1 || manager.acquired[0] != "http://localhost:1457/auth/callback" {
+		t.Fatalf("unexpected acquired listeners: %v", manager.acquired)
+	}
+}
+
+func TestStartListsEveryRedirectURIWhenAllFail(t *testing.T) {
+	manager := &fakeCallbackLeaseManager{acquireErr: errors.New("port busy")}
+	svc := mustNewService(t, "http://localhost:7777",
+		WithCallbackLeaseManager(manager),
+		WithProviderConfig(ProviderConfig{
+			Provider:     "codex",
+			RedirectURIs: []string{"http://localhost:1455/auth/callback", "http://localhost:1457/auth/callback"},
+		}),
+	)
+	defer svc.Stop()
+
+	_, err := svc.Start("codex")
+	if err == nil || !strings.Contains(err.Error(), "localhost:1455") || !strings.Contains(err.Error(), "localhost:1457") {
+		t.Fatalf("expected both redirect URIs in the error, got %v", err)
+	}
+}
+
+func TestSessionsListsAndExpiresSessions(t *testing.T) {
+	svc := mustNewService(t, "http://localhost:7777")
+	now := time.Now().UTC()
+	svc.mu.Lock()
+	svc.sessions["overdue"] = &session{
+		SessionSnapshot: SessionSnapshot{State: "overdue", Provider: "codex", Status: SessionPending, ExpiresAt: now.Add(-time.Minute)},
+		codeVerifier:    "secret-verifier",
+	}
+	svc.sessions["done"] = &session{
+		SessionSnapshot: SessionSnapshot{State: "done", Provider: "codex", Status: SessionSuccess, AccountID: "codex:a@example.com", ExpiresAt: now.Add(time.Minute)},
+	}
+	svc.sessions["waiting"] = &session{
+		SessionSnapshot: SessionSnapshot{State: "waiting", Provider: "codex", Status: SessionPending, ExpiresAt: now.Add(5 * time.Minute)},
+	}
+	svc.mu.Unlock()
+
+	all := svc.Sessions("")
+	if len(all) != 3 || all[0].State != "overdue" || all[1].State != "done" || all[2].State != "waiting" {
+		t.Fatalf("unexpected sessions: %#v", all)
+	}
+	if all[0].Status != SessionExpired {
+		t.Fatalf("expected the overdue session to be reported as expired, got %s", all[0].Status)
+	}
+	if all[1].Status != SessionSuccess || all[1].AccountID != "codex:a@example.com" {
+		t.Fatalf("expected the completed session to be listed, got %#v", all[1])
+	}
+	data, _ := json.Marshal(all)
+	if strings.Contains(string(data), "secret-verifier") {
+		t.Fatalf("session list leaked the code verifier: %s", data)
+	}
+
+	pending := svc.Sessions(SessionPending)
+	if len(pending) != 1 || pending[0].State != "waiting" {
+		t.Fatalf("unexpected pending sessions: %#v", pending)
+	}
+}
+
+type memSecretStore struct {
+	mu      sync.Mutex
+	entries map[string]model.AuthSecrets
+}
+
+func (s *memSecretStore) Put(id string, sec model.AuthSecrets) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[id] = sec
+	return nil
+}
+
+func (s *memSecretStore) Get(id string) (model.AuthSecrets, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.entries[id], nil
+}
+
+func (s *memSecretStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, id)
+	return nil
+}
+
+func (s *memSecretStore) List() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ids := make([]string, 0, len(s.entries))
+	for id := range s.entries {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func testIDToken(email string) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`))
+	payload, _ := json.Marshal(map[string]string{"email": email})
+	return header + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func TestConcurrentCallbacksCompleteIndependently(t *testing.T) {
+	// The first login's token exchange is held until the second login has
+	// finished, so the two callbacks complete in the opposite order.
+	releaseFirst := make(chan struct{})
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse token request: %v", err)
+		}
+		code := r.PostForm.Get("code")
+		if code == "first" {
+			<-releaseFirst
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access-" + code,
+			"refresh_token": "refresh-" + code,
+			"id_token":      testIDToken(code + "@example.com"),
+			"expires_in":    3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	t.Setenv(store.StateFileEnv, filepath.Join(t.TempDir(), "state.json"))
+	stateStore, err := store.NewStateStore()
+	if err != nil {
+		t.Fatalf("new state store: %v", err)
+	}
+	mgr := core.NewManager(stateStore, &memSecretStore{entries: map[string]model.AuthSecrets{}})
+	provider := func(name, redirect string) ProviderConfig {
+		return ProviderConfig{Provider: name, ClientID: name + "-client", AuthURL: "https://auth.example.test/authorize", TokenURL: tokenServer.URL, RedirectURI: redirect}
+	}
+	svc, err := NewService(mgr, "http://localhost:7777",
+		WithProviderConfig(provider("codex", "http://localhost:1455/auth/callback")),
+		WithProviderConfig(provider("acme", "http://localhost:1456/auth/callback")),
+	)
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	defer svc.Stop()
+
+	first, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start first: %v", err)
+	}
+	second, err := svc.Start("acme")
+	if err != nil {
+		t.Fatalf("start second: %v", err)
+	}
+
+	callback := func(state, code string) {
+		rec := httptest.NewRecorder()
+		svc.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state="+state+"&code="+code, nil))
+	}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		callback(first.State, "first")
+	}()
+	callback(second.State, "second")
+	close(releaseFirst)
+	<-done
+
+	for state, want := range map[string]string{first.State: "codex:first@example.com", second.State: "acme:second@example.com"} {
+		snap, err := svc.Status(state)
+		if err != nil {
+			t.Fatalf("status %s: %v", want, err)
+		}
+		if snap.Status != SessionSuccess || snap.AccountID != want || snap.ExpectedAccountID != want {
+			t.Fatalf("expected session for %s to succeed, got %#v", want, snap)
+		}
+	}
+	accounts, err := mgr.ListAccounts(context.Background())
+	if err != nil {
+		t.Fatalf("list accounts: %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("expected both accounts to be stored, got %#v", accounts)
+	}
+}
+
+func TestWaitForPendingCallbacksWaitsForSlowExchange(t *testing.T) {
+	exchangeStarted := make(chan struct{})
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(exchangeStarted)
+		time.Sleep(200 * time.Millisecond)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"id_token":      testIDToken("slow@example.com"),
+			"expires_in":    3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	t.Setenv(store.StateFileEnv, filepath.Join(t.TempDir(), "state.json"))
+	stateStore, err := store.NewStateStore()
+	if err != nil {
+		t.Fatalf("new state store: %v", err)
+	}
+	mgr := core.NewManager(stateStore, &memSecretStore{entries: map[string]model.AuthSecrets{}})
+	svc, err := NewService(mgr, "http://localhost:7777", WithProviderConfig(ProviderConfig{
+		Provider: "codex", ClientID: "codex-client", AuthURL: "https://auth.example.test/authorize",
+		TokenURL: tokenServer.URL, RedirectURI: "http://localhost:1455/auth/callback",
+	}))
+	if err != nil {
+		t.Fatalf("new service: %v", err)
+	}
+	defer svc.Stop()
+
+	sess, err := svc.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	go func() {
+		rec := httptest.NewRecorder()
+		svc.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state="+sess.State+"&code=slow", nil))
+	}()
+	<-exchangeStarted
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := svc.WaitForPendingCallbacks(ctx); err != nil {
+		t.Fatalf("wait for callbacks: %v", err)
+	}
+	snap, err := svc.Status(sess.State)
+	if err != nil || snap.Status != SessionSuccess || snap.AccountID != "codex:slow@example.com" {
+		t.Fatalf("expected the callback to finish before the wait returned, got %#v (%v)", snap, err)
+	}
+
+	// Once draining, new callbacks are turned away instead of racing shutdown.
+	rec := httptest.NewRecorder()
+	svc.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state="+sess.State+"&code=late", nil))
+	if !strings.Contains(rec.Body.String(), "shutting down") {
+		t.Fatalf("expected a late callback to be rejected, got %q", rec.Body.String())
+	}
+}
+
+func TestBuildAccountIDFallbackIsPerSession(t *testing.T) {
+	a := buildAccountID("acme", "", "", "stateAAAAAAAA")
+	b := buildAccountID("acme", "", "", "stateBBBBBBBB")
+	if a == b {
+		t.Fatalf("expected anonymous logins to get distinct ids, both got %q", a)
+	}
+	if got := buildAccountID("acme", "User@Example.com", "acct", "state"); got != "acme:user@example.com" {
+		t.Fatalf("expected the email to win, got %q", got)
+	}
+}
@@ -0,0 +1,132 @@
+package oauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"switchly/internal/secrets"
+	"switchly/internal/store"
+)
+
+const (
+	sessionsFileSuffix = ".oauth-sessions.enc"
+	sessionsKeyFile    = "oauth-sessions.key"
+	sessionsKeySize    = 32
+	sessionsFileFormat = "switchly oauth sessions v1"
+)
+
+var ErrSessionsDecrypt = errors.New("decrypt oauth sessions: wrong key or corrupted file")
+
+// PersistedSession is a session as written by a SessionPersister, including
+// the PKCE verifier and redirect URI needed to finish the login.
+type PersistedSession struct {
+	SessionSnapshot
+	CodeVerifier string `json:"code_verifier,omitempty"`
+	RedirectURI  string `json:"redirect_uri,omitempty"`
+}
+
+// SessionPersister keeps OAuth sessions across daemon restarts. Save is
+// given every session the service tracks and replaces what was saved before.
+type SessionPersister interface {
+	Save(sessions []PersistedSession) error
+	Load() ([]PersistedSession, error)
+}
+
+// FileSessionPersister stores sessions as AES-256-GCM encrypted JSON. The
+// key is a random file next to the sessions, so it keeps verifiers out of
+// plain sight but does not protect them from anyone who can read the dir.
+type FileSessionPersister struct {
+	path    string
+	keyPath string
+}
+
+// NewFileSessionPersister keeps sessions next to the state file at
+// statePath and named after it, so daemons on separate state files do not
+// restore each other's logins. The key is shared by the directory.
+func NewFileSessionPersister(statePath string) (*FileSessionPersister, error) {
+	if strings.TrimSpace(statePath) == "" {
+		return nil, errors.New("state file path is required")
+	}
+	dir := filepath.Dir(statePath)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	base := strings.TrimSuffix(filepath.Base(statePath), filepath.Ext(statePath))
+	return &FileSessionPersister{
+		path:    filepath.Join(dir, base+sessionsFileSuffix),
+		keyPath: filepath.Join(dir, sessionsKeyFile),
+	}, nil
+}
+
+func (p *FileSessionPersister) Save(sessions []PersistedSession) error {
+	plain, err := json.Marshal(sessions)
+	if err != nil {
+		return err
+	}
+	aead, err := p.aead(true)
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	data := aead.Seal(nonce, nonce, plain, []byte(sessionsFileFormat))
+	if err := store.WriteFileAtomic(p.path, data, 0o600); err != nil {
+		return fmt.Errorf("write oauth sessions: %w", err)
+	}
+	return nil
+}
+
+// Load returns no sessions when nothing has been saved yet.
+func (p *FileSessionPersister) Load() ([]PersistedSession, error) {
+	data, err := os.ReadFile(p.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	aead, err := p.aead(false)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < aead.NonceSize() {
+		return nil, ErrSessionsDecrypt
+	}
+	nonce, sealed := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, sealed, []byte(sessionsFileFormat))
+	if err != nil {
+		return nil, ErrSessionsDecrypt
+	}
+	var sessions []PersistedSession
+	if err := json.Unmarshal(plain, &sessions); err != nil {
+		return nil, fmt.Errorf("decode oauth sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// aead reads the key, creating it first when create is set.
+func (p *FileSessionPersister) aead(create bool) (cipher.AEAD, error) {
+	if !create {
+		if _, err := os.Stat(p.keyPath); errors.Is(err, os.ErrNotExist) {
+			return nil, ErrSessionsDecrypt
+		}
+	}
+	key, err := secrets.LoadOrCreateKey(p.keyPath, sessionsKeySize)
+	if err != nil {
+		return nil, fmt.Errorf("oauth sessions key: %w", err)
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,178 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+	"switchly/internal/store"
+)
+
+func TestSessionsSurviveServiceRestart(t *testing.T) {
+	var gotVerifier string
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Errorf("parse token request: %v", err)
+		}
+		gotVerifier = r.PostForm.Get("code_verifier")
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"access_token":  "access",
+			"refresh_token": "refresh",
+			"id_token":      testIDToken("restart@example.com"),
+			"expires_in":    3600,
+		})
+	}))
+	defer tokenServer.Close()
+
+	t.Setenv(store.StateFileEnv, filepath.Join(t.TempDir(), "state.json"))
+	stateStore, err := store.NewStateStore()
+	if err != nil {
+		t.Fatalf("new state store: %v", err)
+	}
+	mgr := core.NewManager(stateStore, &memSecretStore{entries: map[string]model.AuthSecrets{}})
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	newService := func(leases *fakeCallbackLeaseManager) *Service {
+		persister, err := NewFileSessionPersister(statePath)
+		if err != nil {
+			t.Fatalf("new persister: %v", err)
+		}
+		svc, err := NewService(mgr, "http://localhost:7777",
+			WithProviderConfig(ProviderConfig{
+				Provider: "codex", ClientID: "codex-client", AuthURL: "https://auth.example.test/authorize",
+				TokenURL: tokenServer.URL, RedirectURI: "http://localhost:1455/auth/callback",
+			}),
+			WithCallbackLeaseManager(leases),
+			WithSessionPersister(persister),
+		)
+		if err != nil {
+			t.Fatalf("new service: %v", err)
+		}
+		return svc
+	}
+
+	first := newService(&fakeCallbackLeaseManager{})
+	pending, err := first.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	cancelled, err := first.Start("codex")
+	if err != nil {
+		t.Fatalf("start: %v", err)
+	}
+	if err := first.Cancel(cancelled.State); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	verifier := first.sessions[pending.State].codeVerifier
+	first.Stop()
+
+	leases := &fakeCallbackLeaseManager{}
+	second := newService(leases)
+	defer second.Stop()
+	if snap, err := second.Status(cancelled.State); err != nil || snap.Status != SessionCancelled {
+		t.Fatalf("expected the cancelled session to be restored, got %#v (%v)", snap, err)
+	}
+	if len(leases.acquired) != 1 || leases.acquired[0] != "http://localhost:1455/auth/callback" {
+		t.Fatalf("expected the pending session to take its callback listener back, got %v", leases.acquired)
+	}
+
+	rec := httptest.NewRecorder()
+	second.HandleCallback(rec, httptest.NewRequest(http.MethodGet, "/auth/callback?state="+pending.State+"&code=abc", nil))
+	snap, err := second.Status(pending.State)
+	if err != nil || snap.Status != SessionSuccess || snap.AccountID != "codex:restart@example.com" {
+		t.Fatalf("expected the login to complete after the restart, got %#v (%v)", snap, err)
+	}
+	if gotVerifier != verifier {
+		t.Fatalf("expected the original PKCE verifier, got %q want %q", gotVerifier, verifier)
+	}
+}
+
+func TestRestoreSessionsExpiresOverdueSessions(t *testing.T) {
+	persister, err := NewFileSessionPersister(filepath.Join(t.TempDir(), "state.json"))
+	if err != nil {
+		t.Fatalf("new persister: %v", err)
+	}
+	if err := persister.Save([]PersistedSession{{
+		SessionSnapshot: SessionSnapshot{State: "old", Provider: "codex", Status: SessionPending, ExpiresAt: time.Now().UTC().Add(-time.Minute)},
+		CodeVerifier:    "verifier",
+		RedirectURI:     "http://localhost:1455/auth/callback",
+	}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	leases := &fakeCallbackLeaseManager{}
+	svc := mustNewService(t, "http://localhost:7777", WithCallbackLeaseManager(leases), WithSessionPersister(persister))
+	defer svc.Stop()
+	snap, err := svc.Status("old")
+	if err != nil || snap.Status != SessionExpired {
+		t.Fatalf("expected the overdue session to be expired, got %#v (%v)", snap, err)
+	}
+	if len(leases.acquired) != 0 || len(leases.released) != 0 {
+		t.Fatalf("expected no callback listener for an expired session, got acquired=%v released=%v", leases.acquired, leases.released)
+	}
+
+	saved, err := persister.Load()
+	if err != nil || len(saved) != 1 || saved[0].Status != SessionExpired || saved[0].RedirectURI != "" {
+		t.Fatalf("expected the expiry to be persisted, got %#v (%v)", saved, err)
+	}
+}
+
+func TestFileSessionPersisterEncrypts(t *testing.T) {
+	dir := t.TempDir()
+	persister, err := NewFileSessionPersister(filepath.Join(dir, "state.json"))
+	if err != nil {
+		t.Fatalf("new persister: %v", err)
+	}
+	if saved, err := persister.Load(); err != nil || saved != nil {
+		t.Fatalf("expected nothing before the first save, got %#v (%v)", saved, err)
+	}
+	if err := persister.Save([]PersistedSession{{
+		SessionSnapshot: SessionSnapshot{State: "s1", Provider: "codex", Status: SessionPending},
+		CodeVerifier:    "secret-verifier",
+	}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "state"+sessionsFileSuffix))
+	if err != nil {
+		t.Fatalf("read sessions: %v", err)
+	}
+	if bytes.Contains(data, []byte("secret-verifier")) {
+		t.Fatal("expected the verifier not to be stored in plaintext")
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, sessionsKeyFile), bytes.Repeat([]byte{1}, sessionsKeySize), 0o600); err != nil {
+		t.Fatalf("replace key: %v", err)
+	}
+	if _, err := persister.Load(); !errors.Is(err, ErrSessionsDecrypt) {
+		t.Fatalf("expected a decrypt error with the wrong key, got %v", err)
+	}
+}
+
+func TestFileSessionPersisterIsPerStateFile(t *testing.T) {
+	dir := t.TempDir()
+	first, err := NewFileSessionPersister(filepath.Join(dir, "a.json"))
+	if err != nil {
+		t.Fatalf("new persister: %v", err)
+	}
+	second, err := NewFileSessionPersister(filepath.Join(dir, "b.json"))
+	if err != nil {
+		t.Fatalf("new persister: %v", err)
+	}
+	if err := first.Save([]PersistedSession{{SessionSnapshot: SessionSnapshot{State: "s1", Provider: "codex", Status: SessionPending}}}); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if saved, err := second.Load(); err != nil || saved != nil {
+		t.Fatalf("expected the other state file's daemon to see no sessions, got %#v (%v)", saved, err)
+	}
+	if saved, err := first.Load(); err != nil || len(saved) != 1 {
+		t.Fatalf("expected the saved session back, got %#v (%v)", saved, err)
+	}
+}
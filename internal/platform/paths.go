@@ -3,6 +3,7 @@ package platform
 import (
 	"os"
 	"path/filepath"
+	"strings"
 )
 
 func ConfigDir() (string, error) {
@@ -31,3 +32,35 @@ func EnsureConfigDir() (string, error) {
 	}
 	return dir, nil
 }
+
+// CodexDirEnv points Switchly at a non-default Codex CLI directory.
+const CodexDirEnv = "CODEX_DIR"
+
+// codexHomeEnv is the Codex CLI's own override of its directory.
+const codexHomeEnv = "CODEX_HOME"
+
+// CodexConfigDir returns the Codex CLI directory holding auth.json and the
+// session logs: $CODEX_DIR, then $CODEX_HOME, then ~/.codex. It returns ""
+// when none is set and the home directory is unknown.
+func CodexConfigDir() string {
+	for _, env := range []string{CodexDirEnv, codexHomeEnv} {
+		if dir := strings.TrimSpace(os.Getenv(env)); dir != "" {
+			return dir
+		}
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".codex")
+}
+
+// CodexAuthFilePath returns the auth.json inside CodexConfigDir, or "" when
+// that directory is unknown.
+func CodexAuthFilePath() string {
+	dir := CodexConfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "auth.json")
+}
@@ -0,0 +1,42 @@
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCodexConfigDirDefaultsToHome(t *testing.T) {
+	t.Setenv(CodexDirEnv, "")
+	t.Setenv(codexHomeEnv, "")
+	home, err := os.UserHomeDir()
+	if err != nil {
+		t.Skipf("no home directory: %v", err)
+	}
+	if got, want := CodexConfigDir(), filepath.Join(home, ".codex"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestCodexConfigDirHonoursOverrides(t *testing.T) {
+	codexHome := filepath.Join(t.TempDir(), "codex-home")
+	t.Setenv(CodexDirEnv, "")
+	t.Setenv(codexHomeEnv, codexHome)
+	if got := CodexConfigDir(); got != codexHome {
+		t.Fatalf("expected CODEX_HOME %q, got %q", codexHome, got)
+	}
+
+	codexDir := filepath.Join(t.TempDir(), "codex-dir")
+	t.Setenv(CodexDirEnv, codexDir)
+	if got := CodexConfigDir(); got != codexDir {
+		t.Fatalf("expected CODEX_DIR %q to win, got %q", codexDir, got)
+	}
+}
+
+func TestCodexAuthFilePathUsesCodexDir(t *testing.T) {
+	codexDir := t.TempDir()
+	t.Setenv(CodexDirEnv, codexDir)
+	if got, want := CodexAuthFilePath(), filepath.Join(codexDir, "auth.json"); got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
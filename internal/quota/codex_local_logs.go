@@ -0,0 +1,257 @@
+package quota
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"switchly/internal/platform"
+)
+
+// ErrNoLocalSnapshot is returned when no session log under the directory
+// carries rate-limit information.
+var ErrNoLocalSnapshot = errors.New("no quota snapshot found in codex session logs")
+
+type codexLogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Type      string    `json:"type"`
+	Payload   struct {
+		Type       string `json:"type"`
+		RateLimits *struct {
+			Primary   *codexLogWindow `json:"primary"`
+			Secondary *codexLogWindow `json:"secondary"`
+		} `json:"rate_limits"`
+	} `json:"payload"`
+}
+
+type codexLogWindow struct {
+	rawWindow
+	WindowMinutes   int64 `json:"window_minutes"`
+	ResetsInSeconds int64 `json:"resets_in_seconds"`
+}
+
+// DefaultCodexSessionsDir returns where the Codex CLI writes its session
+// logs: the sessions directory under platform.CodexConfigDir.
+func DefaultCodexSessionsDir() (string, error) {
+	dir := platform.CodexConfigDir()
+	if dir == "" {
+		return "", errors.New("cannot determine the codex directory")
+	}
+	return filepath.Join(dir, "sessions"), nil
+}
+
+const (
+	DefaultScanMaxFiles   = 60
+	DefaultScanMaxAgeDays = 7
+)
+
+// ScanOptions bounds the session log scan. Zero values use the defaults;
+// a negative MaxFiles or MaxAgeDays removes that limit.
+type ScanOptions struct {
+	// MaxFiles is how many of the newest log files are read.
+	MaxFiles int
+	// MaxAgeDays skips files not modified within this many days.
+	MaxAgeDays int
+	// Roots are scanned in addition to the sessions directory, e.g. an
+	// archived sessions directory.
+	Roots []string
+}
+
+// LatestCodexSnapshotFromDir returns the newest rate-limit snapshot recorded
+// in the Codex CLI session logs (*.jsonl) under dir, using the default
+// ScanOptions.
+func LatestCodexSnapshotFromDir(dir string) (Snapshot, error) {
+	return LatestCodexSnapshotFromDirWithOptions(dir, ScanOptions{})
+}
+
+// LatestCodexSnapshotFromDirWithOptions is LatestCodexSnapshotFromDir with
+// explicit scan limits. Files are read newest first and the search stops
+// at the first file containing a snapshot.
+func LatestCodexSnapshotFromDirWithOptions(codexDir string, opts ScanOptions) (Snapshot, error) {
+	files, err := codexLogFiles(append([]string{codexDir}, opts.Roots...), opts, time.Now())
+	if err != nil {
+		return Snapshot{}, err
+	}
+	for _, path := range files {
+		snap, ok, err := latestSnapshotInFile(path)
+		if err != nil {
+			return Snapshot{}, err
+		}
+		if ok {
+			return snap, nil
+		}
+	}
+	return Snapshot{}, ErrNoLocalSnapshot
+}
+
+// codexLogFiles lists the *.jsonl files under roots, newest first. Files
+// older than the age limit are dropped while walking, before sorting.
+func codexLogFiles(roots []string, opts ScanOptions, now time.Time) ([]string, error) {
+	maxFiles := opts.MaxFiles
+	if maxFiles == 0 {
+		maxFiles = DefaultScanMaxFiles
+	}
+	maxAgeDays := opts.MaxAgeDays
+	if maxAgeDays == 0 {
+		maxAgeDays = DefaultScanMaxAgeDays
+	}
+	var cutoff time.Time
+	if maxAgeDays > 0 {
+		cutoff = now.AddDate(0, 0, -maxAgeDays)
+	}
+
+	type logFile struct {
+		path    string
+		modTime time.Time
+	}
+	var files []logFile
+	found := false
+	for _, root := range roots {
+		if strings.TrimSpace(root) == "" {
+			continue
+		}
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !strings.HasSuffix(d.Name(), ".jsonl") {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			if !cutoff.IsZero() && info.ModTime().Before(cutoff) {
+				return nil
+			}
+			files = append(files, logFile{path: path, modTime: info.ModTime()})
+			return nil
+		})
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		found = true
+	}
+	if !found {
+		return nil, ErrNoLocalSnapshot
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.After(files[j].modTime)
+	})
+	if maxFiles > 0 && len(files) > maxFiles {
+		files = files[:maxFiles]
+	}
+	out := make([]string, 0, len(files))
+	for _, f := range files {
+		out = append(out, f.path)
+	}
+	return out, nil
+}
+
+func latestSnapshotInFile(path string) (Snapshot, bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return Snapshot{}, false, err
+	}
+	defer f.Close()
+
+	var (
+		best  Snapshot
+		found bool
+	)
+	// Lines carrying full conversation items can be very long, so read them
+	// whole instead of going through bufio.Scanner's token limit.
+	reader := bufio.NewReader(f)
+	for {
+		line, readErr := reader.ReadBytes('\n')
+		// A malformed line, such as one cut short by a running session, is
+		// skipped like any other line without a snapshot.
+		if snap, ok, _ := ParseCodexLogLine(line); ok {
+			if !found || !snap.SourceTimestamp.Before(best.SourceTimestamp) {
+				best = *snap
+				found = true
+			}
+		}
+		if readErr != nil {
+			if errors.Is(readErr, io.EOF) {
+				return best, found, nil
+			}
+			return Snapshot{}, false, readErr
+		}
+	}
+}
+
+var rateLimitsKey = []byte(`"rate_limits"`)
+
+// ParseCodexLogLine parses one line of a Codex session log. ok reports
+// whether the line is a rate-limit event; other lines return without
+// allocating. An error is returned only for a line that mentions
+// rate_limits but is not valid JSON.
+func ParseCodexLogLine(line []byte) (snap *Snapshot, ok bool, err error) {
+	if !bytes.Contains(line, rateLimitsKey) {
+		return nil, false, nil
+	}
+	var entry codexLogLine
+	if err := json.Unmarshal(line, &entry); err != nil {
+		return nil, false, fmt.Errorf("parse codex log line: %w", err)
+	}
+	if !isRateLimitEvent(entry) {
+		return nil, false, nil
+	}
+	s := snapshotFromLog(entry)
+	return &s, true, nil
+}
+
+// isRateLimitEvent accepts token_count events, and entries without a type
+// as written by older Codex versions.
+func isRateLimitEvent(entry codexLogLine) bool {
+	if entry.Payload.RateLimits == nil {
+		return false
+	}
+	if entry.Type != "" && entry.Type != "event_msg" {
+		return false
+	}
+	return entry.Payload.Type == "" || entry.Payload.Type == "token_count"
+}
+
+func snapshotFromLog(entry codexLogLine) Snapshot {
+	at := entry.Timestamp.UTC()
+	snap := Snapshot{SourceTimestamp: at}
+	primary := toWindowFromLog(entry.Payload.RateLimits.Primary, at)
+	secondary := toWindowFromLog(entry.Payload.RateLimits.Secondary, at)
+	if secondary != nil {
+		snap.Session = primary
+		snap.Weekly = secondary
+	} else if raw := entry.Payload.RateLimits.Primary; raw != nil && raw.WindowMinutes >= 24*60 {
+		snap.Weekly = primary
+		snap.SessionUnsupported = true
+	} else {
+		snap.Session = primary
+	}
+	return snap
+}
+
+// toWindowFromLog accepts both the absolute resets_at written by newer Codex
+// versions and the older resets_in_seconds, relative to the log entry.
+func toWindowFromLog(raw *codexLogWindow, at time.Time) *Window {
+	if raw == nil {
+		return nil
+	}
+	win := toWindow(&raw.rawWindow)
+	if win.ResetAt.IsZero() && raw.ResetsInSeconds > 0 && !at.IsZero() {
+		win.ResetAt = at.Add(time.Duration(raw.ResetsInSeconds) * time.Second)
+	}
+	return win
+}
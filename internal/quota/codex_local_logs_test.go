@@ -0,0 +1,260 @@
+package quota
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeCodexLog(t *testing.T, path string, modTime time.Time, lines ...string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	var data []byte
+	for _, line := range lines {
+		data = append(data, line...)
+		data = append(data, '\n')
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("write log: %v", err)
+	}
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatalf("chtimes: %v", err)
+	}
+}
+
+func TestLatestCodexSnapshotFromDirPicksNewestEntry(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeCodexLog(t, filepath.Join(dir, "2026", "10", "01", "rollout-old.jsonl"), now.Add(-time.Hour),
+		`{"timestamp":"2026-10-01T08:00:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":90,"window_minutes":300,"resets_at":1790000000}}}}`,
+	)
+	writeCodexLog(t, filepath.Join(dir, "2026", "10", "02", "rollout-new.jsonl"), now,
+		`{"timestamp":"2026-10-02T09:00:00Z","type":"session_meta","payload":{"id":"abc"}}`,
+		`{"timestamp":"2026-10-02T09:05:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":10.4,"window_minutes":300,"resets_in_seconds":600},"secondary":{"used_percent":40,"window_minutes":10080,"resets_at":1790500000}}}}`,
+		`{"timestamp":"2026-10-02T09:10:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":20,"window_minutes":300,"resets_in_seconds":300},"secondary":{"used_percent":41,"window_minutes":10080,"resets_at":1790500000}}}}`,
+		`not json`,
+	)
+
+	snap, err := LatestCodexSnapshotFromDir(dir)
+	if err != nil {
+		t.Fatalf("LatestCodexSnapshotFromDir: %v", err)
+	}
+	wantAt := time.Date(2026, 10, 2, 9, 10, 0, 0, time.UTC)
+	if !snap.SourceTimestamp.Equal(wantAt) {
+		t.Fatalf("unexpected source timestamp: %s", snap.SourceTimestamp)
+	}
+	if snap.Session == nil || snap.Session.UsedPercent != 20 || !snap.Session.ResetAt.Equal(wantAt.Add(5*time.Minute)) {
+		t.Fatalf("unexpected session window: %#v", snap.Session)
+	}
+	if snap.Weekly == nil || snap.Weekly.UsedPercent != 41 || !snap.Weekly.ResetAt.Equal(time.Unix(1790500000, 0).UTC()) {
+		t.Fatalf("unexpected weekly window: %#v", snap.Weekly)
+	}
+}
+
+func TestLatestCodexSnapshotFromDirWeeklyOnlyPrimary(t *testing.T) {
+	dir := t.TempDir()
+	writeCodexLog(t, filepath.Join(dir, "rollout.jsonl"), time.Now(),
+		`{"timestamp":"2026-10-02T09:00:00Z","payload":{"rate_limits":{"primary":{"used_percent":55,"window_minutes":10080}}}}`,
+	)
+
+	snap, err := LatestCodexSnapshotFromDir(dir)
+	if err != nil {
+		t.Fatalf("LatestCodexSnapshotFromDir: %v", err)
+	}
+	if snap.Session != nil || !snap.SessionUnsupported || snap.Weekly == nil || snap.Weekly.UsedPercent != 55 {
+		t.Fatalf("expected weekly-only snapshot, got %#v", snap)
+	}
+}
+
+func TestLatestCodexSnapshotFromDirNoSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	writeCodexLog(t, filepath.Join(dir, "rollout.jsonl"), time.Now(),
+		`{"timestamp":"2026-10-02T09:00:00Z","type":"session_meta","payload":{"id":"abc"}}`,
+	)
+	if _, err := LatestCodexSnapshotFromDir(dir); !errors.Is(err, ErrNoLocalSnapshot) {
+		t.Fatalf("expected ErrNoLocalSnapshot, got %v", err)
+	}
+	if _, err := LatestCodexSnapshotFromDir(filepath.Join(dir, "missing")); !errors.Is(err, ErrNoLocalSnapshot) {
+		t.Fatalf("expected ErrNoLocalSnapshot for missing dir, got %v", err)
+	}
+}
+
+const testRateLimitLine = `{"timestamp":"2026-10-02T09:00:00Z","payload":{"rate_limits":{"primary":{"used_percent":%d,"window_minutes":300,"resets_in_seconds":600}}}}`
+
+func TestLatestCodexSnapshotSkipsFilesOlderThanMaxAge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeCodexLog(t, filepath.Join(dir, "old", "rollout.jsonl"), now.Add(-10*24*time.Hour), fmt.Sprintf(testRateLimitLine, 90))
+
+	if _, err := LatestCodexSnapshotFromDir(dir); !errors.Is(err, ErrNoLocalSnapshot) {
+		t.Fatalf("expected a 10 day old log to be skipped by default, got %v", err)
+	}
+	snap, err := LatestCodexSnapshotFromDirWithOptions(dir, ScanOptions{MaxAgeDays: 14})
+	if err != nil || snap.Session == nil || snap.Session.UsedPercent != 90 {
+		t.Fatalf("expected the log within 14 days to be read, got %#v err=%v", snap, err)
+	}
+	if _, err := LatestCodexSnapshotFromDirWithOptions(dir, ScanOptions{MaxAgeDays: -1}); err != nil {
+		t.Fatalf("expected no age limit with -1, got %v", err)
+	}
+
+	writeCodexLog(t, filepath.Join(dir, "new", "rollout.jsonl"), now.Add(-2*24*time.Hour), `{"type":"session_meta"}`)
+	if _, err := LatestCodexSnapshotFromDirWithOptions(dir, ScanOptions{MaxAgeDays: 3}); !errors.Is(err, ErrNoLocalSnapshot) {
+		t.Fatalf("expected the older snapshot to be out of range, got %v", err)
+	}
+}
+
+func TestLatestCodexSnapshotMaxFilesAndRoots(t *testing.T) {
+	dir := t.TempDir()
+	archive := t.TempDir()
+	now := time.Now()
+	writeCodexLog(t, filepath.Join(dir, "a.jsonl"), now.Add(-time.Minute), `{"type":"session_meta"}`)
+	writeCodexLog(t, filepath.Join(dir, "b.jsonl"), now.Add(-2*time.Minute), `{"type":"session_meta"}`)
+	writeCodexLog(t, filepath.Join(archive, "c.jsonl"), now.Add(-3*time.Minute), fmt.Sprintf(testRateLimitLine, 30))
+
+	if _, err := LatestCodexSnapshotFromDirWithOptions(dir, ScanOptions{Roots: []string{archive}, MaxFiles: 2}); !errors.Is(err, ErrNoLocalSnapshot) {
+		t.Fatalf("expected only the two newest files to be read, got %v", err)
+	}
+	snap, err := LatestCodexSnapshotFromDirWithOptions(dir, ScanOptions{Roots: []string{archive}})
+	if err != nil || snap.Session == nil || snap.Session.UsedPercent != 30 {
+		t.Fatalf("expected the snapshot from the extra root, got %#v err=%v", snap, err)
+	}
+	snap, err = LatestCodexSnapshotFromDirWithOptions(filepath.Join(dir, "missing"), ScanOptions{Roots: []string{archive}})
+	if err != nil || snap.Session == nil {
+		t.Fatalf("expected a missing sessions dir to be ignored when a root exists, got %v", err)
+	}
+}
+
+func TestParseCodexLogLine(t *testing.T) {
+	at := time.Date(2026, 10, 2, 9, 10, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		line    string
+		ok      bool
+		wantErr bool
+		check   func(t *testing.T, snap *Snapshot)
+	}{
+		{
+			name: "rate limits",
+			line: `{"timestamp":"2026-10-02T09:10:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":20,"window_minutes":300,"resets_in_seconds":300},"secondary":{"used_percent":41,"window_minutes":10080,"resets_at":1790500000}}}}`,
+			ok:   true,
+			check: func(t *testing.T, snap *Snapshot) {
+				if snap.Session == nil || snap.Session.UsedPercent != 20 || !snap.Session.ResetAt.Equal(at.Add(5*time.Minute)) {
+					t.Fatalf("unexpected session window: %#v", snap.Session)
+				}
+				if snap.Weekly == nil || snap.Weekly.UsedPercent != 41 || !snap.SourceTimestamp.Equal(at) {
+					t.Fatalf("unexpected snapshot: %#v", snap)
+				}
+			},
+		},
+		{
+			name: "primary only",
+			line: `{"timestamp":"2026-10-02T09:10:00Z","payload":{"rate_limits":{"primary":{"used_percent":30,"window_minutes":300}}}}`,
+			ok:   true,
+			check: func(t *testing.T, snap *Snapshot) {
+				if snap.Session == nil || snap.Session.UsedPercent != 30 || snap.Weekly != nil || snap.SessionUnsupported {
+					t.Fatalf("expected a session-only snapshot, got %#v", snap)
+				}
+			},
+		},
+		{name: "invalid json", line: `{"payload":{"rate_limits":{"primary":`, wantErr: true},
+		{name: "missing fields", line: `{"timestamp":"2026-10-02T09:10:00Z","payload":{"rate_limits":null}}`},
+		{name: "other event type", line: `{"type":"response_item","payload":{"type":"message","rate_limits":{"primary":{"used_percent":99}}}}`},
+		{name: "other payload type", line: `{"type":"event_msg","payload":{"type":"agent_message","rate_limits":{"primary":{"used_percent":99}}}}`},
+		{name: "no rate limits", line: `{"type":"session_meta","payload":{"id":"abc"}}`},
+		{name: "empty line"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap, ok, err := ParseCodexLogLine([]byte(tt.line))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.ok || (snap != nil) != tt.ok {
+				t.Fatalf("expected ok=%v, got ok=%v snap=%#v", tt.ok, ok, snap)
+			}
+			if tt.check != nil {
+				tt.check(t, snap)
+			}
+		})
+	}
+}
+
+func TestParseCodexLogLineSkipsWithoutAllocating(t *testing.T) {
+	line := []byte(`{"timestamp":"2026-10-02T09:00:00Z","type":"response_item","payload":{"type":"message","content":"hello"}}`)
+	allocs := testing.AllocsPerRun(100, func() {
+		if _, ok, _ := ParseCodexLogLine(line); ok {
+			t.Fatal("expected the line to be skipped")
+		}
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations for a skipped line, got %v", allocs)
+	}
+}
+
+// BenchmarkParseCodexLogLine parses 10,000 lines of which one in a hundred
+// carries rate limits, as in a typical session log.
+func BenchmarkParseCodexLogLine(b *testing.B) {
+	skip := []byte(`{"timestamp":"2026-10-02T09:00:00Z","type":"response_item","payload":{"type":"message","content":"hello"}}`)
+	quota := []byte(fmt.Sprintf(testRateLimitLine, 25))
+	lines := make([][]byte, 10000)
+	for i := range lines {
+		lines[i] = skip
+		if i%100 == 0 {
+			lines[i] = quota
+		}
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, line := range lines {
+			if _, _, err := ParseCodexLogLine(line); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// benchmarkCodexLogScan writes 1000 logs without a snapshot, a tenth of
+// them within the last week, so every file inside the limits is read.
+func benchmarkCodexLogScan(b *testing.B, opts ScanOptions) {
+	dir := b.TempDir()
+	now := time.Now()
+	line := []byte(`{"type":"session_meta","payload":{"id":"abc"}}` + "\n")
+	for i := 0; i < 1000; i++ {
+		age := time.Duration(i) * time.Hour
+		if i >= 100 {
+			age = 8*24*time.Hour + time.Duration(i)*time.Hour
+		}
+		path := filepath.Join(dir, fmt.Sprintf("rollout-%04d.jsonl", i))
+		if err := os.WriteFile(path, bytes.Repeat(line, 200), 0o600); err != nil {
+			b.Fatal(err)
+		}
+		if err := os.Chtimes(path, now.Add(-age), now.Add(-age)); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := LatestCodexSnapshotFromDirWithOptions(dir, opts); !errors.Is(err, ErrNoLocalSnapshot) {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCodexLogScanUnlimited(b *testing.B) {
+	benchmarkCodexLogScan(b, ScanOptions{MaxFiles: -1, MaxAgeDays: -1})
+}
+
+func BenchmarkCodexLogScanLastWeek(b *testing.B) {
+	benchmarkCodexLogScan(b, ScanOptions{MaxFiles: -1})
+}
+
+func BenchmarkCodexLogScanDefault(b *testing.B) {
+	benchmarkCodexLogScan(b, ScanOptions{})
+}
@@ -70,6 +70,10 @@ func FetchCodexSnapshot(ctx context.Context, httpClient *http.Client, accessToke
 		SourceTimestamp: time.Now().UTC(),
 		LimitReached:    raw.RateLimit.LimitReached,
 	}
+	if respAccountID := strings.TrimSpace(resp.Header.Get("X-Account-Id")); respAccountID != "" && respAccountID != strings.TrimSpace(accountID) {
+		snap.AccountIDMismatch = true
+		snap.ReturnedAccountID = respAccountID
+	}
 	// Primary/secondary mapping differs by account tier.
 	// Paid accounts usually return both windows (primary=session, secondary=weekly).
 	// Free accounts may return only one long-horizon window via primary (weekly).
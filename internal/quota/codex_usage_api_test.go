@@ -52,6 +52,29 @@ func TestFetchCodexSnapshotSuccess(t *testing.T) {
 	}
 }
 
+func TestFetchCodexSnapshotFlagsAccountIDMismatch(t *testing.T) {
+	client := &http.Client{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			header := make(http.Header)
+			header.Set("X-Account-Id", "acct-b")
+			body := `{"rate_limit":{"limit_reached":false,"primary_window":{"used_percent":5,"reset_at":1771700000}}}`
+			return &http.Response{
+				StatusCode: 200,
+				Header:     header,
+				Body:       io.NopCloser(strings.NewReader(body)),
+			}, nil
+		}),
+	}
+
+	snap, err := FetchCodexSnapshot(context.Background(), client, "token-a", "acct-a")
+	if err != nil {
+		t.Fatalf("fetch snapshot: %v", err)
+	}
+	if !snap.AccountIDMismatch || snap.ReturnedAccountID != "acct-b" {
+		t.Fatalf("expected account_id mismatch to be flagged, got %#v", snap)
+	}
+}
+
 func TestFetchCodexSnapshotHTTPError(t *testing.T) {
 	client := &http.Client{
 		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
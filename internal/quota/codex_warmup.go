@@ -0,0 +1,98 @@
+package quota
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// warmupPrompt is the smallest request that makes the CLI start a thread
+// and record the rate limits returned with the response.
+const warmupPrompt = "Reply with OK."
+
+// CodexExecFunc runs the Codex CLI with args and returns its stdout.
+type CodexExecFunc func(ctx context.Context, args ...string) ([]byte, error)
+
+// ExecCodex runs the codex binary found on PATH.
+func ExecCodex(ctx context.Context, args ...string) ([]byte, error) {
+	out, err := exec.CommandContext(ctx, "codex", args...).Output()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+		return out, fmt.Errorf("%w: %s", err, bytes.TrimSpace(exitErr.Stderr))
+	}
+	return out, err
+}
+
+// RunCodexWarmup sends a one-line prompt through `codex exec --json` and
+// returns the id of the thread it started, whose session log then holds
+// live rate limits for the signed-in account.
+func RunCodexWarmup(ctx context.Context, run CodexExecFunc) (string, error) {
+	out, err := run(ctx, "exec", "--json", "--skip-git-repo-check", warmupPrompt)
+	if err != nil {
+		return "", fmt.Errorf("run codex exec: %w", err)
+	}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event struct {
+			Type     string `json:"type"`
+			ThreadID string `json:"thread_id"`
+		}
+		if json.Unmarshal(scanner.Bytes(), &event) != nil {
+			continue
+		}
+		if event.Type == "thread.started" && strings.TrimSpace(event.ThreadID) != "" {
+			return strings.TrimSpace(event.ThreadID), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("read codex exec output: %w", err)
+	}
+	return "", errors.New("codex exec did not report a thread id")
+}
+
+// LatestCodexSnapshotForThread returns the newest rate-limit snapshot in the
+// session log of threadID under dir. The CLI names each log after its
+// thread, so no other session's logs are read.
+func LatestCodexSnapshotForThread(dir, threadID string) (Snapshot, error) {
+	threadID = strings.TrimSpace(threadID)
+	if threadID == "" {
+		return Snapshot{}, errors.New("thread id is required")
+	}
+	var (
+		best  Snapshot
+		found bool
+	)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() || !strings.HasSuffix(name, ".jsonl") || !strings.Contains(name, threadID) {
+			return nil
+		}
+		snap, ok, err := latestSnapshotInFile(path)
+		if err != nil {
+			return err
+		}
+		if ok && (!found || !snap.SourceTimestamp.Before(best.SourceTimestamp)) {
+			best = snap
+			found = true
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return Snapshot{}, err
+	}
+	if !found {
+		return Snapshot{}, fmt.Errorf("thread %s: %w", threadID, ErrNoLocalSnapshot)
+	}
+	return best, nil
+}
@@ -0,0 +1,61 @@
+package quota
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestRunCodexWarmupReturnsThreadID(t *testing.T) {
+	var gotArgs []string
+	threadID, err := RunCodexWarmup(context.Background(), func(ctx context.Context, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte("{\"type\":\"thread.started\",\"thread_id\":\"0199a213-81c0-7800-8aa1-bbab2a035a53\"}\n{\"type\":\"turn.started\"}\n{\"type\":\"turn.completed\"}\n"), nil
+	})
+	if err != nil {
+		t.Fatalf("RunCodexWarmup: %v", err)
+	}
+	if threadID != "0199a213-81c0-7800-8aa1-bbab2a035a53" {
+		t.Fatalf("unexpected thread id %q", threadID)
+	}
+	if len(gotArgs) < 2 || gotArgs[0] != "exec" || !slices.Contains(gotArgs, "--json") {
+		t.Fatalf("expected codex exec --json, got %v", gotArgs)
+	}
+
+	if _, err := RunCodexWarmup(context.Background(), func(context.Context, ...string) ([]byte, error) {
+		return []byte("{\"type\":\"turn.completed\"}\n"), nil
+	}); err == nil {
+		t.Fatal("expected an error without a thread.started event")
+	}
+	execErr := errors.New("codex: not found")
+	if _, err := RunCodexWarmup(context.Background(), func(context.Context, ...string) ([]byte, error) {
+		return nil, execErr
+	}); !errors.Is(err, execErr) {
+		t.Fatalf("expected the exec error, got %v", err)
+	}
+}
+
+func TestLatestCodexSnapshotForThreadReadsOnlyThatThread(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeCodexLog(t, filepath.Join(dir, "2026", "10", "02", "rollout-2026-10-02T09-00-00-thread-a.jsonl"), now.Add(-time.Minute),
+		`{"timestamp":"2026-10-02T09:05:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":12,"window_minutes":300,"resets_at":1790000000}}}}`,
+	)
+	writeCodexLog(t, filepath.Join(dir, "2026", "10", "02", "rollout-2026-10-02T09-30-00-thread-b.jsonl"), now,
+		`{"timestamp":"2026-10-02T09:35:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"primary":{"used_percent":80,"window_minutes":300,"resets_at":1790000000}}}}`,
+	)
+
+	snap, err := LatestCodexSnapshotForThread(dir, "thread-a")
+	if err != nil {
+		t.Fatalf("LatestCodexSnapshotForThread: %v", err)
+	}
+	if snap.Session == nil || snap.Session.UsedPercent != 12 {
+		t.Fatalf("expected thread-a's snapshot, got %#v", snap.Session)
+	}
+	if _, err := LatestCodexSnapshotForThread(dir, "thread-c"); !errors.Is(err, ErrNoLocalSnapshot) {
+		t.Fatalf("expected ErrNoLocalSnapshot for an unknown thread, got %v", err)
+	}
+}
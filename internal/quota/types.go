@@ -16,6 +16,10 @@ type Snapshot struct {
 	SessionUnsupported bool
 	SourceTimestamp    time.Time
 	LimitReached       bool
+	AccountIDMismatch  bool
+	// ReturnedAccountID is the account the usage API answered for when it
+	// differs from the requested one.
+	ReturnedAccountID string
 }
 
 type rawWindow struct {
@@ -0,0 +1,21 @@
+//go:build !windows && !linux && !(darwin && cgo)
+
+package secrets
+
+func NewDefaultStore() Store {
+	return newDefaultFallbackStore()
+}
+
+func openBackend(name string) (Store, error) {
+	switch name {
+	case BackendFile:
+		return newDefaultFileStore(), nil
+	case BackendEncryptedFile:
+		return newDefaultEncryptedFileStore()
+	}
+	return nil, nil
+}
+
+func availableBackends() []string {
+	return []string{BackendFile, BackendEncryptedFile}
+}
@@ -9,6 +9,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 	"unsafe"
 
 	"golang.org/x/sys/windows"
@@ -31,6 +33,21 @@ func NewDefaultStore() Store {
 	return &DPAPIStore{baseDir: baseDir}
 }
 
+func openBackend(name string) (Store, error) {
+	if name == BackendDPAPI {
+		return NewDefaultStore(), nil
+	}
+	return nil, nil
+}
+
+func availableBackends() []string {
+	return []string{BackendDPAPI}
+}
+
+func (s *DPAPIStore) Backend() string {
+	return BackendDPAPI
+}
+
 func (s *DPAPIStore) path(accountID string) string {
 	name := base64.RawURLEncoding.EncodeToString([]byte(accountID))
 	return filepath.Join(s.baseDir, name+".bin")
@@ -75,6 +92,30 @@ func (s *DPAPIStore) Delete(accountID string) error {
 	return err
 }
 
+func (s *DPAPIStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".bin") {
+			continue
+		}
+		decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimSuffix(name, ".bin"))
+		if err != nil {
+			continue
+		}
+		ids = append(ids, string(decoded))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
 func dpapiProtect(plain []byte) ([]byte, error) {
 	in := bytesToBlob(plain)
 	var out windows.DataBlob
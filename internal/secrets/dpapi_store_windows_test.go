@@ -49,6 +49,14 @@ func TestDPAPIStorePutGetDelete(t *testing.T) {
 		t.Fatalf("unexpected value: %#v", got)
 	}
 
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != accountID {
+		t.Fatalf("unexpected list result: %v", ids)
+	}
+
 	if err := store.Delete(accountID); err != nil {
 		t.Fatalf("delete failed: %v", err)
 	}
@@ -0,0 +1,167 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"switchly/internal/model"
+	"switchly/internal/platform"
+)
+
+const (
+	encryptedSecretExt = ".enc"
+	storeKeyFile       = "store.key"
+	storeKeySize       = 32
+)
+
+var ErrSecretDecrypt = errors.New("decrypt secret: wrong key or corrupted file")
+
+// EncryptedFileStore keeps each account's secrets in its own AES-256-GCM
+// encrypted file. The key is a random file readable only by its owner, so
+// the secrets are as safe as that file; copied without it they are useless.
+type EncryptedFileStore struct {
+	baseDir string
+	key     []byte
+}
+
+func newDefaultEncryptedFileStore() (*EncryptedFileStore, error) {
+	dir, err := platform.ConfigDir()
+	if err != nil {
+		return nil, err
+	}
+	key, err := LoadOrCreateKey(filepath.Join(dir, storeKeyFile), storeKeySize)
+	if err != nil {
+		return nil, err
+	}
+	baseDir := filepath.Join(dir, "secrets-encrypted")
+	if err := os.MkdirAll(baseDir, 0o700); err != nil {
+		return nil, err
+	}
+	return &EncryptedFileStore{baseDir: baseDir, key: key}, nil
+}
+
+// newDefaultFallbackStore is used when the platform's native store is not
+// available. Machines that already keep plaintext secret files stay on them
+// until they are migrated, so existing accounts keep working.
+func newDefaultFallbackStore() Store {
+	plain := newDefaultFileStore()
+	if ids, err := plain.List(); err == nil && len(ids) > 0 {
+		log.Printf("using plaintext secret files; run `switchly secrets migrate --from %s --to %s` to encrypt them", BackendFile, BackendEncryptedFile)
+		return plain
+	}
+	store, err := newDefaultEncryptedFileStore()
+	if err != nil {
+		log.Printf("encrypted secret store unavailable, falling back to plaintext files: %v", err)
+		return plain
+	}
+	return store
+}
+
+// preferLegacyFileStore keeps machines that still hold plaintext secret
+// files on them until they are migrated to native, so accounts saved before
+// the native store existed keep working after an upgrade.
+func preferLegacyFileStore(native Store, plain *FileStore) Store {
+	if ids, err := plain.List(); err == nil && len(ids) > 0 {
+		log.Printf("using plaintext secret files; run `switchly secrets migrate --from %s --to %s` to move them", BackendFile, BackendOf(native))
+		return plain
+	}
+	return native
+}
+
+func (s *EncryptedFileStore) Backend() string {
+	return BackendEncryptedFile
+}
+
+func (s *EncryptedFileStore) path(accountID string) string {
+	return filepath.Join(s.baseDir, accountID+encryptedSecretExt)
+}
+
+func (s *EncryptedFileStore) aead() (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Put writes the random nonce followed by the ciphertext. The account id is
+// authenticated so a file renamed to another account fails to decrypt.
+func (s *EncryptedFileStore) Put(accountID string, secrets model.AuthSecrets) error {
+	plain, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	aead, err := s.aead()
+	if err != nil {
+		return err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("generate nonce: %w", err)
+	}
+	data := aead.Seal(nonce, nonce, plain, []byte(accountID))
+	return os.WriteFile(s.path(accountID), data, 0o600)
+}
+
+func (s *EncryptedFileStore) Get(accountID string) (model.AuthSecrets, error) {
+	data, err := os.ReadFile(s.path(accountID))
+	if err != nil {
+		return model.AuthSecrets{}, err
+	}
+	aead, err := s.aead()
+	if err != nil {
+		return model.AuthSecrets{}, err
+	}
+	if len(data) < aead.NonceSize()+aead.Overhead() {
+		return model.AuthSecrets{}, fmt.Errorf("encrypted secret for %s is truncated: missing nonce", accountID)
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	plain, err := aead.Open(nil, nonce, ciphertext, []byte(accountID))
+	if err != nil {
+		return model.AuthSecrets{}, fmt.Errorf("%s: %w", accountID, ErrSecretDecrypt)
+	}
+	var out model.AuthSecrets
+	if err := json.Unmarshal(plain, &out); err != nil {
+		return model.AuthSecrets{}, err
+	}
+	return out, nil
+}
+
+func (s *EncryptedFileStore) Delete(accountID string) error {
+	err := os.Remove(s.path(accountID))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func (s *EncryptedFileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, encryptedSecretExt) {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, encryptedSecretExt))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
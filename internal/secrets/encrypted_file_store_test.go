@@ -0,0 +1,82 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func newTestEncryptedFileStore(t *testing.T, key []byte) *EncryptedFileStore {
+	t.Helper()
+	return &EncryptedFileStore{baseDir: t.TempDir(), key: key}
+}
+
+func TestEncryptedFileStoreRoundTrip(t *testing.T) {
+	store := newTestEncryptedFileStore(t, bytes.Repeat([]byte{1}, storeKeySize))
+	in := model.AuthSecrets{AccessToken: "access-secret", RefreshToken: "refresh-secret"}
+	if err := store.Put("codex:a@example.com", in); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+
+	raw, err := os.ReadFile(store.path("codex:a@example.com"))
+	if err != nil {
+		t.Fatalf("read file: %v", err)
+	}
+	if bytes.Contains(raw, []byte("access-secret")) {
+		t.Fatal("secret file contains the plaintext token")
+	}
+
+	out, err := store.Get("codex:a@example.com")
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	if out.AccessToken != in.AccessToken || out.RefreshToken != in.RefreshToken {
+		t.Fatalf("unexpected secrets: %#v", out)
+	}
+	ids, err := store.List()
+	if err != nil || len(ids) != 1 || ids[0] != "codex:a@example.com" {
+		t.Fatalf("unexpected list %v (%v)", ids, err)
+	}
+	if err := store.Delete("codex:a@example.com"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get("codex:a@example.com"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist after delete, got %v", err)
+	}
+}
+
+func TestEncryptedFileStoreWrongKey(t *testing.T) {
+	store := newTestEncryptedFileStore(t, bytes.Repeat([]byte{1}, storeKeySize))
+	if err := store.Put("codex:a@example.com", model.AuthSecrets{AccessToken: "token"}); err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	other := &EncryptedFileStore{baseDir: store.baseDir, key: bytes.Repeat([]byte{2}, storeKeySize)}
+	if _, err := other.Get("codex:a@example.com"); !errors.Is(err, ErrSecretDecrypt) {
+		t.Fatalf("expected ErrSecretDecrypt with the wrong key, got %v", err)
+	}
+
+	// A file copied to another account's name does not decrypt either.
+	data, _ := os.ReadFile(store.path("codex:a@example.com"))
+	if err := os.WriteFile(store.path("codex:b@example.com"), data, 0o600); err != nil {
+		t.Fatalf("copy file: %v", err)
+	}
+	if _, err := store.Get("codex:b@example.com"); !errors.Is(err, ErrSecretDecrypt) {
+		t.Fatalf("expected ErrSecretDecrypt for a renamed file, got %v", err)
+	}
+}
+
+func TestEncryptedFileStoreMissingNonce(t *testing.T) {
+	store := newTestEncryptedFileStore(t, bytes.Repeat([]byte{1}, storeKeySize))
+	if err := os.WriteFile(store.path("codex:a@example.com"), []byte("short"), 0o600); err != nil {
+		t.Fatalf("write file: %v", err)
+	}
+	if _, err := store.Get("codex:a@example.com"); err == nil || !strings.Contains(err.Error(), "missing nonce") {
+		t.Fatalf("expected a missing nonce error, got %v", err)
+	}
+}
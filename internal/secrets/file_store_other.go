@@ -7,6 +7,8 @@ import (
 	"errors"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"switchly/internal/model"
 	"switchly/internal/platform"
@@ -16,7 +18,7 @@ type FileStore struct {
 	baseDir string
 }
 
-func NewDefaultStore() Store {
+func newDefaultFileStore() *FileStore {
 	dir, err := platform.ConfigDir()
 	if err != nil {
 		dir = "."
@@ -25,6 +27,10 @@ func NewDefaultStore() Store {
 	return &FileStore{baseDir: filepath.Join(dir, "secrets")}
 }
 
+func (s *FileStore) Backend() string {
+	return BackendFile
+}
+
 func (s *FileStore) path(accountID string) string {
 	return filepath.Join(s.baseDir, accountID+".json")
 }
@@ -56,3 +62,23 @@ func (s *FileStore) Delete(accountID string) error {
 	}
 	return err
 }
+
+func (s *FileStore) List() ([]string, error) {
+	entries, err := os.ReadDir(s.baseDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return []string{}, nil
+		}
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(name, ".json"))
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
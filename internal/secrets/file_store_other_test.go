@@ -0,0 +1,31 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func TestFileStoreList(t *testing.T) {
+	store := &FileStore{baseDir: t.TempDir()}
+	for _, id := range []string{"codex:b@example.com", "codex:a@example.com"} {
+		if err := store.Put(id, model.AuthSecrets{AccessToken: "token"}); err != nil {
+			t.Fatalf("put %s: %v", id, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(store.baseDir, "notes.txt"), []byte("x"), 0o600); err != nil {
+		t.Fatalf("seed unrelated file: %v", err)
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "codex:a@example.com" || ids[1] != "codex:b@example.com" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+}
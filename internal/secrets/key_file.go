@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LoadOrCreateKey reads the size-byte key at path, creating it from random
+// bytes with mode 0600 on first use. The file is created exclusively, so
+// when two processes start at once both end up with the same key.
+func LoadOrCreateKey(path string, size int) ([]byte, error) {
+	key, err := readKeyFile(path, size)
+	if !errors.Is(err, os.ErrNotExist) {
+		return key, err
+	}
+	key = make([]byte, size)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("generate key: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+	if errors.Is(err, os.ErrExist) {
+		// Another process created it first; wait for it to finish writing.
+		for range 50 {
+			if key, err = readKeyFile(path, size); err == nil {
+				return key, nil
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+	_, err = f.Write(key)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return nil, fmt.Errorf("write key %s: %w", path, err)
+	}
+	return key, nil
+}
+
+func readKeyFile(path string, size int) ([]byte, error) {
+	key, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(key) != size {
+		return nil, fmt.Errorf("key %s has %d bytes, want %d", path, len(key), size)
+	}
+	return key, nil
+}
@@ -0,0 +1,60 @@
+package secrets
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+	"testing"
+)
+
+func TestLoadOrCreateKey(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "config", "test.key")
+
+	generated, err := LoadOrCreateKey(keyPath, 32)
+	if err != nil || len(generated) != 32 {
+		t.Fatalf("generate key: %v (len %d)", err, len(generated))
+	}
+	info, err := os.Stat(keyPath)
+	if err != nil {
+		t.Fatalf("stat key file: %v", err)
+	}
+	if perm := info.Mode().Perm(); runtime.GOOS != "windows" && perm != 0o600 {
+		t.Fatalf("expected key file mode 0600, got %o", perm)
+	}
+	reused, _ := LoadOrCreateKey(keyPath, 32)
+	if !bytes.Equal(generated, reused) {
+		t.Fatal("expected the generated key to be reused")
+	}
+
+	if err := os.WriteFile(keyPath, []byte("short"), 0o600); err != nil {
+		t.Fatalf("write short key: %v", err)
+	}
+	if _, err := LoadOrCreateKey(keyPath, 32); err == nil {
+		t.Fatal("expected an error for a key of the wrong size")
+	}
+}
+
+func TestLoadOrCreateKeyConcurrentCreate(t *testing.T) {
+	keyPath := filepath.Join(t.TempDir(), "test.key")
+	keys := make([][]byte, 8)
+	var wg sync.WaitGroup
+	for i := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := LoadOrCreateKey(keyPath, 32)
+			if err != nil {
+				t.Errorf("load key: %v", err)
+			}
+			keys[i] = key
+		}()
+	}
+	wg.Wait()
+	for i, key := range keys[1:] {
+		if !bytes.Equal(key, keys[0]) {
+			t.Fatalf("caller %d got a different key", i+1)
+		}
+	}
+}
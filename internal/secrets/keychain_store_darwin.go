@@ -0,0 +1,256 @@
+//go:build darwin && cgo
+
+package secrets
+
+/*
+#cgo LDFLAGS: -framework CoreFoundation -framework Security
+#include <CoreFoundation/CoreFoundation.h>
+#include <Security/Security.h>
+#include <stdlib.h>
+#include <string.h>
+
+static CFMutableDictionaryRef switchly_keychain_query(const char *service, const char *account) {
+	CFMutableDictionaryRef query = CFDictionaryCreateMutable(kCFAllocatorDefault, 0, &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+	CFDictionarySetValue(query, kSecClass, kSecClassGenericPassword);
+	CFStringRef svc = CFStringCreateWithCString(kCFAllocatorDefault, service, kCFStringEncodingUTF8);
+	CFDictionarySetValue(query, kSecAttrService, svc);
+	CFRelease(svc);
+	if (account != NULL) {
+		CFStringRef acct = CFStringCreateWithCString(kCFAllocatorDefault, account, kCFStringEncodingUTF8);
+		CFDictionarySetValue(query, kSecAttrAccount, acct);
+		CFRelease(acct);
+	}
+	return query;
+}
+
+static OSStatus switchly_keychain_put(const char *service, const char *account, const void *data, int length) {
+	CFDataRef value = CFDataCreate(kCFAllocatorDefault, (const UInt8 *)data, length);
+	CFMutableDictionaryRef query = switchly_keychain_query(service, account);
+	CFDictionarySetValue(query, kSecValueData, value);
+	OSStatus status = SecItemAdd(query, NULL);
+	CFRelease(query);
+	if (status == errSecDuplicateItem) {
+		CFMutableDictionaryRef match = switchly_keychain_query(service, account);
+		CFMutableDictionaryRef attrs = CFDictionaryCreateMutable(kCFAllocatorDefault, 0, &kCFTypeDictionaryKeyCallBacks, &kCFTypeDictionaryValueCallBacks);
+		CFDictionarySetValue(attrs, kSecValueData, value);
+		status = SecItemUpdate(match, attrs);
+		CFRelease(attrs);
+		CFRelease(match);
+	}
+	CFRelease(value);
+	return status;
+}
+
+static OSStatus switchly_keychain_get(const char *service, const char *account, void **out, int *outLen) {
+	CFMutableDictionaryRef query = switchly_keychain_query(service, account);
+	CFDictionarySetValue(query, kSecReturnData, kCFBooleanTrue);
+	CFDictionarySetValue(query, kSecMatchLimit, kSecMatchLimitOne);
+	CFTypeRef result = NULL;
+	OSStatus status = SecItemCopyMatching(query, &result);
+	CFRelease(query);
+	*out = NULL;
+	*outLen = 0;
+	if (status != errSecSuccess) {
+		return status;
+	}
+	CFIndex length = CFDataGetLength((CFDataRef)result);
+	if (length > 0) {
+		*out = malloc(length);
+		memcpy(*out, CFDataGetBytePtr((CFDataRef)result), length);
+		*outLen = (int)length;
+	}
+	CFRelease(result);
+	return errSecSuccess;
+}
+
+static OSStatus switchly_keychain_delete(const char *service, const char *account) {
+	CFMutableDictionaryRef query = switchly_keychain_query(service, account);
+	OSStatus status = SecItemDelete(query);
+	CFRelease(query);
+	return status;
+}
+
+static OSStatus switchly_keychain_list(const char *service, char **out) {
+	CFMutableDictionaryRef query = switchly_keychain_query(service, NULL);
+	CFDictionarySetValue(query, kSecReturnAttributes, kCFBooleanTrue);
+	CFDictionarySetValue(query, kSecMatchLimit, kSecMatchLimitAll);
+	CFTypeRef result = NULL;
+	OSStatus status = SecItemCopyMatching(query, &result);
+	CFRelease(query);
+	*out = NULL;
+	if (status != errSecSuccess) {
+		return status;
+	}
+
+	CFArrayRef items = (CFArrayRef)result;
+	CFIndex count = CFArrayGetCount(items);
+	size_t total = 1;
+	for (CFIndex i = 0; i < count; i++) {
+		CFStringRef acct = CFDictionaryGetValue(CFArrayGetValueAtIndex(items, i), kSecAttrAccount);
+		if (acct != NULL) {
+			total += CFStringGetMaximumSizeForEncoding(CFStringGetLength(acct), kCFStringEncodingUTF8) + 1;
+		}
+	}
+	char *buf = calloc(total, 1);
+	size_t offset = 0;
+	for (CFIndex i = 0; i < count; i++) {
+		CFStringRef acct = CFDictionaryGetValue(CFArrayGetValueAtIndex(items, i), kSecAttrAccount);
+		if (acct != NULL && CFStringGetCString(acct, buf + offset, total - offset, kCFStringEncodingUTF8)) {
+			offset += strlen(buf + offset);
+			buf[offset++] = '\n';
+		}
+	}
+	buf[offset] = '\0';
+	CFRelease(result);
+	*out = buf;
+	return errSecSuccess;
+}
+*/
+import "C"
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"unsafe"
+
+	"switchly/internal/model"
+)
+
+const keychainService = "switchly"
+
+const keychainProbeAccount = "switchly-keychain-probe"
+
+type KeychainStore struct {
+	service string
+}
+
+func NewDefaultStore() Store {
+	store := &KeychainStore{service: keychainService}
+	if err := store.probe(); err != nil {
+		log.Printf("macOS keychain unavailable, falling back to file secret store: %v", err)
+		return newDefaultFallbackStore()
+	}
+	return preferLegacyFileStore(store, newDefaultFileStore())
+}
+
+func openBackend(name string) (Store, error) {
+	switch name {
+	case BackendFile:
+		return newDefaultFileStore(), nil
+	case BackendEncryptedFile:
+		return newDefaultEncryptedFileStore()
+	case BackendKeychain:
+		store := &KeychainStore{service: keychainService}
+		if err := store.probe(); err != nil {
+			return nil, fmt.Errorf("open macOS keychain: %w", err)
+		}
+		return store, nil
+	}
+	return nil, nil
+}
+
+func availableBackends() []string {
+	return []string{BackendFile, BackendEncryptedFile, BackendKeychain}
+}
+
+func (s *KeychainStore) Backend() string {
+	return BackendKeychain
+}
+
+func (s *KeychainStore) probe() error {
+	_, err := s.Get(keychainProbeAccount)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (s *KeychainStore) Put(accountID string, secrets model.AuthSecrets) error {
+	payload, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+
+	service := C.CString(s.service)
+	defer C.free(unsafe.Pointer(service))
+	account := C.CString(accountID)
+	defer C.free(unsafe.Pointer(account))
+	data := C.CBytes(payload)
+	defer C.free(data)
+
+	if status := C.switchly_keychain_put(service, account, data, C.int(len(payload))); status != C.errSecSuccess {
+		return keychainError("put", status)
+	}
+	return nil
+}
+
+func (s *KeychainStore) Get(accountID string) (model.AuthSecrets, error) {
+	service := C.CString(s.service)
+	defer C.free(unsafe.Pointer(service))
+	account := C.CString(accountID)
+	defer C.free(unsafe.Pointer(account))
+
+	var out unsafe.Pointer
+	var outLen C.int
+	status := C.switchly_keychain_get(service, account, &out, &outLen)
+	if status == C.errSecItemNotFound {
+		return model.AuthSecrets{}, fmt.Errorf("keychain item %s: %w", accountID, os.ErrNotExist)
+	}
+	if status != C.errSecSuccess {
+		return model.AuthSecrets{}, keychainError("get", status)
+	}
+	defer C.free(out)
+
+	var secrets model.AuthSecrets
+	if err := json.Unmarshal(C.GoBytes(out, outLen), &secrets); err != nil {
+		return model.AuthSecrets{}, err
+	}
+	return secrets, nil
+}
+
+func (s *KeychainStore) Delete(accountID string) error {
+	service := C.CString(s.service)
+	defer C.free(unsafe.Pointer(service))
+	account := C.CString(accountID)
+	defer C.free(unsafe.Pointer(account))
+
+	status := C.switchly_keychain_delete(service, account)
+	if status != C.errSecSuccess && status != C.errSecItemNotFound {
+		return keychainError("delete", status)
+	}
+	return nil
+}
+
+func (s *KeychainStore) List() ([]string, error) {
+	service := C.CString(s.service)
+	defer C.free(unsafe.Pointer(service))
+
+	var out *C.char
+	status := C.switchly_keychain_list(service, &out)
+	if status == C.errSecItemNotFound {
+		return []string{}, nil
+	}
+	if status != C.errSecSuccess {
+		return nil, keychainError("list", status)
+	}
+	defer C.free(unsafe.Pointer(out))
+
+	ids := []string{}
+	for _, id := range strings.Split(C.GoString(out), "\n") {
+		if id == "" || id == keychainProbeAccount {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func keychainError(op string, status C.OSStatus) error {
+	return fmt.Errorf("keychain %s failed: OSStatus %d", op, int(status))
+}
@@ -0,0 +1,55 @@
+//go:build darwin && cgo
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+)
+
+func TestKeychainStoreRoundTrip(t *testing.T) {
+	store := &KeychainStore{service: fmt.Sprintf("switchly-test-%d", time.Now().UnixNano())}
+	if err := store.probe(); err != nil {
+		t.Skipf("keychain unavailable: %v", err)
+	}
+
+	const accountID = "codex:test@example.com"
+	t.Cleanup(func() { _ = store.Delete(accountID) })
+
+	in := model.AuthSecrets{AccessToken: "access-token", RefreshToken: "refresh-token"}
+	if err := store.Put(accountID, in); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	in.AccessToken = "rotated-token"
+	if err := store.Put(accountID, in); err != nil {
+		t.Fatalf("overwrite failed: %v", err)
+	}
+
+	out, err := store.Get(accountID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if out.AccessToken != "rotated-token" || out.RefreshToken != "refresh-token" {
+		t.Fatalf("unexpected secrets: %#v", out)
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != accountID {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if err := store.Delete(accountID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := store.Get(accountID); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error after delete, got %v", err)
+	}
+}
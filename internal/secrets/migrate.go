@@ -0,0 +1,54 @@
+package secrets
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MigrationError lists the accounts whose secrets could not be copied.
+type MigrationError struct {
+	Failed map[string]error
+}
+
+func (e *MigrationError) Error() string {
+	ids := make([]string, 0, len(e.Failed))
+	for id := range e.Failed {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	parts := make([]string, 0, len(ids))
+	for _, id := range ids {
+		parts = append(parts, fmt.Sprintf("%s: %v", id, e.Failed[id]))
+	}
+	return fmt.Sprintf("migrate secrets for %d account(s): %s", len(ids), strings.Join(parts, "; "))
+}
+
+// Migrate copies the secrets of accountIDs from src to dst. A failing
+// account does not stop the others; failures are returned together as a
+// *MigrationError. A nil accountIDs migrates everything src lists. Secrets
+// are left in src so a failed migration can be retried.
+func Migrate(src Store, dst Store, accountIDs []string) error {
+	if accountIDs == nil {
+		ids, err := src.List()
+		if err != nil {
+			return fmt.Errorf("list source secrets: %w", err)
+		}
+		accountIDs = ids
+	}
+	failed := map[string]error{}
+	for _, id := range accountIDs {
+		data, err := src.Get(id)
+		if err != nil {
+			failed[id] = fmt.Errorf("read: %w", err)
+			continue
+		}
+		if err := dst.Put(id, data); err != nil {
+			failed[id] = fmt.Errorf("write: %w", err)
+		}
+	}
+	if len(failed) > 0 {
+		return &MigrationError{Failed: failed}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+//go:build !windows
+
+package secrets
+
+import (
+	"errors"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func TestMigrateCopiesSecretsBetweenFileStores(t *testing.T) {
+	src := &FileStore{baseDir: t.TempDir()}
+	dst := &FileStore{baseDir: t.TempDir()}
+	want := map[string]model.AuthSecrets{
+		"codex:a@example.com": {AccessToken: "token-a", RefreshToken: "refresh-a"},
+		"codex:b@example.com": {AccessToken: "token-b", AccountID: "acct-b"},
+	}
+	for id, data := range want {
+		if err := src.Put(id, data); err != nil {
+			t.Fatalf("seed %s: %v", id, err)
+		}
+	}
+
+	if err := Migrate(src, dst, nil); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	for id, data := range want {
+		got, err := dst.Get(id)
+		if err != nil {
+			t.Fatalf("read migrated %s: %v", id, err)
+		}
+		if got != data {
+			t.Fatalf("migrated secrets for %s mismatch: got %#v want %#v", id, got, data)
+		}
+		if _, err := src.Get(id); err != nil {
+			t.Fatalf("expected source secrets for %s to be kept: %v", id, err)
+		}
+	}
+}
+
+func TestMigrateReportsPerAccountErrors(t *testing.T) {
+	src := &FileStore{baseDir: t.TempDir()}
+	dst := &FileStore{baseDir: t.TempDir()}
+	if err := src.Put("codex:a@example.com", model.AuthSecrets{AccessToken: "token-a"}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+
+	err := Migrate(src, dst, []string{"codex:missing@example.com", "codex:a@example.com"})
+	var migrationErr *MigrationError
+	if !errors.As(err, &migrationErr) {
+		t.Fatalf("expected a MigrationError, got %v", err)
+	}
+	if len(migrationErr.Failed) != 1 || migrationErr.Failed["codex:missing@example.com"] == nil {
+		t.Fatalf("unexpected failures: %v", migrationErr.Failed)
+	}
+	if got, err := dst.Get("codex:a@example.com"); err != nil || got.AccessToken != "token-a" {
+		t.Fatalf("expected the readable account to migrate despite the failure, got %#v, %v", got, err)
+	}
+}
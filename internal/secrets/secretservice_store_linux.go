@@ -0,0 +1,309 @@
+//go:build linux
+
+package secrets
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/godbus/dbus/v5"
+
+	"switchly/internal/model"
+)
+
+const (
+	secretServiceName       = "org.freedesktop.secrets"
+	secretServicePath       = dbus.ObjectPath("/org/freedesktop/secrets")
+	secretServiceIface      = "org.freedesktop.Secret.Service"
+	secretCollectionIface   = "org.freedesktop.Secret.Collection"
+	secretItemIface         = "org.freedesktop.Secret.Item"
+	secretPromptIface       = "org.freedesktop.Secret.Prompt"
+	secretServiceLoginPath  = dbus.ObjectPath("/org/freedesktop/secrets/collection/login")
+	secretServiceAppName    = "switchly"
+	secretServicePromptWait = 2 * time.Minute
+)
+
+var secretServiceFallbackOnce sync.Once
+
+type secretServiceBackend interface {
+	search(attrs map[string]string) ([]dbus.ObjectPath, error)
+	getSecret(item dbus.ObjectPath) ([]byte, error)
+	createItem(label string, attrs map[string]string, data []byte) error
+	deleteItem(item dbus.ObjectPath) error
+	attributes(item dbus.ObjectPath) (map[string]string, error)
+}
+
+type SecretServiceStore struct {
+	backend secretServiceBackend
+	service string
+}
+
+func NewDefaultStore() Store {
+	backend, err := newDBusSecretService()
+	if err != nil {
+		secretServiceFallbackOnce.Do(func() {
+			log.Printf("secret service unavailable, falling back to file secret store: %v", err)
+		})
+		return newDefaultFallbackStore()
+	}
+	return preferLegacyFileStore(&SecretServiceStore{backend: backend, service: secretServiceAppName}, newDefaultFileStore())
+}
+
+func openBackend(name string) (Store, error) {
+	switch name {
+	case BackendFile:
+		return newDefaultFileStore(), nil
+	case BackendEncryptedFile:
+		return newDefaultEncryptedFileStore()
+	case BackendSecretService:
+		backend, err := newDBusSecretService()
+		if err != nil {
+			return nil, fmt.Errorf("open secret service: %w", err)
+		}
+		return &SecretServiceStore{backend: backend, service: secretServiceAppName}, nil
+	}
+	return nil, nil
+}
+
+func availableBackends() []string {
+	return []string{BackendFile, BackendEncryptedFile, BackendSecretService}
+}
+
+func (s *SecretServiceStore) Backend() string {
+	return BackendSecretService
+}
+
+func (s *SecretServiceStore) itemAttributes(accountID string) map[string]string {
+	return map[string]string{"service": s.service, "account": accountID}
+}
+
+func (s *SecretServiceStore) Put(accountID string, secrets model.AuthSecrets) error {
+	data, err := json.Marshal(secrets)
+	if err != nil {
+		return err
+	}
+	return s.backend.createItem(s.service+": "+accountID, s.itemAttributes(accountID), data)
+}
+
+func (s *SecretServiceStore) Get(accountID string) (model.AuthSecrets, error) {
+	items, err := s.backend.search(s.itemAttributes(accountID))
+	if err != nil {
+		return model.AuthSecrets{}, err
+	}
+	if len(items) == 0 {
+		return model.AuthSecrets{}, fmt.Errorf("secret service item %s: %w", accountID, os.ErrNotExist)
+	}
+	data, err := s.backend.getSecret(items[0])
+	if err != nil {
+		return model.AuthSecrets{}, err
+	}
+	var out model.AuthSecrets
+	if err := json.Unmarshal(data, &out); err != nil {
+		return model.AuthSecrets{}, err
+	}
+	return out, nil
+}
+
+func (s *SecretServiceStore) Delete(accountID string) error {
+	items, err := s.backend.search(s.itemAttributes(accountID))
+	if err != nil {
+		return err
+	}
+	for _, item := range items {
+		if err := s.backend.deleteItem(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *SecretServiceStore) List() ([]string, error) {
+	items, err := s.backend.search(map[string]string{"service": s.service})
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]struct{}, len(items))
+	ids := make([]string, 0, len(items))
+	for _, item := range items {
+		attrs, err := s.backend.attributes(item)
+		if err != nil {
+			return nil, err
+		}
+		id := attrs["account"]
+		if id == "" {
+			continue
+		}
+		if _, ok := seen[id]; ok {
+			continue
+		}
+		seen[id] = struct{}{}
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+type secretServiceSecret struct {
+	Session     dbus.ObjectPath
+	Parameters  []byte
+	Value       []byte
+	ContentType string
+}
+
+type dbusSecretService struct {
+	conn       *dbus.Conn
+	session    dbus.ObjectPath
+	collection dbus.ObjectPath
+}
+
+func newDBusSecretService() (*dbusSecretService, error) {
+	// Avoid godbus autolaunching a session bus when none is configured.
+	if os.Getenv("DBUS_SESSION_BUS_ADDRESS") == "" {
+		runtimeDir := os.Getenv("XDG_RUNTIME_DIR")
+		if runtimeDir == "" {
+			return nil, errors.New("no D-Bus session bus configured")
+		}
+		if _, err := os.Stat(filepath.Join(runtimeDir, "bus")); err != nil {
+			return nil, fmt.Errorf("no D-Bus session bus: %w", err)
+		}
+	}
+
+	conn, err := dbus.SessionBus()
+	if err != nil {
+		return nil, fmt.Errorf("connect session bus: %w", err)
+	}
+
+	service := conn.Object(secretServiceName, secretServicePath)
+	var output dbus.Variant
+	var session dbus.ObjectPath
+	if err := service.Call(secretServiceIface+".OpenSession", 0, "plain", dbus.MakeVariant("")).Store(&output, &session); err != nil {
+		return nil, fmt.Errorf("open secret service session: %w", err)
+	}
+
+	var collection dbus.ObjectPath
+	if err := service.Call(secretServiceIface+".ReadAlias", 0, "default").Store(&collection); err != nil {
+		return nil, fmt.Errorf("read default collection: %w", err)
+	}
+	if collection == "/" {
+		collection = secretServiceLoginPath
+	}
+	return &dbusSecretService{conn: conn, session: session, collection: collection}, nil
+}
+
+func (d *dbusSecretService) search(attrs map[string]string) ([]dbus.ObjectPath, error) {
+	var items []dbus.ObjectPath
+	if err := d.conn.Object(secretServiceName, d.collection).Call(secretCollectionIface+".SearchItems", 0, attrs).Store(&items); err != nil {
+		return nil, fmt.Errorf("search secret service items: %w", err)
+	}
+	return items, nil
+}
+
+func (d *dbusSecretService) getSecret(item dbus.ObjectPath) ([]byte, error) {
+	if err := d.unlock(item); err != nil {
+		return nil, err
+	}
+	var secret secretServiceSecret
+	if err := d.conn.Object(secretServiceName, item).Call(secretItemIface+".GetSecret", 0, d.session).Store(&secret); err != nil {
+		return nil, fmt.Errorf("get secret: %w", err)
+	}
+	return secret.Value, nil
+}
+
+func (d *dbusSecretService) createItem(label string, attrs map[string]string, data []byte) error {
+	if err := d.unlock(d.collection); err != nil {
+		return err
+	}
+	props := map[string]dbus.Variant{
+		secretItemIface + ".Label":      dbus.MakeVariant(label),
+		secretItemIface + ".Attributes": dbus.MakeVariant(attrs),
+	}
+	secret := secretServiceSecret{
+		Session:     d.session,
+		Parameters:  []byte{},
+		Value:       data,
+		ContentType: "application/json",
+	}
+	var item, prompt dbus.ObjectPath
+	if err := d.conn.Object(secretServiceName, d.collection).Call(secretCollectionIface+".CreateItem", 0, props, secret, true).Store(&item, &prompt); err != nil {
+		return fmt.Errorf("create secret item: %w", err)
+	}
+	return d.prompt(prompt)
+}
+
+func (d *dbusSecretService) deleteItem(item dbus.ObjectPath) error {
+	var prompt dbus.ObjectPath
+	if err := d.conn.Object(secretServiceName, item).Call(secretItemIface+".Delete", 0).Store(&prompt); err != nil {
+		return fmt.Errorf("delete secret item: %w", err)
+	}
+	return d.prompt(prompt)
+}
+
+func (d *dbusSecretService) attributes(item dbus.ObjectPath) (map[string]string, error) {
+	value, err := d.conn.Object(secretServiceName, item).GetProperty(secretItemIface + ".Attributes")
+	if err != nil {
+		return nil, fmt.Errorf("read secret item attributes: %w", err)
+	}
+	attrs, ok := value.Value().(map[string]string)
+	if !ok {
+		return nil, fmt.Errorf("unexpected secret item attributes type %T", value.Value())
+	}
+	return attrs, nil
+}
+
+func (d *dbusSecretService) unlock(paths ...dbus.ObjectPath) error {
+	var unlocked []dbus.ObjectPath
+	var prompt dbus.ObjectPath
+	if err := d.conn.Object(secretServiceName, secretServicePath).Call(secretServiceIface+".Unlock", 0, paths).Store(&unlocked, &prompt); err != nil {
+		return fmt.Errorf("unlock secret service: %w", err)
+	}
+	return d.prompt(prompt)
+}
+
+func (d *dbusSecretService) prompt(path dbus.ObjectPath) error {
+	if path == "" || path == "/" {
+		return nil
+	}
+
+	matchOpts := []dbus.MatchOption{
+		dbus.WithMatchObjectPath(path),
+		dbus.WithMatchInterface(secretPromptIface),
+		dbus.WithMatchMember("Completed"),
+	}
+	if err := d.conn.AddMatchSignal(matchOpts...); err != nil {
+		return fmt.Errorf("watch secret service prompt: %w", err)
+	}
+	defer d.conn.RemoveMatchSignal(matchOpts...)
+
+	signals := make(chan *dbus.Signal, 4)
+	d.conn.Signal(signals)
+	defer d.conn.RemoveSignal(signals)
+
+	if err := d.conn.Object(secretServiceName, path).Call(secretPromptIface+".Prompt", 0, "").Err; err != nil {
+		return fmt.Errorf("secret service prompt: %w", err)
+	}
+
+	timeout := time.After(secretServicePromptWait)
+	for {
+		select {
+		case signal := <-signals:
+			if signal == nil || signal.Path != path || signal.Name != secretPromptIface+".Completed" {
+				continue
+			}
+			if len(signal.Body) > 0 {
+				if dismissed, ok := signal.Body[0].(bool); ok && dismissed {
+					return errors.New("secret service prompt dismissed")
+				}
+			}
+			return nil
+		case <-timeout:
+			return errors.New("secret service prompt timed out")
+		}
+	}
+}
@@ -0,0 +1,48 @@
+//go:build linux && integration
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+)
+
+func TestSecretServiceStoreIntegration(t *testing.T) {
+	backend, err := newDBusSecretService()
+	if err != nil {
+		t.Skipf("secret service unavailable: %v", err)
+	}
+	store := &SecretServiceStore{backend: backend, service: fmt.Sprintf("switchly-test-%d", time.Now().UnixNano())}
+
+	const accountID = "codex:test@example.com"
+	t.Cleanup(func() { _ = store.Delete(accountID) })
+
+	if err := store.Put(accountID, model.AuthSecrets{AccessToken: "access-token"}); err != nil {
+		t.Fatalf("put failed: %v", err)
+	}
+	out, err := store.Get(accountID)
+	if err != nil {
+		t.Fatalf("get failed: %v", err)
+	}
+	if out.AccessToken != "access-token" {
+		t.Fatalf("unexpected secrets: %#v", out)
+	}
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(ids) != 1 || ids[0] != accountID {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+	if err := store.Delete(accountID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+	if _, err := store.Get(accountID); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error after delete, got %v", err)
+	}
+}
@@ -0,0 +1,175 @@
+//go:build linux
+
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/godbus/dbus/v5"
+
+	"switchly/internal/model"
+)
+
+type fakeSecretServiceItem struct {
+	label  string
+	attrs  map[string]string
+	secret []byte
+	locked bool
+}
+
+type fakeSecretService struct {
+	items   map[dbus.ObjectPath]*fakeSecretServiceItem
+	next    int
+	unlocks int
+}
+
+func newFakeSecretService() *fakeSecretService {
+	return &fakeSecretService{items: map[dbus.ObjectPath]*fakeSecretServiceItem{}}
+}
+
+func (f *fakeSecretService) search(attrs map[string]string) ([]dbus.ObjectPath, error) {
+	var out []dbus.ObjectPath
+	for path, item := range f.items {
+		match := true
+		for k, v := range attrs {
+			if item.attrs[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			out = append(out, path)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeSecretService) getSecret(path dbus.ObjectPath) ([]byte, error) {
+	item, ok := f.items[path]
+	if !ok {
+		return nil, errors.New("no such item")
+	}
+	if item.locked {
+		f.unlocks++
+		item.locked = false
+	}
+	return item.secret, nil
+}
+
+func (f *fakeSecretService) createItem(label string, attrs map[string]string, data []byte) error {
+	for _, item := range f.items {
+		if item.attrs["service"] == attrs["service"] && item.attrs["account"] == attrs["account"] {
+			item.label = label
+			item.secret = data
+			return nil
+		}
+	}
+	f.next++
+	path := dbus.ObjectPath(fmt.Sprintf("/org/freedesktop/secrets/collection/login/%d", f.next))
+	f.items[path] = &fakeSecretServiceItem{label: label, attrs: attrs, secret: data, locked: true}
+	return nil
+}
+
+func (f *fakeSecretService) deleteItem(path dbus.ObjectPath) error {
+	delete(f.items, path)
+	return nil
+}
+
+func (f *fakeSecretService) attributes(path dbus.ObjectPath) (map[string]string, error) {
+	item, ok := f.items[path]
+	if !ok {
+		return nil, errors.New("no such item")
+	}
+	return item.attrs, nil
+}
+
+func TestSecretServiceStoreRoundTrip(t *testing.T) {
+	backend := newFakeSecretService()
+	store := &SecretServiceStore{backend: backend, service: secretServiceAppName}
+
+	if err := store.Put("codex:b@example.com", model.AuthSecrets{AccessToken: "token-b"}); err != nil {
+		t.Fatalf("put b: %v", err)
+	}
+	if err := store.Put("codex:a@example.com", model.AuthSecrets{AccessToken: "token-a"}); err != nil {
+		t.Fatalf("put a: %v", err)
+	}
+	if err := store.Put("codex:a@example.com", model.AuthSecrets{AccessToken: "token-a2"}); err != nil {
+		t.Fatalf("overwrite a: %v", err)
+	}
+	backend.items["/other"] = &fakeSecretServiceItem{attrs: map[string]string{"service": "other-app", "account": "x"}}
+
+	got, err := store.Get("codex:a@example.com")
+	if err != nil {
+		t.Fatalf("get a: %v", err)
+	}
+	if got.AccessToken != "token-a2" {
+		t.Fatalf("unexpected access token: %q", got.AccessToken)
+	}
+	if backend.unlocks != 1 {
+		t.Fatalf("expected locked item to be unlocked once, got %d", backend.unlocks)
+	}
+
+	ids, err := store.List()
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != "codex:a@example.com" || ids[1] != "codex:b@example.com" {
+		t.Fatalf("unexpected ids: %v", ids)
+	}
+
+	if err := store.Delete("codex:a@example.com"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := store.Get("codex:a@example.com"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected not-exist error, got %v", err)
+	}
+	if err := store.Delete("codex:missing"); err != nil {
+		t.Fatalf("delete missing: %v", err)
+	}
+}
+
+func TestNewDefaultStoreFallsBackWithoutSessionBus(t *testing.T) {
+	t.Setenv("DBUS_SESSION_BUS_ADDRESS", "")
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	if _, ok := NewDefaultStore().(*EncryptedFileStore); !ok {
+		t.Fatal("expected encrypted file store fallback when no session bus is available")
+	}
+
+	// Existing plaintext secrets keep being used until they are migrated.
+	if err := newDefaultFileStore().Put("codex:a@example.com", model.AuthSecrets{AccessToken: "token"}); err != nil {
+		t.Fatalf("seed plaintext secret: %v", err)
+	}
+	if _, ok := NewDefaultStore().(*FileStore); !ok {
+		t.Fatal("expected the plaintext file store while it still holds secrets")
+	}
+}
+
+func TestSecretServiceDefaultKeepsLegacyPlaintextSecrets(t *testing.T) {
+	native := &SecretServiceStore{backend: newFakeSecretService(), service: secretServiceAppName}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "codex:a@example.com.json"), []byte(`{"access_token":"token-a"}`), 0o600); err != nil {
+		t.Fatalf("seed plaintext secret: %v", err)
+	}
+
+	store := preferLegacyFileStore(native, &FileStore{baseDir: dir})
+	if _, ok := store.(*FileStore); !ok {
+		t.Fatalf("expected the plaintext file store while it holds secrets, got %T", store)
+	}
+	got, err := store.Get("codex:a@example.com")
+	if err != nil || got.AccessToken != "token-a" {
+		t.Fatalf("expected the existing secret to stay readable, got %#v (%v)", got, err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "codex:a@example.com.json")); err != nil {
+		t.Fatalf("remove plaintext secret: %v", err)
+	}
+	if store := preferLegacyFileStore(native, &FileStore{baseDir: dir}); store != native {
+		t.Fatalf("expected the secret service once no plaintext secrets remain, got %T", store)
+	}
+}
@@ -1,9 +1,51 @@
 package secrets
 
-import "switchly/internal/model"
+import (
+	"fmt"
+	"strings"
+
+	"switchly/internal/model"
+)
 
 type Store interface {
 	Put(accountID string, secrets model.AuthSecrets) error
 	Get(accountID string) (model.AuthSecrets, error)
 	Delete(accountID string) error
+	List() ([]string, error)
+}
+
+// Backend names accepted by Open.
+const (
+	BackendFile          = "file"
+	BackendEncryptedFile = "encrypted-file"
+	BackendDPAPI         = "dpapi"
+	BackendKeychain      = "keychain"
+	BackendSecretService = "secret-service"
+)
+
+// BackendOf reports which backend a store uses, or "unknown" for stores
+// that do not say (such as test fakes).
+func BackendOf(s Store) string {
+	if b, ok := s.(interface{ Backend() string }); ok {
+		return b.Backend()
+	}
+	return "unknown"
+}
+
+// Open returns the named backend without falling back to another one, so a
+// migration never silently reads from or writes to the wrong place.
+func Open(backend string) (Store, error) {
+	store, err := openBackend(backend)
+	if err != nil {
+		return nil, err
+	}
+	if store == nil {
+		return nil, fmt.Errorf("secret store %q is not available on this platform (available: %s)", backend, strings.Join(availableBackends(), ", "))
+	}
+	return store, nil
+}
+
+// Backends lists the backends Open accepts on this platform.
+func Backends() []string {
+	return availableBackends()
 }
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"switchly/internal/bundle"
+	"switchly/internal/core"
+)
+
+const (
+	accountBundleVersion = 1
+	minBundlePassphrase  = 8
+)
+
+// accountBundle is the plaintext sealed inside a bundle envelope.
+type accountBundle struct {
+	Version    int                    `json:"version"`
+	ExportedAt time.Time              `json:"exported_at"`
+	Accounts   []core.ExportedAccount `json:"accounts"`
+}
+
+func (s *APIServer) handleBundleExport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := decodeJSONBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Passphrase) < minBundlePassphrase {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("passphrase must be at least %d characters", minBundlePassphrase))
+		return
+	}
+
+	accounts, err := s.manager.ExportAccounts(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	plaintext, err := json.Marshal(accountBundle{
+		Version:    accountBundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Accounts:   accounts,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	env, err := bundle.Seal(plaintext, req.Passphrase)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, env)
+}
+
+func (s *APIServer) handleBundleImport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		Passphrase string           `json:"passphrase"`
+		Bundle     *bundle.Envelope `json:"bundle"`
+	}
+	if err := decodeJSONBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.Bundle == nil {
+		writeError(w, http.StatusBadRequest, errors.New("bundle is required"))
+		return
+	}
+
+	plaintext, err := bundle.Open(*req.Bundle, req.Passphrase)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	var payload accountBundle
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("decode bundle contents: %w", err))
+		return
+	}
+	if payload.Version != accountBundleVersion {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("unsupported bundle contents version %d", payload.Version))
+		return
+	}
+
+	out := batchImportResult{
+		Total:   len(payload.Accounts),
+		Results: make([]batchImportItem, 0, len(payload.Accounts)),
+	}
+	for i, entry := range payload.Accounts {
+		out.record(s.importAccount(r.Context(), i, core.AddAccountInput{
+			ID:       entry.Account.ID,
+			Provider: entry.Account.Provider,
+			Email:    entry.Account.Email,
+			Weight:   entry.Account.Weight,
+			Secrets:  entry.Secrets,
+		}))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
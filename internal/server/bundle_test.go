@@ -0,0 +1,115 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+func exportTestBundle(t *testing.T, api http.Handler, passphrase string) []byte {
+	t.Helper()
+	body, _ := json.Marshal(map[string]string{"passphrase": passphrase})
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/export", bytes.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("export: expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	return rec.Body.Bytes()
+}
+
+func importTestBundle(api http.Handler, envelope []byte, passphrase string) *httptest.ResponseRecorder {
+	body, _ := json.Marshal(map[string]any{"passphrase": passphrase, "bundle": json.RawMessage(envelope)})
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/import/bundle", bytes.NewReader(body)))
+	return rec
+}
+
+func TestBundleExportImportRoundTrip(t *testing.T) {
+	source, _ := newTestManager()
+	for _, in := range []core.AddAccountInput{
+		{ID: "A", Provider: "codex", Email: "a@example.com", Weight: 3, Secrets: model.AuthSecrets{AccessToken: "access-a", RefreshToken: "refresh-a", AccountID: "acct-a"}},
+		{ID: "B", Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "access-b"}},
+	} {
+		if _, err := source.AddAccount(context.Background(), in); err != nil {
+			t.Fatalf("seed %s: %v", in.ID, err)
+		}
+	}
+	envelope := exportTestBundle(t, New(source, nil, nil).Handler(), "correct horse battery")
+
+	var header struct {
+		Format     string `json:"format"`
+		Version    int    `json:"version"`
+		Ciphertext string `json:"ciphertext"`
+	}
+	if err := json.Unmarshal(envelope, &header); err != nil {
+		t.Fatalf("decode envelope: %v", err)
+	}
+	if header.Format != "switchly-bundle" || header.Version != 1 || header.Ciphertext == "" {
+		t.Fatalf("unexpected envelope header: %+v", header)
+	}
+	if strings.Contains(string(envelope), "access-a") {
+		t.Fatal("envelope leaks a plaintext token")
+	}
+
+	target, targetSecrets := newTestManager()
+	rec := importTestBundle(New(target, nil, nil).Handler(), envelope, "correct horse battery")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("import: expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var result batchImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode import result: %v", err)
+	}
+	if result.Total != 2 || result.Succeeded != 2 {
+		t.Fatalf("unexpected import result: %+v", result)
+	}
+
+	acct, err := target.GetAccount(context.Background(), "A")
+	if err != nil {
+		t.Fatalf("get imported account: %v", err)
+	}
+	if acct.Email != "a@example.com" || acct.Weight != 3 {
+		t.Fatalf("unexpected imported account: %+v", acct)
+	}
+	sec := targetSecrets.data["A"]
+	if sec.AccessToken != "access-a" || sec.RefreshToken != "refresh-a" || sec.AccountID != "acct-a" {
+		t.Fatalf("unexpected imported secrets: %+v", sec)
+	}
+}
+
+func TestBundleImportWrongPassphrase(t *testing.T) {
+	source, _ := newTestManager()
+	if _, err := source.AddAccount(context.Background(), core.AddAccountInput{ID: "A", Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "access-a"}}); err != nil {
+		t.Fatalf("seed: %v", err)
+	}
+	envelope := exportTestBundle(t, New(source, nil, nil).Handler(), "correct horse battery")
+
+	target, _ := newTestManager()
+	rec := importTestBundle(New(target, nil, nil).Handler(), envelope, "wrong passphrase")
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "incorrect passphrase") {
+		t.Fatalf("expected a clear passphrase error, got %s", rec.Body.String())
+	}
+	if accounts, _ := target.ListAccounts(context.Background()); len(accounts) != 0 {
+		t.Fatalf("expected nothing imported, got %d accounts", len(accounts))
+	}
+}
+
+func TestBundleExportRejectsShortPassphrase(t *testing.T) {
+	mgr, _ := newTestManager()
+	body := bytes.NewBufferString(`{"passphrase":"short"}`)
+	rec := httptest.NewRecorder()
+	New(mgr, nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/export", body))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
@@ -1,11 +1,47 @@
 package server
 
+import (
+	"runtime"
+	"time"
+)
+
 type DaemonInfo struct {
-	PID               int    `json:"pid"`
-	Addr              string `json:"addr"`
-	PublicBaseURL     string `json:"public_base_url"`
-	RestartSupported  bool   `json:"restart_supported"`
-	DefaultRestartCmd string `json:"default_restart_cmd,omitempty"`
+	PID               int           `json:"pid"`
+	Addr              string        `json:"addr"`
+	PublicBaseURL     string        `json:"public_base_url"`
+	SocketPath        string        `json:"socket_path,omitempty"`
+	RestartSupported  bool          `json:"restart_supported"`
+	DefaultRestartCmd string        `json:"default_restart_cmd,omitempty"`
+	StateFile         string        `json:"state_file,omitempty"`
+	Runtime           *RuntimeStats `json:"runtime,omitempty"`
+}
+
+// RuntimeStats is a snapshot of Go runtime health for the daemon process.
+type RuntimeStats struct {
+	GoVersion      string    `json:"go_version"`
+	NumGoroutines  int       `json:"num_goroutines"`
+	HeapAllocBytes uint64    `json:"heap_alloc_bytes"`
+	HeapSysBytes   uint64    `json:"heap_sys_bytes"`
+	NumGC          uint32    `json:"num_gc"`
+	LastGCAt       time.Time `json:"last_gc_at,omitzero"`
+}
+
+// ReadRuntimeStats collects RuntimeStats for the current process. ReadMemStats
+// briefly stops the world, which is fine at daemon info request rates.
+func ReadRuntimeStats() RuntimeStats {
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	stats := RuntimeStats{
+		GoVersion:      runtime.Version(),
+		NumGoroutines:  runtime.NumGoroutine(),
+		HeapAllocBytes: mem.HeapAlloc,
+		HeapSysBytes:   mem.HeapSys,
+		NumGC:          mem.NumGC,
+	}
+	if mem.NumGC > 0 {
+		stats.LastGCAt = time.Unix(0, int64(mem.LastGC)).UTC()
+	}
+	return stats
 }
 
 type DaemonController interface {
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultLogBufferLines = 1000
+	logSubscriberBuffer   = 64
+)
+
+// LogBuffer is an io.Writer that keeps the most recent lines written to it,
+// so the daemon's log output can be served over the API.
+type LogBuffer struct {
+	mu      sync.Mutex
+	max     int
+	lines   []string
+	partial []byte
+	subs    map[chan string]struct{}
+	closed  bool
+}
+
+func NewLogBuffer(maxLines int) *LogBuffer {
+	if maxLines <= 0 {
+		maxLines = DefaultLogBufferLines
+	}
+	return &LogBuffer{max: maxLines, subs: map[chan string]struct{}{}}
+}
+
+// Write splits p into lines. A trailing fragment without a newline is held
+// until the rest of the line arrives.
+func (b *LogBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	data := append(b.partial, p...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		b.appendLocked(string(data[:i]))
+		data = data[i+1:]
+	}
+	b.partial = append([]byte(nil), data...)
+	return len(p), nil
+}
+
+func (b *LogBuffer) appendLocked(line string) {
+	if len(b.lines) == b.max {
+		copy(b.lines, b.lines[1:])
+		b.lines = b.lines[:b.max-1]
+	}
+	b.lines = append(b.lines, line)
+	for ch := range b.subs {
+		select {
+		case ch <- line:
+		default:
+			// Slow follower; drop the line rather than block logging.
+		}
+	}
+}
+
+// Lines returns up to n of the most recent lines, oldest first. n <= 0
+// returns everything buffered.
+func (b *LogBuffer) Lines(n int) []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tailLocked(n)
+}
+
+func (b *LogBuffer) tailLocked(n int) []string {
+	start := 0
+	if n > 0 && n < len(b.lines) {
+		start = len(b.lines) - n
+	}
+	return append([]string(nil), b.lines[start:]...)
+}
+
+// follow returns the last n lines and a channel receiving every line written
+// afterwards. The two are taken together so no line is missed or repeated.
+// The channel is closed when the daemon stops; ok is false if it already has.
+func (b *LogBuffer) follow(n int) (backlog []string, ch chan string, ok bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, nil, false
+	}
+	ch = make(chan string, logSubscriberBuffer)
+	b.subs[ch] = struct{}{}
+	return b.tailLocked(n), ch, true
+}
+
+func (b *LogBuffer) unfollow(ch chan string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[ch]; ok {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+func (b *LogBuffer) closeFollowers() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// WithLogBuffer serves the lines captured by buf from /v1/daemon/logs.
+func WithLogBuffer(buf *LogBuffer) Option {
+	return func(s *APIServer) {
+		s.logs = buf
+	}
+}
+
+func (s *APIServer) handleDaemonLogs(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if s.logs == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("daemon log capture not configured"))
+		return
+	}
+	lines := 100
+	if raw := strings.TrimSpace(r.URL.Query().Get("lines")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, errors.New("lines must be a positive integer"))
+			return
+		}
+		lines = n
+	}
+	follow, _ := strconv.ParseBool(r.URL.Query().Get("follow"))
+	if !follow {
+		writeJSON(w, http.StatusOK, map[string][]string{"lines": s.logs.Lines(lines)})
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	backlog, ch, ok := s.logs.follow(lines)
+	if !ok {
+		writeError(w, http.StatusServiceUnavailable, errors.New("daemon is shutting down"))
+		return
+	}
+	defer s.logs.unfollow(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	for _, line := range backlog {
+		if _, err := fmt.Fprintf(w, "event: log\ndata: %s\n\n", line); err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case line, ok := <-ch:
+			if !ok {
+				return
+			}
+			if _, err := fmt.Fprintf(w, "event: log\ndata: %s\n\n", line); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
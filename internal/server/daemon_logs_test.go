@@ -0,0 +1,105 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLogBufferCapsAtMaxLines(t *testing.T) {
+	buf := NewLogBuffer(3)
+	for i := 1; i <= 5; i++ {
+		fmt.Fprintf(buf, "line %d\n", i)
+	}
+	if got := buf.Lines(0); !reflect.DeepEqual(got, []string{"line 3", "line 4", "line 5"}) {
+		t.Fatalf("unexpected buffered lines: %v", got)
+	}
+	if got := buf.Lines(2); !reflect.DeepEqual(got, []string{"line 4", "line 5"}) {
+		t.Fatalf("unexpected tail: %v", got)
+	}
+
+	// A line split across writes is only recorded once it is complete.
+	buf.Write([]byte("partial "))
+	if got := buf.Lines(1); got[0] != "line 5" {
+		t.Fatalf("expected the partial line to be held back, got %v", got)
+	}
+	buf.Write([]byte("line\nnext"))
+	if got := buf.Lines(1); got[0] != "partial line" {
+		t.Fatalf("expected the joined line, got %v", got)
+	}
+}
+
+func TestHandleDaemonLogs(t *testing.T) {
+	buf := NewLogBuffer(10)
+	for i := 1; i <= 6; i++ {
+		fmt.Fprintf(buf, "line %d\n", i)
+	}
+	api := New(nil, nil, nil, WithLogBuffer(buf))
+	handler := api.Handler()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/daemon/logs?lines=4", nil))
+	var out struct {
+		Lines []string `json:"lines"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v (%s)", err, rec.Body.String())
+	}
+	if rec.Code != http.StatusOK || !reflect.DeepEqual(out.Lines, []string{"line 3", "line 4", "line 5", "line 6"}) {
+		t.Fatalf("unexpected response %d: %v", rec.Code, out.Lines)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/daemon/logs?lines=0", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for lines=0, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleDaemonLogsFollow(t *testing.T) {
+	buf := NewLogBuffer(10)
+	fmt.Fprintln(buf, "before")
+	api := New(nil, nil, nil, WithLogBuffer(buf))
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/daemon/logs?lines=1&follow=true")
+	if err != nil {
+		t.Fatalf("connect logs: %v", err)
+	}
+	defer resp.Body.Close()
+	reader := bufio.NewReader(resp.Body)
+	if got := readLogLine(t, reader); got != "before" {
+		t.Fatalf("expected the buffered line first, got %q", got)
+	}
+
+	fmt.Fprintln(buf, "after")
+	if got := readLogLine(t, reader); got != "after" {
+		t.Fatalf("expected the new line to be streamed, got %q", got)
+	}
+
+	// Shutting down ends the stream instead of leaving it open.
+	api.closeStreams("shutdown")
+	if _, err := io.ReadAll(reader); err != nil {
+		t.Fatalf("read until the stream ends: %v", err)
+	}
+}
+
+func readLogLine(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read log stream: %v", err)
+		}
+		if strings.HasPrefix(line, "data: ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "data: "))
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"switchly/internal/codexauth"
+	"switchly/internal/core"
+	"switchly/internal/oauth"
+)
+
+// Error codes returned in the "code" field of API error responses. Clients
+// should branch on these rather than on the human-readable message.
+const (
+	ErrCodeValidation       = "validation_error"
+	ErrCodeUnauthorized     = "unauthorized"
+	ErrCodeNotFound         = "not_found"
+	ErrCodeAccountNotFound  = "account_not_found"
+	ErrCodeProviderNotFound = "provider_not_found"
+	ErrCodeMethodNotAllowed = "method_not_allowed"
+	ErrCodeConflict         = "conflict"
+	ErrCodeRateLimited      = "rate_limited"
+	ErrCodeReauthRequired   = "reauth_required"
+	ErrCodeTokenExpired     = "token_expired"
+	ErrCodePersistence      = "persistence_error"
+	ErrCodeUnavailable      = "unavailable"
+	ErrCodeInternal         = "internal_error"
+)
+
+// errorCode picks the code for err, preferring a known sentinel error over
+// the generic code for status.
+func errorCode(status int, err error) string {
+	switch {
+	case errors.Is(err, core.ErrAccountNotFound):
+		return ErrCodeAccountNotFound
+	case errors.Is(err, core.ErrReauthRequired):
+		return ErrCodeReauthRequired
+	case errors.Is(err, core.ErrAccountTokenExpired):
+		return ErrCodeTokenExpired
+	case errors.Is(err, core.ErrPersistState), errors.Is(err, core.ErrPersistSecrets):
+		return ErrCodePersistence
+	case errors.Is(err, oauth.ErrProviderNotFound):
+		return ErrCodeProviderNotFound
+	case errors.Is(err, oauth.ErrBuiltinProvider):
+		return ErrCodeConflict
+	case errors.Is(err, core.ErrNoQuotaReset),
+		errors.Is(err, codexauth.ErrAuthFileNotFound),
+		errors.Is(err, codexauth.ErrNoEnvAccounts),
+		errors.Is(err, codexauth.ErrCopilotTokenNotFound):
+		return ErrCodeNotFound
+	}
+	switch status {
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		return ErrCodeValidation
+	case http.StatusUnauthorized:
+		return ErrCodeUnauthorized
+	case http.StatusNotFound:
+		return ErrCodeNotFound
+	case http.StatusMethodNotAllowed:
+		return ErrCodeMethodNotAllowed
+	case http.StatusConflict:
+		return ErrCodeConflict
+	case http.StatusTooManyRequests:
+		return ErrCodeRateLimited
+	case http.StatusServiceUnavailable:
+		return ErrCodeUnavailable
+	default:
+		return ErrCodeInternal
+	}
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+func TestErrorResponsesIncludeCode(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["acc-a"] = model.Account{ID: "acc-a", Provider: "codex", Status: model.AccountReady}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{"acc-a": {AccessToken: "token-a"}}}
+	handler := New(core.NewManager(state, secrets), nil, nil).Handler()
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+		status int
+		code   string
+	}{
+		{"get missing account", http.MethodGet, "/v1/accounts/missing", "", http.StatusNotFound, ErrCodeAccountNotFound},
+		{"delete missing account", http.MethodDelete, "/v1/accounts/missing", "", http.StatusNotFound, ErrCodeAccountNotFound},
+		{"use missing account", http.MethodPost, "/v1/accounts/missing/activate", "", http.StatusNotFound, ErrCodeAccountNotFound},
+		{"invalid body", http.MethodPost, "/v1/accounts", "{", http.StatusBadRequest, ErrCodeValidation},
+		{"missing fields", http.MethodPost, "/v1/accounts", `{"provider":"codex"}`, http.StatusBadRequest, ErrCodeValidation},
+		{"wrong method", http.MethodPut, "/v1/accounts", "", http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed},
+		{"refresh without refresh token", http.MethodPost, "/v1/accounts/acc-a/refresh", "", http.StatusUnprocessableEntity, ErrCodeReauthRequired},
+		{"oauth disabled", http.MethodGet, "/v1/oauth/providers", "", http.StatusServiceUnavailable, ErrCodeUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			assertErrorCode(t, rec, tc.status, tc.code)
+		})
+	}
+}
+
+func TestErrorCodeUnauthorized(t *testing.T) {
+	manager, _ := newTestManager()
+	handler := New(manager, nil, nil, WithAPIToken("s3cret")).Handler()
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/accounts", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assertErrorCode(t, rec, http.StatusUnauthorized, ErrCodeUnauthorized)
+}
+
+func TestErrorCodePersistence(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["acc-a"] = model.Account{ID: "acc-a", Provider: "codex", Status: model.AccountReady}
+	secrets := &testSecretsStore{
+		data:      map[string]model.AuthSecrets{"acc-a": {AccessToken: "token-a"}},
+		deleteErr: errors.New("disk failure"),
+	}
+	handler := New(core.NewManager(state, secrets), nil, nil).Handler()
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/accounts/acc-a", nil)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	assertErrorCode(t, rec, http.StatusBadRequest, ErrCodePersistence)
+}
+
+func assertErrorCode(t *testing.T, rec *httptest.ResponseRecorder, status int, code string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("expected %d, got %d body=%s", status, rec.Code, rec.Body.String())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["code"] != code || body["error"] == "" {
+		t.Fatalf("expected code %q, got %v", code, body)
+	}
+}
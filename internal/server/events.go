@@ -0,0 +1,140 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"switchly/internal/core"
+)
+
+const (
+	eventClientBuffer      = 16
+	eventKeepaliveInterval = 30 * time.Second
+)
+
+type eventBroadcaster struct {
+	mu      sync.Mutex
+	clients map[chan core.Event]struct{}
+	closed  bool
+}
+
+func newEventBroadcaster() *eventBroadcaster {
+	return &eventBroadcaster{clients: map[chan core.Event]struct{}{}}
+}
+
+func (b *eventBroadcaster) run(events <-chan core.Event) {
+	for evt := range events {
+		b.publish(evt)
+	}
+}
+
+func (b *eventBroadcaster) subscribe() (chan core.Event, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return nil, false
+	}
+	ch := make(chan core.Event, eventClientBuffer)
+	b.clients[ch] = struct{}{}
+	return ch, true
+}
+
+func (b *eventBroadcaster) unsubscribe(ch chan core.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.clients[ch]; ok {
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+func (b *eventBroadcaster) publish(evt core.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.clients {
+		select {
+		case ch <- evt:
+		default:
+			// Slow client; drop the event rather than stall every subscriber.
+		}
+	}
+}
+
+// shutdown delivers a final event and disconnects every client so that
+// http.Server.Shutdown is not held open by long-lived streams.
+func (b *eventBroadcaster) shutdown(evt core.Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for ch := range b.clients {
+		select {
+		case ch <- evt:
+		default:
+		}
+		delete(b.clients, ch)
+		close(ch)
+	}
+}
+
+func (b *eventBroadcaster) clientCount() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.clients)
+}
+
+func (s *APIServer) handleEvents(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeError(w, http.StatusInternalServerError, errors.New("streaming not supported"))
+		return
+	}
+	ch, ok := s.events.subscribe()
+	if !ok {
+		writeError(w, http.StatusServiceUnavailable, errors.New("daemon is shutting down"))
+		return
+	}
+	defer s.events.unsubscribe(ch)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepalive := time.NewTicker(eventKeepaliveInterval)
+	defer keepalive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case evt, ok := <-ch:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(evt)
+			if err != nil {
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", evt.Type, data); err != nil {
+				return
+			}
+			flusher.Flush()
+		case <-keepalive.C:
+			if _, err := fmt.Fprint(w, ": keepalive\n\n"); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"switchly/internal/core"
+	"switchly/internal/model"
+)
+
+func TestHandleEventsStreamsManagerEvents(t *testing.T) {
+	manager, _ := newTestManager()
+	api := New(manager, nil, nil)
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/events")
+	if err != nil {
+		t.Fatalf("connect events: %v", err)
+	}
+	defer resp.Body.Close()
+	if got := resp.Header.Get("Content-Type"); got != "text/event-stream" {
+		t.Fatalf("unexpected content type: %q", got)
+	}
+	waitForEventClients(t, api, 1)
+
+	if _, err := manager.AddAccount(context.Background(), core.AddAccountInput{
+		ID:       "acc-a",
+		Provider: "codex",
+		Secrets:  model.AuthSecrets{AccessToken: "token-a"},
+	}); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+
+	reader := bufio.NewReader(resp.Body)
+	if got := readEventType(t, reader); got != core.EventAccountAdded {
+		t.Fatalf("expected %s, got %s", core.EventAccountAdded, got)
+	}
+
+	api.events.shutdown(core.Event{Type: core.EventDaemonShutdown})
+	if got := readEventType(t, reader); got != core.EventDaemonShutdown {
+		t.Fatalf("expected %s, got %s", core.EventDaemonShutdown, got)
+	}
+	waitForEventClients(t, api, 0)
+}
+
+func TestHandleEventsFromSeveralServers(t *testing.T) {
+	manager, _ := newTestManager()
+	var readers []*bufio.Reader
+	for range 2 {
+		api := New(manager, nil, nil)
+		srv := httptest.NewServer(api.Handler())
+		defer srv.Close()
+		defer api.closeStreams("test")
+		resp, err := http.Get(srv.URL + "/v1/events")
+		if err != nil {
+			t.Fatalf("connect events: %v", err)
+		}
+		defer resp.Body.Close()
+		waitForEventClients(t, api, 1)
+		readers = append(readers, bufio.NewReader(resp.Body))
+	}
+
+	for _, id := range []string{"acc-a", "acc-b"} {
+		if _, err := manager.AddAccount(context.Background(), core.AddAccountInput{
+			ID:       id,
+			Provider: "codex",
+			Secrets:  model.AuthSecrets{AccessToken: "token"},
+		}); err != nil {
+			t.Fatalf("add account: %v", err)
+		}
+	}
+	// Each server gets every event rather than the two splitting them.
+	for i, reader := range readers {
+		for range 2 {
+			if got := readEventType(t, reader); got != core.EventAccountAdded {
+				t.Fatalf("server %d: expected %s, got %s", i, core.EventAccountAdded, got)
+			}
+		}
+	}
+}
+
+func TestHandleEventsCleansUpDisconnectedClients(t *testing.T) {
+	api := New(nil, nil, nil)
+	srv := httptest.NewServer(api.Handler())
+	defer srv.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL+"/v1/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("connect events: %v", err)
+	}
+	waitForEventClients(t, api, 1)
+	cancel()
+	resp.Body.Close()
+	waitForEventClients(t, api, 0)
+}
+
+func waitForEventClients(t *testing.T, api *APIServer, want int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for api.events.clientCount() != want {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected %d event clients, got %d", want, api.events.clientCount())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func readEventType(t *testing.T, reader *bufio.Reader) string {
+	t.Helper()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			t.Fatalf("read event stream: %v", err)
+		}
+		if strings.HasPrefix(line, "event: ") {
+			return strings.TrimSpace(strings.TrimPrefix(line, "event: "))
+		}
+	}
+}
@@ -2,59 +2,173 @@ package server
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
+	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/time/rate"
+
 	"switchly/internal/codexauth"
 	"switchly/internal/core"
 	"switchly/internal/model"
 	"switchly/internal/oauth"
+	"switchly/internal/secrets"
 )
 
 type APIServer struct {
 	manager *core.Manager
 	oauth   *oauth.Service
 	daemon  DaemonController
+	quota   *core.QuotaScheduler
+	events  *eventBroadcaster
+	token   string
+	tracing trace.TracerProvider
+	logs    *LogBuffer
+	rps     int
+	burst   int
+	// unsubscribeEvents stops the manager feeding events.
+	unsubscribeEvents func()
+}
+
+type Option func(*APIServer)
+
+func WithQuotaScheduler(scheduler *core.QuotaScheduler) Option {
+	return func(s *APIServer) {
+		s.quota = scheduler
+	}
+}
+
+func WithAPIToken(token string) Option {
+	return func(s *APIServer) {
+		s.token = token
+	}
+}
+
+// WithAPIRateLimit limits each client IP to rps requests per second with the
+// given burst. rps <= 0 disables limiting.
+func WithAPIRateLimit(rps, burst int) Option {
+	return func(s *APIServer) {
+		s.rps = rps
+		s.burst = burst
+	}
 }
 
-func New(manager *core.Manager, oauthService *oauth.Service, daemonCtl DaemonController) *APIServer {
-	return &APIServer{manager: manager, oauth: oauthService, daemon: daemonCtl}
+// WithTracerProvider sets the provider used for per-request spans. By default
+// the global provider is used.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(s *APIServer) {
+		s.tracing = tp
+	}
+}
+
+func New(manager *core.Manager, oauthService *oauth.Service, daemonCtl DaemonController, opts ...Option) *APIServer {
+	s := &APIServer{manager: manager, oauth: oauthService, daemon: daemonCtl, events: newEventBroadcaster()}
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+	if manager != nil {
+		events, unsubscribe := manager.Subscribe()
+		s.unsubscribeEvents = unsubscribe
+		go s.events.run(events)
+	}
+	return s
 }
 
 func (s *APIServer) Handler() http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/v1/health", s.handleHealth)
 	mux.HandleFunc("/v1/status", s.handleStatus)
+	mux.HandleFunc("/v1/events", s.handleEvents)
 	mux.HandleFunc("/v1/strategy", s.handleStrategy)
+	mux.HandleFunc("/v1/priority", s.handlePriority)
 	mux.HandleFunc("/v1/accounts", s.handleAccounts)
 	mux.HandleFunc("/v1/accounts/", s.handleAccountDetail)
 	mux.HandleFunc("/v1/accounts/import/codex/candidate", s.handleCodexImportCandidate)
 	mux.HandleFunc("/v1/accounts/import/codex", s.handleCodexImport)
+	mux.HandleFunc("/v1/accounts/import/batch", s.handleBatchImport)
+	mux.HandleFunc("/v1/accounts/import/env", s.handleEnvImport)
+	mux.HandleFunc("/v1/accounts/import/copilot", s.handleCopilotImport)
+	mux.HandleFunc("/v1/accounts/import/bundle", s.handleBundleImport)
+	mux.HandleFunc("/v1/accounts/export", s.handleBundleExport)
 	mux.HandleFunc("/v1/quota/sync", s.handleQuotaSync)
 	mux.HandleFunc("/v1/quota/sync-all", s.handleQuotaSyncAll)
+	mux.HandleFunc("/v1/quota/sync-local", s.handleQuotaSyncLocal)
+	mux.HandleFunc("/v1/quota/sync-with-warmup", s.handleQuotaSyncWithWarmup)
+	mux.HandleFunc("/v1/quota/warmup", s.handleQuotaWarmup)
+	mux.HandleFunc("/v1/quota/schedule", s.handleQuotaSchedule)
+	mux.HandleFunc("/v1/quota/next-reset", s.handleQuotaNextReset)
 	mux.HandleFunc("/v1/switch/on-error", s.handleSwitchOnError)
+	mux.HandleFunc("/v1/switch/history", s.handleSwitchHistory)
+	mux.HandleFunc("/v1/switch/rules", s.handleSwitchRules)
+	mux.HandleFunc("/v1/switch/cooldown", s.handleSwitchCooldown)
 	mux.HandleFunc("/v1/oauth/providers", s.handleOAuthProviders)
+	mux.HandleFunc("/v1/oauth/providers/", s.handleOAuthProviderDetail)
 	mux.HandleFunc("/v1/oauth/start", s.handleOAuthStart)
 	mux.HandleFunc("/v1/oauth/status", s.handleOAuthStatus)
+	mux.HandleFunc("/v1/oauth/sessions", s.handleOAuthSessions)
 	mux.HandleFunc("/v1/oauth/cancel", s.handleOAuthCancel)
+	mux.HandleFunc("/v1/oauth/sessions/", s.handleOAuthSessionDetail)
 	mux.HandleFunc("/v1/oauth/callback", s.handleOAuthCallback)
 	mux.HandleFunc("/auth/callback", s.handleOAuthCallback)
+	mux.HandleFunc("/v1/debug/secrets/orphaned", s.handleOrphanedSecrets)
+	mux.HandleFunc("/v1/secrets/backend", s.handleSecretsBackend)
+	mux.HandleFunc("/v1/secrets/migrate", s.handleSecretsMigrate)
 	mux.HandleFunc("/v1/daemon/info", s.handleDaemonInfo)
+	mux.HandleFunc("/v1/daemon/logs", s.handleDaemonLogs)
 	mux.HandleFunc("/v1/daemon/shutdown", s.handleDaemonShutdown)
 	mux.HandleFunc("/v1/daemon/restart", s.handleDaemonRestart)
-	return loggingMiddleware(corsMiddleware(mux))
+	var handler http.Handler = mux
+	if s.token != "" {
+		handler = authMiddleware(s.token)(handler)
+	}
+	if s.rps > 0 {
+		handler = rateLimitMiddleware(s.rps, s.burst)(handler)
+	}
+	return s.tracingMiddleware(requestIDMiddleware(loggingMiddleware(corsMiddleware(handler))))
+}
+
+func (s *APIServer) tracingMiddleware(next http.Handler) http.Handler {
+	opts := []otelhttp.Option{
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+			return r.Method + " " + r.URL.Path
+		}),
+	}
+	if s.tracing != nil {
+		opts = append(opts, otelhttp.WithTracerProvider(s.tracing))
+	}
+	return otelhttp.NewHandler(next, "switchlyd", opts...)
 }
 
 func (s *APIServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodGet) {
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	if r.URL.Query().Get("detailed") != "true" {
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		return
+	}
+	report := s.manager.Health(r.Context())
+	if !report.Healthy {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]interface{}{"status": "degraded", "checks": report.Checks})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "checks": report.Checks})
 }
 
 func (s *APIServer) handleStatus(w http.ResponseWriter, r *http.Request) {
@@ -70,13 +184,23 @@ func (s *APIServer) handleStatus(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *APIServer) handleStrategy(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodGet {
+		strategy, err := s.manager.Strategy(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"strategy": strategy, "available": model.RoutingStrategies})
+		return
+	}
 	if !requireMethod(w, r, http.MethodPatch) {
 		return
 	}
+	// An empty body, {} or an empty strategy resets to the default.
 	var req struct {
 		Strategy model.RoutingStrategy `json:"strategy"`
 	}
-	if err := decodeJSONBody(r, &req, false); err != nil {
+	if err := decodeJSONBody(r, &req, true); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
@@ -87,56 +211,63 @@ func (s *APIServer) handleStrategy(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
-func (s *APIServer) handleAccounts(w http.ResponseWriter, r *http.Request) {
+func (s *APIServer) handlePriority(w http.ResponseWriter, r *http.Request) {
+	var priorities []string
+	var err error
 	switch r.Method {
 	case http.MethodGet:
-		accounts, err := s.manager.ListAccounts(r.Context())
+		priorities, err = s.manager.Priorities(r.Context())
 		if err != nil {
 			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string][]model.Account{"accounts": accounts})
-	case http.MethodPost:
+	case http.MethodPut:
 		var req struct {
-			ID               string `json:"id"`
-			Provider         string `json:"provider"`
-			Email            string `json:"email"`
-			AccessToken      string `json:"access_token"`
-			RefreshToken     string `json:"refresh_token"`
-			IDToken          string `json:"id_token"`
-			AccountID        string `json:"account_id"`
-			AccessExpiresAt  string `json:"access_expires_at"`
-			RefreshExpiresAt string `json:"refresh_expires_at"`
+			Priorities []string `json:"priorities"`
 		}
 		if err := decodeJSONBody(r, &req, false); err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
+		priorities, err = s.manager.SetPriorities(r.Context(), req.Priorities)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+	default:
+		methodNotAllowed(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"priorities": priorities})
+}
 
-		accessExpiry, err := parseOptionalTime(req.AccessExpiresAt)
+func (s *APIServer) handleAccounts(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		filter, err := parseAccountFilter(r)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid access_expires_at: %w", err))
+			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		refreshExpiry, err := parseOptionalTime(req.RefreshExpiresAt)
+		accounts, total, err := s.manager.FilterAccounts(r.Context(), filter)
 		if err != nil {
-			writeError(w, http.StatusBadRequest, fmt.Errorf("invalid refresh_expires_at: %w", err))
-			return
-		}
-
-		account, err := s.manager.AddAccount(r.Context(), core.AddAccountInput{
-			ID:       req.ID,
-			Provider: req.Provider,
-			Email:    req.Email,
-			Secrets: model.AuthSecrets{
-				AccessToken:      req.AccessToken,
-				RefreshToken:     req.RefreshToken,
-				IDToken:          req.IDToken,
-				AccountID:        req.AccountID,
-				AccessExpiresAt:  accessExpiry,
-				RefreshExpiresAt: refreshExpiry,
-			},
-		})
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"accounts": accounts, "total": total})
+	case http.MethodPost:
+		var req addAccountRequest
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		input, err := req.input()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+
+		account, err := s.manager.AddAccount(r.Context(), input)
 		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
@@ -147,6 +278,180 @@ func (s *APIServer) handleAccounts(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+type addAccountRequest struct {
+	ID               string `json:"id"`
+	Provider         string `json:"provider"`
+	Email            string `json:"email"`
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	IDToken          string `json:"id_token"`
+	AccountID        string `json:"account_id"`
+	AccessExpiresAt  string `json:"access_expires_at"`
+	RefreshExpiresAt string `json:"refresh_expires_at"`
+	Weight           int    `json:"weight"`
+}
+
+func (req addAccountRequest) input() (core.AddAccountInput, error) {
+	accessExpiry, err := parseOptionalTime(req.AccessExpiresAt)
+	if err != nil {
+		return core.AddAccountInput{}, fmt.Errorf("invalid access_expires_at: %w", err)
+	}
+	refreshExpiry, err := parseOptionalTime(req.RefreshExpiresAt)
+	if err != nil {
+		return core.AddAccountInput{}, fmt.Errorf("invalid refresh_expires_at: %w", err)
+	}
+	return core.AddAccountInput{
+		ID:       req.ID,
+		Provider: req.Provider,
+		Email:    req.Email,
+		Weight:   req.Weight,
+		Secrets: model.AuthSecrets{
+			AccessToken:      req.AccessToken,
+			RefreshToken:     req.RefreshToken,
+			IDToken:          req.IDToken,
+			AccountID:        req.AccountID,
+			AccessExpiresAt:  accessExpiry,
+			RefreshExpiresAt: refreshExpiry,
+		},
+	}, nil
+}
+
+type batchImportItem struct {
+	Index   int            `json:"index"`
+	ID      string         `json:"id,omitempty"`
+	Success bool           `json:"success"`
+	Account *model.Account `json:"account,omitempty"`
+	Error   string         `json:"error,omitempty"`
+}
+
+type batchImportResult struct {
+	Total     int               `json:"total"`
+	Succeeded int               `json:"succeeded"`
+	Failed    int               `json:"failed"`
+	Results   []batchImportItem `json:"results"`
+}
+
+func (s *APIServer) handleBatchImport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		Accounts []addAccountRequest `json:"accounts"`
+	}
+	if err := decodeJSONBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	out := batchImportResult{
+		Total:   len(req.Accounts),
+		Results: make([]batchImportItem, 0, len(req.Accounts)),
+	}
+	for i, entry := range req.Accounts {
+		input, err := entry.input()
+		if err != nil {
+			out.record(batchImportItem{Index: i, ID: entry.ID, Error: err.Error()})
+			continue
+		}
+		out.record(s.importAccount(r.Context(), i, input))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+// handleEnvImport imports the accounts described by the daemon's
+// environment; see codexauth.LoadEnvAccounts.
+func (s *APIServer) handleEnvImport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	accounts, err := codexauth.LoadEnvAccounts()
+	if errors.Is(err, codexauth.ErrNoEnvAccounts) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("import accounts from environment: %w", err))
+		return
+	}
+
+	out := batchImportResult{
+		Total:   len(accounts),
+		Results: make([]batchImportItem, 0, len(accounts)),
+	}
+	for i, acct := range accounts {
+		out.record(s.importAccount(r.Context(), i, core.AddAccountInput{
+			ID:       acct.ID,
+			Provider: "codex",
+			Email:    acct.Email,
+			Secrets:  acct.Secrets,
+		}))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *APIServer) handleCopilotImport(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	localAccount, err := codexauth.LoadGitHubCopilotAccount()
+	if errors.Is(err, codexauth.ErrCopilotTokenNotFound) {
+		writeError(w, http.StatusNotFound, err)
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("import copilot token: %w", err))
+		return
+	}
+
+	accounts, err := s.manager.ListAccounts(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	exists := containsAccount(accounts, localAccount.ID)
+
+	account, err := s.manager.AddAccount(r.Context(), core.AddAccountInput{
+		ID:       localAccount.ID,
+		Provider: codexauth.ProviderCopilot,
+		Secrets:  localAccount.Secrets,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	action := "created"
+	if exists {
+		action = "updated"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":  "ok",
+		"action":  action,
+		"account": account,
+	})
+}
+
+func (s *APIServer) importAccount(ctx context.Context, index int, input core.AddAccountInput) batchImportItem {
+	item := batchImportItem{Index: index, ID: input.ID}
+	account, err := s.manager.AddAccount(ctx, input)
+	if err != nil {
+		item.Error = err.Error()
+		return item
+	}
+	item.Success = true
+	item.Account = &account
+	return item
+}
+
+func (r *batchImportResult) record(item batchImportItem) {
+	if item.Success {
+		r.Succeeded++
+	} else {
+		r.Failed++
+	}
+	r.Results = append(r.Results, item)
+}
+
 type codexImportCandidate struct {
 	ID             string `json:"id"`
 	Provider       string `json:"provider"`
@@ -237,7 +542,7 @@ func (s *APIServer) handleCodexImport(w http.ResponseWriter, r *http.Request) {
 	}
 	exists := containsAccount(accounts, localAccount.ID)
 	if exists && !overwriteExisting {
-		writeError(w, http.StatusConflict, errors.New("account already exists"))
+		writeCodedError(w, http.StatusConflict, ErrCodeConflict, errors.New("account already exists"))
 		return
 	}
 
@@ -266,56 +571,460 @@ func (s *APIServer) handleCodexImport(w http.ResponseWriter, r *http.Request) {
 func (s *APIServer) handleAccountDetail(w http.ResponseWriter, r *http.Request) {
 	accountID, action, err := parseAccountPath(r.URL.Path)
 	if err != nil {
-		writeError(w, http.StatusNotFound, errors.New("not found"))
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+
+	switch action {
+	case "":
+		switch r.Method {
+		case http.MethodGet:
+			acct, err := s.manager.GetAccount(r.Context(), accountID)
+			if err != nil {
+				writeError(w, statusForAccountError(err), err)
+				return
+			}
+			writeJSON(w, http.StatusOK, acct)
+		case http.MethodDelete:
+			result, err := s.manager.DeleteAccount(r.Context(), accountID)
+			if err != nil {
+				writeError(w, statusForAccountError(err), err)
+				return
+			}
+			writeJSON(w, http.StatusOK, result)
+		case http.MethodPatch:
+			// Unknown fields are rejected so status, quota and secrets can
+			// only change through their dedicated endpoints.
+			var patch core.AccountPatch
+			dec := json.NewDecoder(r.Body)
+			dec.DisallowUnknownFields()
+			if err := dec.Decode(&patch); err != nil {
+				writeError(w, http.StatusBadRequest, err)
+				return
+			}
+			acct, err := s.manager.PatchAccount(r.Context(), accountID, patch)
+			if err != nil {
+				writeError(w, statusForAccountError(err), err)
+				return
+			}
+			writeJSON(w, http.StatusOK, acct)
+		default:
+			methodNotAllowed(w)
+		}
+	case "activate":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		var req struct {
+			Force bool `json:"force"`
+		}
+		if err := decodeJSONBody(r, &req, true); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if req.Force {
+			result, err := s.manager.ForceSetActiveAccount(r.Context(), accountID)
+			if err != nil {
+				writeError(w, statusForAccountError(err), err)
+				return
+			}
+			out := map[string]string{"status": "ok", "account_id": result.AccountID}
+			if result.ApplierError != "" {
+				out["applier_error"] = result.ApplierError
+			}
+			writeJSON(w, http.StatusOK, out)
+			return
+		}
+		if err := s.manager.SetActiveAccount(r.Context(), accountID); err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "quota":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var q model.QuotaSnapshot
+		if err := decodeJSONBody(r, &q, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		if err := s.manager.UpdateQuota(r.Context(), accountID, q); err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+	case "quota/history":
+		if !requireMethod(w, r, http.MethodGet) {
+			return
+		}
+		limit, err := parseLimitParam(r)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		history, err := s.manager.QuotaHistory(r.Context(), accountID, limit)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"account_id": accountID, "history": history})
+	case "status":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var req struct {
+			Status model.AccountStatus `json:"status"`
+		}
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountStatus(r.Context(), accountID, req.Status)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "weight":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var req struct {
+			Weight int `json:"weight"`
+		}
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountWeight(r.Context(), accountID, req.Weight)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "alias":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var req struct {
+			Alias string `json:"alias"`
+		}
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountAlias(r.Context(), accountID, req.Alias)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "notes":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var req struct {
+			Notes string `json:"notes"`
+		}
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountNotes(r.Context(), accountID, req.Notes)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "meta":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var meta map[string]string
+		if err := decodeJSONBody(r, &meta, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountMeta(r.Context(), accountID, meta)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "http-config":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var req model.HTTPClientConfig
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountHTTPConfig(r.Context(), accountID, req)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "thresholds":
+		if !requireMethod(w, r, http.MethodPatch) {
+			return
+		}
+		var req core.ThresholdsPatch
+		if err := decodeJSONBody(r, &req, false); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		account, err := s.manager.SetAccountThresholds(r.Context(), accountID, req)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "pin", "unpin":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		account, err := s.manager.SetAccountPinned(r.Context(), accountID, action == "pin")
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, account)
+	case "refresh":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		result, err := s.manager.RefreshToken(r.Context(), accountID)
+		if errors.Is(err, core.ErrReauthRequired) {
+			writeErrorBody(w, http.StatusUnprocessableEntity, map[string]string{"error": "reauth_required", "code": ErrCodeReauthRequired, "account_id": accountID})
+			return
+		}
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	case "rotate":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		result, err := s.manager.RotateAccount(r.Context(), accountID)
+		if errors.Is(err, core.ErrReauthRequired) {
+			writeErrorBody(w, http.StatusUnprocessableEntity, map[string]string{"error": "reauth_required", "code": ErrCodeReauthRequired, "account_id": accountID})
+			return
+		}
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	case "test":
+		if !requireMethod(w, r, http.MethodPost) {
+			return
+		}
+		result, err := s.manager.CheckAccountToken(r.Context(), accountID)
+		if err != nil {
+			writeError(w, statusForAccountError(err), err)
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	default:
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+	}
+}
+
+func statusForAccountError(err error) int {
+	if errors.Is(err, core.ErrAccountNotFound) {
+		return http.StatusNotFound
+	}
+	return http.StatusBadRequest
+}
+
+func containsAccount(accounts []model.Account, accountID string) bool {
+	for _, account := range accounts {
+		if account.ID == accountID {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *APIServer) handleQuotaSchedule(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if s.quota == nil {
+		writeJSON(w, http.StatusOK, core.QuotaSchedule{Interval: "0s"})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.quota.Schedule())
+}
+
+func (s *APIServer) handleQuotaNextReset(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	result, err := s.manager.NextQuotaReset(r.Context())
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, core.ErrNoQuotaReset) {
+			status = http.StatusNotFound
+		}
+		writeError(w, status, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *APIServer) handleSwitchHistory(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	limit, err := parseLimitParam(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	// The whole history is loaded so the cursor can be resolved against it;
+	// it is capped by the switch history limit anyway.
+	history, err := s.manager.SwitchHistory(r.Context(), 0)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	start, err := parseCursorFromHistory(history, r.URL.Query().Get("cursor"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
 		return
 	}
+	page := switchHistoryPage{History: history[start:]}
+	if limit > 0 && len(page.History) > limit {
+		page.History = page.History[:limit]
+		page.NextCursor = encodeHistoryCursor(page.History[limit-1].Timestamp)
+	}
+	writeJSON(w, http.StatusOK, page)
+}
 
-	switch action {
-	case "":
-		if !requireMethod(w, r, http.MethodDelete) {
-			return
+type switchHistoryPage struct {
+	History    []model.SwitchEvent `json:"history"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// encodeHistoryCursor encodes the timestamp of the oldest event a page
+// returned. Unlike an offset it stays valid while new events are added.
+func encodeHistoryCursor(t time.Time) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(t.UTC().Format(time.RFC3339Nano)))
+}
+
+// parseCursorFromHistory returns the index of the first event in history
+// (newest first) that is older than the cursor; an empty cursor starts at 0.
+func parseCursorFromHistory(history []model.SwitchEvent, cursor string) (int, error) {
+	cursor = strings.TrimSpace(cursor)
+	if cursor == "" {
+		return 0, nil
+	}
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %q", cursor)
+	}
+	before, err := time.Parse(time.RFC3339Nano, string(raw))
+	if err != nil {
+		return 0, fmt.Errorf("invalid cursor: %q", cursor)
+	}
+	for i, evt := range history {
+		if evt.Timestamp.Before(before) {
+			return i, nil
 		}
-		result, err := s.manager.DeleteAccount(r.Context(), accountID)
-		if err != nil {
-			writeError(w, http.StatusBadRequest, err)
-			return
+	}
+	return len(history), nil
+}
+
+func parseAccountFilter(r *http.Request) (core.AccountFilter, error) {
+	query := r.URL.Query()
+	filter := core.AccountFilter{
+		Status:   model.AccountStatus(strings.TrimSpace(query.Get("status"))),
+		Provider: strings.TrimSpace(query.Get("provider")),
+	}
+	percent := func(name string) (*int, error) {
+		raw := strings.TrimSpace(query.Get(name))
+		if raw == "" {
+			return nil, nil
 		}
-		writeJSON(w, http.StatusOK, result)
-	case "activate":
-		if !requireMethod(w, r, http.MethodPost) {
-			return
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 || n > 100 {
+			return nil, fmt.Errorf("invalid %s: %q (want 0-100)", name, raw)
 		}
-		if err := s.manager.SetActiveAccount(r.Context(), accountID); err != nil {
-			writeError(w, http.StatusBadRequest, err)
-			return
+		return &n, nil
+	}
+	var err error
+	if filter.SessionAbove, err = percent("session_gt"); err != nil {
+		return core.AccountFilter{}, err
+	}
+	if filter.WeeklyAbove, err = percent("weekly_gt"); err != nil {
+		return core.AccountFilter{}, err
+	}
+	if filter.Limit, err = parseLimitParam(r); err != nil {
+		return core.AccountFilter{}, err
+	}
+	if raw := strings.TrimSpace(query.Get("offset")); raw != "" {
+		n, err := strconv.Atoi(raw)
+		if err != nil || n < 0 {
+			return core.AccountFilter{}, fmt.Errorf("invalid offset: %q", raw)
 		}
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
-	case "quota":
-		if !requireMethod(w, r, http.MethodPatch) {
+		filter.Offset = n
+	}
+	return filter, nil
+}
+
+func parseLimitParam(r *http.Request) (int, error) {
+	raw := strings.TrimSpace(r.URL.Query().Get("limit"))
+	if raw == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(raw)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid limit: %q", raw)
+	}
+	return n, nil
+}
+
+func (s *APIServer) handleSwitchRules(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+		rules, err := s.manager.SwitchRules(r.Context())
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err)
 			return
 		}
-		var q model.QuotaSnapshot
-		if err := decodeJSONBody(r, &q, false); err != nil {
+		writeJSON(w, http.StatusOK, rules)
+	case http.MethodPut:
+		var req model.SwitchRules
+		if err := decodeJSONBody(r, &req, false); err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		if err := s.manager.UpdateQuota(r.Context(), accountID, q); err != nil {
+		rules, err := s.manager.SetSwitchRules(r.Context(), req)
+		if err != nil {
 			writeError(w, http.StatusBadRequest, err)
 			return
 		}
-		writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+		writeJSON(w, http.StatusOK, rules)
 	default:
-		writeError(w, http.StatusNotFound, errors.New("not found"))
+		methodNotAllowed(w)
 	}
 }
 
-func containsAccount(accounts []model.Account, accountID string) bool {
-	for _, account := range accounts {
-		if account.ID == accountID {
-			return true
-		}
+func (s *APIServer) handleSwitchCooldown(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
 	}
-	return false
+	if err := s.manager.ResetCooldown(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
 }
 
 func (s *APIServer) handleSwitchOnError(w http.ResponseWriter, r *http.Request) {
@@ -326,13 +1035,21 @@ func (s *APIServer) handleSwitchOnError(w http.ResponseWriter, r *http.Request)
 	var req struct {
 		StatusCode   int    `json:"status_code"`
 		ErrorMessage string `json:"error_message"`
+		DryRun       bool   `json:"dry_run"`
 	}
 	if err := decodeJSONBody(r, &req, false); err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
 	}
 
-	decision, err := s.manager.HandleQuotaError(context.Background(), req.StatusCode, req.ErrorMessage)
+	// A client disconnecting must not abort a switch halfway through, but the
+	// trace context should still reach the manager.
+	ctx := context.WithoutCancel(r.Context())
+	handle := s.manager.HandleQuotaError
+	if req.DryRun {
+		handle = s.manager.DryRunQuotaError
+	}
+	decision, err := handle(ctx, req.StatusCode, req.ErrorMessage)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
@@ -360,12 +1077,73 @@ func (s *APIServer) handleQuotaSync(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, result)
 }
 
+func (s *APIServer) handleQuotaSyncLocal(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		AccountID string `json:"account_id"`
+	}
+	if err := decodeJSONBody(r, &req, true); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := s.manager.SyncQuotaFromLocalLogs(r.Context(), req.AccountID)
+	if err != nil {
+		writeError(w, statusForAccountError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *APIServer) handleQuotaSyncWithWarmup(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		AccountID string `json:"account_id"`
+	}
+	if err := decodeJSONBody(r, &req, true); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	result, err := s.manager.SyncQuotaWithWarmup(r.Context(), req.AccountID)
+	if err != nil {
+		writeError(w, statusForAccountError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *APIServer) handleQuotaWarmup(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	result, err := s.manager.RunCodexWarmup(r.Context())
+	if err != nil {
+		writeError(w, statusForAccountError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 func (s *APIServer) handleQuotaSyncAll(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodPost) {
 		return
 	}
 
-	result, err := s.manager.SyncAllQuotasFromCodexAPI(r.Context())
+	var providers []string
+	if raw := strings.TrimSpace(r.URL.Query().Get("providers")); raw != "" {
+		for _, p := range strings.Split(raw, ",") {
+			if v := strings.TrimSpace(p); v != "" {
+				providers = append(providers, v)
+			}
+		}
+	}
+
+	result, err := s.manager.SyncAllQuotasFromCodexAPI(r.Context(), providers)
 	if err != nil {
 		writeError(w, http.StatusBadRequest, err)
 		return
@@ -374,14 +1152,62 @@ func (s *APIServer) handleQuotaSyncAll(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *APIServer) handleOAuthProviders(w http.ResponseWriter, r *http.Request) {
-	if !requireMethod(w, r, http.MethodGet) {
+	if s.oauth == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("oauth service not configured"))
+		return
+	}
+	if r.Method == http.MethodGet {
+		writeJSON(w, http.StatusOK, map[string][]string{"providers": s.oauth.Providers()})
+		return
+	}
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+
+	var req oauth.ProviderConfig
+	if err := decodeJSONBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	cfg, err := s.oauth.RegisterProvider(r.Context(), req)
+	if err != nil {
+		writeError(w, statusForOAuthProviderError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, cfg)
+}
+
+func (s *APIServer) handleOAuthProviderDetail(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodDelete) {
 		return
 	}
 	if s.oauth == nil {
 		writeError(w, http.StatusServiceUnavailable, errors.New("oauth service not configured"))
 		return
 	}
-	writeJSON(w, http.StatusOK, map[string][]string{"providers": s.oauth.Providers()})
+	name := strings.TrimPrefix(r.URL.Path, "/v1/oauth/providers/")
+	if name == "" || strings.Contains(name, "/") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if err := s.oauth.RemoveProvider(r.Context(), name); err != nil {
+		writeError(w, statusForOAuthProviderError(err), err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok", "provider": name})
+}
+
+func statusForOAuthProviderError(err error) int {
+	switch {
+	case errors.Is(err, oauth.ErrProviderNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, oauth.ErrBuiltinProvider):
+		return http.StatusConflict
+	case errors.Is(err, core.ErrPersistState):
+		return http.StatusInternalServerError
+	default:
+		return http.StatusBadRequest
+	}
 }
 
 func (s *APIServer) handleOAuthStart(w http.ResponseWriter, r *http.Request) {
@@ -432,6 +1258,22 @@ func (s *APIServer) handleOAuthStatus(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, http.StatusOK, snap)
 }
 
+func (s *APIServer) handleOAuthSessions(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	if s.oauth == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("oauth service not configured"))
+		return
+	}
+	status := oauth.SessionStatus(strings.TrimSpace(r.URL.Query().Get("status")))
+	if status != "" && !oauth.ValidSessionStatus(status) {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("invalid status %q", status))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"sessions": s.oauth.Sessions(status)})
+}
+
 func (s *APIServer) handleOAuthCancel(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodPost) {
 		return
@@ -452,8 +1294,33 @@ func (s *APIServer) handleOAuthCancel(w http.ResponseWriter, r *http.Request) {
 		writeError(w, http.StatusBadRequest, errors.New("missing state"))
 		return
 	}
-	if err := s.oauth.Cancel(req.State); err != nil {
-		writeError(w, http.StatusNotFound, err)
+	s.cancelOAuthSession(w, req.State)
+}
+
+// handleOAuthSessionDetail serves DELETE /v1/oauth/sessions/{state}.
+func (s *APIServer) handleOAuthSessionDetail(w http.ResponseWriter, r *http.Request) {
+	state := strings.TrimPrefix(r.URL.Path, "/v1/oauth/sessions/")
+	if state == "" || strings.Contains(state, "/") {
+		writeError(w, http.StatusNotFound, errors.New("not found"))
+		return
+	}
+	if !requireMethod(w, r, http.MethodDelete) {
+		return
+	}
+	if s.oauth == nil {
+		writeError(w, http.StatusServiceUnavailable, errors.New("oauth service not configured"))
+		return
+	}
+	s.cancelOAuthSession(w, state)
+}
+
+func (s *APIServer) cancelOAuthSession(w http.ResponseWriter, state string) {
+	if err := s.oauth.Cancel(state); err != nil {
+		status := http.StatusNotFound
+		if errors.Is(err, oauth.ErrSessionFinished) {
+			status = http.StatusConflict
+		}
+		writeError(w, status, err)
 		return
 	}
 	writeJSON(w, http.StatusOK, map[string]string{"status": "cancelled"})
@@ -467,6 +1334,48 @@ func (s *APIServer) handleOAuthCallback(w http.ResponseWriter, r *http.Request)
 	s.oauth.HandleCallback(w, r)
 }
 
+func (s *APIServer) handleOrphanedSecrets(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	ids, err := s.manager.OrphanedSecrets(r.Context())
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string][]string{"orphaned": ids})
+}
+
+func (s *APIServer) handleSecretsBackend(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodGet) {
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"backend":   s.manager.SecretsBackend(),
+		"available": secrets.Backends(),
+	})
+}
+
+func (s *APIServer) handleSecretsMigrate(w http.ResponseWriter, r *http.Request) {
+	if !requireMethod(w, r, http.MethodPost) {
+		return
+	}
+	var req struct {
+		From string `json:"from"`
+		To   string `json:"to"`
+	}
+	if err := decodeJSONBody(r, &req, false); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	result, err := s.manager.MigrateSecrets(r.Context(), req.From, req.To)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
 func (s *APIServer) handleDaemonInfo(w http.ResponseWriter, r *http.Request) {
 	if !requireMethod(w, r, http.MethodGet) {
 		return
@@ -490,6 +1399,7 @@ func (s *APIServer) handleDaemonShutdown(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
+	s.closeStreams("shutdown")
 	writeJSON(w, http.StatusOK, map[string]string{"status": "shutting_down"})
 }
 
@@ -512,9 +1422,22 @@ func (s *APIServer) handleDaemonRestart(w http.ResponseWriter, r *http.Request)
 		writeError(w, http.StatusInternalServerError, err)
 		return
 	}
+	s.closeStreams("restart")
 	writeJSON(w, http.StatusOK, map[string]string{"status": "restarting"})
 }
 
+// closeStreams ends the long-lived event and log streams so that
+// http.Server.Shutdown is not held open by them.
+func (s *APIServer) closeStreams(reason string) {
+	s.events.shutdown(core.Event{Type: core.EventDaemonShutdown, Reason: reason, Time: time.Now().UTC()})
+	if s.unsubscribeEvents != nil {
+		s.unsubscribeEvents()
+	}
+	if s.logs != nil {
+		s.logs.closeFollowers()
+	}
+}
+
 func parseOptionalTime(s string) (time.Time, error) {
 	if strings.TrimSpace(s) == "" {
 		return time.Time{}, nil
@@ -531,10 +1454,12 @@ func parseAccountPath(path string) (string, string, error) {
 	if len(parts) == 1 {
 		return parts[0], "", nil
 	}
-	if len(parts) == 2 && strings.TrimSpace(parts[1]) != "" {
-		return parts[0], parts[1], nil
+	for _, part := range parts[1:] {
+		if strings.TrimSpace(part) == "" {
+			return "", "", errors.New("not found")
+		}
 	}
-	return "", "", errors.New("not found")
+	return parts[0], strings.Join(parts[1:], "/"), nil
 }
 
 func decodeJSONBody(r *http.Request, dst interface{}, allowEmpty bool) error {
@@ -554,7 +1479,7 @@ func requireMethod(w http.ResponseWriter, r *http.Request, method string) bool {
 }
 
 func methodNotAllowed(w http.ResponseWriter) {
-	writeError(w, http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	writeCodedError(w, http.StatusMethodNotAllowed, ErrCodeMethodNotAllowed, errors.New("method not allowed"))
 }
 
 func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
@@ -563,18 +1488,202 @@ func writeJSON(w http.ResponseWriter, status int, payload interface{}) {
 	_ = json.NewEncoder(w).Encode(payload)
 }
 
+// writeError writes err with the code errorCode derives from it. Use
+// writeCodedError when the handler knows a more specific code.
 func writeError(w http.ResponseWriter, status int, err error) {
-	writeJSON(w, status, map[string]string{"error": err.Error()})
+	writeCodedError(w, status, errorCode(status, err), err)
+}
+
+func writeCodedError(w http.ResponseWriter, status int, code string, err error) {
+	writeErrorBody(w, status, map[string]string{"error": err.Error(), "code": code})
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body map[string]string) {
+	if id := w.Header().Get(requestIDHeader); id != "" {
+		body["request_id"] = id
+	}
+	writeJSON(w, status, body)
+}
+
+const requestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID assigned by requestIDMiddleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimSpace(r.Header.Get(requestIDHeader))
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		// Set before the handler runs so writeError can read it back.
+		w.Header().Set(requestIDHeader, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request_id", id))
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c < 0x21 || c > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random RFC 4122 version 4 UUID.
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Flush keeps server-sent events working through the wrapper.
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
 }
 
 func loggingMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		_ = start
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		status := rec.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		log.Printf("request_id=%s method=%s path=%s status=%d duration=%s",
+			RequestIDFromContext(r.Context()), r.Method, r.URL.Path, status, time.Since(start).Round(time.Millisecond))
 	})
 }
 
+// authMiddleware requires a bearer token on every endpoint except health and
+// the OAuth callbacks, which browsers reach without credentials.
+func authMiddleware(token string) func(http.Handler) http.Handler {
+	expected := []byte("Bearer " + token)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/health", "/v1/oauth/callback", "/auth/callback":
+				next.ServeHTTP(w, r)
+				return
+			}
+			if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), expected) != 1 {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				writeCodedError(w, http.StatusUnauthorized, ErrCodeUnauthorized, errors.New("unauthorized"))
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// rateLimitIdle is how long a client's limiter is kept after its last request.
+const rateLimitIdle = 10 * time.Minute
+
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen time.Time
+}
+
+// rateLimitMiddleware limits requests per client IP. Health checks are never
+// limited so supervisors can always tell the daemon is up.
+func rateLimitMiddleware(rps int, burst int) func(http.Handler) http.Handler {
+	if burst < 1 {
+		burst = 1
+	}
+	var (
+		mu        sync.Mutex
+		clients   = make(map[string]*clientLimiter)
+		lastPrune time.Time
+	)
+	limiterFor := func(ip string, now time.Time) *rate.Limiter {
+		mu.Lock()
+		defer mu.Unlock()
+		if now.Sub(lastPrune) > rateLimitIdle {
+			for key, c := range clients {
+				if now.Sub(c.lastSeen) > rateLimitIdle {
+					delete(clients, key)
+				}
+			}
+			lastPrune = now
+		}
+		c, ok := clients[ip]
+		if !ok {
+			c = &clientLimiter{limiter: rate.NewLimiter(rate.Limit(rps), burst)}
+			clients[ip] = c
+		}
+		c.lastSeen = now
+		return c.limiter
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/v1/health" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ip, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				ip = r.RemoteAddr
+			}
+			now := time.Now()
+			res := limiterFor(ip, now).ReserveN(now, 1)
+			if delay := res.DelayFrom(now); delay > 0 {
+				res.CancelAt(now)
+				retryMS := delay.Milliseconds()
+				if retryMS < 1 {
+					retryMS = 1
+				}
+				w.Header().Set("Retry-After", strconv.FormatInt((retryMS+999)/1000, 10))
+				writeJSON(w, http.StatusTooManyRequests, map[string]interface{}{
+					"error":          "rate limited",
+					"code":           ErrCodeRateLimited,
+					"retry_after_ms": retryMS,
+				})
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 func corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
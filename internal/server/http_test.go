@@ -3,14 +3,28 @@ package server
 import (
 	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
 
 	"switchly/internal/core"
 	"switchly/internal/model"
 	"switchly/internal/oauth"
+	"switchly/internal/quota"
+	"switchly/internal/secrets"
 )
 
 func TestCORSMiddlewarePreflight(t *testing.T) {
@@ -53,6 +67,22 @@ func TestCORSMiddlewarePassThrough(t *testing.T) {
 	}
 }
 
+func TestStatusOmitsUnsetTimes(t *testing.T) {
+	mgr, _ := newTestManager()
+	server := New(mgr, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	for _, field := range []string{`"last_error_at"`, `"cooldown_until"`} {
+		if bytes.Contains(rec.Body.Bytes(), []byte(field)) {
+			t.Fatalf("expected %s to be omitted when unset, got %s", field, rec.Body.String())
+		}
+	}
+}
+
 func TestParseAccountPath(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -64,7 +94,10 @@ func TestParseAccountPath(t *testing.T) {
 		{name: "delete path", path: "/v1/accounts/acc-0", wantID: "acc-0", wantAct: ""},
 		{name: "activate path", path: "/v1/accounts/acc-1/activate", wantID: "acc-1", wantAct: "activate"},
 		{name: "quota path", path: "/v1/accounts/acc-2/quota", wantID: "acc-2", wantAct: "quota"},
+		{name: "status path", path: "/v1/accounts/acc-3/status", wantID: "acc-3", wantAct: "status"},
+		{name: "quota history path", path: "/v1/accounts/acc-4/quota/history", wantID: "acc-4", wantAct: "quota/history"},
 		{name: "trailing slash", path: "/v1/accounts/acc-2/", expectErr: true},
+		{name: "empty segment", path: "/v1/accounts/acc-2//history", expectErr: true},
 	}
 
 	for _, tt := range tests {
@@ -120,8 +153,61 @@ func TestHandleAccountDetailDelete(t *testing.T) {
 	}
 }
 
+func TestHandleAccountDetailDeleteNotFound(t *testing.T) {
+	manager, _ := newTestManager()
+	server := New(manager, nil, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/accounts/missing", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleAccountDetailDeleteKeepsStateWhenSecretDeleteFails(t *testing.T) {
+	state := &testStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "acc-a",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"acc-a": {ID: "acc-a", Provider: "codex", Status: model.AccountReady},
+				"acc-b": {ID: "acc-b", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &testSecretsStore{
+		data: map[string]model.AuthSecrets{
+			"acc-a": {AccessToken: "token-a"},
+			"acc-b": {AccessToken: "token-b"},
+		},
+		deleteErr: errors.New("disk failure"),
+	}
+	manager := core.NewManager(state, secrets, core.WithActiveAccountApplier(deleteTestApplier{}))
+	server := New(manager, nil, nil)
+
+	req := httptest.NewRequest(http.MethodDelete, "/v1/accounts/acc-a", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+	if state.state.ActiveAccountID != "acc-a" {
+		t.Fatalf("expected active account to remain acc-a, got %q", state.state.ActiveAccountID)
+	}
+	if _, ok := state.state.Accounts["acc-a"]; !ok {
+		t.Fatal("expected account to remain in state after failed secret delete")
+	}
+}
+
 func TestHandleOAuthCancel(t *testing.T) {
-	oauthService := oauth.NewService(nil, "http://localhost:7777")
+	oauthService, err := oauth.NewService(nil, "http://localhost:7777")
+	if err != nil {
+		t.Fatalf("new oauth service: %v", err)
+	}
 	session, err := oauthService.Start("codex")
 	if err != nil {
 		t.Fatalf("start oauth: %v", err)
@@ -135,8 +221,131 @@ func TestHandleOAuthCancel(t *testing.T) {
 	if rec.Code != http.StatusOK {
 		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
 	}
-	if _, err := oauthService.Status(session.State); err == nil {
-		t.Fatal("expected cancelled session to be removed")
+	snap, err := oauthService.Status(session.State)
+	if err != nil || snap.Status != oauth.SessionCancelled {
+		t.Fatalf("expected a cancelled session, got %#v err=%v", snap, err)
+	}
+}
+
+func TestHandleOAuthSessionDelete(t *testing.T) {
+	oauthService, err := oauth.NewService(nil, "http://localhost:7777")
+	if err != nil {
+		t.Fatalf("new oauth service: %v", err)
+	}
+	session, err := oauthService.Start("codex")
+	if err != nil {
+		t.Fatalf("start oauth: %v", err)
+	}
+	server := New(nil, oauthService, nil)
+
+	for _, tc := range []struct {
+		path string
+		want int
+	}{
+		{"/v1/oauth/sessions/" + session.State, http.StatusOK},
+		{"/v1/oauth/sessions/" + session.State, http.StatusConflict},
+		{"/v1/oauth/sessions/unknown", http.StatusNotFound},
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, tc.path, nil))
+		if rec.Code != tc.want {
+			t.Fatalf("DELETE %s: expected %d, got %d body=%s", tc.path, tc.want, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/oauth/status?state="+session.State, nil))
+	var snap oauth.SessionSnapshot
+	if err := json.Unmarshal(rec.Body.Bytes(), &snap); err != nil {
+		t.Fatalf("decode status: %v", err)
+	}
+	if snap.Status != oauth.SessionCancelled {
+		t.Fatalf("expected polling to report cancelled, got %#v", snap)
+	}
+}
+
+func TestHandleOAuthSessions(t *testing.T) {
+	oauthService, err := oauth.NewService(nil, "http://localhost:7777")
+	if err != nil {
+		t.Fatalf("new oauth service: %v", err)
+	}
+	session, err := oauthService.Start("codex")
+	if err != nil {
+		t.Fatalf("start oauth: %v", err)
+	}
+	api := New(nil, oauthService, nil).Handler()
+
+	list := func(query string) (int, []oauth.SessionSnapshot) {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/oauth/sessions"+query, nil))
+		var out struct {
+			Sessions []oauth.SessionSnapshot `json:"sessions"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		return rec.Code, out.Sessions
+	}
+
+	if code, sessions := list(""); code != http.StatusOK || len(sessions) != 1 || sessions[0].State != session.State {
+		t.Fatalf("unexpected sessions: %d %#v", code, sessions)
+	}
+	if code, sessions := list("?status=success"); code != http.StatusOK || len(sessions) != 0 {
+		t.Fatalf("expected no successful sessions, got %d %#v", code, sessions)
+	}
+	if code, _ := list("?status=bogus"); code != http.StatusBadRequest {
+		t.Fatalf("expected %d for an unknown status, got %d", http.StatusBadRequest, code)
+	}
+}
+
+func TestHandleHealthDetailed(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{}}
+	api := New(core.NewManager(state, secrets), nil, nil).Handler()
+
+	check := func(wantCode int, wantFailed string) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/health?detailed=true", nil))
+		if rec.Code != wantCode {
+			t.Fatalf("expected %d, got %d body=%s", wantCode, rec.Code, rec.Body.String())
+		}
+		var body struct {
+			Status string                      `json:"status"`
+			Checks map[string]core.HealthCheck `json:"checks"`
+		}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		if wantFailed == "" {
+			if body.Status != "ok" || len(body.Checks) != 3 {
+				t.Fatalf("expected all checks to pass, got %s", rec.Body.String())
+			}
+			return
+		}
+		if body.Status != "degraded" || body.Checks[wantFailed].OK || body.Checks[wantFailed].Error == "" {
+			t.Fatalf("expected %s to fail, got %s", wantFailed, rec.Body.String())
+		}
+	}
+
+	check(http.StatusServiceUnavailable, core.HealthCheckAccounts)
+
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	check(http.StatusServiceUnavailable, core.HealthCheckSecrets)
+
+	state.state.ActiveAccountID = "A"
+	check(http.StatusOK, "")
+
+	secrets.getErr = errors.New("keychain locked")
+	check(http.StatusServiceUnavailable, core.HealthCheckSecrets)
+	secrets.getErr = nil
+
+	state.loadErr = errors.New("permission denied")
+	check(http.StatusServiceUnavailable, core.HealthCheckStateFile)
+
+	// The plain probe does not touch any subsystem.
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected plain health to stay ok, got %d", rec.Code)
 	}
 }
 
@@ -190,3 +399,922 @@ func (deleteTestApplier) Apply(context.Context, model.Account, model.AuthSecrets
 func (deleteTestApplier) Clear(context.Context) error {
 	return nil
 }
+
+func TestHandleAccountDetailGet(t *testing.T) {
+	state := &testStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"acc-a": {ID: "acc-a", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{"acc-a": {AccessToken: "token-a"}}}
+	server := New(core.NewManager(state, secrets), nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/accounts/acc-a", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte(`"id":"acc-a"`)) || bytes.Contains(rec.Body.Bytes(), []byte("token-a")) {
+		t.Fatalf("unexpected body: %s", rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/accounts/missing", nil)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleAccountDetailRefreshReauthRequired(t *testing.T) {
+	state := &testStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"acc-a": {ID: "acc-a", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{"acc-a": {AccessToken: "token-a"}}}
+	server := New(core.NewManager(state, secrets), nil, nil)
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/accounts/acc-a/refresh", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["error"] != "reauth_required" || body["account_id"] != "acc-a" {
+		t.Fatalf("unexpected body: %v", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/accounts/acc-a/refresh", nil)
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHandleAccountNotes(t *testing.T) {
+	mgr, _ := newTestManager()
+	if _, err := mgr.AddAccount(context.Background(), core.AddAccountInput{ID: "acc-a", Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "token-a"}}); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+	server := New(mgr, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/acc-a/notes", bytes.NewBufferString(`{"notes":"do not delete"}`)))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"notes":"do not delete"`)) {
+		t.Fatalf("expected notes to be saved, got %d body=%s", rec.Code, rec.Body.String())
+	}
+
+	body, _ := json.Marshal(map[string]string{"notes": strings.Repeat("x", 501)})
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/acc-a/notes", bytes.NewReader(body)))
+	if rec.Code != http.StatusBadRequest || !bytes.Contains(rec.Body.Bytes(), []byte(ErrCodeValidation)) {
+		t.Fatalf("expected %d for notes over 500 characters, got %d body=%s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleAccountMeta(t *testing.T) {
+	mgr, _ := newTestManager()
+	if _, err := mgr.AddAccount(context.Background(), core.AddAccountInput{ID: "acc-a", Provider: "codex", Secrets: model.AuthSecrets{AccessToken: "token-a"}}); err != nil {
+		t.Fatalf("add account: %v", err)
+	}
+	server := New(mgr, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/acc-a/meta", bytes.NewBufferString(`{"org":"acme","region":"eu"}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected meta to be saved, got %d body=%s", rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/acc-a/meta", bytes.NewBufferString(`{"region":""}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected meta key to be removed, got %d body=%s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/accounts", nil))
+	if !bytes.Contains(rec.Body.Bytes(), []byte(`"provider_meta":{"org":"acme"}`)) {
+		t.Fatalf("expected the account list to include meta, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/acc-a/meta", bytes.NewBufferString(`{"org":1}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for a non-string value, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleQuotaSyncWithWarmupRejectsUnknownAccount(t *testing.T) {
+	mgr, _ := newTestManager()
+	server := New(mgr, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/quota/sync-with-warmup", bytes.NewBufferString(`{"account_id":"missing"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for an unknown account, got %d body=%s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/quota/sync-with-warmup", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d for GET, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHandleQuotaWarmup(t *testing.T) {
+	state := &testStateStore{
+		state: model.AppState{
+			Version:         1,
+			ActiveAccountID: "acc-a",
+			Strategy:        model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"acc-a": {ID: "acc-a", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secretStore := &testSecretsStore{data: map[string]model.AuthSecrets{
+		"acc-a": {AccessToken: "token-a", AccessExpiresAt: time.Now().UTC().Add(time.Hour)},
+	}}
+	dir := t.TempDir()
+	line := `{"timestamp":"2026-10-02T09:10:00Z","type":"event_msg","payload":{"type":"token_count","rate_limits":{"secondary":{"used_percent":30,"window_minutes":10080,"resets_at":1790500000}}}}`
+	if err := os.WriteFile(filepath.Join(dir, "rollout-2026-10-02T09-00-00-thread-9.jsonl"), []byte(line+"\n"), 0o600); err != nil {
+		t.Fatalf("write session log: %v", err)
+	}
+	mgr := core.NewManager(state, secretStore,
+		core.WithCodexSessionsDir(dir),
+		core.WithCodexExecFunc(func(ctx context.Context, args ...string) ([]byte, error) {
+			return []byte(`{"type":"thread.started","thread_id":"thread-9"}` + "\n"), nil
+		}),
+		core.WithCodexQuotaFetcher(func(ctx context.Context, httpClient *http.Client, accessToken, accountID string) (quota.Snapshot, error) {
+			return quota.Snapshot{Session: &quota.Window{UsedPercent: 15}, SourceTimestamp: time.Now().UTC()}, nil
+		}),
+	)
+	server := New(mgr, nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/quota/warmup", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out core.WarmupResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out.ThreadID != "thread-9" || out.Quota.Session.UsedPercent != 15 || out.Quota.Weekly.UsedPercent != 30 {
+		t.Fatalf("unexpected warmup result: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/quota/warmup", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d for GET, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHandleAccountDetailRotate(t *testing.T) {
+	state := &testStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"acc-a": {ID: "acc-a", Provider: "codex", Status: model.AccountReady},
+			},
+		},
+	}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{"acc-a": {AccessToken: "token-a"}}}
+	server := New(core.NewManager(state, secrets), nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/acc-a/rotate", nil))
+	if rec.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected %d without a refresh token, got %d body=%s", http.StatusUnprocessableEntity, rec.Code, rec.Body.String())
+	}
+	if state.state.ActiveAccountID != "" {
+		t.Fatalf("expected no switch after a failed rotate, got %q", state.state.ActiveAccountID)
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/missing/rotate", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandleAccountQuotaHistory(t *testing.T) {
+	history := make([]model.QuotaSnapshot, 0, 60)
+	for i := 0; i < 60; i++ {
+		history = append(history, model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: i}})
+	}
+	state := &testStateStore{
+		state: model.AppState{
+			Version:  1,
+			Strategy: model.RoutingRoundRobin,
+			Accounts: map[string]model.Account{
+				"acc-a": {ID: "acc-a", Provider: "codex", Status: model.AccountReady, QuotaHistory: history},
+			},
+		},
+	}
+	server := New(core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}}), nil, nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/accounts/acc-a/quota/history?limit=48", nil)
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var body struct {
+		AccountID string                `json:"account_id"`
+		History   []model.QuotaSnapshot `json:"history"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.AccountID != "acc-a" || len(body.History) != 48 || body.History[47].Session.UsedPercent != 59 {
+		t.Fatalf("unexpected history response: id=%s len=%d", body.AccountID, len(body.History))
+	}
+
+	for path, want := range map[string]int{
+		"/v1/accounts/acc-a/quota/history?limit=0": http.StatusBadRequest,
+		"/v1/accounts/missing/quota/history":       http.StatusNotFound,
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", path, want, rec.Code)
+		}
+	}
+}
+
+func TestHandlePriority(t *testing.T) {
+	mgr, _ := newTestManager()
+	api := New(mgr, nil, nil).Handler()
+
+	req := httptest.NewRequest(http.MethodPut, "/v1/priority", bytes.NewBufferString(`{"priorities":["b","a"]}`))
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/priority", nil)
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`{"priorities":["b","a"]}`)) {
+		t.Fatalf("unexpected response: %d %s", rec.Code, rec.Body.String())
+	}
+
+	req = httptest.NewRequest(http.MethodPut, "/v1/priority", bytes.NewBufferString(`{"priorities":["a","a"]}`))
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for duplicates, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleAccountDetailPin(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/A/pin", nil))
+	if rec.Code != http.StatusOK || !state.state.Accounts["A"].Pinned {
+		t.Fatalf("pin: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/A/unpin", nil))
+	if rec.Code != http.StatusOK || state.state.Accounts["A"].Pinned {
+		t.Fatalf("unpin: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/missing/pin", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for missing account, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestHandleAccountsFilter(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["a"] = model.Account{ID: "a", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 80}}}
+	state.state.Accounts["b"] = model.Account{ID: "b", Provider: "codex", Status: model.AccountDisabled, Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 90}}}
+	state.state.Accounts["c"] = model.Account{ID: "c", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: 20}}}
+	api := New(core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}}), nil, nil).Handler()
+
+	list := func(query string) (int, []string, int) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/accounts"+query, nil))
+		var out struct {
+			Accounts []model.Account `json:"accounts"`
+			Total    int             `json:"total"`
+		}
+		_ = json.Unmarshal(rec.Body.Bytes(), &out)
+		ids := make([]string, 0, len(out.Accounts))
+		for _, acct := range out.Accounts {
+			ids = append(ids, acct.ID)
+		}
+		return rec.Code, ids, out.Total
+	}
+
+	if code, ids, total := list("?status=ready&session_gt=50"); code != http.StatusOK || strings.Join(ids, ",") != "a" || total != 1 {
+		t.Fatalf("unexpected filtered list: %d %v total=%d", code, ids, total)
+	}
+	if code, ids, total := list("?limit=1&offset=1"); code != http.StatusOK || strings.Join(ids, ",") != "b" || total != 3 {
+		t.Fatalf("unexpected page: %d %v total=%d", code, ids, total)
+	}
+	if code, ids, _ := list("?offset=5"); code != http.StatusOK || len(ids) != 0 {
+		t.Fatalf("expected an empty page past the end, got %d %v", code, ids)
+	}
+	for _, query := range []string{"?session_gt=abc", "?weekly_gt=101", "?offset=-1", "?limit=0", "?status=bogus"} {
+		if code, _, _ := list(query); code != http.StatusBadRequest {
+			t.Fatalf("expected %d for %s, got %d", http.StatusBadRequest, query, code)
+		}
+	}
+}
+
+func TestHandleAccountDetailAlias(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/A/alias", bytes.NewBufferString(`{"alias":"work"}`)))
+	if rec.Code != http.StatusOK || state.state.Accounts["A"].Alias != "work" || !bytes.Contains(rec.Body.Bytes(), []byte(`"alias":"work"`)) {
+		t.Fatalf("alias: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/missing/alias", bytes.NewBufferString(`{"alias":"home"}`)))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for missing account, got %d", http.StatusNotFound, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/A/alias", bytes.NewBufferString(`{"alias":"home"}`)))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected %d for POST, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHandleStrategyGetAndReset(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Strategy = model.RoutingFillFirst
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/strategy", nil))
+	var got struct {
+		Strategy  model.RoutingStrategy   `json:"strategy"`
+		Available []model.RoutingStrategy `json:"available"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode strategy: %v (%s)", err, rec.Body.String())
+	}
+	if rec.Code != http.StatusOK || got.Strategy != model.RoutingFillFirst || !reflect.DeepEqual(got.Available, model.RoutingStrategies) {
+		t.Fatalf("unexpected strategy response %d: %#v", rec.Code, got)
+	}
+
+	for _, body := range []string{`{"strategy":""}`, `{}`, ``} {
+		state.state.Strategy = model.RoutingPriority
+		rec = httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/strategy", bytes.NewBufferString(body)))
+		if rec.Code != http.StatusOK || state.state.Strategy != model.DefaultRoutingStrategy {
+			t.Fatalf("expected %q to reset the strategy, got %d %s (strategy %s)", body, rec.Code, rec.Body.String(), state.state.Strategy)
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/strategy", bytes.NewBufferString(`{"strategy":"bogus"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for an unknown strategy, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleAccountPatch(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{
+		ID:         "A",
+		Provider:   "codex",
+		Email:      "old@example.com",
+		Alias:      "work",
+		Status:     model.AccountReady,
+		HTTPConfig: &model.HTTPClientConfig{ProxyURL: "http://corp-proxy:8080"},
+	}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	patch := func(path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, path, bytes.NewBufferString(body)))
+		return rec
+	}
+
+	rec := patch("/v1/accounts/A", `{"email":"new@example.com"}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch email: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	acct := state.state.Accounts["A"]
+	if acct.Email != "new@example.com" || acct.Alias != "work" || acct.HTTPConfig == nil || acct.HTTPConfig.ProxyURL != "http://corp-proxy:8080" {
+		t.Fatalf("expected only email to change, got %#v", acct)
+	}
+
+	rec = patch("/v1/accounts/A", `{"alias":"home","http_client_config":{"timeout_seconds":30}}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("patch alias: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	acct = state.state.Accounts["A"]
+	if acct.Email != "new@example.com" || acct.Alias != "home" || acct.HTTPConfig == nil || acct.HTTPConfig.TimeoutSeconds != 30 {
+		t.Fatalf("unexpected account after patch: %#v", acct)
+	}
+
+	if rec := patch("/v1/accounts/missing", `{"email":"x@example.com"}`); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for missing account, got %d", http.StatusNotFound, rec.Code)
+	}
+	for _, body := range []string{`{"status":"disabled"}`, `{"quota":{}}`, `{}`} {
+		if rec := patch("/v1/accounts/A", body); rec.Code != http.StatusBadRequest {
+			t.Fatalf("expected %d for %s, got %d", http.StatusBadRequest, body, rec.Code)
+		}
+	}
+	if state.state.Accounts["A"].Status != model.AccountReady {
+		t.Fatal("status must not be patchable")
+	}
+}
+
+func TestHandleSecretsBackendAndMigrate(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	src := &testSecretsStore{data: map[string]model.AuthSecrets{"A": {AccessToken: "token-a"}}}
+	dst := &testSecretsStore{data: map[string]model.AuthSecrets{}}
+	mgr := core.NewManager(state, src, core.WithSecretStoreOpener(func(backend string) (secrets.Store, error) {
+		switch backend {
+		case "file":
+			return src, nil
+		case "secret-service":
+			return dst, nil
+		}
+		return nil, errors.New("unavailable")
+	}))
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/secrets/backend", nil))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte(`"backend":"unknown"`)) {
+		t.Fatalf("backend: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/secrets/migrate", bytes.NewBufferString(`{"from":"file","to":"secret-service"}`)))
+	if rec.Code != http.StatusOK || dst.data["A"].AccessToken != "token-a" {
+		t.Fatalf("migrate: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/secrets/migrate", bytes.NewBufferString(`{"from":"file","to":"dpapi"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for unavailable store, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestHandleAccountDetailHTTPConfig(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/A/http-config", bytes.NewBufferString(`{"proxy_url":"http://corp-proxy:8080","timeout_seconds":45}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("http-config: unexpected response %d %s", rec.Code, rec.Body.String())
+	}
+	got := state.state.Accounts["A"].HTTPConfig
+	if got == nil || got.ProxyURL != "http://corp-proxy:8080" || got.TimeoutSeconds != 45 {
+		t.Fatalf("expected stored http config, got %#v", got)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/A/http-config", bytes.NewBufferString(`{"proxy_url":"ftp://corp-proxy"}`)))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected %d for invalid proxy, got %d", http.StatusBadRequest, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/v1/accounts/A/http-config", bytes.NewBufferString(`{}`)))
+	if rec.Code != http.StatusOK || state.state.Accounts["A"].HTTPConfig != nil {
+		t.Fatalf("expected empty config to clear override, got %d %#v", rec.Code, state.state.Accounts["A"].HTTPConfig)
+	}
+}
+
+func TestHandleQuotaNextReset(t *testing.T) {
+	now := time.Now().UTC()
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{
+		Session: model.QuotaWindow{UsedPercent: 100, ResetAt: now.Add(3 * time.Hour)},
+		Weekly:  model.QuotaWindow{UsedPercent: 40, ResetAt: now.Add(72 * time.Hour)},
+	}}
+	state.state.Accounts["B"] = model.Account{ID: "B", Provider: "codex", Status: model.AccountReady, Quota: model.QuotaSnapshot{
+		Session: model.QuotaWindow{UsedPercent: 100, ResetAt: now.Add(-time.Minute)},
+		Weekly:  model.QuotaWindow{UsedPercent: 100, ResetAt: now.Add(90 * time.Minute)},
+	}}
+	state.state.Accounts["C"] = model.Account{ID: "C", Provider: "codex", Status: model.AccountDisabled, Quota: model.QuotaSnapshot{
+		Session: model.QuotaWindow{UsedPercent: 100, ResetAt: now.Add(time.Minute)},
+	}}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/quota/next-reset", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var got core.QuotaResetResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if got.AccountID != "B" || got.Window != "weekly" || !got.EarliestResetAt.Equal(now.Add(90*time.Minute)) {
+		t.Fatalf("unexpected next reset: %+v", got)
+	}
+
+	empty, _ := newTestManager()
+	rec = httptest.NewRecorder()
+	New(empty, nil, nil).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/quota/next-reset", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d without reset times, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestHandleSwitchCooldownReset(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.ActiveAccountID = "A"
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}})
+	api := New(mgr, nil, nil).Handler()
+
+	simulate := func() string {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodPost, "/v1/switch/on-error", bytes.NewBufferString(`{"status_code":429,"error_message":"quota exceeded"}`))
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("on-error: expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var decision core.SwitchDecision
+		if err := json.Unmarshal(rec.Body.Bytes(), &decision); err != nil {
+			t.Fatalf("decode decision: %v", err)
+		}
+		return decision.Reason
+	}
+
+	if reason := simulate(); reason != "no-available-account" {
+		t.Fatalf("expected no-available-account, got %q", reason)
+	}
+	if reason := simulate(); reason != "cooldown" {
+		t.Fatalf("expected cooldown, got %q", reason)
+	}
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/v1/switch/cooldown", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reset: expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	if reason := simulate(); reason != "no-available-account" {
+		t.Fatalf("expected a fresh search after reset, got %q", reason)
+	}
+}
+
+func TestHandleSwitchOnErrorDryRun(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.ActiveAccountID = "A"
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["B"] = model.Account{ID: "B", Provider: "codex", Status: model.AccountReady}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{
+		"B": {AccessToken: "token-b", AccessExpiresAt: time.Now().UTC().Add(time.Hour)},
+	}}
+	api := New(core.NewManager(state, secrets), nil, nil).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/switch/on-error", bytes.NewBufferString(`{"status_code":429,"error_message":"quota exceeded","dry_run":true}`))
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if out["dry_run"] != true || out["would_switch"] != true || out["to_account_id"] != "B" || out["switched"] != false {
+		t.Fatalf("unexpected dry-run response: %s", rec.Body.String())
+	}
+	if state.state.ActiveAccountID != "A" || len(state.state.SwitchHistory) != 0 {
+		t.Fatalf("dry run changed state: active=%q history=%d", state.state.ActiveAccountID, len(state.state.SwitchHistory))
+	}
+}
+
+func TestAuthMiddlewareRequiresBearerToken(t *testing.T) {
+	mgr, _ := newTestManager()
+	api := New(mgr, nil, nil, WithAPIToken("s3cret")).Handler()
+
+	tests := []struct {
+		name   string
+		path   string
+		header string
+		want   int
+	}{
+		{name: "missing token", path: "/v1/status", want: http.StatusUnauthorized},
+		{name: "wrong token", path: "/v1/status", header: "Bearer nope", want: http.StatusUnauthorized},
+		{name: "valid token", path: "/v1/status", header: "Bearer s3cret", want: http.StatusOK},
+		{name: "health is public", path: "/v1/health", want: http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			api.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Fatalf("expected %d, got %d body=%s", tt.want, rec.Code, rec.Body.String())
+			}
+		})
+	}
+
+	open := New(mgr, nil, nil).Handler()
+	rec := httptest.NewRecorder()
+	open.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/status", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected API without token to stay open, got %d", rec.Code)
+	}
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	mgr, _ := newTestManager()
+	api := New(mgr, nil, nil, WithAPIRateLimit(1, 2)).Handler()
+
+	get := func(path, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, req)
+		return rec
+	}
+	for i := 0; i < 2; i++ {
+		if rec := get("/v1/status", "10.0.0.1:5000"); rec.Code != http.StatusOK {
+			t.Fatalf("request %d within the burst: expected %d, got %d", i, http.StatusOK, rec.Code)
+		}
+	}
+
+	rec := get("/v1/status", "10.0.0.1:5001")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected %d above the limit, got %d body=%s", http.StatusTooManyRequests, rec.Code, rec.Body.String())
+	}
+	var body struct {
+		Error        string `json:"error"`
+		Code         string `json:"code"`
+		RetryAfterMS int64  `json:"retry_after_ms"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body.Error != "rate limited" || body.Code != ErrCodeRateLimited || body.RetryAfterMS <= 0 {
+		t.Fatalf("unexpected body: %+v", body)
+	}
+	if rec.Header().Get("Retry-After") == "" {
+		t.Fatal("expected a Retry-After header")
+	}
+
+	for i := 0; i < 5; i++ {
+		if rec := get("/v1/health", "10.0.0.1:5000"); rec.Code != http.StatusOK {
+			t.Fatalf("expected health to be exempt, got %d", rec.Code)
+		}
+	}
+	if rec := get("/v1/status", "10.0.0.2:5000"); rec.Code != http.StatusOK {
+		t.Fatalf("expected another client to have its own limit, got %d", rec.Code)
+	}
+}
+
+func TestRequestIDMiddleware(t *testing.T) {
+	mgr, _ := newTestManager()
+	api := New(mgr, nil, nil).Handler()
+
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/health", nil))
+	generated := rec.Header().Get("X-Request-Id")
+	if rec.Code != http.StatusOK || len(generated) != 36 || generated[14] != '4' {
+		t.Fatalf("expected generated uuid on success, got %d %q", rec.Code, generated)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/accounts/missing", nil)
+	req.Header.Set("X-Request-Id", "trace-123")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotFound || rec.Header().Get("X-Request-Id") != "trace-123" {
+		t.Fatalf("expected echoed request id on error, got %d %q", rec.Code, rec.Header().Get("X-Request-Id"))
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["request_id"] != "trace-123" || body["error"] == "" {
+		t.Fatalf("unexpected error body: %v", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/v1/health", nil)
+	req.Header.Set("X-Request-Id", "bad id\n")
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if got := rec.Header().Get("X-Request-Id"); got == "bad id\n" || len(got) != 36 {
+		t.Fatalf("expected invalid request id to be replaced, got %q", got)
+	}
+}
+
+func TestTracingMiddlewareParentsManagerSpans(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.ActiveAccountID = "A"
+	state.state.Accounts["A"] = model.Account{ID: "A", Provider: "codex", Status: model.AccountReady}
+	mgr := core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}}, core.WithTracerProvider(tp))
+	api := New(mgr, nil, nil, WithTracerProvider(tp)).Handler()
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/switch/on-error", bytes.NewBufferString(`{"status_code":429,"error_message":"quota exceeded"}`))
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 {
+		t.Fatalf("expected 2 spans, got %d", len(spans))
+	}
+	inner, outer := spans[0], spans[1]
+	if inner.Name != "manager.HandleQuotaError" || outer.Name != "POST /v1/switch/on-error" {
+		t.Fatalf("unexpected span names: %q, %q", inner.Name, outer.Name)
+	}
+	if inner.Parent.SpanID() != outer.SpanContext.SpanID() {
+		t.Fatal("expected the manager span to be a child of the request span")
+	}
+}
+
+func TestHandleSwitchHistoryCursor(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	state := &testStateStore{state: model.DefaultState()}
+	for i := 0; i < 5; i++ {
+		state.state.SwitchHistory = append(state.state.SwitchHistory, model.SwitchEvent{
+			Timestamp:   base.Add(time.Duration(i) * time.Minute),
+			ToAccountID: fmt.Sprintf("acct-%d", i),
+			Reason:      "manual",
+		})
+	}
+	api := New(core.NewManager(state, &testSecretsStore{data: map[string]model.AuthSecrets{}}), nil, nil).Handler()
+
+	get := func(query string) (int, switchHistoryPage) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/switch/history"+query, nil))
+		var page switchHistoryPage
+		if rec.Code == http.StatusOK {
+			if err := json.Unmarshal(rec.Body.Bytes(), &page); err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+		}
+		return rec.Code, page
+	}
+	ids := func(page switchHistoryPage) string {
+		out := make([]string, 0, len(page.History))
+		for _, evt := range page.History {
+			out = append(out, evt.ToAccountID)
+		}
+		return strings.Join(out, ",")
+	}
+
+	code, first := get("?limit=2")
+	if code != http.StatusOK || ids(first) != "acct-4,acct-3" || first.NextCursor == "" {
+		t.Fatalf("unexpected first page (%d): %#v", code, first)
+	}
+	code, second := get("?limit=2&cursor=" + first.NextCursor)
+	if code != http.StatusOK || ids(second) != "acct-2,acct-1" || second.NextCursor == "" {
+		t.Fatalf("unexpected second page (%d): %#v", code, second)
+	}
+	code, last := get("?limit=2&cursor=" + second.NextCursor)
+	if code != http.StatusOK || ids(last) != "acct-0" || last.NextCursor != "" {
+		t.Fatalf("unexpected last page (%d): %#v", code, last)
+	}
+
+	code, past := get("?limit=2&cursor=" + encodeHistoryCursor(base.Add(-time.Hour)))
+	if code != http.StatusOK || len(past.History) != 0 || past.NextCursor != "" {
+		t.Fatalf("expected an empty page past the end (%d): %#v", code, past)
+	}
+
+	for _, cursor := range []string{"not-base64!", base64.RawURLEncoding.EncodeToString([]byte("yesterday"))} {
+		if code, _ := get("?cursor=" + cursor); code != http.StatusBadRequest {
+			t.Fatalf("expected %d for cursor %q, got %d", http.StatusBadRequest, cursor, code)
+		}
+	}
+}
+
+func TestHandleAccountDetailTest(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "Bearer token-a" {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer provider.Close()
+
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["acc-a"] = model.Account{ID: "acc-a", Provider: "codex", Status: model.AccountReady}
+	state.state.Accounts["acc-b"] = model.Account{ID: "acc-b", Provider: "codex", Status: model.AccountReady}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{
+		"acc-a": {AccessToken: "token-a"},
+		"acc-b": {AccessToken: "stale"},
+	}}
+	server := New(core.NewManager(state, secrets, core.WithTokenCheckURL("codex", provider.URL)), nil, nil)
+
+	for id, want := range map[string]map[string]any{
+		"acc-a": {"valid": true, "provider": "codex"},
+		"acc-b": {"valid": false, "http_status": float64(http.StatusUnauthorized)},
+	} {
+		rec := httptest.NewRecorder()
+		server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/"+id+"/test", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("%s: expected %d, got %d body=%s", id, http.StatusOK, rec.Code, rec.Body.String())
+		}
+		var body map[string]any
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("%s: decode body: %v", id, err)
+		}
+		for k, v := range want {
+			if body[k] != v {
+				t.Fatalf("%s: expected %s=%v, got %v", id, k, v, body)
+			}
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/missing/test", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d for a missing account, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+type failingApplier struct{}
+
+func (failingApplier) Apply(context.Context, model.Account, model.AuthSecrets) error {
+	return errors.New("auth file is read-only")
+}
+
+func (failingApplier) Clear(context.Context) error {
+	return nil
+}
+
+func TestHandleAccountDetailActivateForce(t *testing.T) {
+	state := &testStateStore{state: model.DefaultState()}
+	state.state.Accounts["acc-a"] = model.Account{ID: "acc-a", Provider: "codex", Status: model.AccountReady}
+	secrets := &testSecretsStore{data: map[string]model.AuthSecrets{"acc-a": {AccessToken: "token-a"}}}
+	server := New(core.NewManager(state, secrets, core.WithActiveAccountApplier(failingApplier{})), nil, nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/acc-a/activate", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected the unforced switch to fail with %d, got %d body=%s", http.StatusBadRequest, rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/acc-a/activate", bytes.NewBufferString(`{"force":true}`)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected %d, got %d body=%s", http.StatusOK, rec.Code, rec.Body.String())
+	}
+	var body map[string]string
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["applier_error"] != "auth file is read-only" {
+		t.Fatalf("expected applier_error in the response, got %v", body)
+	}
+	if state.state.ActiveAccountID != "acc-a" {
+		t.Fatalf("expected acc-a to be persisted as active, got %q", state.state.ActiveAccountID)
+	}
+}
@@ -254,7 +254,8 @@ func TestCodexImportConflictWhenOverwriteDisabled(t *testing.T) {
 }
 
 type testStateStore struct {
-	state model.AppState
+	state   model.AppState
+	loadErr error
 }
 
 func newTestManager() (*core.Manager, *testSecretsStore) {
@@ -264,6 +265,9 @@ func newTestManager() (*core.Manager, *testSecretsStore) {
 }
 
 func (s *testStateStore) Load() (model.AppState, error) {
+	if s.loadErr != nil {
+		return model.AppState{}, s.loadErr
+	}
 	return cloneState(s.state), nil
 }
 
@@ -273,7 +277,9 @@ func (s *testStateStore) Save(in model.AppState) error {
 }
 
 type testSecretsStore struct {
-	data map[string]model.AuthSecrets
+	data      map[string]model.AuthSecrets
+	getErr    error
+	deleteErr error
 }
 
 func (s *testSecretsStore) Put(accountID string, sec model.AuthSecrets) error {
@@ -282,14 +288,28 @@ func (s *testSecretsStore) Put(accountID string, sec model.AuthSecrets) error {
 }
 
 func (s *testSecretsStore) Get(accountID string) (model.AuthSecrets, error) {
+	if s.getErr != nil {
+		return model.AuthSecrets{}, s.getErr
+	}
 	return s.data[accountID], nil
 }
 
 func (s *testSecretsStore) Delete(accountID string) error {
+	if s.deleteErr != nil {
+		return s.deleteErr
+	}
 	delete(s.data, accountID)
 	return nil
 }
 
+func (s *testSecretsStore) List() ([]string, error) {
+	ids := make([]string, 0, len(s.data))
+	for id := range s.data {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
 func cloneState(in model.AppState) model.AppState {
 	out := in
 	out.Accounts = map[string]model.Account{}
@@ -330,3 +350,169 @@ func setTestUserHome(t *testing.T, home string) {
 		t.Setenv("HOMEPATH", homedir)
 	}
 }
+
+func TestBatchImportReportsPartialFailure(t *testing.T) {
+	mgr, secrets := newTestManager()
+	api := New(mgr, nil, nil).Handler()
+
+	body := `{"accounts":[
+		{"id":"acc-1","provider":"codex","access_token":"token-1"},
+		{"id":"acc-2","provider":"codex"},
+		{"id":"acc-3","provider":"codex","access_token":"token-3","access_expires_at":"tomorrow"},
+		{"id":"acc-4","provider":"codex","access_token":"token-4","weight":3}
+	]}`
+	result := postBatchImport(t, api, body)
+
+	if result.Total != 4 || result.Succeeded != 2 || result.Failed != 2 {
+		t.Fatalf("unexpected totals: %+v", result)
+	}
+	for i, wantSuccess := range []bool{true, false, false, true} {
+		item := result.Results[i]
+		if item.Index != i || item.Success != wantSuccess {
+			t.Fatalf("unexpected result %d: %+v", i, item)
+		}
+		if !wantSuccess && item.Error == "" {
+			t.Fatalf("expected error for entry %d", i)
+		}
+	}
+	if !strings.Contains(result.Results[2].Error, "access_expires_at") {
+		t.Fatalf("unexpected error for invalid time: %q", result.Results[2].Error)
+	}
+	if _, ok := secrets.data["acc-2"]; ok {
+		t.Fatal("expected invalid entry not to be stored")
+	}
+	if secrets.data["acc-4"].AccessToken != "token-4" {
+		t.Fatalf("expected later entries to be imported after a failure")
+	}
+}
+
+func TestBatchImportIsIdempotent(t *testing.T) {
+	mgr, _ := newTestManager()
+	api := New(mgr, nil, nil).Handler()
+	body := `{"accounts":[
+		{"id":"acc-1","provider":"codex","access_token":"token-1","weight":2},
+		{"id":"acc-2","provider":"codex","access_token":"token-2"}
+	]}`
+
+	for round := 0; round < 2; round++ {
+		result := postBatchImport(t, api, body)
+		if result.Succeeded != 2 || result.Failed != 0 {
+			t.Fatalf("round %d: unexpected totals: %+v", round, result)
+		}
+	}
+
+	accounts, err := mgr.ListAccounts(context.Background())
+	if err != nil {
+		t.Fatalf("list accounts: %v", err)
+	}
+	if len(accounts) != 2 {
+		t.Fatalf("expected 2 accounts after re-import, got %d", len(accounts))
+	}
+	for _, acct := range accounts {
+		if acct.ID == "acc-1" && acct.Weight != 2 {
+			t.Fatalf("expected weight to be preserved, got %d", acct.Weight)
+		}
+	}
+}
+
+type batchImportResponse struct {
+	Total     int `json:"total"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+	Results   []struct {
+		Index   int    `json:"index"`
+		ID      string `json:"id"`
+		Success bool   `json:"success"`
+		Error   string `json:"error"`
+	} `json:"results"`
+}
+
+func postBatchImport(t *testing.T, api http.Handler, body string) batchImportResponse {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/v1/accounts/import/batch", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var out batchImportResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &out); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	return out
+}
+
+func TestEnvImportAddsAccounts(t *testing.T) {
+	mgr, secrets := newTestManager()
+	api := New(mgr, nil, nil).Handler()
+
+	post := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/import/env", nil))
+		return rec
+	}
+
+	t.Setenv("SWITCHLY_ACCESS_TOKEN", "")
+	if rec := post(); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d without variables, got %d body=%s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+
+	t.Setenv("SWITCHLY_ACCOUNT_0_ACCESS_TOKEN", "access-0")
+	t.Setenv("SWITCHLY_ACCOUNT_0_EMAIL", "ci@example.com")
+	t.Setenv("SWITCHLY_ACCESS_TOKEN", "access-single")
+	t.Setenv("SWITCHLY_ID", "single")
+	rec := post()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	var result batchImportResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil {
+		t.Fatalf("decode response: %v", err)
+	}
+	if result.Total != 2 || result.Succeeded != 2 {
+		t.Fatalf("unexpected totals: %+v", result)
+	}
+	if secrets.data["codex:ci@example.com"].AccessToken != "access-0" || secrets.data["single"].AccessToken != "access-single" {
+		t.Fatalf("unexpected stored secrets: %#v", secrets.data)
+	}
+}
+
+func TestCopilotImportAddsAccount(t *testing.T) {
+	configHome := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", configHome)
+	t.Setenv("LOCALAPPDATA", configHome)
+	t.Setenv("GITHUB_TOKEN", "")
+	mgr, secrets := newTestManager()
+	api := New(mgr, nil, nil).Handler()
+
+	post := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		api.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/accounts/import/copilot", nil))
+		return rec
+	}
+	if rec := post(); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected %d without a token, got %d body=%s", http.StatusNotFound, rec.Code, rec.Body.String())
+	}
+
+	dir := filepath.Join(configHome, "github-copilot")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "hosts.json"), []byte(`{"github.com":{"user":"octocat","oauth_token":"gho_secret"}}`), 0o600); err != nil {
+		t.Fatalf("write hosts.json: %v", err)
+	}
+	rec := post()
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d body=%s", rec.Code, rec.Body.String())
+	}
+	acct, err := mgr.GetAccount(context.Background(), "copilot:octocat")
+	if err != nil {
+		t.Fatalf("get imported account: %v", err)
+	}
+	if acct.Provider != "copilot" || !acct.AccessExpiresAt.IsZero() {
+		t.Fatalf("expected a copilot account without a token expiry, got %#v", acct)
+	}
+	if secrets.data["copilot:octocat"].AccessToken != "gho_secret" {
+		t.Fatalf("unexpected stored secrets: %#v", secrets.data)
+	}
+}
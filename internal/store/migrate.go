@@ -0,0 +1,64 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"switchly/internal/model"
+)
+
+// migrations[v] upgrades a state from version v to v+1. Files written before
+// the version field existed load as version 0.
+var migrations = []func(*model.AppState){
+	migrateV0,
+	migrateV1,
+}
+
+// migrate brings state up to model.StateVersion and reports whether anything
+// changed, so the caller can write the upgraded file back.
+func migrate(state model.AppState) (model.AppState, bool, error) {
+	if state.Version < 0 || state.Version > model.StateVersion {
+		return state, false, fmt.Errorf("state file has schema version %d, this build supports up to %d", state.Version, model.StateVersion)
+	}
+	modified := false
+	for state.Version < model.StateVersion {
+		migrations[state.Version](&state)
+		state.Version++
+		modified = true
+	}
+	return state, modified, nil
+}
+
+// migrateV0 fills the fields that legacy files left out.
+func migrateV0(state *model.AppState) {
+	if state.Accounts == nil {
+		state.Accounts = map[string]model.Account{}
+	}
+	if state.Strategy == "" {
+		state.Strategy = model.DefaultRoutingStrategy
+	}
+}
+
+// migrateV1 back-fills account ids and timestamps, which older builds did
+// not always write.
+func migrateV1(state *model.AppState) {
+	fallback := state.UpdatedAt
+	if fallback.IsZero() {
+		fallback = time.Now().UTC()
+	}
+	for id, acct := range state.Accounts {
+		if acct.ID == "" {
+			acct.ID = id
+		}
+		if acct.CreatedAt.IsZero() {
+			acct.CreatedAt = acct.UpdatedAt
+		}
+		if acct.CreatedAt.IsZero() {
+			acct.CreatedAt = fallback
+		}
+		if acct.UpdatedAt.IsZero() {
+			acct.UpdatedAt = acct.CreatedAt
+		}
+		state.Accounts[id] = acct
+	}
+}
@@ -0,0 +1,113 @@
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+)
+
+func TestLoadMigratesLegacyState(t *testing.T) {
+	stamp := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		input string
+		check func(t *testing.T, state model.AppState)
+	}{
+		{
+			name:  "unversioned file gets defaults",
+			input: `{"active_account_id":"a"}`,
+			check: func(t *testing.T, state model.AppState) {
+				if state.Strategy != model.DefaultRoutingStrategy {
+					t.Fatalf("expected default strategy, got %q", state.Strategy)
+				}
+				if state.Accounts == nil || state.ActiveAccountID != "a" {
+					t.Fatalf("unexpected state: %#v", state)
+				}
+			},
+		},
+		{
+			name:  "account timestamps fall back to the state's",
+			input: `{"version":1,"strategy":"fill-first","updated_at":"2025-03-01T12:00:00Z","accounts":{"a":{"provider":"codex","status":"ready"}}}`,
+			check: func(t *testing.T, state model.AppState) {
+				acct := state.Accounts["a"]
+				if acct.ID != "a" {
+					t.Fatalf("expected the id to be back-filled from the key, got %q", acct.ID)
+				}
+				if !acct.CreatedAt.Equal(stamp) || !acct.UpdatedAt.Equal(stamp) {
+					t.Fatalf("expected timestamps %s, got created %s updated %s", stamp, acct.CreatedAt, acct.UpdatedAt)
+				}
+				if state.Strategy != model.RoutingStrategy("fill-first") {
+					t.Fatalf("expected the stored strategy to be kept, got %q", state.Strategy)
+				}
+			},
+		},
+		{
+			name:  "created_at falls back to updated_at",
+			input: `{"version":1,"strategy":"round-robin","accounts":{"a":{"id":"a","updated_at":"2025-03-01T12:00:00Z"}}}`,
+			check: func(t *testing.T, state model.AppState) {
+				if acct := state.Accounts["a"]; !acct.CreatedAt.Equal(stamp) {
+					t.Fatalf("expected created_at %s, got %s", stamp, acct.CreatedAt)
+				}
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			store := newTestStateStore(t)
+			if err := os.WriteFile(store.Path(), []byte(tc.input), 0o600); err != nil {
+				t.Fatalf("write legacy state: %v", err)
+			}
+			state, err := store.Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if state.Version != store.SchemaVersion() {
+				t.Fatalf("expected version %d, got %d", store.SchemaVersion(), state.Version)
+			}
+			tc.check(t, state)
+
+			// The upgraded state is written back.
+			data, err := os.ReadFile(store.Path())
+			if err != nil {
+				t.Fatalf("read upgraded file: %v", err)
+			}
+			var onDisk model.AppState
+			if err := json.Unmarshal(data, &onDisk); err != nil {
+				t.Fatalf("parse upgraded file: %v", err)
+			}
+			if onDisk.Version != model.StateVersion {
+				t.Fatalf("expected the file to be upgraded to version %d, got %d", model.StateVersion, onDisk.Version)
+			}
+		})
+	}
+}
+
+func TestLoadCurrentStateDoesNotRewrite(t *testing.T) {
+	store := newTestStateStore(t)
+	if err := store.Save(model.DefaultState()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if err := os.Remove(store.BackupPath()); err != nil && !os.IsNotExist(err) {
+		t.Fatalf("remove backup: %v", err)
+	}
+	if _, err := store.Load(); err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if _, err := os.Stat(store.BackupPath()); !os.IsNotExist(err) {
+		t.Fatalf("expected no rewrite for a current file, backup stat: %v", err)
+	}
+}
+
+func TestLoadRejectsNewerSchema(t *testing.T) {
+	store := newTestStateStore(t)
+	if err := os.WriteFile(store.Path(), []byte(`{"version":99}`), 0o600); err != nil {
+		t.Fatalf("write state: %v", err)
+	}
+	if _, err := store.Load(); err == nil || !strings.Contains(err.Error(), "schema version 99") {
+		t.Fatalf("expected a newer schema to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,9 @@
+//go:build !windows
+
+package store
+
+import "os"
+
+func replaceFile(src, dst string) error {
+	return os.Rename(src, dst)
+}
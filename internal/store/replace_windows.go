@@ -0,0 +1,32 @@
+//go:build windows
+
+package store
+
+import (
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/sys/windows"
+)
+
+const replaceAttempts = 5
+
+func replaceFile(src, dst string) error {
+	var err error
+	for attempt := 0; attempt < replaceAttempts; attempt++ {
+		err = os.Rename(src, dst)
+		if err == nil {
+			return nil
+		}
+		if !errors.Is(err, windows.ERROR_ACCESS_DENIED) {
+			return err
+		}
+		// Another process (editor, antivirus) may hold the target open; drop it and retry.
+		if removeErr := os.Remove(dst); removeErr != nil && !errors.Is(removeErr, os.ErrNotExist) {
+			err = removeErr
+		}
+		time.Sleep(time.Duration(attempt+1) * 50 * time.Millisecond)
+	}
+	return err
+}
@@ -0,0 +1,276 @@
+package store
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+
+	"switchly/internal/model"
+	"switchly/internal/platform"
+)
+
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS accounts (
+	id   TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS metadata (
+	key   TEXT PRIMARY KEY,
+	value TEXT NOT NULL
+);`
+
+// Metadata keys. metaState holds the rest of model.AppState (without the
+// accounts) as JSON.
+const (
+	metaVersion         = "version"
+	metaActiveAccountID = "active_account_id"
+	metaStrategy        = "strategy"
+	metaState           = "state"
+)
+
+// SQLiteStateStore keeps the state in a SQLite database with one row per
+// account, so saves do not re-serialise one large JSON document.
+type SQLiteStateStore struct {
+	mu   sync.RWMutex
+	db   *sql.DB
+	path string
+
+	// beforeCommit lets tests fail a save after its writes were issued.
+	beforeCommit func() error
+}
+
+// NewSQLiteStateStore opens the database at $SWITCHLY_STATE_FILE, or
+// state.db in the config directory.
+func NewSQLiteStateStore() (*SQLiteStateStore, error) {
+	path := strings.TrimSpace(os.Getenv(StateFileEnv))
+	if path != "" {
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("%s must be an absolute path, got %q", StateFileEnv, path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+	} else {
+		dir, err := platform.EnsureConfigDir()
+		if err != nil {
+			return nil, err
+		}
+		path = filepath.Join(dir, "state.db")
+	}
+	return openSQLiteStateStore(path)
+}
+
+func openSQLiteStateStore(path string) (*SQLiteStateStore, error) {
+	db, err := sql.Open("sqlite", "file:"+filepath.ToSlash(path)+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)")
+	if err != nil {
+		return nil, err
+	}
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("init sqlite state %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0o600); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStateStore{db: db, path: path}, nil
+}
+
+// Load reads the state from the database, migrating older schemas like the
+// JSON store does.
+func (s *SQLiteStateStore) Load() (model.AppState, error) {
+	state, exists, err := s.read()
+	if err != nil || !exists {
+		return state, err
+	}
+	state, modified, err := migrate(state)
+	if err != nil {
+		return model.AppState{}, err
+	}
+	if state.Accounts == nil {
+		state.Accounts = map[string]model.Account{}
+	}
+	if state.Strategy == "" {
+		state.Strategy = model.DefaultRoutingStrategy
+	}
+	if modified {
+		if err := s.Save(state); err != nil {
+			return model.AppState{}, fmt.Errorf("save migrated state: %w", err)
+		}
+	}
+	return state, nil
+}
+
+func (s *SQLiteStateStore) read() (model.AppState, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	meta := map[string]string{}
+	rows, err := s.db.Query(`SELECT key, value FROM metadata`)
+	if err != nil {
+		return model.AppState{}, false, err
+	}
+	for rows.Next() {
+		var key, value string
+		if err := rows.Scan(&key, &value); err != nil {
+			rows.Close()
+			return model.AppState{}, false, err
+		}
+		meta[key] = value
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return model.AppState{}, false, err
+	}
+	if len(meta) == 0 {
+		return model.DefaultState(), false, nil
+	}
+
+	var state model.AppState
+	if raw := meta[metaState]; raw != "" {
+		if err := json.Unmarshal([]byte(raw), &state); err != nil {
+			return model.AppState{}, false, fmt.Errorf("decode state metadata: %w", err)
+		}
+	}
+	version, err := strconv.Atoi(meta[metaVersion])
+	if err != nil {
+		return model.AppState{}, false, fmt.Errorf("decode state version: %w", err)
+	}
+	state.Version = version
+	state.ActiveAccountID = meta[metaActiveAccountID]
+	state.Strategy = model.RoutingStrategy(meta[metaStrategy])
+
+	state.Accounts = map[string]model.Account{}
+	rows, err = s.db.Query(`SELECT id, data FROM accounts`)
+	if err != nil {
+		return model.AppState{}, false, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var id, data string
+		if err := rows.Scan(&id, &data); err != nil {
+			return model.AppState{}, false, err
+		}
+		var acct model.Account
+		if err := json.Unmarshal([]byte(data), &acct); err != nil {
+			return model.AppState{}, false, fmt.Errorf("decode account %s: %w", id, err)
+		}
+		state.Accounts[id] = acct
+	}
+	if err := rows.Err(); err != nil {
+		return model.AppState{}, false, err
+	}
+	return state, true, nil
+}
+
+// Save writes the whole state in one transaction: every account is upserted,
+// accounts no longer in state are deleted and the metadata is replaced. On
+// any error the database is left as it was.
+func (s *SQLiteStateStore) Save(state model.AppState) (err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	state.UpdatedAt = time.Now().UTC()
+	rest := state
+	rest.Accounts = nil
+	restJSON, err := json.Marshal(rest)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = tx.Rollback()
+		}
+	}()
+
+	upsert, err := tx.Prepare(`INSERT INTO accounts (id, data) VALUES (?, ?)
+		ON CONFLICT(id) DO UPDATE SET data = excluded.data`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+	for id, acct := range state.Accounts {
+		data, err := json.Marshal(acct)
+		if err != nil {
+			return fmt.Errorf("encode account %s: %w", id, err)
+		}
+		if _, err := upsert.Exec(id, string(data)); err != nil {
+			return fmt.Errorf("save account %s: %w", id, err)
+		}
+	}
+	if err := deleteRemovedAccounts(tx, state.Accounts); err != nil {
+		return err
+	}
+
+	for key, value := range map[string]string{
+		metaVersion:         strconv.Itoa(state.Version),
+		metaActiveAccountID: state.ActiveAccountID,
+		metaStrategy:        string(state.Strategy),
+		metaState:           string(restJSON),
+	} {
+		if _, err := tx.Exec(`INSERT INTO metadata (key, value) VALUES (?, ?)
+			ON CONFLICT(key) DO UPDATE SET value = excluded.value`, key, value); err != nil {
+			return fmt.Errorf("save metadata %s: %w", key, err)
+		}
+	}
+	if s.beforeCommit != nil {
+		if err := s.beforeCommit(); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func deleteRemovedAccounts(tx *sql.Tx, accounts map[string]model.Account) error {
+	rows, err := tx.Query(`SELECT id FROM accounts`)
+	if err != nil {
+		return err
+	}
+	var removed []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		if _, ok := accounts[id]; !ok {
+			removed = append(removed, id)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range removed {
+		if _, err := tx.Exec(`DELETE FROM accounts WHERE id = ?`, id); err != nil {
+			return fmt.Errorf("delete account %s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+// SchemaVersion is the state schema version this build reads and writes.
+func (s *SQLiteStateStore) SchemaVersion() int {
+	return model.StateVersion
+}
+
+func (s *SQLiteStateStore) Path() string {
+	return s.path
+}
+
+func (s *SQLiteStateStore) Close() error {
+	return s.db.Close()
+}
@@ -0,0 +1,234 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+)
+
+func newTestSQLiteStore(t testing.TB) *SQLiteStateStore {
+	t.Helper()
+	s, err := openSQLiteStateStore(filepath.Join(t.TempDir(), "state.db"))
+	if err != nil {
+		t.Fatalf("open sqlite store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func testStateWithAccounts(n int) model.AppState {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	state := model.DefaultState()
+	for i := 0; i < n; i++ {
+		id := fmt.Sprintf("codex:user%03d@example.com", i)
+		state.Accounts[id] = model.Account{
+			ID:        id,
+			Provider:  "codex",
+			Email:     fmt.Sprintf("user%03d@example.com", i),
+			Status:    model.AccountReady,
+			Weight:    i + 1,
+			Quota:     model.QuotaSnapshot{Session: model.QuotaWindow{UsedPercent: i % 100, ResetAt: now.Add(time.Hour)}, LastUpdated: now},
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+	}
+	state.ActiveAccountID = "codex:user000@example.com"
+	return state
+}
+
+func TestSQLiteStoreRoundTrip(t *testing.T) {
+	s := newTestSQLiteStore(t)
+
+	empty, err := s.Load()
+	if err != nil {
+		t.Fatalf("load empty: %v", err)
+	}
+	if empty.Version != model.StateVersion || len(empty.Accounts) != 0 {
+		t.Fatalf("expected the default state from an empty database, got %#v", empty)
+	}
+
+	want := testStateWithAccounts(3)
+	want.Strategy = model.RoutingRoundRobin
+	want.Priorities = []string{"codex:user002@example.com"}
+	want.SwitchHistory = []model.SwitchEvent{{Timestamp: time.Date(2026, 3, 1, 11, 0, 0, 0, time.UTC), ToAccountID: "codex:user001@example.com", Reason: "manual"}}
+	if err := s.Save(want); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.ActiveAccountID != want.ActiveAccountID || got.Strategy != want.Strategy || got.Version != want.Version {
+		t.Fatalf("metadata mismatch: got %#v", got)
+	}
+	if !reflect.DeepEqual(got.Accounts, want.Accounts) {
+		t.Fatalf("accounts mismatch:\n got %#v\nwant %#v", got.Accounts, want.Accounts)
+	}
+	if !reflect.DeepEqual(got.Priorities, want.Priorities) || !reflect.DeepEqual(got.SwitchHistory, want.SwitchHistory) {
+		t.Fatalf("state fields mismatch: got %#v", got)
+	}
+
+	delete(want.Accounts, "codex:user001@example.com")
+	if err := s.Save(want); err != nil {
+		t.Fatalf("save after delete: %v", err)
+	}
+	got, err = s.Load()
+	if err != nil {
+		t.Fatalf("load after delete: %v", err)
+	}
+	if _, ok := got.Accounts["codex:user001@example.com"]; ok || len(got.Accounts) != 2 {
+		t.Fatalf("expected removed account to be deleted, got %d accounts", len(got.Accounts))
+	}
+}
+
+func TestSQLiteStoreConcurrentReads(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	if err := s.Save(testStateWithAccounts(20)); err != nil {
+		t.Fatalf("seed save: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 16)
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 10; j++ {
+				state, err := s.Load()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if len(state.Accounts) != 20 {
+					errs <- fmt.Errorf("read %d accounts, want 20", len(state.Accounts))
+					return
+				}
+			}
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for j := 0; j < 5; j++ {
+			if err := s.Save(testStateWithAccounts(20)); err != nil {
+				errs <- err
+				return
+			}
+		}
+	}()
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLiteStoreFailedSaveRollsBack(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	before := testStateWithAccounts(2)
+	if err := s.Save(before); err != nil {
+		t.Fatalf("seed save: %v", err)
+	}
+
+	next := testStateWithAccounts(5)
+	delete(next.Accounts, "codex:user000@example.com")
+	next.ActiveAccountID = "codex:user004@example.com"
+	boom := errors.New("disk full")
+	s.beforeCommit = func() error { return boom }
+	if err := s.Save(next); !errors.Is(err, boom) {
+		t.Fatalf("expected the injected error, got %v", err)
+	}
+	s.beforeCommit = nil
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.ActiveAccountID != before.ActiveAccountID || !reflect.DeepEqual(got.Accounts, before.Accounts) {
+		t.Fatalf("expected the failed save to leave the previous state, got active=%q accounts=%d", got.ActiveAccountID, len(got.Accounts))
+	}
+}
+
+func TestSQLiteStoreMigratesOlderSchema(t *testing.T) {
+	s := newTestSQLiteStore(t)
+	legacy := testStateWithAccounts(1)
+	legacy.Version = 1
+	for id, acct := range legacy.Accounts {
+		acct.ID = ""
+		legacy.Accounts[id] = acct
+	}
+	if err := s.Save(legacy); err != nil {
+		t.Fatalf("save legacy: %v", err)
+	}
+
+	got, err := s.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.Version != model.StateVersion {
+		t.Fatalf("expected version %d, got %d", model.StateVersion, got.Version)
+	}
+	for id, acct := range got.Accounts {
+		if acct.ID != id {
+			t.Fatalf("expected account ID to be back-filled, got %q for %q", acct.ID, id)
+		}
+	}
+}
+
+func TestOpenSelectsDriver(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(StateFileEnv, filepath.Join(dir, "state.db"))
+	t.Setenv(StateDriverEnv, DriverSQLite)
+
+	st, err := Open("")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	sqliteStore, ok := st.(*SQLiteStateStore)
+	if !ok {
+		t.Fatalf("expected the driver from %s, got %T", StateDriverEnv, st)
+	}
+	sqliteStore.Close()
+
+	t.Setenv(StateFileEnv, filepath.Join(dir, "state.json"))
+	if st, err := Open(DriverJSON); err != nil {
+		t.Fatalf("open json: %v", err)
+	} else if _, ok := st.(*StateStore); !ok {
+		t.Fatalf("expected the JSON store, got %T", st)
+	}
+	if _, err := Open("yaml"); err == nil {
+		t.Fatal("expected an unknown driver to be rejected")
+	}
+}
+
+func benchmarkLoadSave(b *testing.B, s Store) {
+	if err := s.Save(testStateWithAccounts(100)); err != nil {
+		b.Fatalf("seed save: %v", err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		state, err := s.Load()
+		if err != nil {
+			b.Fatal(err)
+		}
+		state.RoutingCursor = i
+		if err := s.Save(state); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkJSONStoreLoadSave100(b *testing.B) {
+	benchmarkLoadSave(b, &StateStore{path: filepath.Join(b.TempDir(), "accounts.json")})
+}
+
+func BenchmarkSQLiteStoreLoadSave100(b *testing.B) {
+	benchmarkLoadSave(b, newTestSQLiteStore(b))
+}
@@ -3,7 +3,10 @@ package store
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -11,12 +14,57 @@ import (
 	"switchly/internal/platform"
 )
 
+// StateFileEnv overrides the state file location, e.g. to run several
+// daemons side by side. It must be an absolute path.
+const StateFileEnv = "SWITCHLY_STATE_FILE"
+
+// StateDriverEnv selects the state store driver when Open is given none.
+const StateDriverEnv = "SWITCHLY_STATE_DRIVER"
+
+const (
+	DriverJSON   = "json"
+	DriverSQLite = "sqlite"
+)
+
+// Store is implemented by every state store driver.
+type Store interface {
+	Load() (model.AppState, error)
+	Save(state model.AppState) error
+	Path() string
+}
+
+// Open returns the state store for driver, falling back to
+// $SWITCHLY_STATE_DRIVER and then the JSON file store.
+func Open(driver string) (Store, error) {
+	driver = strings.ToLower(strings.TrimSpace(driver))
+	if driver == "" {
+		driver = strings.ToLower(strings.TrimSpace(os.Getenv(StateDriverEnv)))
+	}
+	switch driver {
+	case "", DriverJSON:
+		return NewStateStore()
+	case DriverSQLite:
+		return NewSQLiteStateStore()
+	default:
+		return nil, fmt.Errorf("unknown state driver %q (want %s or %s)", driver, DriverJSON, DriverSQLite)
+	}
+}
+
 type StateStore struct {
 	mu   sync.RWMutex
 	path string
 }
 
 func NewStateStore() (*StateStore, error) {
+	if path := strings.TrimSpace(os.Getenv(StateFileEnv)); path != "" {
+		if !filepath.IsAbs(path) {
+			return nil, fmt.Errorf("%s must be an absolute path, got %q", StateFileEnv, path)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return nil, err
+		}
+		return &StateStore{path: path}, nil
+	}
 	if _, err := platform.EnsureConfigDir(); err != nil {
 		return nil, err
 	}
@@ -27,29 +75,53 @@ func NewStateStore() (*StateStore, error) {
 	return &StateStore{path: path}, nil
 }
 
+// Load reads the state file. Files written with an older schema are
+// migrated and saved back in the current schema.
 func (s *StateStore) Load() (model.AppState, error) {
+	state, exists, err := s.read()
+	if err != nil || !exists {
+		return state, err
+	}
+	state, modified, err := migrate(state)
+	if err != nil {
+		return model.AppState{}, err
+	}
+	if state.Accounts == nil {
+		state.Accounts = map[string]model.Account{}
+	}
+	if state.Strategy == "" {
+		state.Strategy = model.DefaultRoutingStrategy
+	}
+	if modified {
+		if err := s.Save(state); err != nil {
+			return model.AppState{}, fmt.Errorf("save migrated state: %w", err)
+		}
+	}
+	return state, nil
+}
+
+func (s *StateStore) read() (model.AppState, bool, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
 	data, err := os.ReadFile(s.path)
 	if errors.Is(err, os.ErrNotExist) {
-		return model.DefaultState(), nil
+		return model.DefaultState(), false, nil
 	}
 	if err != nil {
-		return model.AppState{}, err
+		return model.AppState{}, false, err
 	}
 
 	var state model.AppState
 	if err := json.Unmarshal(data, &state); err != nil {
-		return model.AppState{}, err
-	}
-	if state.Accounts == nil {
-		state.Accounts = map[string]model.Account{}
+		return model.AppState{}, false, err
 	}
-	if state.Strategy == "" {
-		state.Strategy = model.RoutingRoundRobin
-	}
-	return state, nil
+	return state, true, nil
+}
+
+// SchemaVersion is the state schema version this build reads and writes.
+func (s *StateStore) SchemaVersion() int {
+	return model.StateVersion
 }
 
 func (s *StateStore) Save(state model.AppState) error {
@@ -65,7 +137,28 @@ func (s *StateStore) Save(state model.AppState) error {
 		return err
 	}
 
-	return os.WriteFile(s.path, data, 0o600)
+	if err := s.backupLocked(); err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0o600)
+}
+
+func (s *StateStore) Backup() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.backupLocked()
+}
+
+func (s *StateStore) backupLocked() error {
+	err := copyFile(s.path, s.BackupPath(), 0o600)
+	if isNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *StateStore) BackupPath() string {
+	return s.path + ".bak"
 }
 
 func (s *StateStore) Path() string {
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"switchly/internal/model"
+)
+
+func newTestStateStore(t *testing.T) *StateStore {
+	t.Helper()
+	return &StateStore{path: filepath.Join(t.TempDir(), "accounts.json")}
+}
+
+func TestSaveWritesStateAndKeepsSingleBackup(t *testing.T) {
+	store := newTestStateStore(t)
+
+	for _, id := range []string{"first", "second", "third"} {
+		state := model.DefaultState()
+		state.ActiveAccountID = id
+		if err := store.Save(state); err != nil {
+			t.Fatalf("save %s: %v", id, err)
+		}
+	}
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if got.ActiveAccountID != "third" {
+		t.Fatalf("unexpected active account: %q", got.ActiveAccountID)
+	}
+
+	backup, err := os.ReadFile(store.BackupPath())
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	if !strings.Contains(string(backup), `"active_account_id": "second"`) {
+		t.Fatalf("expected backup of previous state, got %s", backup)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(store.Path()))
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected only state and backup files, got %d entries", len(entries))
+	}
+}
+
+func TestAccountNotesSurviveSaveLoad(t *testing.T) {
+	for name, s := range map[string]Store{"json": newTestStateStore(t), "sqlite": newTestSQLiteStore(t)} {
+		t.Run(name, func(t *testing.T) {
+			state := model.DefaultState()
+			state.Accounts["acc-1"] = model.Account{ID: "acc-1", Provider: "codex", Notes: "production team account, do not delete"}
+			if err := s.Save(state); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+			got, err := s.Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if notes := got.Accounts["acc-1"].Notes; notes != "production team account, do not delete" {
+				t.Fatalf("expected notes to persist, got %q", notes)
+			}
+		})
+	}
+}
+
+func TestAccountProviderMetaSurvivesSaveLoad(t *testing.T) {
+	for name, s := range map[string]Store{"json": newTestStateStore(t), "sqlite": newTestSQLiteStore(t)} {
+		t.Run(name, func(t *testing.T) {
+			state := model.DefaultState()
+			state.Accounts["acc-1"] = model.Account{ID: "acc-1", Provider: "copilot", ProviderMeta: map[string]string{"org": "acme", "plan": "business"}}
+			if err := s.Save(state); err != nil {
+				t.Fatalf("save: %v", err)
+			}
+			got, err := s.Load()
+			if err != nil {
+				t.Fatalf("load: %v", err)
+			}
+			if meta := got.Accounts["acc-1"].ProviderMeta; len(meta) != 2 || meta["org"] != "acme" || meta["plan"] != "business" {
+				t.Fatalf("expected provider meta to persist, got %#v", meta)
+			}
+		})
+	}
+}
+
+func TestSaveCrashMidWriteKeepsLiveState(t *testing.T) {
+	store := newTestStateStore(t)
+	state := model.DefaultState()
+	state.ActiveAccountID = "stable"
+	if err := store.Save(state); err != nil {
+		t.Fatalf("seed save: %v", err)
+	}
+
+	origSync := syncFile
+	syncFile = func(f *os.File) error {
+		if !strings.HasPrefix(filepath.Base(f.Name()), "accounts.json.tmp-") {
+			return origSync(f)
+		}
+		// Leave a truncated temp file behind as if the process died mid-write.
+		if err := f.Truncate(10); err != nil {
+			return err
+		}
+		return errors.New("simulated crash")
+	}
+	defer func() { syncFile = origSync }()
+
+	state.ActiveAccountID = "broken"
+	if err := store.Save(state); err == nil {
+		t.Fatal("expected save to fail")
+	}
+
+	got, err := store.Load()
+	if err != nil {
+		t.Fatalf("load after crash: %v", err)
+	}
+	if got.ActiveAccountID != "stable" {
+		t.Fatalf("expected live state to survive, got %q", got.ActiveAccountID)
+	}
+
+	matches, err := filepath.Glob(store.Path() + ".tmp-*")
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("expected temp files to be cleaned up, got %v", matches)
+	}
+	backup, err := os.ReadFile(store.BackupPath())
+	if err != nil {
+		t.Fatalf("read backup: %v", err)
+	}
+	if !strings.Contains(string(backup), `"active_account_id": "stable"`) {
+		t.Fatalf("expected backup to be written before the failed save, got %s", backup)
+	}
+}
+
+func TestNewStateStoreHonoursStateFileEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "nested", "state.json")
+	t.Setenv(StateFileEnv, path)
+
+	store, err := NewStateStore()
+	if err != nil {
+		t.Fatalf("new state store: %v", err)
+	}
+	if store.Path() != path {
+		t.Fatalf("expected path %s, got %s", path, store.Path())
+	}
+	if err := store.Save(model.DefaultState()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected state file at %s: %v", path, err)
+	}
+
+	t.Setenv(StateFileEnv, filepath.Join("relative", "state.json"))
+	if _, err := NewStateStore(); err == nil || !strings.Contains(err.Error(), "absolute") {
+		t.Fatalf("expected relative path to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"switchly/internal/model"
+)
+
+const watchDebounce = 200 * time.Millisecond
+
+// Watch sends the reloaded state every time the state file changes, so
+// changes written by another process become visible. Bursts of events are
+// debounced. The directory is watched rather than the file because Save
+// replaces the file with a rename. The channel is closed when ctx is done.
+func (s *StateStore) Watch(ctx context.Context) (<-chan model.AppState, error) {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("watch state file: %w", err)
+	}
+	if err := watcher.Add(filepath.Dir(s.path)); err != nil {
+		watcher.Close()
+		return nil, fmt.Errorf("watch state file: %w", err)
+	}
+
+	out := make(chan model.AppState, 1)
+	go func() {
+		defer close(out)
+		defer watcher.Close()
+
+		timer := time.NewTimer(watchDebounce)
+		timer.Stop()
+		defer timer.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case evt, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(evt.Name) != filepath.Clean(s.path) || !evt.Has(fsnotify.Write|fsnotify.Create) {
+					continue
+				}
+				timer.Reset(watchDebounce)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("watch state file: %v", err)
+			case <-timer.C:
+				state, err := s.Load()
+				if err != nil {
+					log.Printf("reload state file: %v", err)
+					continue
+				}
+				select {
+				case out <- state:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return out, nil
+}
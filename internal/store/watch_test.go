@@ -0,0 +1,51 @@
+package store
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"switchly/internal/model"
+)
+
+func TestWatchReportsExternalChanges(t *testing.T) {
+	store := newTestStateStore(t)
+	if err := store.Save(model.DefaultState()); err != nil {
+		t.Fatalf("save: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	changes, err := store.Watch(ctx)
+	if err != nil {
+		t.Fatalf("watch: %v", err)
+	}
+
+	// A second store on the same file stands in for another process.
+	other := &StateStore{path: store.Path()}
+	go func() {
+		state := model.DefaultState()
+		state.ActiveAccountID = "codex:b@example.com"
+		if err := other.Save(state); err != nil {
+			t.Errorf("save from other store: %v", err)
+		}
+	}()
+
+	select {
+	case state := <-changes:
+		if state.ActiveAccountID != "codex:b@example.com" {
+			t.Fatalf("expected the externally written state, got active %q", state.ActiveAccountID)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no change reported within 1s")
+	}
+
+	cancel()
+	select {
+	case _, ok := <-changes:
+		if ok {
+			t.Fatal("expected the channel to be closed after cancel")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("watcher did not stop after cancel")
+	}
+}
@@ -0,0 +1,119 @@
+// Package webhook POSTs account switch events to an operator-supplied URL so
+// external systems such as CI pipelines can react to them.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// SignatureHeader carries "sha256=<hex HMAC-SHA256 of the body>" when a
+	// secret is configured.
+	SignatureHeader = "X-Switchly-Signature"
+
+	EventAccountSwitched = "account.switched"
+	EventQuotaSyncFailed = "quota.sync_failed"
+
+	defaultRetryDelay = 2 * time.Second
+	requestTimeout    = 10 * time.Second
+)
+
+// Event is the JSON body of a delivery.
+type Event struct {
+	Event     string    `json:"event"`
+	From      string    `json:"from,omitempty"`
+	To        string    `json:"to,omitempty"`
+	Reason    string    `json:"reason,omitempty"`
+	AccountID string    `json:"account_id,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	TS        time.Time `json:"ts"`
+}
+
+// Deliverer sends events to a single URL, retrying a failed delivery once.
+type Deliverer struct {
+	url        string
+	secret     string
+	client     *http.Client
+	retryDelay time.Duration
+}
+
+func NewDeliverer(rawURL, secret string) (*Deliverer, error) {
+	rawURL = strings.TrimSpace(rawURL)
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, fmt.Errorf("invalid webhook url %q: expected an http(s) URL", rawURL)
+	}
+	return &Deliverer{
+		url:        rawURL,
+		secret:     secret,
+		client:     &http.Client{Timeout: requestTimeout},
+		retryDelay: defaultRetryDelay,
+	}, nil
+}
+
+// Send posts event and, if that fails, tries once more after a short delay.
+// It blocks until delivery succeeds, both attempts fail, or ctx is done.
+func (d *Deliverer) Send(ctx context.Context, event Event) error {
+	if event.TS.IsZero() {
+		event.TS = time.Now().UTC()
+	}
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("encode webhook event: %w", err)
+	}
+
+	err = d.post(ctx, body)
+	if err == nil {
+		return nil
+	}
+	select {
+	case <-ctx.Done():
+		return err
+	case <-time.After(d.retryDelay):
+	}
+	if retryErr := d.post(ctx, body); retryErr != nil {
+		return fmt.Errorf("deliver %s webhook: %w", event.Event, retryErr)
+	}
+	return nil
+}
+
+func (d *Deliverer) post(ctx context.Context, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "switchly-webhook")
+	if d.secret != "" {
+		req.Header.Set(SignatureHeader, Sign(d.secret, body))
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// Sign returns the SignatureHeader value for body. Receivers should compare it
+// with hmac.Equal rather than ==.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
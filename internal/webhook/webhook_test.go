@@ -0,0 +1,117 @@
+package webhook
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+type capturedDelivery struct {
+	body      []byte
+	signature string
+}
+
+func captureServer(t *testing.T, failFirst int) (*httptest.Server, func() []capturedDelivery) {
+	t.Helper()
+	var (
+		mu         sync.Mutex
+		deliveries []capturedDelivery
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		deliveries = append(deliveries, capturedDelivery{body: body, signature: r.Header.Get(SignatureHeader)})
+		attempt := len(deliveries)
+		mu.Unlock()
+		if attempt <= failFirst {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, func() []capturedDelivery {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]capturedDelivery(nil), deliveries...)
+	}
+}
+
+func TestSendSignsBody(t *testing.T) {
+	srv, deliveries := captureServer(t, 0)
+	d, err := NewDeliverer(srv.URL, "s3cret")
+	if err != nil {
+		t.Fatalf("new deliverer: %v", err)
+	}
+
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	err = d.Send(context.Background(), Event{Event: EventAccountSwitched, From: "a", To: "b", Reason: "quota-exceeded", TS: ts})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+
+	got := deliveries()
+	if len(got) != 1 {
+		t.Fatalf("expected 1 delivery, got %d", len(got))
+	}
+	if want := Sign("s3cret", got[0].body); !hmac.Equal([]byte(got[0].signature), []byte(want)) {
+		t.Fatalf("signature %q does not match body, want %q", got[0].signature, want)
+	}
+	var body map[string]string
+	if err := json.Unmarshal(got[0].body, &body); err != nil {
+		t.Fatalf("decode body: %v", err)
+	}
+	if body["event"] != EventAccountSwitched || body["from"] != "a" || body["to"] != "b" || body["reason"] != "quota-exceeded" || body["ts"] != "2026-01-02T03:04:05Z" {
+		t.Fatalf("unexpected body: %s", got[0].body)
+	}
+}
+
+func TestSendRetriesOnce(t *testing.T) {
+	srv, deliveries := captureServer(t, 1)
+	d, err := NewDeliverer(srv.URL, "")
+	if err != nil {
+		t.Fatalf("new deliverer: %v", err)
+	}
+	d.retryDelay = time.Millisecond
+
+	if err := d.Send(context.Background(), Event{Event: EventQuotaSyncFailed, AccountID: "a", Error: "boom"}); err != nil {
+		t.Fatalf("expected retry to succeed, got %v", err)
+	}
+	got := deliveries()
+	if len(got) != 2 {
+		t.Fatalf("expected 2 attempts, got %d", len(got))
+	}
+	if got[1].signature != "" {
+		t.Fatalf("expected no signature without a secret, got %q", got[1].signature)
+	}
+}
+
+func TestSendGivesUpAfterRetry(t *testing.T) {
+	srv, deliveries := captureServer(t, 10)
+	d, err := NewDeliverer(srv.URL, "s3cret")
+	if err != nil {
+		t.Fatalf("new deliverer: %v", err)
+	}
+	d.retryDelay = time.Millisecond
+
+	if err := d.Send(context.Background(), Event{Event: EventAccountSwitched}); err == nil {
+		t.Fatal("expected delivery error")
+	}
+	if n := len(deliveries()); n != 2 {
+		t.Fatalf("expected exactly 2 attempts, got %d", n)
+	}
+}
+
+func TestNewDelivererRejectsBadURL(t *testing.T) {
+	for _, raw := range []string{"", "example.com/hook", "ftp://example.com/hook"} {
+		if _, err := NewDeliverer(raw, ""); err == nil {
+			t.Fatalf("expected error for %q", raw)
+		}
+	}
+}
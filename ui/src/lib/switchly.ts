@@ -8,6 +8,7 @@ export type ApiRequest = <T>(path: string, init?: SwitchlyRequestInit) => Promis
 export type QuotaWindow = {
   used_percent: number;
   reset_at?: string;
+  source_timestamp?: string;
 };
 
 export type QuotaSnapshot = {